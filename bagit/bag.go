@@ -0,0 +1,378 @@
+// Package bagit reads and writes BagIt bags (RFC 8493), for moving content into and
+// out of OCFL objects in transfer workflows.
+//
+// Bags may be directories, or zip files containing a bag (either at the top level of
+// the zip, or within a single top level directory).  Only complete bags are supported;
+// bags with a fetch.txt listing remote payload are rejected.
+package bagit
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+)
+
+// Well known files and directories of a bag
+const (
+	Declaration = "bagit.txt"
+	Info        = "bag-info.txt"
+	Fetch       = "fetch.txt"
+	PayloadDir  = "data"
+)
+
+// Version is the BagIt version of bags written by this package
+const Version = "1.0"
+
+// Manifest maps the paths of files in a bag (relative to the bag, e.g. data/a.txt)
+// to their digests
+type Manifest map[string]metadata.Digest
+
+// Bag is a BagIt bag, open for reading
+type Bag struct {
+	Name      string                                // Name of the bag's directory or zip file, without extension
+	Info      map[string][]string                   // Metadata in bag-info.txt, by label
+	Manifests map[metadata.DigestAlgorithm]Manifest // Payload manifests, by algorithm
+
+	tagManifests map[metadata.DigestAlgorithm]Manifest
+	fsys         iofs.FS
+	closer       io.Closer
+}
+
+// Open opens the bag in the given directory or zip file, and reads its declaration,
+// metadata, and manifests.  Open does not validate the bag; see Validate.
+func Open(p string) (*Bag, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open bag")
+	}
+
+	bag := &Bag{
+		Name: strings.TrimSuffix(filepath.Base(p), ".zip"),
+	}
+
+	if info.IsDir() {
+		bag.fsys = os.DirFS(p)
+	} else {
+		z, err := zip.OpenReader(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not open bag %s", p)
+		}
+		bag.closer = z
+		bag.fsys, err = bagRoot(z)
+		if err != nil {
+			_ = z.Close()
+			return nil, errors.Wrapf(err, "could not open bag %s", p)
+		}
+	}
+
+	if err := bag.read(); err != nil {
+		_ = bag.Close()
+		return nil, errors.Wrapf(err, "could not read bag %s", p)
+	}
+
+	return bag, nil
+}
+
+// Finds the bag within a zip, which is conventionally within a single top level directory
+func bagRoot(z *zip.ReadCloser) (iofs.FS, error) {
+	if _, err := iofs.Stat(z, Declaration); err == nil {
+		return z, nil
+	}
+
+	entries, err := iofs.ReadDir(z, ".")
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return iofs.Sub(z, entries[0].Name())
+	}
+
+	return nil, fmt.Errorf("no %s at the top level of the zip, or within a single top level directory", Declaration)
+}
+
+// Close releases the resources of the bag
+func (b *Bag) Close() error {
+	if b.closer != nil {
+		return b.closer.Close()
+	}
+	return nil
+}
+
+// Open opens a file of the bag, given its path relative to the bag
+func (b *Bag) Open(name string) (iofs.File, error) {
+	return b.fsys.Open(name)
+}
+
+// Algorithms returns the algorithms of the bag's payload manifests, sorted
+func (b *Bag) Algorithms() []metadata.DigestAlgorithm {
+	algs := make([]metadata.DigestAlgorithm, 0, len(b.Manifests))
+	for alg := range b.Manifests {
+		algs = append(algs, alg)
+	}
+	sort.Slice(algs, func(i, j int) bool { return algs[i] < algs[j] })
+	return algs
+}
+
+// Payload returns the paths of the payload files listed in the bag's manifests,
+// relative to the bag (e.g. data/a.txt), sorted
+func (b *Bag) Payload() []string {
+	var paths []string
+	for _, alg := range b.Algorithms() {
+		for p := range b.Manifests[alg] {
+			paths = append(paths, p)
+		}
+		break
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (b *Bag) read() error {
+	decl, err := readTags(b.fsys, Declaration)
+	if err != nil {
+		return err
+	}
+	if len(decl["BagIt-Version"]) != 1 {
+		return fmt.Errorf("%s does not declare a BagIt-Version", Declaration)
+	}
+	if enc := decl["Tag-File-Character-Encoding"]; len(enc) != 1 || !strings.EqualFold(enc[0], "UTF-8") {
+		return fmt.Errorf("%s does not declare the UTF-8 Tag-File-Character-Encoding", Declaration)
+	}
+
+	b.Info = make(map[string][]string)
+	if _, err := iofs.Stat(b.fsys, Info); err == nil {
+		if b.Info, err = readTags(b.fsys, Info); err != nil {
+			return err
+		}
+	}
+
+	b.Manifests = make(map[metadata.DigestAlgorithm]Manifest)
+	b.tagManifests = make(map[metadata.DigestAlgorithm]Manifest)
+	entries, err := iofs.ReadDir(b.fsys, ".")
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		manifests := b.Manifests
+		alg := strings.TrimSuffix(strings.TrimPrefix(e.Name(), "manifest-"), ".txt")
+		if strings.HasPrefix(e.Name(), "tagmanifest-") {
+			manifests = b.tagManifests
+			alg = strings.TrimSuffix(strings.TrimPrefix(e.Name(), "tagmanifest-"), ".txt")
+		} else if !strings.HasPrefix(e.Name(), "manifest-") {
+			continue
+		}
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".txt") {
+			continue
+		}
+
+		m, err := readManifest(b.fsys, e.Name(), metadata.DigestAlgorithm(alg))
+		if err != nil {
+			return err
+		}
+		manifests[metadata.DigestAlgorithm(alg)] = m
+	}
+
+	if len(b.Manifests) == 0 {
+		return fmt.Errorf("bag has no payload manifest")
+	}
+
+	return nil
+}
+
+// Validate verifies that the bag is complete: every payload file is listed in every
+// payload manifest, every file listed is present, the Payload-Oxum (if any) matches
+// the payload, and every tag file listed in a tag manifest matches its digest.  Tag
+// manifests using unsupported algorithms (see metadata.NewHash) are not verified.
+//
+// Payload digests are not verified, as that requires reading all content; Import
+// verifies them as content is read.
+func (b *Bag) Validate() error {
+	if _, err := iofs.Stat(b.fsys, Fetch); err == nil {
+		return fmt.Errorf("bag %s has a %s; bags with remote payload are not supported", b.Name, Fetch)
+	}
+
+	var present []string
+	var octets int64
+	err := iofs.WalkDir(b.fsys, PayloadDir, func(p string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		present = append(present, p)
+		octets += info.Size()
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not read payload of bag %s", b.Name)
+	}
+
+	for _, alg := range b.Algorithms() {
+		m := b.Manifests[alg]
+		for p := range m {
+			if !strings.HasPrefix(p, PayloadDir+"/") {
+				return fmt.Errorf("%s manifest of bag %s lists %s, outside its payload", alg, b.Name, p)
+			}
+		}
+		for _, p := range present {
+			if _, ok := m[p]; !ok {
+				return fmt.Errorf("payload file %s is not in the %s manifest of bag %s", p, alg, b.Name)
+			}
+		}
+		if len(m) != len(present) {
+			for p := range m {
+				if _, err := iofs.Stat(b.fsys, p); err != nil {
+					return fmt.Errorf("file %s in the %s manifest of bag %s is missing", p, alg, b.Name)
+				}
+			}
+		}
+	}
+
+	if oxum := b.Info["Payload-Oxum"]; len(oxum) > 0 {
+		expected := fmt.Sprintf("%d.%d", octets, len(present))
+		if strings.TrimSpace(oxum[0]) != expected {
+			return fmt.Errorf("Payload-Oxum %s of bag %s does not match its payload (%s)", oxum[0], b.Name, expected)
+		}
+	}
+
+	for alg, m := range b.tagManifests {
+		if _, ok := metadata.NewHash(alg); !ok {
+			continue // Cannot be verified
+		}
+		for p, expected := range m {
+			digest, err := b.digest(p, alg)
+			if err != nil {
+				return errors.Wrapf(err, "could not verify tag file %s of bag %s", p, b.Name)
+			}
+			if !digest.Equal(expected) {
+				return fmt.Errorf("tag file %s of bag %s does not match its %s digest %s (computed %s)", p, b.Name, alg, expected, digest)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (b *Bag) digest(p string, alg metadata.DigestAlgorithm) (metadata.Digest, error) {
+	h, ok := metadata.NewHash(alg)
+	if !ok {
+		return "", fmt.Errorf("unsupported digest algorithm %s", alg)
+	}
+
+	f, err := b.fsys.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return metadata.Digest(hex.EncodeToString(h.Sum(nil))), nil
+}
+
+// Reads a tag file of labelled values, e.g. bag-info.txt.  Lines starting with
+// whitespace continue the value of the previous line.
+func readTags(fsys iofs.FS, name string) (map[string][]string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open %s", name)
+	}
+	defer f.Close()
+
+	tags := make(map[string][]string)
+	var label string
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimPrefix(scanner.Text(), "\ufeff")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && label != "" {
+			values := tags[label]
+			values[len(values)-1] += " " + strings.TrimSpace(line)
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("malformed line %d of %s: %q", n, name, line)
+		}
+		label = strings.TrimSpace(parts[0])
+		tags[label] = append(tags[label], strings.TrimSpace(parts[1]))
+	}
+
+	return tags, errors.Wrapf(scanner.Err(), "could not read %s", name)
+}
+
+// Reads a manifest of digests and file paths
+func readManifest(fsys iofs.FS, name string, alg metadata.DigestAlgorithm) (Manifest, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open %s", name)
+	}
+	defer f.Close()
+
+	m := make(Manifest)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimPrefix(scanner.Text(), "\ufeff")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.SplitN(strings.TrimSpace(line), " ", 2)
+		if len(fields) != 2 {
+			fields = strings.SplitN(strings.TrimSpace(line), "\t", 2)
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("malformed line %d of %s: %q", n, name, line)
+		}
+
+		digest, err := metadata.ParseDigest(alg, fields[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "bad digest on line %d of %s", n, name)
+		}
+
+		p := path.Clean(strings.TrimLeft(decodePath(fields[1]), " \t"))
+		if strings.HasPrefix(p, "../") || path.IsAbs(p) {
+			return nil, fmt.Errorf("path %s on line %d of %s is outside the bag", p, n, name)
+		}
+		m[p] = digest
+	}
+
+	return m, errors.Wrapf(scanner.Err(), "could not read %s", name)
+}
+
+// Decodes the percent encoded line breaks and percent signs of manifest paths
+func decodePath(p string) string {
+	var decoded strings.Builder
+	for i := 0; i < len(p); i++ {
+		if p[i] == '%' && i+2 < len(p) {
+			if c, err := strconv.ParseUint(p[i+1:i+3], 16, 8); err == nil && (c == '%' || c == '\r' || c == '\n') {
+				decoded.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		decoded.WriteByte(p[i])
+	}
+	return decoded.String()
+}
@@ -0,0 +1,174 @@
+package bagit
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+)
+
+// ExportOptions configure how versions of objects are exported as bags
+type ExportOptions struct {
+	Version string // Version to export, or the head version if empty
+
+	// Open opens a content file, given its path relative to the object root.  By default,
+	// content is read from the local filesystem.
+	Open func(physicalPath string) (io.ReadCloser, error)
+}
+
+// Export writes the logical state of a version of an object as a bag in the given
+// directory, which must not exist or be empty.  Its payload manifest uses the digests
+// of the object's manifest, and a manifest is also written for each fixity algorithm
+// recording digests of every exported file.  The content of files is verified against
+// the manifest as it is copied.
+func Export(ctx context.Context, inv *metadata.Inventory, objRoot, dest string, opts ExportOptions) (err error) {
+	if opts.Version == "" {
+		opts.Version = inv.Head
+	}
+	if opts.Open == nil {
+		opts.Open = func(p string) (io.ReadCloser, error) {
+			return os.Open(filepath.Join(objRoot, filepath.FromSlash(p)))
+		}
+	}
+
+	if _, ok := metadata.NewHash(inv.DigestAlgorithm); !ok {
+		return fmt.Errorf("cannot export %s: unsupported digest algorithm %s", inv.ID, inv.DigestAlgorithm)
+	}
+
+	files, err := inv.Files(opts.Version)
+	if err != nil {
+		return errors.Wrapf(err, "cannot export %s", inv.ID)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].LogicalPath < files[j].LogicalPath })
+
+	if entries, err := ioutil.ReadDir(dest); err == nil && len(entries) > 0 {
+		return fmt.Errorf("cannot export %s to %s: it is not empty", inv.ID, dest)
+	}
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return errors.Wrapf(err, "could not create bag %s", dest)
+	}
+	defer func() {
+		if err != nil {
+			_ = os.RemoveAll(dest)
+		}
+	}()
+
+	manifests := map[metadata.DigestAlgorithm]Manifest{inv.DigestAlgorithm: {}}
+	for alg := range inv.Fixity {
+		if _, ok := metadata.NewHash(alg); ok && alg != inv.DigestAlgorithm {
+			manifests[alg] = Manifest{}
+		}
+	}
+
+	var octets int64
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		p := PayloadDir + "/" + f.LogicalPath
+		digest, _ := inv.DigestOf(f.PhysicalPath)
+		n, err := exportFile(opts.Open, f.PhysicalPath, filepath.Join(dest, filepath.FromSlash(p)), inv.DigestAlgorithm, digest)
+		if err != nil {
+			return errors.Wrapf(err, "could not export %s of %s", f.LogicalPath, inv.ID)
+		}
+		octets += n
+
+		manifests[inv.DigestAlgorithm][p] = digest.Normalize()
+		for alg, m := range manifests {
+			if alg == inv.DigestAlgorithm {
+				continue
+			}
+			if d, ok := f.Fixity[alg]; ok {
+				m[p] = d.Normalize()
+			} else {
+				delete(manifests, alg) // Fixity manifests must be complete
+			}
+		}
+	}
+
+	tags := map[string]string{
+		Declaration: fmt.Sprintf("BagIt-Version: %s\nTag-File-Character-Encoding: UTF-8\n", Version),
+		Info: fmt.Sprintf("Bagging-Date: %s\nExternal-Identifier: %s\nOCFL-Object-Version: %s\nPayload-Oxum: %d.%d\n",
+			time.Now().Format("2006-01-02"), inv.ID, opts.Version, octets, len(files)),
+	}
+	for alg, m := range manifests {
+		tags[fmt.Sprintf("manifest-%s.txt", alg)] = m.String()
+	}
+
+	tagManifest := Manifest{}
+	for name, content := range tags {
+		if err := ioutil.WriteFile(filepath.Join(dest, name), []byte(content), 0644); err != nil {
+			return errors.Wrapf(err, "could not write %s", name)
+		}
+
+		h, _ := metadata.NewHash(inv.DigestAlgorithm)
+		h.Write([]byte(content))
+		tagManifest[name] = metadata.Digest(hex.EncodeToString(h.Sum(nil)))
+	}
+
+	name := fmt.Sprintf("tagmanifest-%s.txt", inv.DigestAlgorithm)
+	return errors.Wrapf(ioutil.WriteFile(filepath.Join(dest, name), []byte(tagManifest.String()), 0644), "could not write %s", name)
+}
+
+// Copies a content file into the bag, verifying its digest
+func exportFile(open func(string) (io.ReadCloser, error), src, dest string, alg metadata.DigestAlgorithm, digest metadata.Digest) (int64, error) {
+	in, err := open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return 0, err
+	}
+	out, err := os.Create(dest)
+	if err != nil {
+		return 0, err
+	}
+
+	h, _ := metadata.NewHash(alg)
+	n, err := io.Copy(io.MultiWriter(out, h), in)
+	if e := out.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if computed := metadata.Digest(hex.EncodeToString(h.Sum(nil))); !computed.Equal(digest) {
+		return 0, fmt.Errorf("content %s does not match its %s digest %s (computed %s)", src, alg, digest, computed)
+	}
+	return n, nil
+}
+
+// String formats the manifest as the content of a manifest file, sorted by path
+func (m Manifest) String() string {
+	paths := make([]string, 0, len(m))
+	for p := range m {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var b strings.Builder
+	for _, p := range paths {
+		fmt.Fprintf(&b, "%s  %s\n", m[p], encodePath(p))
+	}
+	return b.String()
+}
+
+// Manifest paths percent-encode line breaks, and percent signs
+var pathEncoding = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+
+func encodePath(p string) string {
+	return pathEncoding.Replace(p)
+}
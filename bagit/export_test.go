@@ -0,0 +1,90 @@
+package bagit_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/bagit"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/go-test/deep"
+)
+
+func TestExport(t *testing.T) {
+	driver, root := newDriver(t)
+
+	imported, err := bagit.Open(writeBag(t, t.TempDir(), payload))
+	if err != nil {
+		t.Fatalf("could not open bag %+v", err)
+	}
+	defer imported.Close()
+	if err := bagit.Import(context.Background(), driver, imported, "test:bag", bagit.ImportOptions{}); err != nil {
+		t.Fatalf("could not import bag %+v", err)
+	}
+
+	s, _ := driver.Open("test:bag", ocfl.Options{Version: ocfl.NEW})
+	_ = s.Delete("a.txt")
+	_ = s.Put("new%.txt", strings.NewReader("new"))
+	if err := s.Commit(ocfl.CommitInfo{}); err != nil {
+		t.Fatalf("could not commit %+v", err)
+	}
+
+	objRoot := filepath.Join(root, url.QueryEscape("test:bag"))
+	inv, err := fs.ReadInventory(objRoot)
+	if err != nil {
+		t.Fatalf("could not read inventory %+v", err)
+	}
+
+	export := func(version string) *bagit.Bag {
+		dest := filepath.Join(t.TempDir(), "export")
+		if err := bagit.Export(context.Background(), inv, objRoot, dest, bagit.ExportOptions{Version: version}); err != nil {
+			t.Fatalf("could not export %s %+v", version, err)
+		}
+
+		bag, err := bagit.Open(dest)
+		if err != nil {
+			t.Fatalf("could not open exported bag %+v", err)
+		}
+		if err := bag.Validate(); err != nil {
+			t.Errorf("Exported bag is not valid %+v", err)
+		}
+		return bag
+	}
+
+	// The imported version round trips, fixity and all
+	v1 := export("v1")
+	defer v1.Close()
+	if diff := deep.Equal(v1.Manifests, imported.Manifests); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(v1.Info["External-Identifier"], []string{"test:bag"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Without md5 fixity for every file, only the sha512 manifest is written
+	head := export("")
+	defer head.Close()
+	if diff := deep.Equal(head.Algorithms(), []metadata.DigestAlgorithm{"sha512"}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(head.Payload(), []string{"data/dir/b.txt", "data/dir/c.txt", "data/new%.txt"}); diff != nil {
+		t.Error(diff)
+	}
+	f, _ := head.Open("data/dir/c.txt")
+	content, _ := ioutil.ReadAll(f)
+	f.Close()
+	if string(content) != "hello" {
+		t.Errorf("Wrong exported content %q", content)
+	}
+
+	// Corrupt content is not exported
+	_ = ioutil.WriteFile(filepath.Join(objRoot, "v1", "content", "dir", "b.txt"), []byte("corrupt"), 0644)
+	if err := bagit.Export(context.Background(), inv, objRoot, filepath.Join(t.TempDir(), "bad"), bagit.ExportOptions{}); err == nil {
+		t.Errorf("Expected export of corrupt content to fail")
+	}
+}
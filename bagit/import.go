@@ -0,0 +1,159 @@
+package bagit
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+)
+
+// ImportOptions configure how bags are imported into objects
+type ImportOptions struct {
+	Commit ocfl.CommitInfo // Commit info of the new version; by default, its message names the bag
+}
+
+// Import validates a bag, and writes its payload into a new version of the object with
+// the given ID, creating the object if necessary.  The payload's logical paths are its
+// paths within the bag's data directory.  The new version contains the content of the
+// previous version (if any) as well.
+//
+// The digests of the payload in every manifest of the bag are verified as content is read,
+// and the commit is refused if any do not match.  Digests using algorithms other than the
+// object's are carried into its fixity block.  Manifests using algorithms that are not
+// supported (see metadata.NewHash) cannot be verified, so are ignored, but at least one
+// must be supported.
+func Import(ctx context.Context, o ocfl.Opener, bag *Bag, id string, opts ImportOptions) (err error) {
+	if err := bag.Validate(); err != nil {
+		return err
+	}
+	if !verifiable(bag) {
+		return fmt.Errorf("bag %s has no manifest using a supported digest algorithm", bag.Name)
+	}
+
+	// Digests computed as content is read, by every supported algorithm of the bag
+	computed := make(map[string]map[metadata.DigestAlgorithm]metadata.Digest)
+
+	session, err := o.OpenContext(ctx, id, ocfl.Options{
+		Create:  true,
+		Version: ocfl.NEW,
+		PreCommit: []ocfl.PreCommitHook{func(inv *metadata.Inventory) error {
+			return verifyImport(inv, bag, computed)
+		}},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not open session")
+	}
+
+	defer func() {
+		if err != nil {
+			_ = session.Close()
+			return
+		}
+
+		commit := opts.Commit
+		if commit.Message == "" {
+			commit.Message = fmt.Sprintf("Imported bag %s", bag.Name)
+		}
+		err = session.CommitContext(ctx, commit)
+		if e := session.Close(); err == nil {
+			err = e
+		}
+	}()
+
+	for _, p := range bag.Payload() {
+		digests, err := bag.put(ctx, session, p)
+		if err != nil {
+			return errors.Wrapf(err, "could not import %s of bag %s", p, bag.Name)
+		}
+		computed[p] = digests
+	}
+
+	return nil
+}
+
+// Determines whether any of the bag's manifests use a supported algorithm
+func verifiable(b *Bag) bool {
+	for alg := range b.Manifests {
+		if _, ok := metadata.NewHash(alg); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Puts a payload file into a session, computing its digests with each of the bag's
+// supported algorithms as it is read.  Digests are computed independently of the
+// session, whose digest algorithm is not known until commit.
+func (b *Bag) put(ctx context.Context, session ocfl.Session, p string) (map[metadata.DigestAlgorithm]metadata.Digest, error) {
+	f, err := b.fsys.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hashes := make(map[metadata.DigestAlgorithm]hash.Hash)
+	writers := make([]io.Writer, 0, len(b.Manifests))
+	for alg := range b.Manifests {
+		if h, ok := metadata.NewHash(alg); ok {
+			hashes[alg] = h
+			writers = append(writers, h)
+		}
+	}
+
+	err = session.PutContext(ctx, strings.TrimPrefix(p, PayloadDir+"/"), io.TeeReader(f, io.MultiWriter(writers...)))
+	if err != nil {
+		return nil, err
+	}
+
+	digests := make(map[metadata.DigestAlgorithm]metadata.Digest, len(hashes))
+	for alg, h := range hashes {
+		digests[alg] = metadata.Digest(hex.EncodeToString(h.Sum(nil)))
+	}
+	return digests, nil
+}
+
+// Verifies the payload digests of the bag against those computed as content was read,
+// and records those of algorithms other than the object's as fixity
+func verifyImport(inv *metadata.Inventory, bag *Bag, computed map[string]map[metadata.DigestAlgorithm]metadata.Digest) error {
+	head, err := inv.Snapshot(inv.Head)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range bag.Payload() {
+		lpath := strings.TrimPrefix(p, PayloadDir+"/")
+		digest, ok := head.Digest(lpath)
+		if !ok {
+			return fmt.Errorf("%s of bag %s is missing from %s", p, bag.Name, inv.Head)
+		}
+
+		for _, alg := range bag.Algorithms() {
+			expected := bag.Manifests[alg][p]
+
+			actual, ok := computed[p][alg]
+			if !ok {
+				continue // Not supported, so cannot be verified
+			}
+			if !actual.Equal(expected) {
+				return fmt.Errorf("%s of bag %s does not match its %s digest %s (computed %s)", p, bag.Name, alg, expected, actual)
+			}
+
+			if alg == inv.DigestAlgorithm {
+				continue
+			}
+			for _, physical := range inv.Manifest[digest] {
+				if err := inv.AddFixity(physical, alg, expected); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
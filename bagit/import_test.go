@@ -0,0 +1,253 @@
+package bagit_test
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/md5"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl/bagit"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/fspath"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/go-test/deep"
+)
+
+var payload = map[string]string{
+	"a.txt":     "hello",
+	"dir/b.txt": "world",
+	"dir/c.txt": "hello",
+}
+
+func TestImport(t *testing.T) {
+	cases := []struct {
+		name string
+		bag  func(dir string) string
+	}{
+		{"dir", func(dir string) string {
+			return writeBag(t, dir, payload)
+		}},
+		{"zip", func(dir string) string {
+			return zipBag(t, writeBag(t, dir, payload))
+		}},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			driver, root := newDriver(t)
+
+			bag, err := bagit.Open(c.bag(t.TempDir()))
+			if err != nil {
+				t.Fatalf("could not open bag %+v", err)
+			}
+			defer bag.Close()
+
+			err = bagit.Import(context.Background(), driver, bag, "test:bag", bagit.ImportOptions{})
+			if err != nil {
+				t.Fatalf("could not import bag %+v", err)
+			}
+
+			inv, err := fs.ReadInventory(filepath.Join(root, url.QueryEscape("test:bag")))
+			if err != nil {
+				t.Fatalf("could not read inventory %+v", err)
+			}
+
+			head, _ := inv.Snapshot(inv.Head)
+			if diff := deep.Equal(head.Paths(), []string{"a.txt", "dir/b.txt", "dir/c.txt"}); diff != nil {
+				t.Error(diff)
+			}
+			if head.Message != "Imported bag mybag" {
+				t.Errorf("Wrong commit message %q", head.Message)
+			}
+
+			// md5 digests are carried into the fixity block, sha512 digests are those of the manifest
+			if diff := deep.Equal(inv.GetFixity("v1/content/a.txt"), map[metadata.DigestAlgorithm]metadata.Digest{
+				"md5": digest(md5.New(), "hello"),
+			}); diff != nil {
+				t.Error(diff)
+			}
+			if _, ok := inv.Manifest[digest(sha512.New(), "world")]; !ok {
+				t.Errorf("Expected sha512 manifest digests, got %v", inv.Manifest)
+			}
+		})
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	cases := []struct {
+		name    string
+		corrupt func(bag string)
+	}{
+		{"corrupt", func(bag string) {
+			_ = ioutil.WriteFile(filepath.Join(bag, "data", "a.txt"), []byte("HELLO"), 0644)
+		}},
+		{"unlisted", func(bag string) {
+			_ = ioutil.WriteFile(filepath.Join(bag, "data", "extra.txt"), []byte("extra"), 0644)
+		}},
+		{"missing", func(bag string) {
+			_ = os.Remove(filepath.Join(bag, "data", "dir", "b.txt"))
+		}},
+		{"oxum", func(bag string) {
+			_ = ioutil.WriteFile(filepath.Join(bag, "bag-info.txt"), []byte("Payload-Oxum: 15.2\n"), 0644)
+		}},
+		{"fetch", func(bag string) {
+			_ = ioutil.WriteFile(filepath.Join(bag, "fetch.txt"), []byte("http://example.org/x 5 data/x.txt\n"), 0644)
+		}},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			driver, root := newDriver(t)
+
+			dir := writeBag(t, t.TempDir(), payload)
+			c.corrupt(dir)
+
+			bag, err := bagit.Open(dir)
+			if err != nil {
+				t.Fatalf("could not open bag %+v", err)
+			}
+			defer bag.Close()
+
+			if err := bagit.Import(context.Background(), driver, bag, "test:bag", bagit.ImportOptions{}); err == nil {
+				t.Errorf("Expected import of an invalid bag to fail")
+			}
+			if _, err := os.Stat(filepath.Join(root, url.QueryEscape("test:bag"), "inventory.json")); err == nil {
+				t.Errorf("Object should not have been committed")
+			}
+		})
+	}
+}
+
+// Manifests using algorithms with no implementation are ignored, rather than failing
+func TestImportUnsupportedAlgorithm(t *testing.T) {
+	driver, root := newDriver(t)
+
+	dir := writeBag(t, t.TempDir(), payload)
+	var unsupported strings.Builder
+	for p := range payload {
+		fmt.Fprintf(&unsupported, "%s  data/%s\n", strings.Repeat("0", 128), p)
+	}
+	_ = ioutil.WriteFile(filepath.Join(dir, "manifest-blake2b-512.txt"), []byte(unsupported.String()), 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, "tagmanifest-blake2b-512.txt"), []byte(strings.Repeat("0", 128)+"  bagit.txt\n"), 0644)
+
+	bag, err := bagit.Open(dir)
+	if err != nil {
+		t.Fatalf("could not open bag %+v", err)
+	}
+	defer bag.Close()
+
+	if err = bagit.Import(context.Background(), driver, bag, "test:bag", bagit.ImportOptions{}); err != nil {
+		t.Fatalf("could not import bag %+v", err)
+	}
+
+	inv, err := fs.ReadInventory(filepath.Join(root, url.QueryEscape("test:bag")))
+	if err != nil {
+		t.Fatalf("could not read inventory %+v", err)
+	}
+	if _, ok := inv.GetFixity("v1/content/a.txt")["blake2b-512"]; ok {
+		t.Errorf("Unverified digests should not be recorded as fixity")
+	}
+}
+
+func newDriver(t *testing.T) (*fs.Driver, string) {
+	root := t.TempDir()
+	if err := fs.MkRoot(root); err != nil {
+		t.Fatalf("could not initialize ocfl root %+v", err)
+	}
+
+	driver, err := fs.NewDriver(fs.Config{
+		Root:        root,
+		ObjectPaths: fspath.GeneratorFunc(url.QueryEscape),
+		FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
+	})
+	if err != nil {
+		t.Fatalf("Error setting up driver %+v", err)
+	}
+	return driver, root
+}
+
+// Writes a bag named mybag with md5 and sha512 manifests
+func writeBag(t *testing.T, dir string, files map[string]string) string {
+	bag := filepath.Join(dir, "mybag")
+
+	var paths []string
+	for p, content := range files {
+		paths = append(paths, p)
+		file := filepath.Join(bag, "data", filepath.FromSlash(p))
+		_ = os.MkdirAll(filepath.Dir(file), 0755)
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatalf("could not write bag %+v", err)
+		}
+	}
+	sort.Strings(paths)
+
+	var md5s, sha512s strings.Builder
+	for _, p := range paths {
+		fmt.Fprintf(&md5s, "%s  data/%s\n", digest(md5.New(), files[p]), p)
+		fmt.Fprintf(&sha512s, "%s  data/%s\n", digest(sha512.New(), files[p]), p)
+	}
+
+	for name, content := range map[string]string{
+		"bagit.txt":           "BagIt-Version: 1.0\nTag-File-Character-Encoding: UTF-8\n",
+		"manifest-md5.txt":    md5s.String(),
+		"manifest-sha512.txt": sha512s.String(),
+	} {
+		if err := ioutil.WriteFile(filepath.Join(bag, name), []byte(content), 0644); err != nil {
+			t.Fatalf("could not write bag %+v", err)
+		}
+	}
+
+	return bag
+}
+
+// Zips a bag into a single top level directory
+func zipBag(t *testing.T, bag string) string {
+	f, err := os.Create(bag + ".zip")
+	if err != nil {
+		t.Fatalf("could not create zip %+v", err)
+	}
+	defer f.Close()
+
+	z := zip.NewWriter(f)
+	err = filepath.Walk(bag, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(filepath.Dir(bag), p)
+		w, err := z.Create(filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		content, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(content)
+		return err
+	})
+	if err == nil {
+		err = z.Close()
+	}
+	if err != nil {
+		t.Fatalf("could not zip bag %+v", err)
+	}
+
+	return bag + ".zip"
+}
+
+func digest(h hash.Hash, content string) metadata.Digest {
+	h.Write([]byte(content))
+	return metadata.Digest(hex.EncodeToString(h.Sum(nil)))
+}
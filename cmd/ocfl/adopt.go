@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/birkland/ocfl"
+	"github.com/urfave/cli"
+)
+
+type adoptOpts struct {
+	commitMessage string
+}
+
+func adopt() cli.Command {
+
+	opts := adoptOpts{}
+
+	return cli.Command{
+		Name:  "adopt",
+		Usage: "Convert a plain directory into an OCFL object, in place",
+		Description: `Convert an existing directory of files into a new OCFL object, whose
+	first version contains every file in the directory, at its path within
+	it.  Rather than being copied, the directory is moved into the object's
+	v1/content directory, so it must be on the same filesystem as the OCFL
+	root.  For example
+
+	  ocfl adopt /data/collections/letters test:letters
+
+	The directory may already be where the root's layout places the object
+	(see 'ocfl layout path'), in which case it is converted where it is.
+	Every file is hashed before anything is moved, and the directory is left
+	where it was if adoption fails.  Empty directories are dropped, and
+	directories containing symbolic links cannot be adopted (use 'ocfl cp'
+	instead).`,
+		ArgsUsage: "dir object",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "message, m",
+				Usage:       "Commit message (default: names the directory)",
+				Destination: &opts.commitMessage,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			return adoptAction(opts, c.Args())
+		},
+	}
+}
+
+func adoptAction(opts adoptOpts, args []string) error {
+	if len(args) != 2 {
+		return usagef("expected a directory, and the ID of the object to convert it into")
+	}
+	dir, id := args[0], args[1]
+
+	message := opts.commitMessage
+	if message == "" {
+		message = fmt.Sprintf("Adopted %s", dir)
+	}
+
+	ctx, cancel := interruptible()
+	defer cancel()
+
+	return newFsDriver().Adopt(ctx, dir, id, ocfl.CommitInfo{
+		Date:    time.Now(),
+		Message: message,
+	})
+}
@@ -0,0 +1,21 @@
+# Bash completion for ocfl.  Source this file (e.g. from ~/.bashrc), or copy it
+# to /etc/bash_completion.d/ocfl.  Commands, object IDs, versions, and logical
+# paths are completed by ocfl itself; local files are completed otherwise.
+
+_ocfl() {
+    local line="${COMP_LINE:0:COMP_POINT}"
+    local cur="${line##* }"
+    local candidates
+
+    candidates=$(${line% *} --generate-bash-completion 2>/dev/null)
+    COMPREPLY=( $(compgen -W "${candidates}" -- "${cur}") )
+
+    # Object IDs often contain colons, which bash treats as word breaks, so
+    # only the part after the last colon is replaced
+    if [[ "${cur}" == *:* && "${COMP_WORDBREAKS}" == *:* ]]; then
+        local prefix="${cur%"${cur##*:}"}"
+        COMPREPLY=( "${COMPREPLY[@]#"${prefix}"}" )
+    fi
+}
+
+complete -o default -F _ocfl ocfl
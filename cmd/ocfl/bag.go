@@ -0,0 +1,115 @@
+package main
+
+import (
+	"time"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/bagit"
+	"github.com/urfave/cli"
+)
+
+type importBagOpts struct {
+	commitMessage string
+}
+
+func importBag() cli.Command {
+
+	opts := importBagOpts{}
+
+	return cli.Command{
+		Name:  "import-bag",
+		Usage: "Import the payload of a BagIt bag into an OCFL object",
+		Description: `Validate a BagIt bag (a directory, or a zip file), and write its
+	payload into a new version of the given object, creating the object if
+	it does not exist.  Files in the bag's data directory keep their paths
+	within it as logical paths, e.g.
+
+	  ocfl import-bag /path/to/mybag.zip test:obj
+
+	The bag's checksums are verified as its payload is read, and nothing is
+	committed if any fail to match.  Checksums using algorithms other than
+	the object's (e.g. md5) are recorded in the object's fixity block.`,
+		ArgsUsage: "bag object",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "message, m",
+				Usage:       "Commit message (default: names the bag)",
+				Destination: &opts.commitMessage,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			return importBagAction(opts, c.Args())
+		},
+	}
+}
+
+func importBagAction(opts importBagOpts, args []string) error {
+	if len(args) != 2 {
+		return usagef("expected a bag, and an object to import it into")
+	}
+
+	bag, err := bagit.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer bag.Close()
+
+	ctx, cancel := interruptible()
+	defer cancel()
+
+	return bagit.Import(ctx, newDriver(), bag, args[1], bagit.ImportOptions{
+		Commit: ocfl.CommitInfo{
+			Date:    time.Now(),
+			Message: opts.commitMessage,
+		},
+	})
+}
+
+func exportBag() cli.Command {
+	return cli.Command{
+		Name:  "export-bag",
+		Usage: "Export a version of an OCFL object as a BagIt bag",
+		Description: `Write the logical files of a version of an object (the head version,
+	if not given) as the payload of a BagIt bag in the given directory,
+	which must not exist or be empty.  For example
+
+	  ocfl export-bag test:obj v2 /path/to/bag
+
+	The bag's manifest uses the digests of the object's manifest, and a
+	manifest is also written for each fixity algorithm with digests of
+	every exported file.  Content is verified as it is copied.`,
+		ArgsUsage:    "object [version] dest",
+		BashComplete: completeArgs(objectArg, versionArg),
+
+		Action: func(c *cli.Context) error {
+			return exportBagAction(c.Args())
+		},
+	}
+}
+
+func exportBagAction(args []string) error {
+	var version string
+	switch len(args) {
+	case 2:
+	case 3:
+		version = args[1]
+	default:
+		return usagef("expected an object, an optional version, and a destination")
+	}
+
+	d := newFsDriver()
+	path, err := d.ObjectPath(args[0])
+	if err != nil {
+		return err
+	}
+	inv, err := readInventory(d, args[0])
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := interruptible()
+	defer cancel()
+
+	return bagit.Export(ctx, inv, path, args[len(args)-1], bagit.ExportOptions{Version: version})
+}
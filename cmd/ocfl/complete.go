@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/birkland/ocfl"
+	"github.com/urfave/cli"
+)
+
+// The kinds of arguments that can be completed from the contents of the OCFL root
+type argKind int
+
+const (
+	objectArg  argKind = iota // Object ID
+	versionArg                // Version of the object named by the first argument
+	pathArg                   // Logical path in the head version of the object named by the first argument
+)
+
+// completeArgs completes each positional argument of a command as the given kind of argument.
+// Arguments beyond those given are not completed.
+func completeArgs(kinds ...argKind) cli.BashCompleteFunc {
+	return func(c *cli.Context) {
+		args := c.Args()
+		if len(args) < len(kinds) {
+			printCompletions(kinds[len(args)], args)
+		}
+	}
+}
+
+// completeObjects completes every argument of a command as an object ID
+func completeObjects(c *cli.Context) {
+	printCompletions(objectArg, c.Args())
+}
+
+// Completing cp's last argument as an object ID (the sources are local files, which the
+// shell completes), or as a logical path in the object given by -o
+func completeCp(c *cli.Context) {
+	if id := c.String("object"); id != "" {
+		printCompletions(pathArg, []string{id})
+		return
+	}
+	completeObjects(c)
+}
+
+// Prints candidates for an argument, one per line.  Completion must not be noisy, so nothing
+// is printed if the root can't be found or read.
+func printCompletions(kind argKind, args []string) {
+	if _, err := rootDir(mainOpts.root); err != nil {
+		return
+	}
+	d := newDriver()
+
+	if kind == objectArg {
+		_ = d.Walk(ocfl.Select{Type: ocfl.Object}, func(ref ocfl.EntityRef) error {
+			fmt.Println(ref.ID)
+			return nil
+		})
+		return
+	}
+
+	inv, err := readInventory(d, args[0])
+	if err != nil {
+		return
+	}
+
+	switch kind {
+	case versionArg:
+		fmt.Println(strings.Join(inv.VersionNames(), "\n"))
+	case pathArg:
+		if head, err := inv.Snapshot(inv.Head); err == nil {
+			fmt.Println(strings.Join(head.Paths(), "\n"))
+		}
+	}
+}
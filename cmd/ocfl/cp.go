@@ -1,27 +1,43 @@
 package main
 
 import (
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/birkland/ocfl"
-	"github.com/karrick/godirwalk"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/metadata"
 	"github.com/pkg/errors"
 	"github.com/urfave/cli"
 
 	"golang.org/x/sync/errgroup"
 )
 
+// Number of files copied (or digested) concurrently, unless given by --jobs
+const cpWorkers = 10
+
 type cpOpts struct {
 	recursive     bool
 	commitMessage string
 	object        string
+	symlinks      string
+	dryRun        bool
+	progress      bool
+	update        bool
+	link          bool
+	dedup         bool
+	exact         bool // Copy a single source file to dest as its exact logical path
 }
 
 func cp() cli.Command {
@@ -46,11 +62,23 @@ func cp() cli.Command {
 	
 		ocfl cp -r -o test:obj /usr foo/bar
 	
+	As with cp(1), a single source file is instead copied to dest as its exact 
+	logical path, unless dest ends with a slash or is already a directory in 
+	the object, so the following copies local.txt to foo/renamed.txt
+
+		ocfl cp -o test:obj local.txt foo/renamed.txt
+	
+	Given - as the only src, content is read from standard input into the 
+	logical path given as dest, which requires an explicit object (-o), e.g.
+
+		tar -c /usr | ocfl cp -o test:obj - usr.tar
+
 	If the object does not exist then a new one will be created.  If it does
 	exist, then a new version of that object will be created, containing the
 	contents of the previous version with the new content merged in
 	`,
-		ArgsUsage: "src... dest",
+		ArgsUsage:    "src... dest",
+		BashComplete: completeCp,
 		Flags: []cli.Flag{
 			cli.BoolFlag{
 				Name:        "recursive, r",
@@ -67,6 +95,37 @@ func cp() cli.Command {
 				Usage:       "Commit message (optional)",
 				Destination: &opts.commitMessage,
 			},
+			cli.BoolFlag{
+				Name:        "dry-run, n",
+				Usage:       "Show what would be copied (and deduplicated) without writing anything",
+				Destination: &opts.dryRun,
+			},
+			cli.BoolFlag{
+				Name:        "update, u",
+				Usage:       "Skip files whose content is unchanged from the object's head version",
+				Destination: &opts.update,
+			},
+			cli.BoolFlag{
+				Name:        "link",
+				Usage:       "Hard link files into the object rather than copying them, where on the same filesystem as the root",
+				Destination: &opts.link,
+			},
+			cli.BoolFlag{
+				Name:        "dedup",
+				Usage:       "Do not write content that is already in the object, or copied more than once",
+				Destination: &opts.dedup,
+			},
+			cli.BoolFlag{
+				Name:        "progress",
+				Usage:       "Show progress (files copied, rate, and estimated time remaining) on stderr",
+				Destination: &opts.progress,
+			},
+			cli.StringFlag{
+				Name:        "symlinks",
+				Usage:       "How to treat symbolic links when copying recursively: follow, skip, or error",
+				Value:       "follow",
+				Destination: &opts.symlinks,
+			},
 		},
 
 		Action: func(c *cli.Context) error {
@@ -77,47 +136,106 @@ func cp() cli.Command {
 
 func cpAction(opts cpOpts, args []string) (err error) {
 	if len(args) < 2 {
-		return fmt.Errorf("too few arguments")
+		return usagef("too few arguments")
 	}
 
-	d := newDriver()
+	d := newFsDriver(func(cfg *fs.Config) {
+		cfg.Hardlink = opts.link
+		cfg.Dedup = opts.dedup
+	})
 
 	lastArg := args[len(args)-1]
 	src := args[:len(args)-1]
 
-	session, err := d.Open(object(opts, lastArg), ocfl.Options{
-		Create:  true,
-		Version: ocfl.NEW,
+	stdin := len(src) == 1 && src[0] == "-"
+	if stdin {
+		if opts.object == "" || dest(opts, lastArg) == "" {
+			return usagef("copying from stdin requires an object (-o), and a logical path as dest")
+		}
+		if opts.dryRun || opts.update || opts.link {
+			return usagef("--dry-run, --update, and --link cannot be used when copying from stdin")
+		}
+	}
+
+	if !stdin {
+		if opts.exact, err = exactDest(opts, src, lastArg); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := interruptible()
+	defer cancel()
+
+	if opts.dryRun {
+		return dryRun(ctx, opts, src, object(opts, lastArg), dest(opts, lastArg))
+	}
+
+	status := &cpStatus{start: time.Now()}
+	if opts.progress {
+		status.line = &progressLine{}
+		if stdin {
+			status.files = 1 // Its size is unknown
+		} else if status.files, status.bytes, err = countSources(opts, src, dest(opts, lastArg)); err != nil {
+			return err
+		}
+	}
+
+	var head *objectHead
+	unchanged := ocfl.CommitUnchanged
+	if opts.update {
+		if head, err = readHead(object(opts, lastArg)); err != nil {
+			return err
+		}
+		unchanged = ocfl.DiscardUnchanged
+	}
+
+	session, err := d.OpenContext(ctx, object(opts, lastArg), ocfl.Options{
+		Create:    true,
+		Version:   ocfl.NEW,
+		Progress:  status.update,
+		Unchanged: unchanged,
 	})
 	if err != nil {
 		return errors.Wrapf(err, "could not open session")
 	}
 
 	defer func() {
+		status.line.clear()
 		if err != nil {
-			// TODO:  Implement rollback!
-			log.Printf("Error encountered.  NOT committing.  You need to clean up manually until Rollback() is implemented")
+			slog.Error("error encountered, NOT committing")
+			if e := session.Close(); e != nil {
+				slog.Error("could not clean up after error", "err", e)
+			}
 			return
 		}
 
-		err = session.Commit(ocfl.CommitInfo{
+		err = session.CommitContext(ctx, ocfl.CommitInfo{
 			Date:    time.Now(),
-			Name:    userName(),
-			Address: address(),
 			Message: opts.commitMessage,
 		})
+		if e := session.Close(); err == nil {
+			err = e
+		}
+		if err == nil {
+			status.summarize(object(opts, lastArg))
+		}
 	}()
-	return doCopy(opts, src, dest(opts, lastArg), session)
+
+	if stdin {
+		return errors.Wrapf(session.PutContext(ctx, dest(opts, lastArg), os.Stdin), "could not copy stdin to %s", dest(opts, lastArg))
+	}
+	return doCopy(ctx, opts, src, dest(opts, lastArg), session, head, status)
 }
 
-func doCopy(opts cpOpts, files []string, dest string, s ocfl.Session) error {
+// Copies files into the session, skipping those unchanged from the given head version, if any
+func doCopy(ctx context.Context, opts cpOpts, files []string, dest string, s ocfl.Session, head *objectHead, status *cpStatus) error {
 
 	q := make(chan relativeFile, 10)
 	var once sync.Once
 	producer := make(chan struct{}, 1)
 
 	var g errgroup.Group
-	for i := 1; i <= 10; i++ {
+	for i := jobs(0, cpWorkers); i > 0; i-- {
 		g.Go(func() (err error) {
 			for {
 				f, alive := <-q
@@ -125,15 +243,29 @@ func doCopy(opts cpOpts, files []string, dest string, s ocfl.Session) error {
 					return nil
 				}
 
+				if head != nil {
+					unchanged, err := head.unchanged(f.relative(), f.loc, f.Size())
+					if err != nil {
+						once.Do(func() {
+							close(producer)
+						})
+						return errors.Wrapf(err, "could not compare %s to %s", f.loc, f.relative())
+					}
+					if unchanged {
+						status.skipped()
+						continue
+					}
+				}
+
 				content, err := os.Open(f.loc)
 				if err != nil {
 					return errors.Wrapf(err, "could not open file")
 				}
 				defer content.Close()
 
-				err = s.Put(f.relative(), content)
+				err = s.PutContext(ctx, f.relative(), content)
 				if err != nil {
-					log.Printf("Error putting content at %s: %s", f.relative(), err)
+					slog.Error("could not put content", "path", f.relative(), "err", err)
 					once.Do(func() {
 						close(producer)
 					})
@@ -150,6 +282,12 @@ func doCopy(opts cpOpts, files []string, dest string, s ocfl.Session) error {
 }
 
 func scan(opts cpOpts, q chan<- relativeFile, paths []string, dest string, cancel <-chan struct{}) error {
+	defer close(q)
+
+	symlinks, err := fs.ParseSymlinkPolicy(opts.symlinks)
+	if err != nil {
+		return err
+	}
 
 	var g errgroup.Group
 	for _, path := range paths {
@@ -158,6 +296,7 @@ func scan(opts cpOpts, q chan<- relativeFile, paths []string, dest string, cance
 		if err != nil {
 			return err
 		}
+		file.exact = opts.exact && !file.IsDir()
 
 		if !file.IsDir() {
 			select {
@@ -169,42 +308,303 @@ func scan(opts cpOpts, q chan<- relativeFile, paths []string, dest string, cance
 		}
 
 		if !opts.recursive {
-			log.Printf("Skipping directory %s", file.relative())
+			slog.Warn("skipping directory, use -r to copy it", "path", file.relative())
 			continue
 		}
 
 		g.Go(func() error {
-			err := godirwalk.Walk(file.loc, &godirwalk.Options{
-				FollowSymbolicLinks: true,
-				Unsorted:            true,
-				Callback: func(fullpath string, de *godirwalk.Dirent) error {
-					if de.IsRegular() {
-						select {
-						case q <- relativeFile{
-							base: file.base,
-							dest: dest,
-							loc:  fullpath,
-						}:
-						case <-cancel:
-							return fmt.Errorf("file scan cancelled")
-						}
-					}
-					return nil
-				},
+			err := fs.WalkFiles(file.loc, symlinks, func(fullpath string) error {
+				info, err := os.Stat(fullpath)
+				if err != nil {
+					return err
+				}
+
+				select {
+				case q <- relativeFile{
+					FileInfo: info,
+					base:     file.base,
+					dest:     dest,
+					loc:      fullpath,
+				}:
+				case <-cancel:
+					return fmt.Errorf("file scan cancelled")
+				}
+				return nil
 			})
 			return errors.Wrapf(err, "Error performing walk in %s (absolute path of %s)", file.loc, paths)
 		})
 
 	}
-	defer close(q)
 	return g.Wait()
 }
 
+// Tracks the progress of a copy, for display and for the final summary
+type cpStatus struct {
+	sync.Mutex
+	line     *progressLine // Shows progress, if not nil
+	start    time.Time
+	files    int   // Number of files to copy, if counted in advance
+	bytes    int64 // Their total size
+	skips    int   // Number of files skipped as unchanged
+	progress ocfl.Progress
+}
+
+func (c *cpStatus) skipped() {
+	c.Lock()
+	defer c.Unlock()
+	c.skips++
+}
+
+func (c *cpStatus) update(p ocfl.Progress) {
+	c.Lock()
+	c.progress = p
+	c.Unlock()
+
+	if c.line == nil || p.Committed {
+		return
+	}
+
+	rate := float64(p.BytesRead) / time.Since(c.start).Seconds()
+	eta := "-"
+	if rate > 0 && c.bytes >= p.BytesRead {
+		eta = time.Duration(float64(c.bytes-p.BytesRead) / rate * float64(time.Second)).Round(time.Second).String()
+	}
+	c.line.show("%d/%d files, %s/s, ETA %s: %s", p.FilesPut, c.files, humanBytes(int64(rate)), eta, p.Path)
+}
+
+// Prints a summary of a completed copy
+func (c *cpStatus) summarize(id string) {
+	c.Lock()
+	defer c.Unlock()
+
+	skipped := ""
+	if c.skips > 0 {
+		skipped = fmt.Sprintf(", skipping %d unchanged files", c.skips)
+	}
+
+	if !c.progress.Committed {
+		fmt.Printf("Nothing committed to %s%s\n", id, skipped)
+		return
+	}
+	fmt.Printf("Created %s %s: copied %d files (%s), writing %s of new content%s in %s\n",
+		id, c.progress.Version, c.progress.FilesPut, humanBytes(c.progress.BytesRead),
+		humanBytes(c.progress.BytesWritten), skipped, time.Since(c.start).Round(time.Millisecond))
+}
+
+// Counts the files to be copied, and their total size
+func countSources(opts cpOpts, files []string, dest string) (int, int64, error) {
+	q := make(chan relativeFile, 10)
+	done := make(chan struct{})
+
+	var count int
+	var size int64
+	go func() {
+		for f := range q {
+			count++
+			size += f.Size()
+		}
+		close(done)
+	}()
+
+	err := scan(opts, q, files, dest, make(chan struct{}))
+	<-done
+	return count, size, err
+}
+
+// Formats a number of bytes for display, e.g. 1.5 MiB
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// A source file, as it would be copied in a dry run
+type plannedFile struct {
+	path   string // Logical path
+	size   int64
+	digest metadata.Digest
+}
+
+// Scans and digests the given files, and prints the changes copying them into the given
+// object would make, without opening a session
+func dryRun(ctx context.Context, opts cpOpts, files []string, id, dest string) error {
+	head, err := readHead(id)
+	if err != nil {
+		return err
+	}
+
+	version := metadata.Padding(mainOpts.padding).First()
+	if head.inv != nil {
+		if version, err = metadata.VersionID(head.inv.Head).Increment(); err != nil {
+			return err
+		}
+	}
+
+	q := make(chan relativeFile, 10)
+	var once sync.Once
+	producer := make(chan struct{}, 1)
+
+	var mu sync.Mutex
+	var planned []plannedFile
+
+	var g errgroup.Group
+	for i := jobs(0, cpWorkers); i > 0; i-- {
+		g.Go(func() error {
+			for f := range q {
+				digest, size, err := digestFile(ctx, f.loc)
+				if err != nil {
+					once.Do(func() {
+						close(producer)
+					})
+					return errors.Wrapf(err, "could not read %s", f.loc)
+				}
+
+				mu.Lock()
+				planned = append(planned, plannedFile{path: f.relative(), size: size, digest: digest})
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	if err := scan(opts, q, files, dest, producer); err != nil {
+		return err
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	sort.Slice(planned, func(i, j int) bool { return planned[i].path < planned[j].path })
+
+	// With --dedup, content is deduplicated if it is already in the object, or copied more than once
+	seen := make(map[metadata.Digest]bool)
+	var added, modified, unchanged, deduped, written int
+	var dedupedBytes, writtenBytes int64
+	for _, f := range planned {
+		status := "A"
+		if existing, ok := head.digest(f.path); ok && existing.Equal(f.digest) {
+			unchanged++
+			fmt.Printf("=    %s\n", f.path)
+			continue
+		} else if ok {
+			status = "M"
+			modified++
+		} else {
+			added++
+		}
+
+		note := ""
+		inObject := false
+		if head.inv != nil {
+			_, inObject = head.inv.FindDigest(f.digest)
+		}
+		if opts.dedup && (inObject || seen[f.digest]) {
+			deduped++
+			dedupedBytes += f.size
+			note = "    (deduplicated)"
+		} else {
+			written++
+			writtenBytes += f.size
+		}
+		seen[f.digest] = true
+
+		fmt.Printf("%s    %s%s\n", status, f.path, note)
+	}
+
+	fmt.Printf("Dry run: would create %s %s with %d added, %d modified, and %d unchanged files\n",
+		id, version, added, modified, unchanged)
+	fmt.Printf("Would write %d files (%d bytes) of new content, deduplicating %d files (%d bytes)\n",
+		written, writtenBytes, deduped, dedupedBytes)
+	return nil
+}
+
+// The head version of an object, against which sources are compared
+type objectHead struct {
+	path  string              // Path of the object root
+	inv   *metadata.Inventory // Inventory of the object, or nil if it does not exist
+	state *metadata.Snapshot
+}
+
+// Reads the head version of the object with the given ID, if it exists
+func readHead(id string) (*objectHead, error) {
+	d := newFsDriver()
+	objPath, err := d.ObjectPath(id)
+	if err != nil {
+		return nil, err
+	}
+
+	head := &objectHead{path: objPath}
+	if head.inv, err = readInventory(d, id); errors.Cause(err) == ocfl.ErrNotFound {
+		return head, nil
+	} else if err != nil {
+		return nil, err
+	}
+	head.state, err = head.inv.Snapshot(head.inv.Head)
+	return head, err
+}
+
+// Looks up the digest of a logical path in the head version, if present
+func (h *objectHead) digest(lpath string) (metadata.Digest, bool) {
+	if h.state == nil {
+		return "", false
+	}
+	return h.state.Digest(lpath)
+}
+
+// Determines whether a source file has the same content as the logical file at the given
+// path in the head version.  Sizes are compared first, so that only sources the same size as
+// the head's content are read.
+func (h *objectHead) unchanged(lpath, src string, size int64) (bool, error) {
+	existing, ok := h.digest(lpath)
+	if !ok {
+		return false, nil
+	}
+
+	if physical := h.inv.Manifest[existing]; len(physical) > 0 {
+		info, err := os.Stat(filepath.Join(h.path, filepath.FromSlash(physical[0])))
+		if err == nil && info.Size() != size {
+			return false, nil
+		}
+	}
+
+	digest, _, err := digestFile(context.Background(), src)
+	if err != nil {
+		return false, err
+	}
+	return digest.Equal(existing), nil
+}
+
+// Computes the sha512 digest (as the driver does, when content is put) and size of a file
+func digestFile(ctx context.Context, path string) (metadata.Digest, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	hash := sha512.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", 0, err
+	}
+	return metadata.Digest(hex.EncodeToString(hash.Sum(nil))), size, nil
+}
+
 type relativeFile struct {
 	os.FileInfo
-	base string // Base path
-	loc  string // Absolute path
-	dest string // destination path
+	base  string // Base path
+	loc   string // Absolute path
+	dest  string // destination path
+	exact bool   // Whether dest is the exact logical path of the file
 }
 
 func newRelativeFile(path string) (tracker relativeFile, err error) {
@@ -224,9 +624,37 @@ func newRelativeFile(path string) (tracker relativeFile, err error) {
 }
 
 func (p relativeFile) relative() string {
+	if p.exact {
+		return p.dest
+	}
 	return strings.TrimLeft(filepath.ToSlash(filepath.Join(p.dest, strings.TrimPrefix(p.loc, p.base))), "/")
 }
 
+// Determines whether a single source file is copied to dest as its exact logical path,
+// rather than into it as a directory.  As with cp(1), it is unless dest ends with a slash,
+// or is a directory in the head version of the object.
+func exactDest(opts cpOpts, src []string, lastArg string) (bool, error) {
+	path := dest(opts, lastArg)
+	if len(src) != 1 || path == "" || strings.HasSuffix(path, "/") {
+		return false, nil
+	}
+
+	if info, err := os.Stat(src[0]); err != nil || info.IsDir() {
+		return false, nil // Reported when scanning
+	}
+
+	head, err := readHead(object(opts, lastArg))
+	if err != nil || head.state == nil {
+		return err == nil, err
+	}
+	for _, p := range head.state.Paths() {
+		if strings.HasPrefix(p, path+"/") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 // figure out the object to copy into.  If it was specified via -o,
 // use that.  Otherwise, use the given arg (which is the last cli arg)
 func object(opts cpOpts, dest string) string {
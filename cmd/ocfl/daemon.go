@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/bagit"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/drivers/index"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+type daemonOpts struct {
+	config string
+}
+
+// The daemon's configuration file
+type daemonConfig struct {
+	Root   string       `json:"root"`   // OCFL root, unless given by --root or OCFL_ROOT
+	Listen string       `json:"listen"` // Address to serve the root on
+	Audit  auditConfig  `json:"audit"`
+	Ingest ingestConfig `json:"ingest"`
+	Index  indexConfig  `json:"index"`
+}
+
+type auditConfig struct {
+	Interval duration `json:"interval"` // How often objects due an audit are audited; zero disables audits
+	MaxAge   duration `json:"maxAge"`   // How long an audit lasts before the object is due another
+	Jobs     int      `json:"jobs"`     // Number of objects audited concurrently
+}
+
+type ingestConfig struct {
+	Dir      string   `json:"dir"`      // Directory watched for bags to ingest; empty disables ingest
+	Interval duration `json:"interval"` // How often the directory is checked for bags
+}
+
+type indexConfig struct {
+	Path     string   `json:"path"`     // Index file (see ocfl index) kept up to date; empty disables indexing
+	Interval duration `json:"interval"` // How often the index is rebuilt
+}
+
+// Bags that could not be ingested are moved into this directory, within the ingest
+// directory.  Like anything else whose name begins with a dot, it is not ingested.
+const failedIngestDir = ".failed"
+
+// A time.Duration written as a string, e.g. "720h"
+type duration struct {
+	time.Duration
+}
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	var err error
+	d.Duration, err = time.ParseDuration(s)
+	return err
+}
+
+var defaultDaemonConfig = daemonConfig{
+	Listen: "localhost:8080",
+	Audit: auditConfig{
+		Interval: duration{time.Hour},
+		MaxAge:   duration{720 * time.Hour},
+	},
+	Ingest: ingestConfig{
+		Interval: duration{time.Minute},
+	},
+	Index: indexConfig{
+		Interval: duration{time.Hour},
+	},
+}
+
+func daemon() cli.Command {
+
+	opts := daemonOpts{}
+
+	return cli.Command{
+		Name:  "daemon",
+		Usage: "Serve an OCFL root over HTTP, ingest bags, and audit and index its objects continuously",
+		Description: `Run the HTTP server of 'ocfl serve', along with an ingest watcher,
+	rolling fixity audits (see 'ocfl fixity audit'), and index maintenance
+	(see 'ocfl index'), in one long-running process, configured by a single
+	JSON file.  For example
+
+	  ocfl daemon --config ocfl.json
+
+	where ocfl.json contains
+
+	  {
+	    "root": "/path/to/root",
+	    "listen": "localhost:8080",
+	    "audit": {"interval": "1h", "maxAge": "720h", "jobs": 4},
+	    "ingest": {"dir": "/path/to/dropbox", "interval": "1m"},
+	    "index": {"path": "/path/to/index.db", "interval": "1h"}
+	  }
+
+	Every interval, objects not audited within maxAge are audited, so that
+	every object is audited about once per maxAge.  Failures are logged.
+
+	Every ingest interval, each BagIt bag (a directory, or a zip file) in
+	the ingest directory is imported into a new version of an object (see
+	'ocfl import-bag'), then removed.  The object's ID is the bag's
+	External-Identifier, if its bag-info.txt has one, or else its name,
+	query unescaped (e.g. urn%3Atest%2Fa for urn:test/a).  Bags that cannot
+	be imported are logged, and moved into the .failed directory within it.
+	Names beginning with a dot are ignored, so bags should be copied under
+	such a name, and renamed once complete.
+
+	Every index interval, the index is rebuilt, so 'ocfl ls --index' lists
+	the root as it was then.
+
+	Any setting may be omitted to use its default (those shown, with jobs
+	defaulting to the global --jobs).  An audit interval of "0s" disables
+	audits, and ingest and indexing are disabled unless their dir or path
+	is given.
+
+	The daemon runs until interrupted.`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "config, c",
+				Usage:       "Configuration file",
+				EnvVar:      "OCFL_DAEMON_CONFIG",
+				Destination: &opts.config,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			return daemonAction(opts)
+		},
+	}
+}
+
+func daemonAction(opts daemonOpts) error {
+	cfg, err := readDaemonConfig(opts.config)
+	if err != nil {
+		return err
+	}
+	if mainOpts.root == "" {
+		mainOpts.root = cfg.Root
+	}
+
+	ctx, cancel := interruptible()
+	defer cancel()
+
+	driver := newFsDriver()
+
+	if cfg.Audit.Interval.Duration > 0 {
+		go auditContinuously(ctx, driver, cfg.Audit)
+	}
+	if cfg.Ingest.Dir != "" {
+		go ingestContinuously(ctx, driver, cfg.Ingest)
+	}
+	if cfg.Index.Path != "" {
+		go indexContinuously(ctx, driver, cfg.Index)
+	}
+
+	return listenAndServe(ctx, cfg.Listen)
+}
+
+// Reads the configuration file, if any, over the defaults
+func readDaemonConfig(path string) (daemonConfig, error) {
+	cfg := defaultDaemonConfig
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, errors.Wrapf(err, "could not read daemon configuration")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(&cfg); err != nil {
+		return cfg, usagef("invalid daemon configuration %s: %s", path, err)
+	}
+	if cfg.Audit.Interval.Duration < 0 || cfg.Audit.MaxAge.Duration <= 0 {
+		return cfg, usagef("invalid daemon configuration %s: the audit interval must not be negative, and maxAge must be positive", path)
+	}
+	if cfg.Ingest.Interval.Duration <= 0 || cfg.Index.Interval.Duration <= 0 {
+		return cfg, usagef("invalid daemon configuration %s: the ingest and index intervals must be positive", path)
+	}
+	return cfg, nil
+}
+
+// Audits the objects due an audit every interval, until the context is cancelled
+func auditContinuously(ctx context.Context, driver *fs.Driver, cfg auditConfig) {
+	for {
+		auditDue(ctx, driver, cfg)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cfg.Interval.Duration):
+		}
+	}
+}
+
+func auditDue(ctx context.Context, driver *fs.Driver, cfg auditConfig) {
+	audited, failed := 0, 0
+	err := driver.Audit(ctx, fs.AuditOptions{
+		Since:   time.Now().Add(-cfg.MaxAge.Duration),
+		Workers: jobs(cfg.Jobs, 0),
+	}, func(r *fs.AuditRecord) error {
+		audited++
+		if !r.Passed {
+			failed++
+			for _, f := range r.Failures {
+				slog.Error("audit failed", "id", r.ID, "path", r.Path, "failure", f.String())
+			}
+		}
+		return nil
+	})
+
+	switch {
+	case ctx.Err() != nil:
+	case err != nil:
+		slog.Error("could not complete audit", "err", err)
+	case audited > 0:
+		slog.Info("audited objects", "audited", audited, "failed", failed)
+	default:
+		slog.Debug("no objects due an audit")
+	}
+}
+
+// Ingests the bags in the ingest directory every interval, until the context is cancelled
+func ingestContinuously(ctx context.Context, driver *fs.Driver, cfg ingestConfig) {
+	for {
+		ingestBags(ctx, driver, cfg)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cfg.Interval.Duration):
+		}
+	}
+}
+
+func ingestBags(ctx context.Context, driver *fs.Driver, cfg ingestConfig) {
+	entries, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		slog.Error("could not read ingest directory", "dir", cfg.Dir, "err", err)
+		return
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || (!entry.IsDir() && filepath.Ext(name) != ".zip") {
+			continue
+		}
+
+		bag := filepath.Join(cfg.Dir, name)
+		id, err := ingestBag(ctx, driver, bag)
+		if ctx.Err() != nil {
+			return // Interrupted bags are ingested again next time
+		}
+
+		if err != nil {
+			slog.Error("could not ingest bag", "bag", bag, "err", err)
+			failed := filepath.Join(cfg.Dir, failedIngestDir, name)
+			if err = os.MkdirAll(filepath.Dir(failed), 0755); err == nil {
+				err = os.Rename(bag, failed)
+			}
+			if err != nil {
+				slog.Error("could not set aside bag", "bag", bag, "err", err)
+			}
+			continue
+		}
+
+		slog.Info("ingested bag", "bag", bag, "id", id)
+		if err = os.RemoveAll(bag); err != nil {
+			slog.Error("could not remove ingested bag", "bag", bag, "err", err)
+		}
+	}
+}
+
+// Imports a bag into the object it names, returning the object's ID
+func ingestBag(ctx context.Context, driver *fs.Driver, path string) (string, error) {
+	bag, err := bagit.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer bag.Close()
+
+	id := bag.Name
+	if ids := bag.Info["External-Identifier"]; len(ids) > 0 {
+		id = ids[0]
+	} else if unescaped, err := url.QueryUnescape(id); err == nil {
+		id = unescaped
+	}
+
+	return id, bagit.Import(ctx, driver, bag, id, bagit.ImportOptions{
+		Commit: ocfl.CommitInfo{Date: time.Now()},
+	})
+}
+
+// Rebuilds the index every interval, until the context is cancelled
+func indexContinuously(ctx context.Context, driver *fs.Driver, cfg indexConfig) {
+	for {
+		start := time.Now()
+		err := index.Build(ctx, cfg.Path, driver)
+		switch {
+		case ctx.Err() != nil:
+		case err != nil:
+			slog.Error("could not rebuild index", "path", cfg.Path, "err", err)
+		default:
+			slog.Info("rebuilt index", "path", cfg.Path, "elapsed", time.Since(start))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cfg.Interval.Duration):
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/birkland/ocfl/metadata"
+	"github.com/urfave/cli"
+)
+
+type diffOpts struct {
+	nameOnly bool
+	json     bool
+}
+
+func diff() cli.Command {
+
+	opts := diffOpts{}
+
+	return cli.Command{
+		Name:  "diff",
+		Usage: "Show the changes between two versions of an OCFL object",
+		Description: `Compare the logical files of two versions of an OCFL object.
+
+	Each changed path is printed with a letter describing the change:
+	A (added), D (deleted), M (modified content), or R (renamed, i.e.
+	the same content at a new path).  For example, to show the changes
+	made in version v3 of test:obj
+
+	  ocfl diff test:obj v2 v3
+
+	With --name-only, only the changed paths are printed (the new path,
+	for renames), and with --json, the changes are printed as JSON.`,
+		ArgsUsage:    "object from to",
+		BashComplete: completeArgs(objectArg, versionArg, versionArg),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:        "name-only",
+				Usage:       "Show only the paths of changed files",
+				Destination: &opts.nameOnly,
+			},
+			cli.BoolFlag{
+				Name:        "json",
+				Usage:       "Print the changes as JSON",
+				Destination: &opts.json,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			return diffAction(opts, c.Args())
+		},
+	}
+}
+
+func diffAction(opts diffOpts, args []string) error {
+	if len(args) != 3 {
+		return usagef("expected an object, and two versions to compare")
+	}
+
+	inv, err := readInventory(newDriver(), args[0])
+	if err != nil {
+		return err
+	}
+
+	d, err := inv.Diff(args[1], args[2])
+	if err != nil {
+		return err
+	}
+
+	if opts.json {
+		return json.NewEncoder(os.Stdout).Encode(d)
+	}
+
+	printDiff(d, opts.nameOnly)
+	return nil
+}
+
+// Prints each changed path of a diff, with a letter describing its change
+func printDiff(d *metadata.VersionDiff, nameOnly bool) {
+	changes := func(kind string, paths []string) {
+		for _, p := range paths {
+			if nameOnly {
+				fmt.Println(p)
+			} else {
+				fmt.Printf("%s    %s\n", kind, p)
+			}
+		}
+	}
+	changes("A", d.Added)
+	changes("D", d.Removed)
+	changes("M", d.Modified)
+	for _, r := range d.Renamed {
+		if nameOnly {
+			fmt.Println(r.To)
+		} else {
+			fmt.Printf("R    %s -> %s\n", r.From, r.To)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/urfave/cli"
+)
+
+type duOpts struct {
+	versions bool
+	bytes    bool
+	workers  int
+	json     bool
+}
+
+func du() cli.Command {
+
+	opts := duOpts{}
+
+	return cli.Command{
+		Name:  "du",
+		Usage: "Report the storage used by OCFL objects",
+		Description: `Report the storage used by the given objects (or every object in the
+	OCFL root, if none are given), for capacity planning.  For each object,
+	prints the logical size of its head version (every logical file counted),
+	its physical size (its content, with each file stored once, however many
+	logical files or versions share it), and the total size of its object
+	root (including inventories), followed by its ID.  Given several objects,
+	a final line gives the totals.
+
+	With --versions, the number of files and logical size of each version
+	is printed too, along with the size of the content it added, e.g.
+
+	  ocfl du --versions test:obj
+
+	Only inventories are read, and the sizes of content files determined.`,
+		ArgsUsage:    "[ id ...]",
+		BashComplete: completeObjects,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:        "versions",
+				Usage:       "Also report the usage of each version",
+				Destination: &opts.versions,
+			},
+			cli.BoolFlag{
+				Name:        "bytes, b",
+				Usage:       "Print sizes in bytes, rather than human readable units",
+				Destination: &opts.bytes,
+			},
+			cli.IntFlag{
+				Name:        "jobs, j",
+				Usage:       "Number of objects to measure concurrently (default: number of CPUs)",
+				Destination: &opts.workers,
+			},
+			cli.BoolFlag{
+				Name:        "json",
+				Usage:       "Print reports as JSON",
+				Destination: &opts.json,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			return duAction(opts, c.Args())
+		},
+	}
+}
+
+func duAction(opts duOpts, ids []string) error {
+	ctx, cancel := interruptible()
+	defer cancel()
+
+	size := humanBytes
+	if opts.bytes {
+		size = func(n int64) string { return strconv.FormatInt(n, 10) }
+	}
+
+	total := fs.UsageReport{}
+	objects, missing := 0, 0
+	enc := json.NewEncoder(os.Stdout)
+	err := newFsDriver().Usage(ctx, fs.UsageOptions{Workers: jobs(opts.workers, 0)}, func(r *fs.UsageReport) error {
+		objects++
+		missing += r.Missing
+		total.Logical += r.Logical
+		total.Physical += r.Physical
+		total.Total += r.Total
+
+		if opts.json {
+			if !opts.versions {
+				r.Versions = nil
+			}
+			return enc.Encode(r)
+		}
+
+		fmt.Printf("%10s  %10s  %10s    %s\n", size(r.Logical), size(r.Physical), size(r.Total), r.ID)
+		if opts.versions {
+			for _, v := range r.Versions {
+				fmt.Printf("    %s    %d files    %s    +%s\n", v.Version, v.Files, size(v.Logical), size(v.Added))
+			}
+		}
+		return nil
+	}, ids...)
+	if err != nil {
+		return err
+	}
+
+	if objects > 1 && !opts.json {
+		fmt.Printf("%10s  %10s  %10s    total (%d objects)\n", size(total.Logical), size(total.Physical), size(total.Total), objects)
+	}
+	if missing > 0 {
+		return invalidf("%d content files are missing, and not counted", missing)
+	}
+	return nil
+}
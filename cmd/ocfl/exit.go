@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"syscall"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+// Exit codes, so that scripts can tell kinds of failure apart
+const (
+	exitFailure  = 1 // Any failure not described below
+	exitUsage    = 2 // Invalid arguments or flags
+	exitNotFound = 3 // An object, or a version or file of one, does not exist
+	exitInvalid  = 4 // Objects failed validation, or fixity or layout checks
+	exitConflict = 5 // Another writer committed to, or holds the lock of, an object
+	exitIO       = 6 // Reading or writing storage failed
+)
+
+// usageError is the cause of errors in the arguments or flags given to a command
+type usageError struct{ error }
+
+func usagef(format string, args ...any) error {
+	return usageError{fmt.Errorf(format, args...)}
+}
+
+// onUsageError marks errors parsing flags as usage errors
+func onUsageError(c *cli.Context, err error, isSubcommand bool) error {
+	return usageError{err}
+}
+
+// invalidError is the cause of errors reporting that objects failed checks
+type invalidError struct{ error }
+
+func invalidf(format string, args ...any) error {
+	return invalidError{fmt.Errorf(format, args...)}
+}
+
+// exitCode determines the exit code for an error, by its cause
+func exitCode(err error) int {
+	cause := errors.Cause(err)
+	switch cause.(type) {
+	case usageError:
+		return exitUsage
+	case invalidError:
+		return exitInvalid
+	}
+
+	switch {
+	case cause == ocfl.ErrNotFound, cause == metadata.ErrNotFound, os.IsNotExist(cause):
+		return exitNotFound
+	case cause == ocfl.ErrConflict, cause == fs.ErrLocked:
+		return exitConflict
+	}
+
+	switch cause.(type) {
+	case *os.PathError, *os.LinkError, *os.SyscallError, syscall.Errno:
+		return exitIO
+	}
+	return exitFailure
+}
+
+// exit logs an error, and exits with the code for its kind
+func exit(err error) {
+	slog.Error(err.Error())
+	os.Exit(exitCode(err))
+}
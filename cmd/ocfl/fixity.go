@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/urfave/cli"
+)
+
+type fixityOpts struct {
+	algorithms cli.StringSlice
+	sample     float64
+	workers    int
+	json       bool
+}
+
+type auditOpts struct {
+	maxAge  time.Duration
+	workers int
+	dryRun  bool
+}
+
+func fixity() cli.Command {
+
+	opts := auditOpts{}
+	checkOpts := fixityOpts{}
+
+	return cli.Command{
+		Name:  "fixity",
+		Usage: "Verify the fixity of OCFL object content",
+		Description: `Recompute the digests of the content of the given objects (or every object
+	in the OCFL root, if none are given), and compare them to those in each
+	object's manifest and fixity block.  Each object is printed with any
+	files that fail to match as it completes, or as JSON (one object per
+	line) with --json.
+
+	By default, digests of every algorithm an object uses are verified,
+	which --algorithm restricts to those given.  With --sample, only that
+	percentage of each object's files are checked, chosen at random, e.g.
+	to spot check 5% of every object's content using md5 fixity digests:
+
+	  ocfl fixity --sample 5 --algorithm md5
+
+	Nothing is recorded in the objects (see 'ocfl fixity audit').  Fails
+	if any object fails its check.`,
+		ArgsUsage:    "[ id ...]",
+		BashComplete: completeObjects,
+		Flags: []cli.Flag{
+			cli.StringSliceFlag{
+				Name:  "algorithm, a",
+				Usage: "Verify only digests using this algorithm (may be repeated)",
+				Value: &checkOpts.algorithms,
+			},
+			cli.Float64Flag{
+				Name:        "sample",
+				Usage:       "Percentage of each object's files to check, chosen at random (default: all)",
+				Destination: &checkOpts.sample,
+			},
+			cli.IntFlag{
+				Name:        "jobs, j",
+				Usage:       "Number of objects to check concurrently (default: number of CPUs)",
+				Destination: &checkOpts.workers,
+			},
+			cli.BoolFlag{
+				Name:        "json",
+				Usage:       "Print reports as JSON",
+				Destination: &checkOpts.json,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return fixityAction(checkOpts, c.Args())
+		},
+		Subcommands: []cli.Command{
+			{
+				Name:  "audit",
+				Usage: "Verify the digests of all content of objects, and record the results",
+				Description: `Verify the digests of all content of the given objects (or every object
+	in the OCFL root, if none are given) against their manifests and fixity
+	blocks, and record the result in each object's audit log (logs/fixity-audit.jsonl).
+	The result of each object is printed as it completes.
+
+	With --max-age, objects audited more recently are skipped.  This allows
+	audits to be run periodically, and an interrupted audit to be resumed by
+	running it again.  For example, to audit every object not audited within
+	the last 30 days:
+
+	  ocfl fixity audit --max-age 720h
+
+	Fails if any object fails its audit.`,
+				ArgsUsage:    "[ id ...]",
+				BashComplete: completeObjects,
+				Flags: []cli.Flag{
+					cli.DurationFlag{
+						Name:        "max-age",
+						Usage:       "Skip objects audited within this duration",
+						Destination: &opts.maxAge,
+					},
+					cli.IntFlag{
+						Name:        "jobs, j",
+						Usage:       "Number of objects to audit concurrently (default: number of CPUs)",
+						Destination: &opts.workers,
+					},
+					cli.BoolFlag{
+						Name:        "dry-run, n",
+						Usage:       "Audit objects, but do not record the results",
+						Destination: &opts.dryRun,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return auditAction(opts, c.Args())
+				},
+			},
+		},
+	}
+}
+
+func fixityAction(opts fixityOpts, ids []string) error {
+	ctx, cancel := interruptible()
+	defer cancel()
+
+	fixityOpts := fs.FixityOptions{
+		Sample:  opts.sample,
+		Workers: jobs(opts.workers, 0),
+	}
+	for _, alg := range opts.algorithms {
+		fixityOpts.Algorithms = append(fixityOpts.Algorithms, metadata.DigestAlgorithm(alg))
+	}
+
+	failed := 0
+	enc := json.NewEncoder(os.Stdout)
+	err := newFsDriver().CheckFixity(ctx, fixityOpts, func(r *fs.FixityReport) error {
+		if !r.Passed() {
+			failed++
+		}
+		if opts.json {
+			return enc.Encode(r)
+		}
+
+		status := "passed"
+		if !r.Passed() {
+			status = "FAILED"
+		}
+		fmt.Printf("%s    %s    %s    (%d files checked)\n", status, r.ID, r.Path, r.Checked)
+		for _, f := range r.Failures {
+			fmt.Printf("    %s\n", f)
+		}
+		return nil
+	}, ids...)
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return invalidf("%d objects failed their fixity check", failed)
+	}
+	return nil
+}
+
+func auditAction(opts auditOpts, ids []string) error {
+	ctx, cancel := interruptible()
+	defer cancel()
+
+	auditOpts := fs.AuditOptions{
+		Workers: jobs(opts.workers, 0),
+		DryRun:  opts.dryRun,
+	}
+	if opts.maxAge > 0 {
+		auditOpts.Since = time.Now().Add(-opts.maxAge)
+	}
+
+	failed := 0
+	err := newFsDriver().Audit(ctx, auditOpts, func(r *fs.AuditRecord) error {
+		status := "passed"
+		if !r.Passed {
+			status = "FAILED"
+			failed++
+		}
+		fmt.Printf("%s    %s    %s\n", status, r.ID, r.Path)
+		for _, f := range r.Failures {
+			fmt.Printf("    %s\n", f)
+		}
+		return nil
+	}, ids...)
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return invalidf("%d objects failed their audit", failed)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/urfave/cli"
+)
+
+type gcOpts struct {
+	dryRun bool
+}
+
+func gc() cli.Command {
+
+	opts := gcOpts{}
+
+	return cli.Command{
+		Name:  "gc",
+		Usage: "Remove debris left behind by interrupted writes",
+		Description: `Find and remove temporary files, uncommitted versions, and content
+	not referenced by any inventory, as may be left behind by crashes or
+	failed commits.  Each item is printed as it is found.
+
+	Do not run gc while other processes are writing to the OCFL root, as their
+	work in progress is indistinguishable from debris.  To only list debris
+	without removing it:
+
+	  ocfl gc --dry-run`,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:        "dry-run, n",
+				Usage:       "Report debris, but do not remove it",
+				Destination: &opts.dryRun,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			return gcAction(opts)
+		},
+	}
+}
+
+func gcAction(opts gcOpts) error {
+	ctx, cancel := interruptible()
+	defer cancel()
+
+	return newFsDriver().GC(ctx, fs.GCOptions{DryRun: opts.dryRun}, func(d fs.Debris) error {
+		fmt.Printf("%s    %s\n", d.Reason, d.Path)
+		return nil
+	})
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/birkland/ocfl/drivers/index"
+	"github.com/urfave/cli"
+)
+
+func indexCmd() cli.Command {
+	return cli.Command{
+		Name:  "index",
+		Usage: "Build an index of the OCFL root, for fast listing",
+		Description: `Walk the OCFL root, and record its objects, versions, and files in
+	a SQLite index file, replacing any index already there.  Listing from
+	the index (with ocfl ls --index) then takes milliseconds, rather than
+	walking the root, even for roots of many millions of objects, e.g.
+
+	  ocfl index /var/lib/ocfl/index.db
+	  ocfl ls --index /var/lib/ocfl/index.db -t object
+
+	The index reflects the root as it was when built, so should be rebuilt
+	as the root changes.  It may be rebuilt while it is being read.`,
+		ArgsUsage: "index-file",
+
+		Action: func(c *cli.Context) error {
+			return indexAction(c.Args())
+		},
+	}
+}
+
+func indexAction(args []string) error {
+	if len(args) != 1 {
+		return usagef("expected the index file to write")
+	}
+
+	ctx, cancel := interruptible()
+	defer cancel()
+
+	return index.Build(ctx, args[0], newDriver())
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/urfave/cli"
+)
+
+func layout() cli.Command {
+	return cli.Command{
+		Name:  "layout",
+		Usage: "Show and check the storage layout of an OCFL root",
+		Description: `The storage layout of an OCFL root maps object IDs to the directories
+	of their object roots.  It is declared in ocfl_layout.json by roots
+	created with 'ocfl mkroot --layout'.  Otherwise, object IDs are query
+	escaped to form directory names directly beneath the root.`,
+		Subcommands: []cli.Command{
+			{
+				Name:  "show",
+				Usage: "Show the storage layout declared by the OCFL root",
+				Action: func(c *cli.Context) error {
+					return layoutShowAction()
+				},
+			},
+			{
+				Name:         "path",
+				Usage:        "Print the object root path of each given object ID",
+				ArgsUsage:    "id [id ...]",
+				BashComplete: completeObjects,
+				Action: func(c *cli.Context) error {
+					return layoutPathAction(c.Args())
+				},
+			},
+			{
+				Name:  "check",
+				Usage: "Verify that every object is where the layout would place it",
+				Description: `Reads the ID of every object in the OCFL root, and prints the path
+	of each object that is not in the directory generated from its ID, along
+	with the expected path.  Fails if any such object is found.`,
+				Action: func(c *cli.Context) error {
+					return layoutCheckAction()
+				},
+			},
+		},
+	}
+}
+
+func layoutShowAction() error {
+	dir := root(mainOpts.root)
+
+	layout, err := fs.ReadLayout(dir)
+	if err != nil {
+		return err
+	}
+	if layout == nil {
+		fmt.Printf("%s declares no storage layout\n", dir)
+		return nil
+	}
+
+	fmt.Printf("extension:    %s\n", layout.Extension)
+	fmt.Printf("description:  %s\n", layout.Description)
+
+	config, err := ioutil.ReadFile(layout.ConfigPath(dir))
+	if os.IsNotExist(err) {
+		fmt.Println("config:       (defaults)")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	fmt.Printf("config:\n%s", config)
+	return nil
+}
+
+func layoutPathAction(ids []string) error {
+	if len(ids) == 0 {
+		return usagef("please provide at least one object ID")
+	}
+
+	driver := newFsDriver()
+	for _, id := range ids {
+		path, err := driver.ObjectPath(id)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s    %s\n", id, path)
+	}
+	return nil
+}
+
+func layoutCheckAction() error {
+	ctx, cancel := interruptible()
+	defer cancel()
+
+	mismatches := 0
+	err := newFsDriver().CheckLayout(ctx, func(m fs.LayoutMismatch) error {
+		mismatches++
+		fmt.Printf("%s    %s    expected %s\n", m.ID, m.Path, m.Expected)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if mismatches > 0 {
+		return invalidf("%d objects do not agree with the layout", mismatches)
+	}
+	return nil
+}
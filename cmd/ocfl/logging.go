@@ -0,0 +1,27 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+)
+
+// setupLogging configures the default logger to write to stderr at the given level
+// (debug, info, warn, or error), as text (key=value pairs) or JSON.  The standard
+// library's log package writes through it as well.
+func setupLogging(level, format string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return usagef("unknown log level %s, expected debug, info, warn, or error", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: l}
+	switch format {
+	case "text":
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, opts)))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, opts)))
+	default:
+		return usagef("unknown log format %s, expected text or json", format)
+	}
+	return nil
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/index"
+	"github.com/birkland/ocfl/metadata"
 	"github.com/urfave/cli"
 )
 
@@ -12,6 +19,33 @@ type lsOpts struct {
 	physical bool
 	ocfltype string
 	head     bool
+	sorted   bool
+	long     bool
+	json     bool
+	glob     string
+	regex    string
+	count    bool
+	index    string
+}
+
+// lsEntity is the JSON representation of a listed entity
+type lsEntity struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id"`
+	Coords  []string        `json:"coords"`
+	Addr    string          `json:"addr"`
+	Object  string          `json:"object,omitempty"`
+	Version string          `json:"version,omitempty"`
+	Digest  metadata.Digest `json:"digest,omitempty"`
+	Size    *int64          `json:"size,omitempty"`
+}
+
+// lsCounts summarizes the entities in the scope of a listing
+type lsCounts struct {
+	Objects  int   `json:"objects"`
+	Versions int   `json:"versions"`
+	Files    int   `json:"files"`
+	Bytes    int64 `json:"bytes"` // Total size of the files
 }
 
 func ls() cli.Command {
@@ -37,7 +71,8 @@ func ls() cli.Command {
 	of an OCFL object, as well as the files in each version), 
 	and/or restricted by type (i.e. list all logical files under 
 	an ocfl root)`,
-		ArgsUsage: "[ file | id ] ...",
+		ArgsUsage:    "[ file | id ] ...",
+		BashComplete: completeObjects,
 		Flags: []cli.Flag{
 			cli.BoolFlag{
 				Name:        "head",
@@ -54,6 +89,41 @@ func ls() cli.Command {
 				Usage:       "Show only {object, version, file} entities",
 				Destination: &opts.ocfltype,
 			},
+			cli.BoolFlag{
+				Name:        "sort, s",
+				Usage:       "List entities in a stable, lexically sorted order",
+				Destination: &opts.sorted,
+			},
+			cli.BoolFlag{
+				Name:        "long, l",
+				Usage:       "Show the size of each file, in bytes",
+				Destination: &opts.long,
+			},
+			cli.StringFlag{
+				Name:        "glob, g",
+				Usage:       "Show only files whose logical paths (or objects whose IDs, with -t object) match a glob pattern, e.g. '**/*.tiff'",
+				Destination: &opts.glob,
+			},
+			cli.StringFlag{
+				Name:        "regex, e",
+				Usage:       "Show only files whose logical paths (or objects whose IDs, with -t object) match a regular expression",
+				Destination: &opts.regex,
+			},
+			cli.BoolFlag{
+				Name:        "count, c",
+				Usage:       "Print the number of objects, versions, and files, and their total size, rather than each entity",
+				Destination: &opts.count,
+			},
+			cli.BoolFlag{
+				Name:        "json",
+				Usage:       "Print each entity as a JSON object, one per line",
+				Destination: &opts.json,
+			},
+			cli.StringFlag{
+				Name:        "index, i",
+				Usage:       "List entities from the given index (see ocfl index), rather than walking the OCFL root",
+				Destination: &opts.index,
+			},
 		},
 
 		Action: func(c *cli.Context) error {
@@ -63,18 +133,119 @@ func ls() cli.Command {
 }
 
 func lsAction(opts lsOpts, args []string) error {
-	d := newDriver()
+	var d ocfl.Walker
+	if opts.index != "" {
+		i, err := index.NewDriver(index.Config{Path: opts.index})
+		if err != nil {
+			return err
+		}
+		defer i.Close()
+		d = i
+	} else {
+		d = newDriver()
+	}
+
+	ctx, cancel := interruptible()
+	defer cancel()
+
+	desired := ocfl.Select{Type: ocfl.ParseType(opts.ocfltype), Head: opts.head, Sorted: opts.sorted, Sizes: opts.long}
+
+	var pattern *regexp.Regexp
+	if opts.regex != "" {
+		var err error
+		if pattern, err = regexp.Compile(opts.regex); err != nil {
+			return usagef("bad regular expression '%s': %s", opts.regex, err)
+		}
+	}
+
+	// Patterns match object IDs when listing objects, and logical paths otherwise
+	if desired.Type == ocfl.Object {
+		desired.ObjectID, desired.ObjectRegexp = opts.glob, pattern
+	} else {
+		desired.LogicalPath, desired.PathRegexp = opts.glob, pattern
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+
+	if opts.count {
+		return lsCount(ctx, d, desired, opts.json, args)
+	}
+
+	return d.WalkContext(ctx, desired, func(ref ocfl.EntityRef) error {
+		if ref.Type == ocfl.Root || ref.Type == ocfl.Intermediate {
+			return nil
+		}
 
-	return d.Walk(ocfl.Select{Type: ocfl.ParseType(opts.ocfltype), Head: opts.head}, func(ref ocfl.EntityRef) error {
 		coords := ref.Coords()
 
+		if opts.json {
+			return enc.Encode(jsonEntity(ref, coords, opts.long))
+		}
+
 		if opts.physical {
 			coords = append(coords, ref.Addr)
 		}
 
-		if ref.Type != ocfl.Root && ref.Type != ocfl.Intermediate {
-			fmt.Println(strings.Join(coords, "    "))
+		if opts.long {
+			size := "-"
+			if ref.Type == ocfl.File && ref.Size >= 0 {
+				size = strconv.FormatInt(ref.Size, 10)
+			}
+			coords = append([]string{size}, coords...)
+		}
+
+		fmt.Println(strings.Join(coords, "    "))
+		return nil
+	}, args...)
+}
+
+func jsonEntity(ref ocfl.EntityRef, coords []string, long bool) lsEntity {
+	e := lsEntity{
+		Type:   strings.ToLower(ref.Type.String()),
+		ID:     ref.ID,
+		Coords: coords,
+		Addr:   ref.Addr,
+		Object: coords[0],
+		Digest: ref.Digest,
+	}
+	if len(coords) > 1 {
+		e.Version = coords[1]
+	}
+	if long && ref.Type == ocfl.File && ref.Size >= 0 {
+		e.Size = &ref.Size
+	}
+	return e
+}
+
+// Counts the entities in the scope of a listing, and the total size of its files
+func lsCount(ctx context.Context, d ocfl.Walker, desired ocfl.Select, asJSON bool, args []string) error {
+	desired.Sizes, desired.Sorted = true, false
+
+	var counts lsCounts
+	err := d.WalkContext(ctx, desired, func(ref ocfl.EntityRef) error {
+		switch ref.Type {
+		case ocfl.Object:
+			counts.Objects++
+		case ocfl.Version:
+			counts.Versions++
+		case ocfl.File:
+			counts.Files++
+			if ref.Size > 0 {
+				counts.Bytes += ref.Size
+			}
 		}
 		return nil
 	}, args...)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		return json.NewEncoder(os.Stdout).Encode(counts)
+	}
+	fmt.Printf("objects     %d\n", counts.Objects)
+	fmt.Printf("versions    %d\n", counts.Versions)
+	fmt.Printf("files       %d\n", counts.Files)
+	fmt.Printf("bytes       %d (%s)\n", counts.Bytes, humanBytes(counts.Bytes))
+	return nil
 }
@@ -1,21 +1,43 @@
 package main
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
 	"net/url"
 	"os"
-	"os/user"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/birkland/ocfl"
 	"github.com/birkland/ocfl/drivers/fs"
 	"github.com/birkland/ocfl/fspath"
+	"github.com/birkland/ocfl/identity"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/birkland/ocfl/signature"
+	"github.com/pkg/errors"
 	"github.com/urfave/cli"
 )
 
 var mainOpts = struct {
-	root    string
-	user    string
-	address string
+	root       string
+	user       string
+	address    string
+	idToken    string
+	signingKey string
+	staging    string
+	sync       bool
+	reflink    bool
+	lock       bool
+	lockWait   time.Duration
+	fedora     bool
+	padding    int
+	precision  string
+	jobs       int
+	logLevel   string
+	logFormat  string
 }{}
 
 func main() {
@@ -24,9 +46,29 @@ func main() {
 	app.Usage = "OCFL commandline utilities"
 	app.EnableBashCompletion = true
 	app.Commands = []cli.Command{
+		adopt(),
+		checkSidecars(),
 		cp(),
+		daemon(),
+		diff(),
+		du(),
+		exportBag(),
+		fixity(),
+		gc(),
+		importBag(),
+		indexCmd(),
+		layout(),
 		ls(),
 		mkroot(),
+		mv(),
+		pack(),
+		purge(),
+		reportCmd(),
+		rollback(),
+		serve(),
+		unpack(),
+		validate(),
+		verifySignature(),
 	}
 	app.Flags = []cli.Flag{
 		cli.StringFlag{
@@ -47,63 +89,320 @@ func main() {
 			EnvVar:      "ADDRESS",
 			Destination: &mainOpts.address,
 		},
+		cli.StringFlag{
+			Name:        "id-token",
+			Usage:       "OpenID Connect ID token whose claims provide the user and address, if not given",
+			EnvVar:      "OCFL_ID_TOKEN",
+			Destination: &mainOpts.idToken,
+		},
+		cli.StringFlag{
+			Name:        "signing-key",
+			Usage:       "PEM encoded ed25519 private key for signing the inventory of each committed version",
+			EnvVar:      "OCFL_SIGNING_KEY",
+			Destination: &mainOpts.signingKey,
+		},
+		cli.StringFlag{
+			Name:        "staging",
+			Usage:       "Directory for staging content before it is moved into an object",
+			EnvVar:      "OCFL_STAGING",
+			Destination: &mainOpts.staging,
+		},
+		cli.BoolFlag{
+			Name:        "sync",
+			Usage:       "Flush content and inventories to stable storage before each commit completes",
+			EnvVar:      "OCFL_SYNC",
+			Destination: &mainOpts.sync,
+		},
+		cli.BoolFlag{
+			Name:        "reflink",
+			Usage:       "Clone local files rather than copying them, where the filesystem supports it",
+			EnvVar:      "OCFL_REFLINK",
+			Destination: &mainOpts.reflink,
+		},
+		cli.BoolFlag{
+			Name:        "lock",
+			Usage:       "Lock objects while writing new versions, so concurrent writers cannot clobber each other",
+			EnvVar:      "OCFL_LOCK",
+			Destination: &mainOpts.lock,
+		},
+		cli.DurationFlag{
+			Name:        "lock-timeout",
+			Usage:       "How long to wait for another writer to release an object's lock",
+			Value:       fs.DefaultLockTimeout,
+			EnvVar:      "OCFL_LOCK_TIMEOUT",
+			Destination: &mainOpts.lockWait,
+		},
+		cli.BoolFlag{
+			Name:        "fedora",
+			Usage:       "Map Fedora 6 resource paths or IDs to objects, in roots shared with Fedora",
+			EnvVar:      "OCFL_FEDORA",
+			Destination: &mainOpts.fedora,
+		},
+		cli.IntFlag{
+			Name:        "version-padding",
+			Usage:       "Zero pad the version numbers of new objects to this many digits (not recommended)",
+			EnvVar:      "OCFL_VERSION_PADDING",
+			Destination: &mainOpts.padding,
+		},
+		cli.IntFlag{
+			Name:        "jobs, j",
+			Usage:       "Number of concurrent workers for copying, validating, and checking fixity, or 0 for each command's default",
+			EnvVar:      "OCFL_JOBS",
+			Destination: &mainOpts.jobs,
+		},
+		cli.StringFlag{
+			Name:        "timestamp-precision",
+			Usage:       "Write the creation dates of new versions to {milliseconds, seconds}",
+			EnvVar:      "OCFL_TIMESTAMP_PRECISION",
+			Value:       "milliseconds",
+			Destination: &mainOpts.precision,
+		},
+		cli.StringFlag{
+			Name:        "log-level",
+			Usage:       "Log messages of at least this level to stderr: {debug, info, warn, error}",
+			EnvVar:      "OCFL_LOG_LEVEL",
+			Value:       "info",
+			Destination: &mainOpts.logLevel,
+		},
+		cli.StringFlag{
+			Name:        "log-format",
+			Usage:       "Format of log messages: {text, json}",
+			EnvVar:      "OCFL_LOG_FORMAT",
+			Value:       "text",
+			Destination: &mainOpts.logFormat,
+		},
+	}
+	app.OnUsageError = onUsageError
+	for i := range app.Commands {
+		app.Commands[i].OnUsageError = onUsageError
+		for j := range app.Commands[i].Subcommands {
+			app.Commands[i].Subcommands[j].OnUsageError = onUsageError
+		}
+	}
+	app.Before = func(c *cli.Context) error {
+		return setupLogging(mainOpts.logLevel, mainOpts.logFormat)
 	}
 
-	err := app.Run(os.Args)
-	if err != nil {
-		log.Fatal(err)
+	if err := app.Run(os.Args); err != nil {
+		exit(err)
 	}
 }
 
-func newDriver() ocfl.Driver {
-	d, err := fs.NewDriver(fs.Config{
-		Root:        root(mainOpts.root),
-		ObjectPaths: fspath.GeneratorFunc(url.QueryEscape),
-		FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
-	})
+// Constructors of drivers for OCFL roots, by the scheme of the roots' URIs.  Roots given
+// as plain paths are file roots.  Configuration functions apply to file drivers only.
+var driverSchemes = map[string]func(root string, configure ...func(*fs.Config)) (ocfl.Driver, error){
+	"file": newFileDriver,
+}
+
+// newDriver creates a driver for the OCFL root, chosen by the scheme of the root's URI.
+// Commands may adjust the configuration of file drivers with options of their own.
+func newDriver(configure ...func(*fs.Config)) ocfl.Driver {
+	scheme, root, err := parseRoot(mainOpts.root)
+	if err != nil {
+		exit(err)
+	}
+
+	constructor, ok := driverSchemes[scheme]
+	if !ok {
+		exit(usagef("cannot use OCFL root %s: %s:// roots are not supported", mainOpts.root, scheme))
+	}
+
+	d, err := constructor(root, configure...)
 	if err != nil {
-		log.Fatalf("could not initialize file driver %+v", err)
+		exit(err)
 	}
 	return d
 }
 
-func root(dir string) string {
-	if dir == "" {
-		pwd, err := os.Getwd()
+// For operations specific to the filesystem driver, which are refused for roots of other schemes
+func newFsDriver(configure ...func(*fs.Config)) *fs.Driver {
+	d, ok := newDriver(configure...).(*fs.Driver)
+	if !ok {
+		exit(usagef("cannot use OCFL root %s: the command requires a local filesystem root", mainOpts.root))
+	}
+	return d
+}
+
+// Creates a filesystem driver for the given root directory, or the root containing the
+// working directory if none is given
+func newFileDriver(dir string, configure ...func(*fs.Config)) (ocfl.Driver, error) {
+	dir, err := locateRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := fs.Config{
+		Root:           dir,
+		ObjectPaths:    fspath.QueryEscape{},
+		FilePaths:      fspath.GeneratorFunc(fs.Passthrough),
+		Identity:       identityProvider(),
+		Staging:        mainOpts.staging,
+		Sync:           mainOpts.sync,
+		Reflink:        mainOpts.reflink,
+		Lock:           mainOpts.lock,
+		LockTimeout:    mainOpts.lockWait,
+		VersionPadding: metadata.Padding(mainOpts.padding),
+	}
+
+	if mainOpts.fedora {
+		cfg.ObjectPaths = fspath.NewFedora()
+	}
+
+	switch mainOpts.precision {
+	case "milliseconds", "ms":
+		cfg.TimestampPrecision = metadata.Milliseconds
+	case "seconds", "s":
+		cfg.TimestampPrecision = metadata.Seconds
+	default:
+		return nil, usagef("unknown timestamp precision %s, expected milliseconds or seconds", mainOpts.precision)
+	}
+
+	if mainOpts.signingKey != "" {
+		signer, err := signature.LoadSigner(mainOpts.signingKey)
 		if err != nil {
-			log.Fatalf("could not get pwd %s", err)
+			return nil, errors.Wrapf(err, "could not load signing key")
 		}
-		dir = pwd
+		cfg.Signer = signer
+	}
+
+	for _, f := range configure {
+		f(&cfg)
 	}
 
-	dir, err := fs.LocateRoot(dir)
+	d, err := fs.NewDriver(cfg)
 	if err != nil {
-		log.Fatalf("error locating root %s", err)
+		return nil, errors.Wrapf(err, "could not initialize file driver")
 	}
+	slog.Debug("using OCFL root", "root", cfg.Root)
+	return d, nil
+}
+
+// Reads the inventory of an object through a driver able to read inventories (as the
+// filesystem driver can).  Fails with ocfl.ErrNotFound if there is no such object.
+func readInventory(d ocfl.Driver, id string) (*metadata.Inventory, error) {
+	reader, ok := d.(interface {
+		Inventory(ocfl.EntityRef) (*metadata.Inventory, error)
+	})
+	if !ok {
+		return nil, fmt.Errorf("cannot read object %s: the driver of %s cannot read inventories", id, mainOpts.root)
+	}
+
+	var obj *ocfl.EntityRef
+	err := d.Walk(ocfl.Select{Type: ocfl.Object}, func(ref ocfl.EntityRef) error {
+		obj = &ref
+		return nil
+	}, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read object %s", id)
+	}
+	if obj == nil {
+		return nil, errors.Wrapf(ocfl.ErrNotFound, "could not read object %s", id)
+	}
+
+	inv, err := reader.Inventory(*obj)
+	return inv, errors.Wrapf(err, "could not read object %s", id)
+}
+
+// jobs returns the number of concurrent workers to use: as given to a command, or else
+// by the global --jobs flag, or else the given default
+func jobs(local, def int) int {
+	switch {
+	case local > 0:
+		return local
+	case mainOpts.jobs > 0:
+		return mainOpts.jobs
+	}
+	return def
+}
+
+// interruptible returns a context that is cancelled when the process
+// receives an interrupt, so that long-running walks or copies can be aborted cleanly
+func interruptible() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
 
+	go func() {
+		select {
+		case <-sig:
+			slog.Warn("interrupted, aborting")
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(sig)
+	}()
+
+	return ctx, cancel
+}
+
+// The directory of the OCFL root given as a URI or path, for commands that require a local
+// filesystem root
+func root(root string) string {
+	dir, err := rootDir(root)
+	if err != nil {
+		exit(err)
+	}
 	return dir
 }
 
-func userName() string {
-	if mainOpts.user != "" {
-		return mainOpts.user
+func rootDir(root string) (string, error) {
+	scheme, dir, err := parseRoot(root)
+	if err != nil {
+		return "", err
+	}
+	if scheme != "file" {
+		return "", usagef("cannot use OCFL root %s: the command requires a local filesystem root", root)
+	}
+	return locateRoot(dir)
+}
+
+// Finds the OCFL root at the given path, or containing the working directory if none is given
+func locateRoot(dir string) (string, error) {
+	var err error
+	if dir == "" {
+		if dir, err = os.Getwd(); err != nil {
+			return "", errors.Wrapf(err, "could not get pwd")
+		}
 	}
 
-	sysUser, err := user.Current()
-	if err == nil && sysUser.Name != "" {
-		return sysUser.Name
+	dir, err = fs.LocateRoot(dir)
+	return dir, errors.Wrapf(err, "error locating root")
+}
+
+// Splits the root given as a URI into its scheme, and the location understood by that
+// scheme's driver.  Only strings containing :// are URIs, so that relative paths may
+// contain colons (e.g. my:root), and Windows paths are not mistaken for URIs.  Roots
+// that are not URIs are file paths.
+func parseRoot(root string) (scheme, location string, err error) {
+	if !strings.Contains(root, "://") {
+		return "file", root, nil
 	}
 
-	// Last ditch, on Windows
-	name, _ := os.LookupEnv("USERNAME")
-	return name
+	u, err := url.Parse(root)
+	if err != nil {
+		return "", "", usagef("cannot use OCFL root %s: %s", root, err)
+	}
+	if u.Scheme != "file" {
+		return u.Scheme, root, nil
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return "", "", usagef("cannot use OCFL root %s: remote file:// hosts are not supported", root)
+	}
+	return "file", filepath.FromSlash(u.Path), nil
 }
 
-func address() string {
-	if mainOpts.address != "" {
-		return mainOpts.address
+// Commit identities come from explicit flags first, then an ID token if
+// present, and finally the OS user
+func identityProvider() ocfl.IdentityProvider {
+	providers := identity.Chain{identity.Static{
+		Name:    mainOpts.user,
+		Address: mainOpts.address,
+	}}
+
+	if mainOpts.idToken != "" {
+		providers = append(providers, identity.OIDCToken(mainOpts.idToken))
 	}
 
-	host, _ := os.Hostname()
-	return userName() + "@" + host
+	return append(providers, identity.OSUser{})
 }
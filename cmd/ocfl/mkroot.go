@@ -3,11 +3,18 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/fspath"
 	"github.com/urfave/cli"
 )
 
+var mkrootOpts = struct {
+	layout       string
+	layoutConfig string
+}{}
+
 func mkroot() cli.Command {
 	return cli.Command{
 		Name:  "mkroot",
@@ -22,8 +29,25 @@ func mkroot() cli.Command {
 	environment variable into an OCFL root.  If neither are defined, it 
 	converts the current working directory into an OCFL root, provided the
 	directory is empty.
+
+	If a storage layout extension is given with --layout, the root declares
+	it in ocfl_layout.json, and objects are placed according to it.  Its
+	parameters may be given as JSON with --layout-config; otherwise, the
+	extension's defaults are used.
 	`,
 		ArgsUsage: "[ dir ] ",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "layout, l",
+				Usage:       "Storage layout extension (one of " + strings.Join(fspath.Layouts(), ", ") + ")",
+				Destination: &mkrootOpts.layout,
+			},
+			cli.StringFlag{
+				Name:        "layout-config",
+				Usage:       "JSON parameters of the storage layout",
+				Destination: &mkrootOpts.layoutConfig,
+			},
+		},
 		Action: func(c *cli.Context) error {
 			return mkrootAction(c.Args())
 		},
@@ -37,7 +61,7 @@ func mkrootAction(args []string) error {
 	case 1:
 		return initRoot(args[0])
 	default:
-		return fmt.Errorf("mkroot takes zero or one arguments")
+		return usagef("mkroot takes zero or one arguments")
 	}
 }
 
@@ -49,5 +73,16 @@ func initRoot(path string) (err error) {
 		}
 	}
 
-	return fs.MkRoot(path)
+	if mkrootOpts.layout == "" {
+		if mkrootOpts.layoutConfig != "" {
+			return usagef("--layout-config requires a --layout")
+		}
+		return fs.MkRoot(path)
+	}
+
+	layout, err := fspath.NewLayout(mkrootOpts.layout, []byte(mkrootOpts.layoutConfig))
+	if err != nil {
+		return err
+	}
+	return fs.InitRoot(path, layout)
 }
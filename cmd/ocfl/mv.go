@@ -0,0 +1,81 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/birkland/ocfl"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+type mvOpts struct {
+	commitMessage string
+}
+
+func mv() cli.Command {
+
+	opts := mvOpts{}
+
+	return cli.Command{
+		Name:  "mv",
+		Usage: "Rename files within an OCFL object",
+		Description: `Rename a logical file within an OCFL object, in a new version
+
+	The new version references the same content as the old path did, so
+	no content is copied.  For example, the following moves foo.txt into
+	the bar directory of test:obj
+
+		ocfl mv test:obj foo.txt bar/foo.txt
+	`,
+		ArgsUsage:    "object src dest",
+		BashComplete: completeArgs(objectArg, pathArg, pathArg),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "message, m",
+				Usage:       "Commit message (optional)",
+				Destination: &opts.commitMessage,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			return mvAction(opts, c.Args())
+		},
+	}
+}
+
+func mvAction(opts mvOpts, args []string) (err error) {
+	if len(args) != 3 {
+		return usagef("expected an object, a source path, and a destination path")
+	}
+
+	d := newDriver()
+
+	ctx, cancel := interruptible()
+	defer cancel()
+
+	session, err := d.OpenContext(ctx, args[0], ocfl.Options{Version: ocfl.NEW})
+	if err != nil {
+		return errors.Wrapf(err, "could not open session")
+	}
+
+	defer func() {
+		if err != nil {
+			slog.Error("error encountered, NOT committing")
+			if e := session.Close(); e != nil {
+				slog.Error("could not clean up after error", "err", e)
+			}
+			return
+		}
+
+		err = session.CommitContext(ctx, ocfl.CommitInfo{
+			Date:    time.Now(),
+			Message: opts.commitMessage,
+		})
+		if e := session.Close(); err == nil {
+			err = e
+		}
+	}()
+
+	return session.Move(args[1], args[2])
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/urfave/cli"
+)
+
+type packOpts struct {
+	format string
+	prefix string
+}
+
+func pack() cli.Command {
+
+	opts := packOpts{}
+
+	return cli.Command{
+		Name:  "pack",
+		Usage: "Package an OCFL object into a single ZIP or tar archive",
+		Description: `Write an object into a single archive, for transfer to a dark archive or
+	another institution.  The archive holds the exact layout of the object
+	root, including every inventory, sidecar, and extension, so it can be
+	unpacked (see 'ocfl unpack') into any OCFL root.  Its format is given by
+	its name (.zip or .tar), e.g.
+
+	  ocfl pack test:obj /transfer/obj.zip
+
+	If a version is given, only versions up to and including it are packed,
+	and the packed object's head is that version.  The archive must not
+	already exist.
+
+	Given - as the archive, the object is streamed to stdout as tar (or as
+	given by --format), without creating any temporary files, e.g.
+
+	  ocfl pack test:obj - | ssh archive 'cat > /tape/obj.tar'`,
+		ArgsUsage:    "object [version] archive",
+		BashComplete: completeArgs(objectArg, versionArg),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "format, f",
+				Usage:       "Archive format {zip, tar} (default: given by the archive's name, or tar for stdout)",
+				Destination: &opts.format,
+			},
+			cli.StringFlag{
+				Name:        "prefix",
+				Usage:       "Directory within the archive to place the object in (default: none)",
+				Destination: &opts.prefix,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			return packAction(opts, c.Args())
+		},
+	}
+}
+
+func unpack() cli.Command {
+	return cli.Command{
+		Name:  "unpack",
+		Usage: "Create OCFL objects from archives written by pack",
+		Description: `Extract each archive written by 'ocfl pack' into the OCFL root, as the
+	object it contains, e.g.
+
+	  ocfl unpack /transfer/obj.zip
+
+	Each archive is extracted into a temporary directory within the root and
+	validated, and only moved into place if it contains a valid object that
+	is not already in the root.  The ID of each object is printed as it is
+	unpacked.`,
+		ArgsUsage: "archive [archive ...]",
+
+		Action: func(c *cli.Context) error {
+			return unpackAction(c.Args())
+		},
+	}
+}
+
+func packAction(opts packOpts, args []string) error {
+	if len(args) < 2 || len(args) > 3 {
+		return usagef("expected an object, an optional version, and an archive")
+	}
+	id, archive := args[0], args[len(args)-1]
+
+	packOpts := fs.PackOptions{
+		Format: fs.ArchiveFormat(opts.format),
+		Prefix: opts.prefix,
+	}
+	if len(args) == 3 {
+		packOpts.Version = args[1]
+	}
+
+	var err error
+	switch {
+	case packOpts.Format != "":
+		if packOpts.Format != fs.ZipArchive && packOpts.Format != fs.TarArchive {
+			return usagef("unknown archive format %s", opts.format)
+		}
+	case archive == "-":
+		packOpts.Format = fs.TarArchive
+	default:
+		if packOpts.Format, err = fs.ArchiveFormatOf(archive); err != nil {
+			return usagef("%s", err)
+		}
+	}
+
+	ctx, cancel := interruptible()
+	defer cancel()
+
+	if archive == "-" {
+		return newFsDriver().Pack(ctx, id, os.Stdout, packOpts)
+	}
+
+	if _, err = os.Stat(archive); err == nil {
+		return fmt.Errorf("refusing to overwrite %s", archive)
+	}
+
+	w, err := fs.AtomicWrite(archive)
+	if err != nil {
+		return err
+	}
+
+	if err = newFsDriver().Pack(ctx, id, w, packOpts); err != nil {
+		_ = w.Rollback()
+		return err
+	}
+	return w.Close()
+}
+
+func unpackAction(archives []string) error {
+	if len(archives) == 0 {
+		return usagef("please provide at least one archive")
+	}
+
+	ctx, cancel := interruptible()
+	defer cancel()
+
+	driver := newFsDriver()
+	for _, archive := range archives {
+		id, err := driver.Unpack(ctx, archive)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s    %s\n", id, archive)
+	}
+	return nil
+}
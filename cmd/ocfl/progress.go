@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Displays progress on a single, periodically updated line of stderr.
+// Progress and other output may be delivered by different goroutines, hence the lock.
+type progressLine struct {
+	sync.Mutex
+	last  time.Time
+	shown bool
+}
+
+// Shows the given status, unless one was shown very recently
+func (p *progressLine) show(format string, args ...interface{}) {
+	p.Lock()
+	defer p.Unlock()
+	if time.Since(p.last) < 200*time.Millisecond {
+		return
+	}
+	p.last = time.Now()
+	p.shown = true
+	fmt.Fprintf(os.Stderr, "\r\033[K"+format, args...)
+}
+
+// Clears the progress line, if shown, so that other output may be printed
+func (p *progressLine) clear() {
+	if p == nil {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	if !p.shown {
+		return
+	}
+	p.shown = false
+	fmt.Fprint(os.Stderr, "\r\033[K")
+}
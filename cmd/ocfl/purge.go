@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/birkland/ocfl"
+	"github.com/urfave/cli"
+)
+
+type purgeOpts struct {
+	force         bool
+	tombstone     bool
+	commitMessage string
+}
+
+func purge() cli.Command {
+
+	opts := purgeOpts{}
+
+	return cli.Command{
+		Name:  "purge",
+		Usage: "Permanently remove an OCFL object",
+		Description: `Remove an OCFL object, and all of its versions, from the root
+
+	This cannot be undone.  Unless --force is given, purge asks for confirmation
+	before removing anything.  With --tombstone, a record of the object's removal
+	(who removed it, when, and why) is kept in the root, e.g.
+
+		ocfl purge --tombstone -m "Withdrawn at the depositor's request" test:obj
+	`,
+		ArgsUsage:    "object",
+		BashComplete: completeArgs(objectArg),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:        "force, f",
+				Usage:       "Remove the object without asking for confirmation",
+				Destination: &opts.force,
+			},
+			cli.BoolFlag{
+				Name:        "tombstone, t",
+				Usage:       "Record a tombstone noting the object's removal",
+				Destination: &opts.tombstone,
+			},
+			cli.StringFlag{
+				Name:        "message, m",
+				Usage:       "Reason for the removal, recorded in the tombstone (optional)",
+				Destination: &opts.commitMessage,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			return purgeAction(opts, c.Args())
+		},
+	}
+}
+
+func purgeAction(opts purgeOpts, args []string) error {
+	if len(args) != 1 {
+		return usagef("expected an object")
+	}
+	id := args[0]
+
+	if !opts.force && !confirm(fmt.Sprintf("Permanently remove %s and all of its versions?", id)) {
+		return fmt.Errorf("not removing %s", id)
+	}
+
+	err := newDriver().Purge(id, ocfl.PurgeOptions{
+		Tombstone: opts.tombstone,
+		Info: ocfl.CommitInfo{
+			Date:    time.Now(),
+			Message: opts.commitMessage,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed %s\n", id)
+	return nil
+}
+
+// Asks a yes or no question on stderr, reading the answer from stdin.  Anything
+// but a yes, including no answer at all, is a no.
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
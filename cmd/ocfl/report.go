@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+
+	"github.com/birkland/ocfl/report"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+type reportOpts struct {
+	format string
+	output string
+	head   bool
+}
+
+func reportCmd() cli.Command {
+
+	opts := reportOpts{}
+
+	return cli.Command{
+		Name:  "report",
+		Usage: "Produce a tabular report of files in OCFL objects",
+		Description: `Given an identifier of an OCFL entity (or none, for the entire root),
+	write a report with one row per logical file per version, containing the 
+	object ID, version, logical path, digest, size, and creation date.
+
+	For example, the following writes a CSV report of the head versions of 
+	all objects in the OCFL root to report.csv, and a Parquet report of every 
+	version to report.parquet
+
+	  ocfl report --head -o report.csv
+	  ocfl report -f parquet -o report.parquet`,
+		ArgsUsage:    "[ file | id ] ...",
+		BashComplete: completeObjects,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "format, f",
+				Usage:       "Report format {csv, parquet}",
+				Value:       "csv",
+				Destination: &opts.format,
+			},
+			cli.StringFlag{
+				Name:        "output, o",
+				Usage:       "Output file (default stdout)",
+				Destination: &opts.output,
+			},
+			cli.BoolFlag{
+				Name:        "head",
+				Usage:       "Report only the head version of each object",
+				Destination: &opts.head,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			return reportAction(opts, c.Args())
+		},
+	}
+}
+
+func reportAction(opts reportOpts, args []string) (err error) {
+	out := os.Stdout
+	if opts.output != "" {
+		out, err = os.Create(opts.output)
+		if err != nil {
+			return errors.Wrapf(err, "could not create report file")
+		}
+		defer func() {
+			if e := out.Close(); e != nil && err == nil {
+				err = e
+			}
+		}()
+	}
+
+	w, err := report.NewWriter(opts.format, out)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := interruptible()
+	defer cancel()
+
+	return report.Generate(ctx, newDriver(), w, report.Options{Head: opts.head}, args...)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli"
+)
+
+type rollbackOpts struct {
+	force  bool
+	dryRun bool
+}
+
+func rollback() cli.Command {
+
+	opts := rollbackOpts{}
+
+	return cli.Command{
+		Name:  "rollback",
+		Usage: "Remove the head version of an OCFL object",
+		Description: `Undo a mistaken commit by removing the head version of an OCFL object,
+	restoring the previous version as head.
+
+	The head version is described, along with the changes that rolling it back
+	would undo, and confirmation is asked for unless --force is given.  To only
+	show what would be rolled back:
+
+		ocfl rollback --dry-run test:obj
+
+	The only version of an object cannot be rolled back; use purge instead.
+	`,
+		ArgsUsage:    "object",
+		BashComplete: completeArgs(objectArg),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:        "force, f",
+				Usage:       "Roll back without asking for confirmation",
+				Destination: &opts.force,
+			},
+			cli.BoolFlag{
+				Name:        "dry-run, n",
+				Usage:       "Show what would be rolled back, without changing anything",
+				Destination: &opts.dryRun,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			return rollbackAction(opts, c.Args())
+		},
+	}
+}
+
+func rollbackAction(opts rollbackOpts, args []string) error {
+	if len(args) != 1 {
+		return usagef("expected an object")
+	}
+	id := args[0]
+
+	d := newFsDriver()
+	inv, err := readInventory(d, id)
+	if err != nil {
+		return err
+	}
+
+	versions := inv.VersionNames()
+	if len(versions) < 2 {
+		return fmt.Errorf("cannot roll back %s, it only has one version", id)
+	}
+	head, prev := inv.Head, versions[len(versions)-2]
+
+	v := inv.Versions[head]
+	fmt.Printf("%s %s, created %s by %s", id, head, v.Created.Format("2006-01-02 15:04:05"), v.User.Name)
+	if v.Message != "" {
+		fmt.Printf(": %s", v.Message)
+	}
+	fmt.Println()
+
+	// The changes rolling back would make, i.e. those of the head version, undone
+	changes, err := inv.Diff(head, prev)
+	if err != nil {
+		return err
+	}
+	printDiff(changes, false)
+
+	if opts.dryRun {
+		fmt.Printf("Dry run: would roll back %s to %s\n", id, prev)
+		return nil
+	}
+	if !opts.force && !confirm(fmt.Sprintf("Roll back %s to %s, removing %s?", id, prev, head)) {
+		return fmt.Errorf("not rolling back %s", id)
+	}
+
+	if err := d.Rollback(id); err != nil {
+		return err
+	}
+	fmt.Printf("Rolled back %s to %s\n", id, prev)
+	return nil
+}
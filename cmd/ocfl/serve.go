@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/birkland/ocfl/server"
+	"github.com/urfave/cli"
+)
+
+type serveOpts struct {
+	listen string
+}
+
+func serve() cli.Command {
+
+	opts := serveOpts{}
+
+	return cli.Command{
+		Name:  "serve",
+		Usage: "Serve read access to the objects of an OCFL root over HTTP",
+		Description: `Start an HTTP server exposing the objects, versions, inventories,
+	and file content of the OCFL root, so that it can be browsed and
+	fetched without shared filesystem access.  For example
+
+	  ocfl -r /path/to/root serve --listen :8080
+
+	Object IDs are given as single escaped path segments, and 'head'
+	may be used in place of the head version's name:
+
+	  GET /objects                                   IDs of all objects
+	  GET /objects/{id}                              Object and its versions
+	  GET /objects/{id}/inventory.json               Object inventory
+	  GET /objects/{id}/{version}                    Version and its files
+	  GET /objects/{id}/{version}/inventory.json     Version inventory
+	  GET /objects/{id}/{version}/content/{path}     File content
+
+	The server runs until interrupted.`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "listen, l",
+				Usage:       "Address to listen on",
+				Value:       "localhost:8080",
+				Destination: &opts.listen,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			return serveAction(opts)
+		},
+	}
+}
+
+func serveAction(opts serveOpts) error {
+	ctx, cancel := interruptible()
+	defer cancel()
+
+	return listenAndServe(ctx, opts.listen)
+}
+
+// Serves the OCFL root at the given address until the context is cancelled
+func listenAndServe(ctx context.Context, listen string) error {
+	srv := &http.Server{
+		Addr:              listen,
+		Handler:           server.New(newDriver(), server.Options{}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdown)
+	}()
+
+	slog.Info("serving", "root", mainOpts.root, "listen", listen)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/urfave/cli"
+)
+
+type sidecarOpts struct {
+	versions bool
+	workers  int
+	json     bool
+}
+
+func checkSidecars() cli.Command {
+
+	opts := sidecarOpts{}
+
+	return cli.Command{
+		Name:  "check-sidecars",
+		Usage: "Verify that inventories match their sidecar digests",
+		Description: `Quickly check that the inventories of OCFL objects are intact, by
+	comparing each to the digest in its sidecar.  No content is read, so this
+	completes far sooner than validate or fixity on large roots.
+
+	Given object IDs, only those objects are checked, otherwise every object
+	in the root is.  With --versions, the inventory of every version is 
+	checked too, e.g.
+
+	  ocfl check-sidecars --versions`,
+		ArgsUsage:    "[ id ...]",
+		BashComplete: completeObjects,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:        "versions",
+				Usage:       "Also check the inventory of every version",
+				Destination: &opts.versions,
+			},
+			cli.IntFlag{
+				Name:        "jobs, j",
+				Usage:       "Number of objects to check concurrently (default: number of CPUs)",
+				Destination: &opts.workers,
+			},
+			cli.BoolFlag{
+				Name:        "json",
+				Usage:       "Print reports as JSON",
+				Destination: &opts.json,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			return checkSidecarsAction(opts, c.Args())
+		},
+	}
+}
+
+func checkSidecarsAction(opts sidecarOpts, ids []string) error {
+	ctx, cancel := interruptible()
+	defer cancel()
+
+	sidecarOpts := fs.SidecarOptions{
+		Versions: opts.versions,
+		Workers:  jobs(opts.workers, 0),
+	}
+
+	failed := 0
+	enc := json.NewEncoder(os.Stdout)
+	err := newFsDriver().CheckSidecars(ctx, sidecarOpts, func(r *fs.SidecarReport) error {
+		if !r.Passed() {
+			failed++
+		}
+		if opts.json {
+			return enc.Encode(r)
+		}
+
+		status := "passed"
+		if !r.Passed() {
+			status = "FAILED"
+		}
+		fmt.Printf("%s    %s    %s    (%d inventories checked)\n", status, r.ID, r.Path, r.Checked)
+		for _, f := range r.Failures {
+			fmt.Printf("    %s\n", f)
+		}
+		return nil
+	}, ids...)
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return invalidf("%d objects have inventories that do not match their sidecars", failed)
+	}
+	return nil
+}
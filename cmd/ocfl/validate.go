@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/urfave/cli"
+)
+
+type validateOpts struct {
+	level    string
+	workers  int
+	json     bool
+	progress bool
+}
+
+func validate() cli.Command {
+
+	opts := validateOpts{}
+
+	return cli.Command{
+		Name:  "validate",
+		Usage: "Validate OCFL objects",
+		Description: `Validate the given OCFL objects, which may be given as object IDs, or as
+	paths to object roots.  Given the path of an OCFL root, or nothing at all,
+	the structure of the root, and every object in it, is validated.
+
+	Validation verifies inventories and their sidecars, and that the content
+	of each object is exactly that listed in its manifest.  At the default
+	'full' level, the digests of all content are verified as well, whereas
+	the 'structure' level does not read content at all.  For example, to
+	quickly check every object in the root, 8 at a time:
+
+	  ocfl validate --level structure --jobs 8
+
+	Each object is printed with its errors and warnings, or as JSON (one
+	object per line) with --json.  With --progress, the number of files
+	checked and bytes read so far are shown on stderr.  Validation may be
+	interrupted at any time.  Fails if any object is invalid.`,
+		ArgsUsage:    "[ id | path | root ...]",
+		BashComplete: completeObjects,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "level",
+				Usage:       "Validation level: full, or structure",
+				Value:       "full",
+				Destination: &opts.level,
+			},
+			cli.IntFlag{
+				Name:        "jobs, j",
+				Usage:       "Number of objects to validate concurrently (default: number of CPUs)",
+				Destination: &opts.workers,
+			},
+			cli.BoolFlag{
+				Name:        "json",
+				Usage:       "Print reports as JSON",
+				Destination: &opts.json,
+			},
+			cli.BoolFlag{
+				Name:        "progress",
+				Usage:       "Show progress on stderr",
+				Destination: &opts.progress,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			return validateAction(opts, c.Args())
+		},
+	}
+}
+
+func validateAction(opts validateOpts, args []string) error {
+	ctx, cancel := interruptible()
+	defer cancel()
+
+	validateOpts := fs.ValidateOptions{Workers: jobs(opts.workers, 0)}
+	switch opts.level {
+	case "full":
+		validateOpts.Level = fs.Full
+	case "structure":
+		validateOpts.Level = fs.Structure
+	default:
+		return usagef("unknown validation level '%s'", opts.level)
+	}
+
+	var status *progressLine
+	if opts.progress {
+		status = &progressLine{}
+		validateOpts.Progress = status.update
+		defer status.clear()
+	}
+
+	invalid := 0
+	collect := func(report *fs.ValidationReport) error {
+		if !report.Valid() {
+			invalid++
+		}
+		status.clear()
+		return printReport(report, opts.json)
+	}
+
+	if len(args) == 0 {
+		args = []string{root(mainOpts.root)}
+	}
+
+	for _, arg := range args {
+		if err := validateArg(ctx, arg, validateOpts, collect); err != nil {
+			return err
+		}
+	}
+
+	if invalid > 0 {
+		return invalidf("found errors in %d objects or roots", invalid)
+	}
+	return nil
+}
+
+// Validates an OCFL root or object root if given a path to one, otherwise
+// the object with the given ID
+func validateArg(ctx context.Context, arg string, opts fs.ValidateOptions, cb func(*fs.ValidationReport) error) error {
+	if info, err := os.Stat(arg); err == nil && info.IsDir() {
+		if dir, err := fs.LocateRoot(arg); err == nil && dir == arg {
+			report, err := fs.ValidateStorageRoot(ctx, dir)
+			if err != nil {
+				return err
+			}
+			if err := cb(report); err != nil {
+				return err
+			}
+
+			mainOpts.root = dir
+			return newFsDriver().ValidateRoot(ctx, opts, cb)
+		}
+
+		report, err := fs.ValidatePath(ctx, arg, opts)
+		if err == nil {
+			return cb(report)
+		}
+	}
+
+	report, err := newFsDriver().Validate(ctx, arg, opts)
+	if err != nil {
+		return err
+	}
+	return cb(report)
+}
+
+func printReport(report *fs.ValidationReport, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(os.Stdout).Encode(report)
+	}
+
+	status := "valid"
+	if !report.Valid() {
+		status = "INVALID"
+	}
+	fmt.Printf("%s    %s    %s\n", status, report.ID, report.Path)
+	for _, r := range report.Results {
+		fmt.Printf("    %s\n", r)
+	}
+	return nil
+}
+
+// Shows validation progress on the status line
+func (p *progressLine) update(progress fs.ValidationProgress) {
+	p.show("%d files, %d MiB checked: %s", progress.FilesChecked, progress.BytesHashed>>20, progress.Path)
+}
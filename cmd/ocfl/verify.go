@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/signature"
+	"github.com/urfave/cli"
+)
+
+type verifyOpts struct {
+	key string
+}
+
+func verifySignature() cli.Command {
+
+	opts := verifyOpts{}
+
+	return cli.Command{
+		Name:  "verify-signature",
+		Usage: "Verify the signature of an OCFL object version",
+		Description: `Given an OCFL object ID, and optionally a version (default head), verify
+	the detached signature of that version's inventory using the given public key.
+
+	Versions are signed when committed with a --signing-key, e.g.
+
+	  ocfl --signing-key key.pem cp file.txt test:obj
+	  ocfl verify-signature -k key.pub.pem test:obj`,
+		ArgsUsage:    "id [ version ]",
+		BashComplete: completeArgs(objectArg, versionArg),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:        "key, k",
+				Usage:       "PEM encoded ed25519 public key",
+				Destination: &opts.key,
+			},
+		},
+
+		Action: func(c *cli.Context) error {
+			return verifyAction(opts, c.Args())
+		},
+	}
+}
+
+func verifyAction(opts verifyOpts, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return usagef("verify-signature takes an object ID, and an optional version")
+	}
+
+	if opts.key == "" {
+		return usagef("a public key (-k) is required")
+	}
+
+	verifier, err := signature.LoadVerifier(opts.key)
+	if err != nil {
+		return err
+	}
+
+	version, label := ocfl.HEAD, "head"
+	if len(args) == 2 {
+		version, label = args[1], args[1]
+	}
+
+	err = newFsDriver().VerifySignature(args[0], version, verifier)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s %s: signature OK\n", args[0], label)
+	return nil
+}
@@ -0,0 +1,8 @@
+// Package chaos contains an OCFL driver that wraps another driver, injecting
+// failures and latency into its operations.
+//
+// It is intended for resilience testing, i.e. verifying that applications (and
+// drivers' own commit and rollback paths) behave sanely when storage is flaky.
+// Failures may also be injected beneath a filesystem driver, as it writes and
+// renames files (see FileFaults), so that puts and commits fail part way through.
+package chaos
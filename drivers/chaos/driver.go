@@ -0,0 +1,261 @@
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+)
+
+// Op names an operation that failures may be injected into
+type Op string
+
+// Operations subject to failure injection
+const (
+	Open   Op = "open"
+	Put    Op = "put"
+	Delete Op = "delete"
+	Move   Op = "move"
+	Commit Op = "commit"
+	Walk   Op = "walk"
+
+	// Filesystem operations, subject to failure only through FileFaults
+	Write  Op = Op(fs.FileWrite)
+	Rename Op = Op(fs.FileRename)
+)
+
+// ErrInjected is the cause of failures injected by the default failure policy
+var ErrInjected = fmt.Errorf("injected failure")
+
+// Injector decides whether a given operation should fail.  It returns a non-nil
+// error in order to make the operation fail with that error.  The target is the
+// object ID (open), logical path (put, delete), commit message (commit), location
+// or entity ID (walk), or file path (write, rename) of the operation.
+type Injector func(op Op, target string) error
+
+// Config configures failure injection.
+//
+// By default, every operation in Ops fails with probability Rate, with ErrInjected.
+// A custom Injector may be provided to replace this policy entirely.
+type Config struct {
+	Rate     float64       // Probability, from 0 to 1, that an operation fails
+	Ops      []Op          // Operations subject to failure.  If empty, all operations are.
+	Latency  time.Duration // Delay added to every operation
+	Partial  bool          // If true, failed Puts write part of their content before failing
+	Seed     int64         // Random seed, for reproducible failures
+	Injector Injector      // Custom failure policy, overrides Rate and Ops
+}
+
+// Driver wraps an ocfl.Driver, injecting failures according to its configuration
+type Driver struct {
+	ocfl.Driver
+	*policy
+}
+
+// NewDriver wraps the given driver with one that injects failures
+func NewDriver(d ocfl.Driver, cfg Config) *Driver {
+	return &Driver{
+		Driver: d,
+		policy: newPolicy(cfg),
+	}
+}
+
+// FileFaults injects failures into the filesystem operations of an fs.Driver
+// configured with it (see fs.Config), e.g.
+//
+//	fs.NewDriver(fs.Config{Root: root, Faults: chaos.FileFaults(chaos.Config{Rate: 0.1})})
+//
+// Unlike failures injected by a Driver around the operations of a session, these
+// occur part way through them: as content or inventories are written, or renamed
+// into place, so that a commit may fail after its version inventory is written,
+// but before it is promoted to the object root.  Of the operations in Ops, only
+// Write and Rename apply.  Partial has no effect, as writes are partial by nature.
+func FileFaults(cfg Config) fs.FaultInjector {
+	p := newPolicy(cfg)
+	return func(op fs.FileOp, path string) error {
+		return p.inject(context.Background(), Op(op), path)
+	}
+}
+
+// policy decides, according to a configuration, which operations fail
+type policy struct {
+	cfg    Config
+	mutex  sync.Mutex
+	random *rand.Rand
+}
+
+func newPolicy(cfg Config) *policy {
+	return &policy{
+		cfg:    cfg,
+		random: rand.New(rand.NewSource(cfg.Seed)),
+	}
+}
+
+// inject sleeps for the configured latency (or until the context is done), and
+// then consults the failure policy
+func (d *policy) inject(ctx context.Context, op Op, target string) error {
+	if d.cfg.Latency > 0 {
+		select {
+		case <-time.After(d.cfg.Latency):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if d.cfg.Injector != nil {
+		return d.cfg.Injector(op, target)
+	}
+
+	if !d.subject(op) {
+		return nil
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.random.Float64() < d.cfg.Rate {
+		return ErrInjected
+	}
+	return nil
+}
+
+func (d *policy) subject(op Op) bool {
+	if len(d.cfg.Ops) == 0 {
+		return true
+	}
+
+	for _, o := range d.cfg.Ops {
+		if o == op {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Walk walks the underlying driver, unless a failure is injected
+func (d *Driver) Walk(desired ocfl.Select, cb func(ocfl.EntityRef) error, loc ...string) error {
+	return d.WalkContext(context.Background(), desired, cb, loc...)
+}
+
+// WalkContext walks the underlying driver, unless a failure is injected.  Failures may be
+// injected before the walk, or in between entities visited by the walk.
+func (d *Driver) WalkContext(ctx context.Context, desired ocfl.Select, cb func(ocfl.EntityRef) error, loc ...string) error {
+	var target string
+	if len(loc) > 0 {
+		target = loc[0]
+	}
+
+	if err := d.inject(ctx, Walk, target); err != nil {
+		return err
+	}
+
+	return d.Driver.WalkContext(ctx, desired, func(ref ocfl.EntityRef) error {
+		if err := d.inject(ctx, Walk, ref.ID); err != nil {
+			return err
+		}
+		return cb(ref)
+	}, loc...)
+}
+
+// Open opens a session on the underlying driver, unless a failure is injected
+func (d *Driver) Open(id string, opts ocfl.Options) (ocfl.Session, error) {
+	return d.OpenContext(context.Background(), id, opts)
+}
+
+// OpenContext opens a session on the underlying driver, unless a failure is injected
+func (d *Driver) OpenContext(ctx context.Context, id string, opts ocfl.Options) (ocfl.Session, error) {
+	if err := d.inject(ctx, Open, id); err != nil {
+		return nil, err
+	}
+
+	s, err := d.Driver.OpenContext(ctx, id, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session{Session: s, driver: d}, nil
+}
+
+type session struct {
+	ocfl.Session
+	driver *Driver
+}
+
+func (s *session) Put(lpath string, r io.Reader) error {
+	return s.PutContext(context.Background(), lpath, r)
+}
+
+// PutContext injects a failure either before the put, or if partial writes are configured,
+// part way through reading its content.
+func (s *session) PutContext(ctx context.Context, lpath string, r io.Reader) error {
+	err := s.driver.inject(ctx, Put, lpath)
+	if err != nil && !s.driver.cfg.Partial {
+		return err
+	}
+
+	if err != nil {
+		r = &failingReader{Reader: r, err: err, remaining: 1 + s.driver.intn(4096)}
+	}
+
+	return s.Session.PutContext(ctx, lpath, r)
+}
+
+func (s *session) Delete(lpath string) error {
+	if err := s.driver.inject(context.Background(), Delete, lpath); err != nil {
+		return err
+	}
+	return s.Session.Delete(lpath)
+}
+
+func (s *session) Move(src, dest string) error {
+	if err := s.driver.inject(context.Background(), Move, src); err != nil {
+		return err
+	}
+	return s.Session.Move(src, dest)
+}
+
+func (s *session) Commit(commit ocfl.CommitInfo) error {
+	return s.CommitContext(context.Background(), commit)
+}
+
+func (s *session) CommitContext(ctx context.Context, commit ocfl.CommitInfo) error {
+	if err := s.driver.inject(ctx, Commit, commit.Message); err != nil {
+		return err
+	}
+	return s.Session.CommitContext(ctx, commit)
+}
+
+func (d *policy) intn(n int) int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.random.Intn(n)
+}
+
+// failingReader reads a limited number of bytes before failing with an error
+type failingReader struct {
+	io.Reader
+	err       error
+	remaining int
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	if f.remaining <= 0 {
+		return 0, f.err
+	}
+
+	if len(p) > f.remaining {
+		p = p[:f.remaining]
+	}
+
+	n, err := f.Reader.Read(p)
+	f.remaining -= n
+	if err == io.EOF {
+		return n, f.err
+	}
+	return n, err
+}
@@ -0,0 +1,208 @@
+package chaos_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/chaos"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/fspath"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+)
+
+const objectID = "urn:test/chaos"
+
+func TestAlwaysFail(t *testing.T) {
+	runWithDriver(t, func(d ocfl.Driver) {
+		c := chaos.NewDriver(d, chaos.Config{Rate: 1, Ops: []chaos.Op{chaos.Open}})
+
+		_, err := c.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		if errors.Cause(err) != chaos.ErrInjected {
+			t.Errorf("expected injected error, got %+v", err)
+		}
+
+		// Walks are not subject to failure
+		err = c.Walk(ocfl.Select{}, func(ocfl.EntityRef) error { return nil })
+		if err != nil {
+			t.Errorf("walk should not have failed %+v", err)
+		}
+	})
+}
+
+func TestPartialPut(t *testing.T) {
+	runWithDriver(t, func(d ocfl.Driver) {
+		c := chaos.NewDriver(d, chaos.Config{
+			Injector: func(op chaos.Op, target string) error {
+				if op == chaos.Put && target == "bad" {
+					return chaos.ErrInjected
+				}
+				return nil
+			},
+			Partial: true,
+		})
+
+		session, err := c.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		if err != nil {
+			t.Fatalf("could not open session %+v", err)
+		}
+
+		if err = session.Put("good", strings.NewReader("good content")); err != nil {
+			t.Fatalf("put should have succeeded %+v", err)
+		}
+
+		err = session.Put("bad", strings.NewReader(strings.Repeat("bad content", 1000)))
+		if errors.Cause(err) != chaos.ErrInjected {
+			t.Fatalf("expected injected error, got %+v", err)
+		}
+
+		if err = session.Commit(ocfl.CommitInfo{}); err != nil {
+			t.Fatalf("commit failed %+v", err)
+		}
+
+		var files []string
+		_ = d.Walk(ocfl.Select{Type: ocfl.File}, func(ref ocfl.EntityRef) error {
+			files = append(files, ref.ID)
+			_, err := os.Stat(ref.Addr)
+			if err != nil {
+				t.Errorf("file %s is missing its content", ref.ID)
+			}
+			return nil
+		}, objectID)
+
+		if len(files) != 1 || files[0] != "good" {
+			t.Errorf("expected only the good file to be committed, got %s", files)
+		}
+	})
+}
+
+func TestFileFaultsCommit(t *testing.T) {
+	var failing bool
+	var d *fs.Driver
+	faults := chaos.FileFaults(chaos.Config{
+		Injector: func(op chaos.Op, target string) error {
+			root, _ := d.ObjectPath(objectID)
+			if failing && op == chaos.Rename && target == filepath.Join(root, metadata.InventoryFile) {
+				return chaos.ErrInjected
+			}
+			return nil
+		},
+	})
+
+	runWithFsDriver(t, fs.Config{Faults: faults}, func(driver *fs.Driver) {
+		d = driver
+		put(t, d, "a", "a's content")
+
+		// The version inventory is written, but never promoted to the object root
+		failing = true
+		session, _ := d.Open(objectID, ocfl.Options{Version: ocfl.NEW})
+		_ = session.Put("b", strings.NewReader("b's content"))
+		if err := session.Commit(ocfl.CommitInfo{}); errors.Cause(err) != chaos.ErrInjected {
+			t.Fatalf("expected injected error, got %+v", err)
+		}
+
+		inv := readInventory(t, d)
+		if inv.Head != "v1" {
+			t.Errorf("expected the object to remain at v1, got %s", inv.Head)
+		}
+		assertValid(t, d)
+
+		failing = false
+		put(t, d, "b", "b's content")
+		if inv = readInventory(t, d); inv.Head != "v2" {
+			t.Errorf("expected a new v2, got %s", inv.Head)
+		}
+		assertValid(t, d)
+	})
+}
+
+func TestFileFaultsPut(t *testing.T) {
+	for _, op := range []chaos.Op{chaos.Write, chaos.Rename} {
+		op := op
+		t.Run(string(op), func(t *testing.T) {
+			faults := chaos.FileFaults(chaos.Config{
+				Injector: func(o chaos.Op, target string) error {
+					if o == op && filepath.Base(target) == "bad" {
+						return chaos.ErrInjected
+					}
+					return nil
+				},
+			})
+
+			runWithFsDriver(t, fs.Config{Faults: faults}, func(d *fs.Driver) {
+				session, _ := d.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+				_ = session.Put("good", strings.NewReader("good content"))
+				if err := session.Put("bad", strings.NewReader("bad content")); errors.Cause(err) != chaos.ErrInjected {
+					t.Fatalf("expected injected error, got %+v", err)
+				}
+				if err := session.Commit(ocfl.CommitInfo{}); err != nil {
+					t.Fatalf("commit failed %+v", err)
+				}
+
+				// Nothing of the failed put remains in the object
+				assertValid(t, d)
+			})
+		})
+	}
+}
+
+func put(t *testing.T, d *fs.Driver, lpath, content string) {
+	session, _ := d.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+	_ = session.Put(lpath, strings.NewReader(content))
+	if err := session.Commit(ocfl.CommitInfo{}); err != nil {
+		t.Fatalf("commit failed %+v", err)
+	}
+}
+
+func readInventory(t *testing.T, d *fs.Driver) *metadata.Inventory {
+	root, _ := d.ObjectPath(objectID)
+	inv, err := fs.ReadInventory(root)
+	if err != nil {
+		t.Fatalf("could not read inventory %+v", err)
+	}
+	return inv
+}
+
+func assertValid(t *testing.T, d *fs.Driver) {
+	root, _ := d.ObjectPath(objectID)
+	report, err := fs.ValidatePath(context.Background(), root, fs.ValidateOptions{})
+	if err != nil {
+		t.Fatalf("validation failed %+v", err)
+	}
+	if !report.Valid() {
+		t.Errorf("object is invalid: %v", report.Results)
+	}
+}
+
+func runWithDriver(t *testing.T, f func(ocfl.Driver)) {
+	runWithFsDriver(t, fs.Config{}, func(d *fs.Driver) { f(d) })
+}
+
+// Runs with a filesystem driver with the given configuration, in a new root
+func runWithFsDriver(t *testing.T, cfg fs.Config, f func(*fs.Driver)) {
+	dir, err := ioutil.TempDir("", "chaos")
+	if err != nil {
+		t.Fatalf("could not create temp dir %+v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err = fs.MkRoot(dir); err != nil {
+		t.Fatalf("could not create root %+v", err)
+	}
+
+	cfg.Root = dir
+	cfg.ObjectPaths = fspath.GeneratorFunc(url.QueryEscape)
+	cfg.FilePaths = fspath.GeneratorFunc(fs.Passthrough)
+	d, err := fs.NewDriver(cfg)
+	if err != nil {
+		t.Fatalf("could not create driver %+v", err)
+	}
+
+	f(d)
+}
@@ -0,0 +1,264 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+)
+
+// Adopt converts a plain directory of files into a new object with the given ID.  The
+// object has a single version containing every file in the directory, at logical paths
+// relative to it, committed with the given commit info.
+//
+// Rather than being copied, the directory itself is moved to become the content directory
+// of the first version, so it must be on the same filesystem as the OCFL root.  It may
+// already be where the object's directory would be, in which case it is converted in place.
+// Every file is hashed before anything is moved, and the object is assembled in a temporary
+// directory next to its final location, so a failure leaves the directory where it was.
+// Symbolic links are not followed, and are refused, as are directories that are, contain,
+// or are within OCFL objects or roots.  If the driver locks objects, the new object's lock
+// is held throughout.
+func (d *Driver) Adopt(ctx context.Context, dir, id string, commit ocfl.CommitInfo) (err error) {
+	if d.root == nil {
+		return fmt.Errorf("cannot adopt %s: please define an OCFL root", dir)
+	}
+	if d.cfg.ObjectPaths == nil {
+		return fmt.Errorf("no object path generation function given!  (check driver config)")
+	}
+
+	id = d.normalizeID(id)
+
+	unlock, err := d.lockObject(id)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := unlock(); err == nil {
+			err = e
+		}
+	}()
+
+	src, err := absPath(dir)
+	if err != nil {
+		return errors.Wrapf(err, "could not calculate absolute path of %s", dir)
+	}
+	if info, err := os.Stat(src); err != nil {
+		return errors.Wrapf(err, "cannot adopt %s", dir)
+	} else if !info.IsDir() {
+		return fmt.Errorf("cannot adopt %s: not a directory", dir)
+	}
+	if rel, err := filepath.Rel(src, d.root.Addr); err == nil && !strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("cannot adopt %s: it contains the OCFL root", dir)
+	}
+	if err = checkNotInObject(src); err != nil {
+		return errors.Wrapf(err, "cannot adopt %s", dir)
+	}
+
+	objDir, err := d.adoptedObjectDir(src, id)
+	if err != nil {
+		return err
+	}
+
+	inv, err := d.adoptedInventory(ctx, src, id, commit)
+	if err != nil {
+		return errors.Wrapf(err, "cannot adopt %s", dir)
+	}
+
+	err = os.MkdirAll(filepath.Dir(objDir), dirPermission)
+	if err != nil {
+		return errors.Wrapf(err, "could not create parent directory of %s", objDir)
+	}
+
+	staging := filepath.Join(filepath.Dir(objDir), AtomicPrefix+"adopt."+filepath.Base(objDir))
+	versionDir := filepath.Join(staging, inv.Head)
+	contentDir := filepath.Join(versionDir, inv.ContentDirectory())
+	if err = os.MkdirAll(versionDir, dirPermission); err != nil {
+		return errors.Wrapf(err, "could not create staging directory %s", staging)
+	}
+
+	err = os.Rename(src, contentDir)
+	if err != nil {
+		_ = os.RemoveAll(staging)
+		return errors.Wrapf(err, "could not move %s into %s (it must be on the same filesystem as the OCFL root; copy it with cp otherwise)", dir, id)
+	}
+
+	// From here on, a failure puts the directory back where it was
+	if err = stageAdopted(staging, contentDir, inv); err == nil {
+		// The object directory may be an empty directory, which can't be renamed over
+		_ = os.Remove(objDir)
+		err = os.Rename(staging, objDir)
+		d.invalidateID(id)
+	}
+	if err != nil {
+		if restoreErr := os.Rename(contentDir, src); restoreErr != nil {
+			return errors.Wrapf(err, "could not adopt %s, and could not move it back from %s", dir, contentDir)
+		}
+		_ = os.RemoveAll(staging)
+		return errors.Wrapf(err, "could not adopt %s", dir)
+	}
+
+	return nil
+}
+
+// Determines the directory of the object adopting the given source directory.  Unless
+// that is the source directory itself, it must not already exist.
+func (d *Driver) adoptedObjectDir(src, id string) (string, error) {
+	objDir, err := d.ObjectPath(id)
+	if err != nil {
+		return "", err
+	}
+	if objDir, err = absPath(objDir); err != nil {
+		return "", errors.Wrapf(err, "could not calculate absolute path of object dir for %s", id)
+	}
+
+	if objDir != src {
+		return d.newObjectDir(id)
+	}
+
+	obj, _, err := d.readObject(context.Background(), id)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not read object %s", id)
+	}
+	if obj != nil {
+		return "", fmt.Errorf("object already exists: %s", id)
+	}
+	return objDir, nil
+}
+
+// Declarations of OCFL objects and roots, of any version, begin with this
+const ocflNamastePrefix = "0=ocfl_"
+
+// Verifies that neither a directory nor any of its ancestors is an OCFL object
+func checkNotInObject(dir string) error {
+	for {
+		isObject, _, err := isRoot(dir, ocfl.Object)
+		if err != nil {
+			return errors.Wrapf(err, "could not determine whether %s is an OCFL object", dir)
+		}
+		if isObject {
+			return fmt.Errorf("%s is an OCFL object", dir)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+// Hashes every file in the source directory, and creates an inventory with a single
+// version containing them, at physical paths that mirror the directory.
+func (d *Driver) adoptedInventory(ctx context.Context, src, id string, commit ocfl.CommitInfo) (*metadata.Inventory, error) {
+	inv, err := metadata.NewPaddedInventory(id, d.cfg.VersionPadding)
+	if err != nil {
+		return nil, err
+	}
+	inv.TimestampPrecision = d.cfg.TimestampPrecision
+
+	commit, err = commit.WithDefaults(d.cfg.Identity)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not determine commit identity of %s", id)
+	}
+	v := inv.Versions[inv.Head]
+	v.Created = d.cfg.TimestampPrecision.Truncate(commit.Date)
+	v.Message = commit.Message
+	v.User = metadata.User{
+		Name:    commit.Name,
+		Address: commit.Address,
+	}
+	inv.Versions[inv.Head] = v
+
+	contentDir := filepath.ToSlash(filepath.Join(inv.Head, inv.ContentDirectory()))
+	err = WalkFiles(src, RejectSymlinks, func(path string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		lpath := filepath.ToSlash(rel)
+		if strings.HasPrefix(filepath.Base(path), ocflNamastePrefix) {
+			return fmt.Errorf("%s declares an OCFL object or root, which cannot be adopted", lpath)
+		}
+		if d.cfg.FilePaths != nil && d.cfg.FilePaths.Generate(lpath) != lpath {
+			return fmt.Errorf("the file path configuration would not store %s at its logical path", lpath)
+		}
+		if d.portable() {
+			if err := checkPortable(lpath); err != nil {
+				return err
+			}
+		}
+
+		digest, err := fileDigest(path, metadata.DigestAlgorithm(inv.DigestAlgorithm))
+		if err != nil {
+			return errors.Wrapf(err, "could not hash %s", path)
+		}
+		return inv.PutFile(lpath, contentDir+"/"+lpath, metadata.Digest(digest))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(inv.Manifest) == 0 {
+		return nil, fmt.Errorf("no files to adopt")
+	}
+	return inv, nil
+}
+
+// Completes a staged object whose content directory is in place, by removing empty
+// directories from its content, and writing its inventories and namaste file.
+func stageAdopted(staging, contentDir string, inv *metadata.Inventory) error {
+	if err := removeEmptyDirs(contentDir); err != nil {
+		return err
+	}
+
+	versionDir := filepath.Join(staging, inv.Head)
+	if err := writeInventory(inventoryAt(inv, inv.Head), versionDir); err != nil {
+		return err
+	}
+	if err := copyInventoryFiles(versionDir, staging); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(staging, ocflObjectRoot), []byte(objectRootNamasteContent), filePermission)
+}
+
+// Removes every empty directory beneath (but not including) the given directory
+func removeEmptyDirs(dir string) error {
+	var dirs []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() && path != dir {
+			dirs = append(dirs, path)
+		}
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not walk %s", dir)
+	}
+
+	// Deepest first, so directories containing only empty directories are removed too
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, d := range dirs {
+		entries, err := ioutil.ReadDir(d)
+		if err != nil {
+			return errors.Wrapf(err, "could not read directory %s", d)
+		}
+		if len(entries) == 0 {
+			if err = os.Remove(d); err != nil {
+				return errors.Wrapf(err, "could not remove empty directory %s", d)
+			}
+		}
+	}
+	return nil
+}
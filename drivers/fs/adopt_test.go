@@ -0,0 +1,155 @@
+package fs_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/fspath"
+	"github.com/go-test/deep"
+)
+
+func TestAdopt(t *testing.T) {
+	files := map[string]string{
+		"a":          "a",
+		"dir/b":      "b",
+		"dir/sub/c":  "a",
+		"other/d.tx": "d",
+	}
+
+	populate := func(t *testing.T, dir string) {
+		for name, content := range files {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			_ = os.MkdirAll(filepath.Dir(path), 0755)
+			if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		_ = os.MkdirAll(filepath.Join(dir, "empty", "nested"), 0755)
+	}
+
+	verify := func(t *testing.T, driver driverWrapper, d *fs.Driver) {
+		report, err := d.Validate(context.Background(), objectID, fs.ValidateOptions{})
+		if err != nil {
+			t.Fatalf("validation failed %+v", err)
+		}
+		if !report.Valid() {
+			t.Errorf("adopted object should be valid: %v", report.Results)
+		}
+
+		found := make(map[string]string)
+		driver.Walk(ocfl.Select{Type: ocfl.File, Head: true}, func(ref ocfl.EntityRef) error {
+			content, _ := ioutil.ReadFile(ref.Addr)
+			found[ref.ID] = string(content)
+			return nil
+		}, objectID)
+		if diff := deep.Equal(found, files); diff != nil {
+			t.Error(diff)
+		}
+
+		path, _ := d.ObjectPath(objectID)
+		inv, _ := fs.ReadInventory(path)
+		if msg := inv.Versions[inv.Head].Message; msg != "adopted" {
+			t.Errorf("wrong commit message %q", msg)
+		}
+	}
+
+	t.Run("elsewhere", func(t *testing.T) {
+		runWithDriverWrapper(t, func(driver driverWrapper) {
+			d := driver.driver.(*fs.Driver)
+			src := filepath.Join(filepath.Dir(driver.root), filepath.Base(driver.root)+"_adopted")
+			defer os.RemoveAll(src)
+			populate(t, src)
+
+			if err := d.Adopt(context.Background(), src, objectID, ocfl.CommitInfo{Message: "adopted"}); err != nil {
+				t.Fatalf("adopt failed %+v", err)
+			}
+			if _, err := os.Stat(src); !os.IsNotExist(err) {
+				t.Errorf("adopted directory should have been moved")
+			}
+			verify(t, driver, d)
+
+			populate(t, src)
+			if err := d.Adopt(context.Background(), src, objectID, ocfl.CommitInfo{}); err == nil {
+				t.Errorf("adopting as an existing object should fail")
+			}
+			if _, err := os.Stat(filepath.Join(src, "a")); err != nil {
+				t.Errorf("a failed adoption should leave the directory in place")
+			}
+		})
+	})
+
+	t.Run("inPlace", func(t *testing.T) {
+		runWithDriverWrapper(t, func(driver driverWrapper) {
+			d := driver.driver.(*fs.Driver)
+			path, _ := d.ObjectPath(objectID)
+			populate(t, path)
+
+			if err := d.Adopt(context.Background(), path, objectID, ocfl.CommitInfo{Message: "adopted"}); err != nil {
+				t.Fatalf("adopt failed %+v", err)
+			}
+			verify(t, driver, d)
+		})
+	})
+
+	t.Run("symlinks", func(t *testing.T) {
+		runWithDriverWrapper(t, func(driver driverWrapper) {
+			d := driver.driver.(*fs.Driver)
+			path, _ := d.ObjectPath(objectID)
+			populate(t, path)
+			if err := os.Symlink(filepath.Join(path, "a"), filepath.Join(path, "link")); err != nil {
+				t.Skip("symlinks not supported")
+			}
+
+			if err := d.Adopt(context.Background(), path, objectID, ocfl.CommitInfo{}); err == nil {
+				t.Errorf("adopting a directory containing symlinks should fail")
+			}
+			if _, err := os.Stat(filepath.Join(path, "dir", "b")); err != nil {
+				t.Errorf("a failed adoption should leave the directory in place")
+			}
+		})
+	})
+
+	t.Run("objects", func(t *testing.T) {
+		runWithDriverWrapper(t, func(driver driverWrapper) {
+			d := driver.driver.(*fs.Driver)
+			session := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+			session.Put("dir/a", strings.NewReader("a"))
+			session.Commit(ocfl.CommitInfo{})
+			path, _ := d.ObjectPath(objectID)
+
+			// Containing the object
+			outer := filepath.Join(filepath.Dir(driver.root), filepath.Base(driver.root)+"_outer")
+			defer os.RemoveAll(outer)
+			populate(t, outer)
+			_ = ioutil.WriteFile(filepath.Join(outer, "dir", "0=ocfl_object_1.0"), []byte("ocfl_object_1.0\n"), 0644)
+
+			for _, dir := range []string{path, filepath.Join(path, "v1", "content", "dir"), outer} {
+				if err := d.Adopt(context.Background(), dir, "new:obj", ocfl.CommitInfo{}); err == nil {
+					t.Errorf("adopting %s should fail", dir)
+				}
+			}
+
+			report, err := d.Validate(context.Background(), objectID, fs.ValidateOptions{})
+			if err != nil || !report.Valid() {
+				t.Errorf("the existing object should be untouched: %v %+v", report, err)
+			}
+		})
+	})
+
+	t.Run("noRoot", func(t *testing.T) {
+		d, _ := fs.NewDriver(fs.Config{ObjectPaths: fspath.GeneratorFunc(url.QueryEscape)})
+		if err := d.Adopt(context.Background(), os.TempDir(), objectID, ocfl.CommitInfo{}); err == nil {
+			t.Errorf("adopting without a root should fail")
+		}
+		if _, err := d.Unpack(context.Background(), "obj.zip"); err == nil {
+			t.Errorf("unpacking without a root should fail")
+		}
+	})
+}
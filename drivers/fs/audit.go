@@ -0,0 +1,193 @@
+package fs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+)
+
+// AuditLog is the file, relative to an object root, in which the results of
+// fixity audits of the object are recorded, one JSON record per line
+const AuditLog = "logs/fixity-audit.jsonl"
+
+// AuditRecord is the result of a fixity audit of an object
+type AuditRecord struct {
+	ID         string                     `json:"id"`
+	Path       string                     `json:"-"` // Path of the object root
+	Time       time.Time                  `json:"time"`
+	Head       string                     `json:"head"`       // Head version at the time of the audit
+	Algorithms []metadata.DigestAlgorithm `json:"algorithms"` // Algorithms of the digests that were verified
+	Passed     bool                       `json:"passed"`
+	Failures   metadata.ValidationResults `json:"failures,omitempty"`
+}
+
+// AuditOptions configure fixity audits
+type AuditOptions struct {
+	Since   time.Time // If given, objects last audited at or after this time are skipped
+	Workers int       // Number of objects audited concurrently; defaults to the number of CPUs
+	DryRun  bool      // If true, results are not recorded in the objects' audit logs
+}
+
+// Audit performs a deep fixity check (i.e. a Full validation) of the objects
+// with the given IDs, or every object in the OCFL root if none are given, and
+// records the result of each in the object's AuditLog.  The callback is invoked
+// with each result as soon as it is recorded, in no particular order.
+//
+// Objects audited at or after opts.Since are skipped, so periodic audits can
+// be performed by giving the time of the previous one minus the desired interval,
+// and an interrupted audit resumes by giving the time it began.
+func (d *Driver) Audit(ctx context.Context, opts AuditOptions, cb func(*AuditRecord) error, ids ...string) error {
+	if d.root == nil {
+		return fmt.Errorf("cannot audit: please define an OCFL root")
+	}
+
+	produce := d.sendObjectRoots
+	if len(ids) > 0 {
+		produce = d.sendObjects(ids)
+	}
+
+	return visitObjects(ctx, opts.Workers, produce, func(ctx context.Context, objRoot string) (*AuditRecord, bool, error) {
+		if !opts.Since.IsZero() {
+			last, err := lastAudit(objRoot)
+			if err != nil {
+				return nil, false, err
+			}
+			if last != nil && !last.Time.Before(opts.Since) {
+				return nil, false, nil
+			}
+		}
+
+		record, err := auditObject(ctx, objRoot)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if !opts.DryRun {
+			if err := d.recordAudit(record); err != nil {
+				return nil, false, err
+			}
+		}
+		return record, true, nil
+	}, cb)
+}
+
+// LastAudit returns the most recently recorded audit of the object with the
+// given ID, or nil if it has never been audited
+func (d *Driver) LastAudit(ctx context.Context, id string) (*AuditRecord, error) {
+	obj, _, err := d.readObject(ctx, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read object %s", id)
+	}
+	if obj == nil {
+		return nil, errors.Wrap(ocfl.ErrNotFound, id)
+	}
+	return lastAudit(obj.Addr)
+}
+
+func auditObject(ctx context.Context, objRoot string) (*AuditRecord, error) {
+	record := &AuditRecord{
+		Path: objRoot,
+		Time: time.Now().UTC(),
+	}
+
+	report, err := validateObject(ctx, objRoot, Full, nil)
+	if err != nil {
+		return nil, err
+	}
+	record.ID = report.ID
+	record.Passed = report.Valid()
+	record.Failures = report.Results.Filter(metadata.SeverityError)
+
+	if inv, err := ReadInventory(objRoot); err == nil {
+		record.Head = inv.Head
+		record.Algorithms = auditedAlgorithms(inv)
+	}
+	return record, nil
+}
+
+// The manifest's digest algorithm, followed by any supported fixity algorithms
+func auditedAlgorithms(inv *metadata.Inventory) []metadata.DigestAlgorithm {
+	var algs []metadata.DigestAlgorithm
+	if _, supported := metadata.NewHash(inv.DigestAlgorithm); !supported {
+		return algs
+	}
+
+	for alg := range inv.Fixity {
+		if _, supported := metadata.NewHash(alg); supported && alg != inv.DigestAlgorithm {
+			algs = append(algs, alg)
+		}
+	}
+	sort.Slice(algs, func(i, j int) bool { return algs[i] < algs[j] })
+	return append([]metadata.DigestAlgorithm{inv.DigestAlgorithm}, algs...)
+}
+
+// Appends the record to the audit log of its object
+func (d *Driver) recordAudit(record *AuditRecord) error {
+	path := filepath.Join(record.Path, filepath.FromSlash(AuditLog))
+	if err := os.MkdirAll(filepath.Dir(path), dirPermission); err != nil {
+		return errors.Wrapf(err, "could not create log directory for %s", record.Path)
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrapf(err, "could not encode audit record of %s", record.ID)
+	}
+
+	log, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, filePermission)
+	if err != nil {
+		return errors.Wrapf(err, "could not open audit log %s", path)
+	}
+	defer log.Close()
+
+	// A single write, so concurrent audits cannot interleave records
+	if _, err := log.Write(append(line, '\n')); err != nil {
+		return errors.Wrapf(err, "could not write to audit log %s", path)
+	}
+	if d.cfg.Sync {
+		return errors.Wrapf(log.Sync(), "could not sync audit log %s", path)
+	}
+	return nil
+}
+
+// Reads the last record of an object's audit log, if any
+func lastAudit(objRoot string) (*AuditRecord, error) {
+	path := filepath.Join(objRoot, filepath.FromSlash(AuditLog))
+	log, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open audit log %s", path)
+	}
+	defer log.Close()
+
+	var last []byte
+	lines := bufio.NewScanner(log)
+	lines.Buffer(nil, 16*1024*1024)
+	for lines.Scan() {
+		if len(lines.Bytes()) > 0 {
+			last = append(last[:0], lines.Bytes()...)
+		}
+	}
+	if err := lines.Err(); err != nil {
+		return nil, errors.Wrapf(err, "could not read audit log %s", path)
+	}
+	if last == nil {
+		return nil, nil
+	}
+
+	record := &AuditRecord{Path: objRoot}
+	if err := json.Unmarshal(last, record); err != nil {
+		return nil, errors.Wrapf(err, "could not parse last record of audit log %s", path)
+	}
+	return record, nil
+}
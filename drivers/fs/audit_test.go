@@ -0,0 +1,83 @@
+package fs_test
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/go-test/deep"
+)
+
+func TestAudit(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		for _, id := range []string{"urn:test/a", "urn:test/b"} {
+			s := driver.Open(id, ocfl.Options{Create: true, Version: ocfl.NEW})
+			s.Put("hello.txt", strings.NewReader("hello"))
+			s.Commit(ocfl.CommitInfo{})
+		}
+
+		d := driver.driver.(*fs.Driver)
+		path, _ := d.ObjectPath("urn:test/b")
+		_ = ioutil.WriteFile(filepath.Join(path, "v1", "content", "hello.txt"), []byte("corrupt"), 0644)
+
+		audit := func(opts fs.AuditOptions, ids ...string) map[string]bool {
+			passed := make(map[string]bool)
+			err := d.Audit(context.Background(), opts, func(r *fs.AuditRecord) error {
+				passed[r.ID] = r.Passed
+				return nil
+			}, ids...)
+			if err != nil {
+				t.Fatalf("audit failed %+v", err)
+			}
+			return passed
+		}
+
+		start := time.Now()
+		if diff := deep.Equal(audit(fs.AuditOptions{Since: start}), map[string]bool{
+			"urn:test/a": true,
+			"urn:test/b": false,
+		}); diff != nil {
+			t.Error(diff)
+		}
+
+		// Resuming the audit skips objects already audited
+		if passed := audit(fs.AuditOptions{Since: start}); len(passed) != 0 {
+			t.Errorf("Expected audited objects to be skipped, got %v", passed)
+		}
+
+		if diff := deep.Equal(audit(fs.AuditOptions{}, "urn:test/a"), map[string]bool{"urn:test/a": true}); diff != nil {
+			t.Error(diff)
+		}
+
+		last, err := d.LastAudit(context.Background(), "urn:test/b")
+		if err != nil {
+			t.Fatalf("could not read last audit %+v", err)
+		}
+		if last.Passed || last.Head != "v1" || last.Time.Before(start.Add(-time.Second)) {
+			t.Errorf("Wrong last audit record %+v", last)
+		}
+		if diff := deep.Equal(last.Algorithms, []metadata.DigestAlgorithm{"sha512"}); diff != nil {
+			t.Error(diff)
+		}
+		if len(last.Failures) != 1 || last.Failures[0].Code != metadata.E092 {
+			t.Errorf("Expected a content failure, got %v", last.Failures)
+		}
+
+		log, _ := ioutil.ReadFile(filepath.Join(path, filepath.FromSlash(fs.AuditLog)))
+		if lines := strings.Count(string(log), "\n"); lines != 1 {
+			t.Errorf("Expected one audit record of urn:test/b, got %d", lines)
+		}
+
+		// Audit logs do not make objects invalid
+		report, err := d.Validate(context.Background(), "urn:test/a", fs.ValidateOptions{})
+		if err != nil || !report.Valid() {
+			t.Errorf("Audited object is not valid: %v %+v", report.Results, err)
+		}
+	})
+}
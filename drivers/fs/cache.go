@@ -0,0 +1,140 @@
+package fs
+
+import (
+	"container/list"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/birkland/ocfl/metadata"
+)
+
+// inventoryCache is a size-limited, least recently used cache of parsed inventories,
+// keyed by object root path.  An entry is only used if the inventory file's size and
+// modification time are unchanged since it was read.
+type inventoryCache struct {
+	sync.Mutex
+	limit   int
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type cacheEntry struct {
+	path    string
+	size    int64
+	modTime time.Time
+	inv     *metadata.Inventory
+}
+
+func newInventoryCache(limit int) *inventoryCache {
+	return &inventoryCache{
+		limit:   limit,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Reads the inventory of the object at the given path, from the cache if it
+// is current.  The caller receives its own copy, which it may modify.
+func (c *inventoryCache) read(objPath string) (*metadata.Inventory, error) {
+	info, err := os.Stat(filepath.Join(objPath, metadata.InventoryFile))
+	if err != nil {
+		return ReadInventory(objPath)
+	}
+
+	c.Lock()
+	if e, ok := c.entries[objPath]; ok {
+		entry := e.Value.(*cacheEntry)
+		if entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+			c.lru.MoveToFront(e)
+			c.Unlock()
+			return copyInventory(entry.inv), nil
+		}
+		c.remove(e)
+	}
+	c.Unlock()
+
+	inv, err := ReadInventory(objPath)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if e, ok := c.entries[objPath]; ok {
+		c.remove(e)
+	}
+	c.entries[objPath] = c.lru.PushFront(&cacheEntry{
+		path:    objPath,
+		size:    info.Size(),
+		modTime: info.ModTime(),
+		inv:     copyInventory(inv),
+	})
+	for c.lru.Len() > c.limit {
+		c.remove(c.lru.Back())
+	}
+
+	return inv, nil
+}
+
+// Removes the cached inventory of the object at the given path, if any
+func (c *inventoryCache) invalidate(objPath string) {
+	c.Lock()
+	defer c.Unlock()
+	if e, ok := c.entries[objPath]; ok {
+		c.remove(e)
+	}
+}
+
+func (c *inventoryCache) remove(e *list.Element) {
+	delete(c.entries, e.Value.(*cacheEntry).path)
+	c.lru.Remove(e)
+}
+
+// Makes a deep copy of an inventory's exported content
+func copyInventory(inv *metadata.Inventory) *metadata.Inventory {
+	cp := *inv
+	cp.Manifest = inv.Manifest.Clone()
+
+	if inv.Versions != nil {
+		cp.Versions = make(map[string]metadata.Version, len(inv.Versions))
+		for name, v := range inv.Versions {
+			v.State = v.State.Clone()
+			cp.Versions[name] = v
+		}
+	}
+
+	if inv.Fixity != nil {
+		cp.Fixity = make(metadata.Fixity, len(inv.Fixity))
+		for alg, m := range inv.Fixity {
+			cp.Fixity[alg] = m.Clone()
+		}
+	}
+
+	return &cp
+}
+
+// Removes any cached inventory of the object at the given path
+func (d *Driver) invalidate(objPath string) {
+	if d.cache != nil {
+		d.cache.invalidate(objPath)
+	}
+}
+
+// Removes any cached inventory of the object with the given ID, e.g. once it
+// has been created at the path generated from its ID
+func (d *Driver) invalidateID(id string) {
+	if path, err := d.ObjectPath(id); err == nil {
+		d.invalidate(path)
+	}
+}
+
+// Reads the inventory of the object at the given path, using the driver's
+// cache if it has one.
+func (d *Driver) readInventory(objPath string) (*metadata.Inventory, error) {
+	if d.cache == nil {
+		return ReadInventory(objPath)
+	}
+	return d.cache.read(objPath)
+}
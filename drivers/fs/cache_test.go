@@ -0,0 +1,84 @@
+package fs_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/fspath"
+)
+
+func TestInventoryCache(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		_ = fs.MkRoot(ocflRoot)
+
+		newDriver := func(cache int) *fs.Driver {
+			d, err := fs.NewDriver(fs.Config{
+				Root:           ocflRoot,
+				ObjectPaths:    fspath.GeneratorFunc(url.QueryEscape),
+				FilePaths:      fspath.GeneratorFunc(fs.Passthrough),
+				InventoryCache: cache,
+			})
+			if err != nil {
+				t.Fatalf("Error setting up driver %+v", err)
+			}
+			return d
+		}
+
+		cached := newDriver(1)
+		uncached := newDriver(0)
+
+		files := func(id string) []string {
+			var found []string
+			err := cached.Walk(ocfl.Select{Type: ocfl.File, Head: true, Sorted: true}, func(ref ocfl.EntityRef) error {
+				found = append(found, ref.ID)
+				return nil
+			}, id)
+			if err != nil {
+				t.Fatalf("Walk failed %+v", err)
+			}
+			return found
+		}
+
+		put := func(d *fs.Driver, id, lpath string, commit bool) {
+			session, err := d.Open(id, ocfl.Options{Create: true, Version: ocfl.NEW})
+			if err != nil {
+				t.Fatalf("Could not open %s: %+v", id, err)
+			}
+			_ = session.Put(lpath, strings.NewReader(lpath))
+			if commit {
+				if err = session.Commit(ocfl.CommitInfo{}); err != nil {
+					t.Fatalf("Commit failed %+v", err)
+				}
+			}
+		}
+
+		expect := func(id string, expected ...string) {
+			if found := files(id); strings.Join(found, ",") != strings.Join(expected, ",") {
+				t.Errorf("Expected files %v in %s, found %v", expected, id, found)
+			}
+		}
+
+		put(cached, "obj1", "a", true)
+		expect("obj1", "a")
+
+		// Commits through the caching driver are seen
+		put(cached, "obj1", "b", true)
+		expect("obj1", "a", "b")
+
+		// Uncommitted changes do not leak into the cache
+		put(cached, "obj1", "uncommitted", false)
+		expect("obj1", "a", "b")
+
+		// Changes made elsewhere are seen
+		put(uncached, "obj1", "c", true)
+		expect("obj1", "a", "b", "c")
+
+		// Entries beyond the limit are evicted without ill effect
+		put(cached, "obj2", "x", true)
+		expect("obj2", "x")
+		expect("obj1", "a", "b", "c")
+	})
+}
@@ -0,0 +1,243 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+)
+
+// CloneOptions configure how an object is cloned
+type CloneOptions struct {
+	Link    bool // Hard link content rather than copying it, falling back to copying if linking fails
+	Flatten bool // Create a single version containing the source's head state, rather than preserving history
+}
+
+// Clone creates a new object with the given ID, containing the content of an existing object.
+//
+// By default, the clone has the same version history as the source.  If flattened, the clone
+// has a single version containing the state (and version metadata) of the source's head version.
+// The clone is assembled in a temporary directory, and moved into place only when complete.
+// If the driver locks objects, the clone's lock is held throughout.
+func (d *Driver) Clone(src, dest string, opts CloneOptions) (err error) {
+	if d.cfg.ObjectPaths == nil {
+		return fmt.Errorf("no object path generation function given!  (check driver config)")
+	}
+
+	src, dest = d.normalizeID(src), d.normalizeID(dest)
+
+	unlock, err := d.lockObject(dest)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := unlock(); err == nil {
+			err = e
+		}
+	}()
+
+	srcObj, srcInv, err := d.readObject(context.Background(), src)
+	if err != nil {
+		return errors.Wrapf(err, "could not read object %s", src)
+	}
+	if srcObj == nil {
+		return errors.Wrap(ocfl.ErrNotFound, src)
+	}
+
+	destDir, err := d.newObjectDir(dest)
+	if err != nil {
+		return err
+	}
+
+	var inv *metadata.Inventory
+	var sources map[string]string
+	if opts.Flatten {
+		inv, sources, err = d.flatInventory(srcInv, dest)
+	} else {
+		inv, sources = copiedInventory(srcInv, dest)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "could not create inventory for clone of %s", src)
+	}
+
+	for p, srcPath := range sources {
+		sources[p] = filepath.Join(srcObj.Addr, filepath.FromSlash(srcPath))
+	}
+	err = createObject(destDir, inv, sources, opts.Link)
+	d.invalidateID(dest)
+	return err
+}
+
+// Determines the directory of an object to be created with the given ID, which
+// must not already exist.
+func (d *Driver) newObjectDir(id string) (string, error) {
+	obj, _, err := d.readObject(context.Background(), id)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not read object %s", id)
+	}
+	if obj != nil {
+		return "", fmt.Errorf("object already exists: %s", id)
+	}
+
+	dir, err := absPath(filepath.Join(d.root.Addr, d.cfg.ObjectPaths.Generate(id)))
+	if err != nil {
+		return "", errors.Wrapf(err, "could not calculate absolute path of object dir for %s", id)
+	}
+	if entries, err := ioutil.ReadDir(dir); err == nil && len(entries) > 0 {
+		return "", fmt.Errorf("refusing to create %s in non-empty directory %s", id, dir)
+	}
+
+	return dir, nil
+}
+
+// Creates an object with the given inventory in a staging directory, copying (or linking)
+// content from the given source files, then moves it into place.
+func createObject(dir string, inv *metadata.Inventory, sources map[string]string, link bool) error {
+	err := os.MkdirAll(filepath.Dir(dir), dirPermission)
+	if err != nil {
+		return errors.Wrapf(err, "could not create parent directory of %s", dir)
+	}
+
+	staging := filepath.Join(filepath.Dir(dir), AtomicPrefix+"clone."+filepath.Base(dir))
+	err = stageClone(staging, inv, sources, link)
+	if err != nil {
+		_ = os.RemoveAll(staging)
+		return errors.Wrapf(err, "could not create %s", inv.ID)
+	}
+
+	// The destination may be an empty directory, which can't be renamed over
+	_ = os.Remove(dir)
+	err = os.Rename(staging, dir)
+	if err != nil {
+		_ = os.RemoveAll(staging)
+		return errors.Wrapf(err, "could not move %s into place", inv.ID)
+	}
+
+	return nil
+}
+
+// Copies an inventory under a new id.  Returns the inventory, and a mapping of
+// new physical paths to the source paths they are copied from.
+func copiedInventory(src *metadata.Inventory, id string) (*metadata.Inventory, map[string]string) {
+	inv := *src
+	inv.ID = id
+	sources := make(map[string]string)
+
+	for _, paths := range inv.Manifest {
+		for _, p := range paths {
+			sources[p] = p
+		}
+	}
+
+	return &inv, sources
+}
+
+// Creates an inventory with a single version, having the state of the head
+// version of the source inventory.
+func (d *Driver) flatInventory(src *metadata.Inventory, id string) (*metadata.Inventory, map[string]string, error) {
+	head := src.Versions[src.Head]
+	v1 := string(metadata.VersionID(src.Head).Renumber(1))
+	sources := make(map[string]string)
+
+	inv := &metadata.Inventory{
+		ID:              id,
+		Type:            src.Type,
+		DigestAlgorithm: src.DigestAlgorithm,
+		Head:            v1,
+		Manifest:        make(metadata.Manifest),
+		Versions: map[string]metadata.Version{
+			v1: {
+				Created: head.Created,
+				Message: head.Message,
+				User:    head.User,
+				Type:    head.Type,
+				State:   make(metadata.Manifest, len(head.State)),
+			},
+		},
+	}
+
+	files, err := src.Files(src.Head)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filePaths := Passthrough
+	if d.cfg.FilePaths != nil {
+		filePaths = d.cfg.FilePaths.Generate
+	}
+
+	for _, f := range files {
+		digest, _ := src.DigestOf(f.PhysicalPath)
+		inv.Versions[v1].State[digest] = append(inv.Versions[v1].State[digest], f.LogicalPath)
+
+		if _, ok := inv.Manifest[digest]; ok {
+			continue
+		}
+
+		p := v1 + "/content/" + strings.TrimLeft(filePaths(f.LogicalPath), "/")
+		inv.Manifest[digest] = []string{p}
+		sources[p] = f.PhysicalPath
+	}
+
+	for alg, manifest := range src.Fixity {
+		for digest, paths := range manifest {
+			for newPath, srcPath := range sources {
+				for _, p := range paths {
+					if p != srcPath {
+						continue
+					}
+					if inv.Fixity == nil {
+						inv.Fixity = make(metadata.Fixity)
+					}
+					if inv.Fixity[alg] == nil {
+						inv.Fixity[alg] = make(metadata.Manifest)
+					}
+					inv.Fixity[alg][digest] = append(inv.Fixity[alg][digest], newPath)
+				}
+			}
+		}
+	}
+
+	return inv, sources, nil
+}
+
+// Copies (or links) content from the given source files, and writes inventories into
+// a staging directory, producing a complete object
+func stageClone(staging string, inv *metadata.Inventory, sources map[string]string, link bool) error {
+	for dest, srcPath := range sources {
+		destPath := filepath.Join(staging, filepath.FromSlash(dest))
+
+		var err error
+		if link {
+			err = linkOrCopy(srcPath, destPath)
+		} else {
+			err = copyFile(srcPath, destPath)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, v := range inv.VersionNames() {
+		dir := filepath.Join(staging, v)
+		if err := os.MkdirAll(dir, dirPermission); err != nil {
+			return err
+		}
+		if err := writeInventory(inventoryAt(inv, v), dir); err != nil {
+			return err
+		}
+	}
+
+	err := copyInventoryFiles(filepath.Join(staging, inv.Head), staging)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(staging, ocflObjectRoot), []byte(objectRootNamasteContent), filePermission)
+}
@@ -0,0 +1,98 @@
+package fs_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+)
+
+func TestClone(t *testing.T) {
+	for _, flatten := range []bool{false, true} {
+		for _, link := range []bool{false, true} {
+			opts := fs.CloneOptions{Flatten: flatten, Link: link}
+			t.Run(fmt.Sprintf("flatten=%t,link=%t", flatten, link), func(t *testing.T) {
+				runWithDriverWrapper(t, func(driver driverWrapper) {
+					d := driver.driver.(*fs.Driver)
+					clone := "urn:test/clone"
+
+					session := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+					session.Put("a", strings.NewReader("a"))
+					session.Put("b", strings.NewReader("b"))
+					session.Commit(ocfl.CommitInfo{Message: "first"})
+
+					session = driver.Open(objectID, ocfl.Options{Version: ocfl.NEW})
+					session.Put("c", strings.NewReader("c"))
+					session.Delete("b")
+					session.Commit(ocfl.CommitInfo{Message: "second"})
+
+					if err := d.Clone(objectID, clone, opts); err != nil {
+						t.Fatalf("clone failed %+v", err)
+					}
+
+					if err := d.Clone(objectID, clone, opts); err == nil {
+						t.Errorf("cloning onto an existing object should fail")
+					}
+
+					var obj ocfl.EntityRef
+					versions := make(map[string][]string)
+					driver.Walk(ocfl.Select{}, func(ref ocfl.EntityRef) error {
+						switch ref.Type {
+						case ocfl.Object:
+							obj = ref
+						case ocfl.File:
+							content, err := ioutil.ReadFile(ref.Addr)
+							if err != nil || string(content) != ref.ID {
+								t.Errorf("bad content for %s: %v", ref.ID, err)
+							}
+							versions[ref.Parent.ID] = append(versions[ref.Parent.ID], ref.ID)
+						}
+						return nil
+					}, clone)
+
+					inv, err := fs.ReadInventory(obj.Addr)
+					if err != nil {
+						t.Fatalf("could not read clone inventory %+v", err)
+					}
+					if inv.ID != clone {
+						t.Errorf("clone has wrong id %s", inv.ID)
+					}
+					if err := inv.Validate(); err != nil {
+						t.Errorf("clone inventory is invalid %+v", err)
+					}
+
+					expectedVersions := map[bool]int{true: 1, false: 2}[flatten]
+					if len(versions) != expectedVersions {
+						t.Errorf("expected %d versions, got %v", expectedVersions, versions)
+					}
+
+					if inv.Versions[inv.Head].Message != "second" || len(versions[inv.Head]) != 2 {
+						t.Errorf("clone head does not match source head: %v", versions[inv.Head])
+					}
+
+					// Modifying the clone must not affect the source
+					session = driver.Open(clone, ocfl.Options{Version: ocfl.NEW})
+					session.Put("d", strings.NewReader("d"))
+					session.Commit(ocfl.CommitInfo{})
+
+					var srcFiles int
+					driver.Walk(ocfl.Select{Type: ocfl.File, Head: true}, func(ref ocfl.EntityRef) error {
+						srcFiles++
+						return nil
+					}, objectID)
+					if srcFiles != 2 {
+						t.Errorf("source object was modified, it has %d files", srcFiles)
+					}
+
+					if _, err := os.Stat(obj.Addr); err != nil {
+						t.Errorf("clone directory missing %+v", err)
+					}
+				})
+			})
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+)
+
+// Copier is implemented by sessions that can copy logical files from other
+// objects without round-tripping their content through the caller.
+//
+// Sessions returned by Driver.Open implement Copier, e.g.
+//
+//	err := session.(fs.Copier).CopyFrom("urn:other", ocfl.HEAD, "foo/bar.txt", "baz.txt")
+type Copier interface {
+	CopyFrom(srcObject, srcVersion, srcPath, lpath string) error
+}
+
+// Stops iteration once what is sought is found
+var errFound = fmt.Errorf("found")
+
+// CopyFrom copies a logical file from a version of another object (or this one) into the session's
+// version at the given logical path.  If the session's object already contains content with
+// the file's digest, the file is simply added to the version's state.  Otherwise, its content is
+// copied as if by Put.
+func (s *session) CopyFrom(srcObject, srcVersion, srcPath, lpath string) error {
+	if s.discarded {
+		return fmt.Errorf("cannot copy to %s, its new version was discarded", s.version.Parent.ID)
+	}
+
+	obj, inv, err := s.driver.readObject(context.Background(), srcObject)
+	if err != nil {
+		return errors.Wrapf(err, "could not read source object %s", srcObject)
+	}
+	if obj == nil {
+		return errors.Wrapf(ocfl.ErrNotFound, "source %s", srcObject)
+	}
+
+	if srcVersion == ocfl.HEAD {
+		srcVersion = inv.Head
+	}
+
+	var file *metadata.File
+	err = inv.FilesIter(srcVersion, func(f metadata.File) error {
+		if f.LogicalPath == srcPath {
+			file = &f
+			return errFound
+		}
+		return nil
+	})
+	if err != nil && err != errFound {
+		return errors.Wrapf(err, "could not read files in %s %s", srcObject, srcVersion)
+	}
+	if file == nil {
+		return fmt.Errorf("no file %s in %s %s", srcPath, srcObject, srcVersion)
+	}
+
+	digest, _ := inv.DigestOf(file.PhysicalPath)
+
+	err = s.prepareWrite()
+	if err != nil {
+		return errors.Wrapf(err, "could not copy to %s", s.version.Parent.ID)
+	}
+
+	s.Lock()
+	_, exists := s.inventory.FindDigest(digest)
+	if exists && inv.DigestAlgorithm == s.inventory.DigestAlgorithm && s.driver.cfg.Dedup {
+		err = s.inventory.PutLogicalFile(lpath, digest)
+		s.Unlock()
+		return err
+	}
+	s.Unlock()
+
+	content, err := os.Open(filepath.Join(obj.Addr, filepath.FromSlash(file.PhysicalPath)))
+	if err != nil {
+		return errors.Wrapf(err, "could not open content of %s in %s %s", srcPath, srcObject, srcVersion)
+	}
+	defer content.Close()
+
+	return s.Put(lpath, content)
+}
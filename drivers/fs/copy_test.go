@@ -0,0 +1,58 @@
+package fs_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+)
+
+func TestCopyFrom(t *testing.T) {
+	runWithDriverConfig(t, func(cfg *fs.Config) { cfg.Dedup = true }, func(driver driverWrapper) {
+		other := "urn:test/other"
+
+		session := driver.Open(other, ocfl.Options{Create: true, Version: ocfl.NEW})
+		session.Put("shared", strings.NewReader("shared content"))
+		session.Put("unique", strings.NewReader("unique content"))
+		session.Commit(ocfl.CommitInfo{})
+
+		session = driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		session.Put("mine", strings.NewReader("shared content"))
+
+		copier := session.session.(fs.Copier)
+		for src, dest := range map[string]string{"shared": "copied/shared", "unique": "copied/unique"} {
+			if err := copier.CopyFrom(other, ocfl.HEAD, src, dest); err != nil {
+				t.Fatalf("copy of %s failed %+v", src, err)
+			}
+		}
+
+		if err := copier.CopyFrom(other, ocfl.HEAD, "DOES_NOT_EXIST", "foo"); err == nil {
+			t.Errorf("copying a nonexistent file should fail")
+		}
+
+		session.Commit(ocfl.CommitInfo{})
+
+		var obj ocfl.EntityRef
+		driver.Walk(ocfl.Select{Type: ocfl.Object}, func(ref ocfl.EntityRef) error {
+			obj = ref
+			return nil
+		}, objectID)
+
+		inv, _ := fs.ReadInventory(obj.Addr)
+		files, _ := inv.Files("v1")
+		if len(files) != 3 {
+			t.Errorf("expected 3 files, got %d", len(files))
+		}
+
+		// Shared content was not copied again
+		if len(inv.Manifest) != 2 {
+			t.Errorf("expected 2 distinct pieces of content, got %d", len(inv.Manifest))
+		}
+		for _, paths := range inv.Manifest {
+			if len(paths) != 1 {
+				t.Errorf("content was duplicated: %s", paths)
+			}
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/birkland/ocfl"
+	"github.com/pkg/errors"
+)
+
+// DedupOptions configure the deduplication of an object's content
+type DedupOptions struct {
+	DryRun bool // Only report redundant content, without modifying the object
+}
+
+// Dedup reclaims the space used by redundant copies of content in an object,
+// e.g. one ingested without deduplication (see Config.Dedup).  Of each set of
+// files with the same digest, only the one in the earliest version is kept, and the
+// others are removed from the manifest and deleted.  Returns the physical paths of
+// the redundant files, relative to the object root.
+//
+// Redundant files cannot be kept, as content not in the manifest would make the
+// object invalid.  The inventory is updated before they are deleted, so a crash
+// in between leaves only unreferenced files behind (see GC).  Inventories of prior
+// versions are left as they are.  Deduplication should only be performed on objects
+// that are not being concurrently modified, e.g. by holding the object's lock, as the
+// driver does if it locks objects.
+func (d *Driver) Dedup(id string, opts DedupOptions) (redundant []string, err error) {
+	unlock, err := d.lockObject(id)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if e := unlock(); err == nil {
+			err = e
+		}
+	}()
+
+	obj, inv, err := d.readObject(context.Background(), id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read object %s", id)
+	}
+	if obj == nil {
+		return nil, errors.Wrap(ocfl.ErrNotFound, id)
+	}
+
+	redundant = inv.DedupManifest()
+	if len(redundant) == 0 || opts.DryRun {
+		return redundant, nil
+	}
+
+	headDir := filepath.Join(obj.Addr, inv.Head)
+	if err = d.cfg.Faults.writeInventory(inv, headDir); err != nil {
+		return nil, errors.Wrapf(err, "could not write deduplicated inventory of %s", id)
+	}
+	if err = d.cfg.Faults.copyInventoryFiles(headDir, obj.Addr); err != nil {
+		return nil, errors.Wrapf(err, "could not write deduplicated inventory of %s", id)
+	}
+	d.invalidate(obj.Addr)
+
+	// The head inventory has changed, so its signature must too
+	if d.cfg.Signer != nil {
+		if err = signInventory(d.cfg.Signer, obj.Addr, inv.Head); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range redundant {
+		path := filepath.Join(obj.Addr, filepath.FromSlash(p))
+		if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrapf(err, "could not remove redundant content %s of %s", p, id)
+		}
+		if err = removeEmptyParents(filepath.Dir(path), obj.Addr); err != nil {
+			return nil, err
+		}
+	}
+
+	return redundant, nil
+}
@@ -0,0 +1,82 @@
+package fs_test
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/fspath"
+	"github.com/go-test/deep"
+)
+
+func TestDedupObject(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		_ = fs.MkRoot(ocflRoot)
+
+		d, _ := fs.NewDriver(fs.Config{
+			Root:        ocflRoot,
+			ObjectPaths: fspath.GeneratorFunc(url.QueryEscape),
+			FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
+		})
+		driver := driverWrapper{driver: d, t: t, root: ocflRoot}
+
+		session := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		session.Put("file1", strings.NewReader("same"))
+		session.Commit(ocfl.CommitInfo{})
+
+		session = driver.Open(objectID, ocfl.Options{Version: ocfl.NEW})
+		session.Put("dir/file2", strings.NewReader("same"))
+		session.Put("unique", strings.NewReader("unique"))
+		session.Commit(ocfl.CommitInfo{})
+
+		path, _ := d.ObjectPath(objectID)
+		expected := []string{"v2/content/dir/file2"}
+
+		redundant, err := d.Dedup(objectID, fs.DedupOptions{DryRun: true})
+		if err != nil {
+			t.Fatalf("dedup failed %+v", err)
+		}
+		if diff := deep.Equal(redundant, expected); diff != nil {
+			t.Error(diff)
+		}
+		if _, err := os.Stat(filepath.Join(path, "v2", "content", "dir", "file2")); err != nil {
+			t.Errorf("a dry run should not remove content")
+		}
+
+		redundant, err = d.Dedup(objectID, fs.DedupOptions{})
+		if err != nil {
+			t.Fatalf("dedup failed %+v", err)
+		}
+		if diff := deep.Equal(redundant, expected); diff != nil {
+			t.Error(diff)
+		}
+		if _, err := os.Stat(filepath.Join(path, "v2", "content", "dir")); !os.IsNotExist(err) {
+			t.Errorf("redundant content should have been removed")
+		}
+
+		report, err := d.Validate(context.Background(), objectID, fs.ValidateOptions{})
+		if err != nil {
+			t.Fatalf("validation failed %+v", err)
+		}
+		if !report.Valid() {
+			t.Errorf("deduplicated object should be valid: %v", report.Results)
+		}
+
+		inv, _ := fs.ReadInventory(path)
+		files, _ := inv.Files("v2")
+		for _, f := range files {
+			if f.LogicalPath == "dir/file2" && f.PhysicalPath != "v1/content/file1" {
+				t.Errorf("expected dir/file2 to refer to v1 content, got %s", f.PhysicalPath)
+			}
+		}
+
+		if redundant, _ = d.Dedup(objectID, fs.DedupOptions{}); len(redundant) != 0 {
+			t.Errorf("nothing should be left to dedup, got %s", redundant)
+		}
+	})
+}
@@ -2,17 +2,22 @@ package fs
 
 import (
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/birkland/ocfl"
 	"github.com/birkland/ocfl/fspath"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/birkland/ocfl/signature"
 	"github.com/pkg/errors"
 )
 
 // Driver represents the filesystem driver for OCFL
 type Driver struct {
-	root *ocfl.EntityRef
-	cfg  Config
+	root  *ocfl.EntityRef
+	cfg   Config
+	cache *inventoryCache
 }
 
 // Config encapsulates an OCFL filesystem driver config.
@@ -23,10 +28,100 @@ type Driver struct {
 // of OCFL object directories.  If not provided, the driver will perform
 // a brute force search through the directory tree when it needs to perform
 // lookups of OCFL directories when given an object ID.
+//
+// If the OCFL root declares its storage layout in LayoutFile, the declared
+// layout is used for object paths instead of any ObjectPaths provided, unless
+// ObjectPaths is an fspath.Layout of the same extension with the same parameters
+// (e.g. fspath.Fedora, which specializes the hashed n-tuple layout).
 type Config struct {
 	Root        string           // OCFL root directory
 	ObjectPaths fspath.Generator // OCFL object directories based on id
 	FilePaths   fspath.Generator // physical file paths based on logical path
+
+	// Dedup, if true, causes content whose digest is already present in the
+	// manifest not to be written again; the version's state simply references
+	// the existing content.  By default, content is always written into the
+	// version it is put in.
+	Dedup bool
+
+	// LinkUnchanged, if true, hard links content carried over unchanged from
+	// prior versions into each new version's content directory upon commit,
+	// so that every version directory is self-contained without duplicating
+	// disk blocks.  Content is copied where it cannot be linked.  It is most
+	// useful without Dedup.
+	LinkUnchanged bool
+
+	// Signer, if provided, produces a detached signature over each version
+	// inventory upon commit.  Signatures are stored in SignatureDir.
+	Signer signature.Signer
+
+	// Identity, if provided, supplies the user name and address of commits
+	// whose CommitInfo leaves them empty.
+	Identity ocfl.IdentityProvider
+
+	// Staging, if provided, is a directory where content is written before
+	// being moved into an object upon completion.  Ideally, it is on the same
+	// filesystem as the OCFL root, in which case moves are simple renames.
+	// If not provided, temporary files are created beside their targets.
+	Staging string
+
+	// Symlinks determines how symbolic links are treated when walking the
+	// directories of the OCFL root.  By default, they are followed.
+	Symlinks SymlinkPolicy
+
+	// Portable, if true, rejects object and content paths that could not be
+	// created on Windows (e.g. reserved device names like CON or NUL), or that
+	// differ only by case from an existing path in the version.  These checks
+	// are always performed on Windows.
+	Portable bool
+
+	// Reflink, if true, clones content put from an *os.File rather than copying
+	// it, on filesystems that support it (e.g. btrfs and XFS on linux, APFS on
+	// macOS).  Content is copied where cloning is not supported.
+	Reflink bool
+
+	// Hardlink, if true, hard links content put from a regular file into the object
+	// rather than copying it, once its digest has been computed.  Content is copied
+	// where it cannot be linked (e.g. across filesystems).  A linked file is shared
+	// with its source, so changing the source changes the object's content too.
+	Hardlink bool
+
+	// InventoryCache, if positive, is the maximum number of parsed inventories
+	// to retain in memory, so that repeated operations on the same objects
+	// need not re-read them.  Cached inventories are re-read if their files'
+	// size or modification time changes.
+	InventoryCache int
+
+	// Lock, if true, makes sessions that write new versions hold an advisory
+	// lock on their object, so that concurrent writers (even in other processes)
+	// cannot clobber each other's versions.  The lock is held from Open until a
+	// successful Commit, or Close.  Lock files are kept in LockDir.
+	Lock bool
+
+	// LockTimeout is how long Open waits for another writer to release an
+	// object's lock before failing with ErrLocked.  Defaults to DefaultLockTimeout.
+	LockTimeout time.Duration
+
+	// Sync, if true, flushes written content, inventories, and the directories
+	// containing them to stable storage before a commit returns.  Content is
+	// flushed before any inventory referencing it is written, and the version's
+	// inventory before it is promoted to the object root, so a crash cannot
+	// leave an inventory that references missing or torn content or versions.
+	Sync bool
+
+	// VersionPadding is the zero padding of the version numbers of new objects.
+	// By default, they are unpadded, as recommended by the OCFL spec.  Existing
+	// objects keep the padding they were created with.
+	VersionPadding metadata.Padding
+
+	// Faults, if provided, injects failures into the filesystem operations of
+	// puts, commits, and other changes to inventories, for resilience testing
+	// (see package chaos).
+	Faults FaultInjector
+
+	// TimestampPrecision is the precision of the creation dates of new versions, which
+	// are always written in UTC.  Dates of existing versions are written as they were read.
+	TimestampPrecision metadata.TimestampPrecision
 }
 
 // Passthrough is a basic PathFunc for creating filesystem paths that
@@ -38,9 +133,29 @@ func Passthrough(id string) string {
 // NewDriver initializes a new filesystem OCFL driver with
 // the given OCFL root directory.
 func NewDriver(cfg Config) (*Driver, error) {
+	if err := cfg.VersionPadding.Validate(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Staging != "" {
+		finfo, err := os.Stat(cfg.Staging)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not access staging directory")
+		}
+		if !finfo.IsDir() {
+			return nil, fmt.Errorf("staging area %s is not a directory", cfg.Staging)
+		}
+	}
+
+	var cache *inventoryCache
+	if cfg.InventoryCache > 0 {
+		cache = newInventoryCache(cfg.InventoryCache)
+	}
+
 	if cfg.Root == "" {
 		return &Driver{
-			cfg: cfg,
+			cfg:   cfg,
+			cache: cache,
 		}, nil
 	}
 
@@ -53,11 +168,26 @@ func NewDriver(cfg Config) (*Driver, error) {
 		return nil, fmt.Errorf("%s is not an OCFL root", cfg.Root)
 	}
 
+	layout, err := ReadLayout(cfg.Root)
+	if err != nil {
+		return nil, err
+	}
+	if layout != nil {
+		declared, err := layout.Generator(cfg.Root)
+		if err != nil {
+			return nil, err
+		}
+		if !sameLayout(cfg.ObjectPaths, declared) {
+			cfg.ObjectPaths = declared
+		}
+	}
+
 	return &Driver{
 		root: &ocfl.EntityRef{
 			Type: ocfl.Root,
 			Addr: cfg.Root,
 		},
-		cfg: cfg,
+		cfg:   cfg,
+		cache: cache,
 	}, nil
 }
@@ -0,0 +1,32 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// LocalExtension is the local (unregistered) storage root extension under which the
+// driver keeps state of its own, such as object locks and the tombstones of purged
+// objects.  Its directory in the root's extensions directory holds a config.json
+// naming it, as the OCFL specification requires of extensions, and a directory for
+// each kind of state.
+const LocalExtension = "birkland-ocfl-fs"
+
+// Creates the directory of the given kind of state within the LocalExtension of the
+// given root, and the config of the extension, if they do not exist.  Returns the
+// path of the directory.
+func mkLocalExtensionDir(root, name string) (string, error) {
+	extDir := filepath.Join(root, extensionsDir, LocalExtension)
+	dir := filepath.Join(extDir, name)
+	if err := os.MkdirAll(dir, dirPermission); err != nil {
+		return "", errors.Wrapf(err, "could not create extension directory %s", dir)
+	}
+
+	config := filepath.Join(extDir, extensionConfigFile)
+	if _, err := os.Stat(config); !os.IsNotExist(err) {
+		return dir, errors.Wrapf(err, "could not read %s", config)
+	}
+	return dir, writeJSON(config, map[string]string{"extensionName": LocalExtension})
+}
@@ -0,0 +1,63 @@
+package fs
+
+import "io"
+
+// FileOp names a filesystem operation that faults may be injected into
+type FileOp string
+
+// Filesystem operations subject to fault injection
+const (
+	FileWrite  FileOp = "write"  // Writing part of a file's content
+	FileRename FileOp = "rename" // Moving a completely written file into place
+)
+
+// FaultInjector decides whether a filesystem operation upon the given path
+// should fail, for resilience testing (see package chaos).  It returns a non-nil
+// error in order to make the operation fail with that error.
+//
+// Faults are injected into content written by sessions, and into inventories and
+// sidecars as they are written and promoted into object roots, so that failures
+// occur part way through puts and commits.
+type FaultInjector func(op FileOp, path string) error
+
+// Wraps a write so that its writes, and its final rename, fail as the injector
+// decides.  A nil injector injects nothing.
+func (f FaultInjector) wrap(w *ManagedWrite, path string) *ManagedWrite {
+	if f == nil {
+		return w
+	}
+
+	closeFunc := w.closeFunc
+	w.WriteCloser = &faultyWriter{WriteCloser: w.WriteCloser, inject: func() error { return f(FileWrite, path) }}
+	w.closeFunc = func() error {
+		if err := f(FileRename, path); err != nil {
+			return err
+		}
+		if closeFunc != nil {
+			return closeFunc()
+		}
+		return nil
+	}
+	return w
+}
+
+// Creates an AtomicWrite subject to the injector's faults
+func (f FaultInjector) atomicWrite(path string) (*ManagedWrite, error) {
+	w, err := AtomicWrite(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.wrap(w, path), nil
+}
+
+type faultyWriter struct {
+	io.WriteCloser
+	inject func() error
+}
+
+func (w *faultyWriter) Write(p []byte) (int, error) {
+	if err := w.inject(); err != nil {
+		return 0, err
+	}
+	return w.WriteCloser.Write(p)
+}
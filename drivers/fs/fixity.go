@@ -0,0 +1,175 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sort"
+
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+)
+
+// FixityOptions configure fixity checks
+type FixityOptions struct {
+	// Algorithms whose digests are verified, of those an object's manifest or fixity block
+	// uses.  By default, the manifest's algorithm and every supported fixity algorithm.
+	Algorithms []metadata.DigestAlgorithm
+
+	// Sample is the percentage of each object's content files to verify, chosen at random.
+	// Zero (or 100) verifies every file.
+	Sample float64
+
+	Workers int // Number of objects checked concurrently; defaults to the number of CPUs
+}
+
+// FixityReport is the result of a fixity check of an object
+type FixityReport struct {
+	ID         string                     `json:"id"`
+	Path       string                     `json:"path"`       // Path of the object root
+	Algorithms []metadata.DigestAlgorithm `json:"algorithms"` // Algorithms of the digests that were verified
+	Checked    int                        `json:"checked"`    // Number of content files that were verified
+	Failures   metadata.ValidationResults `json:"failures,omitempty"`
+}
+
+// Passed indicates whether every content file checked matched its digests
+func (r *FixityReport) Passed() bool {
+	return !r.Failures.HasErrors()
+}
+
+// CheckFixity recomputes the digests of the content of the objects with the given IDs, or
+// every object in the OCFL root if none are given, and compares them to those in each
+// object's manifest and fixity block.  The callback is invoked with the report of each
+// object as soon as it is checked, in no particular order.
+//
+// Unlike validation (or an Audit), only the content of objects is checked, not their
+// structure, and a random sample of content may be checked rather than all of it.
+// Nothing is recorded in the objects.
+func (d *Driver) CheckFixity(ctx context.Context, opts FixityOptions, cb func(*FixityReport) error, ids ...string) error {
+	if d.root == nil {
+		return fmt.Errorf("cannot check fixity: please define an OCFL root")
+	}
+	if opts.Sample < 0 || opts.Sample > 100 {
+		return fmt.Errorf("invalid sample percentage %g: must be between 0 and 100", opts.Sample)
+	}
+	for _, alg := range opts.Algorithms {
+		if _, supported := metadata.NewHash(alg); !supported {
+			return fmt.Errorf("unsupported digest algorithm %s", alg)
+		}
+	}
+
+	produce := d.sendObjectRoots
+	if len(ids) > 0 {
+		produce = d.sendObjects(ids)
+	}
+
+	return visitObjects(ctx, opts.Workers, produce, func(ctx context.Context, objRoot string) (*FixityReport, bool, error) {
+		report, err := checkFixity(ctx, objRoot, opts)
+		return report, err == nil, err
+	}, cb)
+}
+
+func checkFixity(ctx context.Context, objRoot string, opts FixityOptions) (*FixityReport, error) {
+	inv, err := ReadInventory(objRoot)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not check fixity of %s", objRoot)
+	}
+
+	report := &ValidationReport{ID: inv.ID, Path: objRoot, Results: metadata.ValidationResults{}}
+	fixity := &FixityReport{ID: inv.ID, Path: objRoot}
+
+	// The algorithms to check, of those the object uses
+	for _, alg := range auditedAlgorithms(inv) {
+		if len(opts.Algorithms) == 0 || containsAlgorithm(opts.Algorithms, alg) {
+			fixity.Algorithms = append(fixity.Algorithms, alg)
+		}
+	}
+
+	expected := make(map[string]map[metadata.DigestAlgorithm]metadata.Digest)
+	for digest, paths := range inv.Manifest {
+		for _, p := range paths {
+			expected[p] = make(map[metadata.DigestAlgorithm]metadata.Digest)
+			if containsAlgorithm(fixity.Algorithms, inv.DigestAlgorithm) {
+				expected[p][inv.DigestAlgorithm] = digest
+			}
+		}
+	}
+	for _, alg := range fixity.Algorithms {
+		if alg == inv.DigestAlgorithm {
+			continue
+		}
+		for digest, paths := range inv.Fixity[alg] {
+			for _, p := range paths {
+				if _, inManifest := expected[p]; inManifest {
+					expected[p][alg] = digest
+				}
+			}
+		}
+	}
+
+	var paths []string
+	for p, digests := range expected {
+		if len(digests) > 0 {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	paths = sample(paths, opts.Sample)
+
+	for _, p := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		digests, err := fileDigests(ctx, filepath.Join(objRoot, filepath.FromSlash(p)), expected[p], nil)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		fixity.Checked++
+		if err != nil {
+			report.errorf(metadata.E092, p, "content is missing or unreadable: %s", err)
+			continue
+		}
+
+		for _, alg := range fixity.Algorithms {
+			if digest, ok := expected[p][alg]; ok && !digest.Equal(metadata.Digest(digests[alg])) {
+				code := metadata.E093
+				if alg == inv.DigestAlgorithm {
+					code = metadata.E092
+				}
+				report.errorf(code, p, "content does not match its %s digest %s (computed %s)", alg, digest, digests[alg])
+			}
+		}
+	}
+
+	fixity.Failures = report.Results
+	return fixity, nil
+}
+
+// Chooses the given percentage of paths at random, keeping their order
+func sample(paths []string, percent float64) []string {
+	if percent == 0 || percent >= 100 || len(paths) == 0 {
+		return paths
+	}
+
+	n := int(math.Ceil(float64(len(paths)) * percent / 100))
+	chosen := rand.Perm(len(paths))[:n]
+	sort.Ints(chosen)
+
+	sampled := make([]string, n)
+	for i, j := range chosen {
+		sampled[i] = paths[j]
+	}
+	return sampled
+}
+
+func containsAlgorithm(algs []metadata.DigestAlgorithm, alg metadata.DigestAlgorithm) bool {
+	for _, a := range algs {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package fs_test
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/go-test/deep"
+)
+
+func TestCheckFixity(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		for _, id := range []string{"urn:test/a", "urn:test/b"} {
+			s := driver.Open(id, ocfl.Options{Create: true, Version: ocfl.NEW})
+			for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+				s.Put(name, strings.NewReader("hello "+name))
+			}
+			s.Commit(ocfl.CommitInfo{})
+		}
+
+		d := driver.driver.(*fs.Driver)
+		path, _ := d.ObjectPath("urn:test/b")
+		_ = ioutil.WriteFile(filepath.Join(path, "v1", "content", "c.txt"), []byte("corrupt"), 0644)
+
+		check := func(opts fs.FixityOptions, ids ...string) map[string]*fs.FixityReport {
+			reports := make(map[string]*fs.FixityReport)
+			err := d.CheckFixity(context.Background(), opts, func(r *fs.FixityReport) error {
+				reports[r.ID] = r
+				return nil
+			}, ids...)
+			if err != nil {
+				t.Fatalf("fixity check failed %+v", err)
+			}
+			return reports
+		}
+
+		reports := check(fs.FixityOptions{})
+		if len(reports) != 2 || !reports["urn:test/a"].Passed() || reports["urn:test/b"].Passed() {
+			t.Fatalf("Wrong fixity results %+v", reports)
+		}
+		failures := reports["urn:test/b"].Failures
+		if len(failures) != 1 || failures[0].Code != metadata.E092 || failures[0].Path != "v1/content/c.txt" {
+			t.Errorf("Expected a content failure, got %v", failures)
+		}
+		if diff := deep.Equal(reports["urn:test/a"].Algorithms, []metadata.DigestAlgorithm{"sha512"}); diff != nil {
+			t.Error(diff)
+		}
+		if reports["urn:test/a"].Checked != 4 {
+			t.Errorf("Expected 4 files checked, got %d", reports["urn:test/a"].Checked)
+		}
+
+		// Sampling checks a fraction of each object's files
+		reports = check(fs.FixityOptions{Sample: 50}, "urn:test/a")
+		if len(reports) != 1 || reports["urn:test/a"].Checked != 2 {
+			t.Errorf("Expected 2 sampled files of urn:test/a, got %+v", reports)
+		}
+
+		// Algorithms an object does not use are not checked
+		reports = check(fs.FixityOptions{Algorithms: []metadata.DigestAlgorithm{"md5"}}, "urn:test/b")
+		if r := reports["urn:test/b"]; !r.Passed() || r.Checked != 0 || len(r.Algorithms) != 0 {
+			t.Errorf("Expected nothing checked, got %+v", r)
+		}
+
+		for _, opts := range []fs.FixityOptions{
+			{Sample: 101},
+			{Algorithms: []metadata.DigestAlgorithm{"bogus"}},
+		} {
+			if err := d.CheckFixity(context.Background(), opts, func(*fs.FixityReport) error { return nil }); err == nil {
+				t.Errorf("Expected invalid options %+v to fail", opts)
+			}
+		}
+	})
+}
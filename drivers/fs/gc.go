@@ -0,0 +1,147 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/karrick/godirwalk"
+	"github.com/pkg/errors"
+)
+
+// GCOptions configure garbage collection
+type GCOptions struct {
+	DryRun bool // If true, debris is only reported, not removed
+}
+
+// Debris is a file or directory left behind by an interrupted write
+type Debris struct {
+	Path   string // Absolute path of the file or directory
+	Reason string // Why it is considered debris
+}
+
+// Reasons that files or directories are considered debris
+const (
+	TempFile           = "temporary file"
+	UncommittedVersion = "uncommitted version"
+	UnreferencedFile   = "content not in manifest"
+)
+
+// GC finds debris left behind by crashes or failed writes, and removes it unless
+// DryRun is specified.  Debris comprises temporary files from atomic writes (in the OCFL
+// root, or the staging directory), version directories that were never committed
+// to an object's inventory, and files in version content directories that are
+// not referenced by the object's manifest.  Symbolic links are never followed.
+//
+// GC must not be run while other processes write to the OCFL root, as it cannot
+// distinguish their work in progress from debris.
+func (d *Driver) GC(ctx context.Context, opts GCOptions, cb func(Debris) error) error {
+	if d.root == nil {
+		return fmt.Errorf("cannot collect garbage: please define an OCFL root")
+	}
+
+	collect := func(debris Debris) error {
+		if err := cb(debris); err != nil {
+			return err
+		}
+		if opts.DryRun {
+			return nil
+		}
+		return errors.Wrapf(os.RemoveAll(debris.Path), "could not remove %s", debris.Path)
+	}
+
+	if d.cfg.Staging != "" {
+		if err := gcStaging(d.cfg.Staging, collect); err != nil {
+			return err
+		}
+	}
+
+	return fsWalk(d.root.Addr, SkipSymlinks, true, func(ospath string, e *godirwalk.Dirent) (bool, error) {
+		if err := ctx.Err(); err != nil {
+			return dontGoDeeper, err
+		}
+
+		if strings.HasPrefix(e.Name(), AtomicPrefix) {
+			return dontGoDeeper, collect(Debris{Path: ospath, Reason: TempFile})
+		}
+
+		if !e.IsDir() {
+			return dontGoDeeper, nil
+		}
+
+		if isObject, _, err := isRoot(ospath, ocfl.Object); isObject && err == nil {
+			return dontGoDeeper, gcObject(ospath, collect)
+		}
+
+		return goDeeper, nil
+	})
+}
+
+// Collects temporary files in the staging directory
+func gcStaging(dir string, collect func(Debris) error) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return errors.Wrapf(err, "could not read staging directory %s", dir)
+	}
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), AtomicPrefix) {
+			if err := collect(Debris{Path: filepath.Join(dir, entry.Name()), Reason: TempFile}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Collects temporary files, uncommitted versions, and unreferenced content within an object
+func gcObject(objRoot string, collect func(Debris) error) error {
+	inv, err := ReadInventory(objRoot)
+	if err != nil {
+		return err
+	}
+
+	manifest := make(map[string]bool)
+	for _, paths := range inv.Manifest {
+		for _, p := range paths {
+			manifest[p] = true
+		}
+	}
+
+	return fsWalk(objRoot, SkipSymlinks, true, func(ospath string, e *godirwalk.Dirent) (bool, error) {
+		if ospath == objRoot {
+			return goDeeper, nil
+		}
+
+		if strings.HasPrefix(e.Name(), AtomicPrefix) {
+			return dontGoDeeper, collect(Debris{Path: ospath, Reason: TempFile})
+		}
+
+		rel := filepath.ToSlash(strings.TrimPrefix(ospath, objRoot+string(filepath.Separator)))
+		segments := strings.Split(rel, "/")
+		version := segments[0]
+
+		// Only version directories are subject to collection; leave logs, extensions, etc. alone
+		if !metadata.VersionID(version).Valid() {
+			return dontGoDeeper, nil
+		}
+
+		if _, committed := inv.Versions[version]; !committed {
+			if !e.IsDir() {
+				return dontGoDeeper, nil
+			}
+			return dontGoDeeper, collect(Debris{Path: ospath, Reason: UncommittedVersion})
+		}
+
+		if len(segments) > 2 && segments[1] == inv.ContentDirectory() && e.IsRegular() && !manifest[rel] {
+			return dontGoDeeper, collect(Debris{Path: ospath, Reason: UnreferencedFile})
+		}
+
+		return goDeeper, nil
+	})
+}
@@ -0,0 +1,109 @@
+package fs_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/fspath"
+	"github.com/go-test/deep"
+)
+
+func TestGC(t *testing.T) {
+	runInTempDir(t, func(tempDir string) {
+		ocflRoot := filepath.Join(tempDir, "root")
+		staging := filepath.Join(tempDir, "staging")
+		_ = fs.MkRoot(ocflRoot)
+		_ = os.Mkdir(staging, 0755)
+
+		driver, err := fs.NewDriver(fs.Config{
+			Root:        ocflRoot,
+			ObjectPaths: fspath.GeneratorFunc(url.QueryEscape),
+			FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
+			Staging:     staging,
+		})
+		if err != nil {
+			t.Fatalf("Error setting up driver %+v", err)
+		}
+
+		for _, lpath := range []string{"a", "b"} {
+			session, _ := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+			_ = session.Put(lpath, strings.NewReader(lpath))
+			if err = session.Commit(ocfl.CommitInfo{}); err != nil {
+				t.Fatalf("Commit failed %+v", err)
+			}
+		}
+
+		objRoot := filepath.Join(ocflRoot, url.QueryEscape(objectID))
+		debris := map[string]string{
+			filepath.Join(objRoot, fs.AtomicPrefix+"inventory.json"): fs.TempFile,
+			filepath.Join(objRoot, "v3"):                             fs.UncommittedVersion,
+			filepath.Join(objRoot, "v1", "content", "junk"):          fs.UnreferencedFile,
+			filepath.Join(ocflRoot, fs.AtomicPrefix+"squash"):        fs.TempFile,
+			filepath.Join(staging, fs.AtomicPrefix+"a.123"):          fs.TempFile,
+		}
+		_ = os.MkdirAll(filepath.Join(objRoot, "v3", "content"), 0755)
+		_ = ioutil.WriteFile(filepath.Join(objRoot, "v3", "content", "c"), []byte("c"), 0664)
+		for path, reason := range debris {
+			if reason != fs.UncommittedVersion {
+				_ = ioutil.WriteFile(path, []byte("debris"), 0664)
+			}
+		}
+
+		var expected []string
+		for path, reason := range debris {
+			expected = append(expected, reason+" "+path)
+		}
+		sort.Strings(expected)
+
+		gc := func(opts fs.GCOptions) []string {
+			var found []string
+			err := driver.GC(context.Background(), opts, func(d fs.Debris) error {
+				found = append(found, d.Reason+" "+d.Path)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("GC failed %+v", err)
+			}
+			sort.Strings(found)
+			return found
+		}
+
+		// A dry run reports, but leaves everything in place
+		if diff := deep.Equal(gc(fs.GCOptions{DryRun: true}), expected); diff != nil {
+			t.Errorf("Dry run: %s", diff)
+		}
+		if diff := deep.Equal(gc(fs.GCOptions{DryRun: true}), expected); diff != nil {
+			t.Errorf("Dry run removed debris: %s", diff)
+		}
+
+		if diff := deep.Equal(gc(fs.GCOptions{}), expected); diff != nil {
+			t.Errorf("GC: %s", diff)
+		}
+		for path := range debris {
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("%s was not removed", path)
+			}
+		}
+		if found := gc(fs.GCOptions{}); len(found) > 0 {
+			t.Errorf("Debris remained after GC: %v", found)
+		}
+
+		// The object itself is intact
+		var files []string
+		_ = driver.Walk(ocfl.Select{Type: ocfl.File, Head: true, Sorted: true}, func(ref ocfl.EntityRef) error {
+			files = append(files, ref.ID)
+			return nil
+		}, objectID)
+		if diff := deep.Equal(files, []string{"a", "b"}); diff != nil {
+			t.Error(diff)
+		}
+	})
+}
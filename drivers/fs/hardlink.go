@@ -0,0 +1,54 @@
+package fs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Returns the file content put from the reader may be hard linked from: a regular file,
+// named by its path, and positioned at its start.  Returns nil if there is none.
+func linkableFile(r io.Reader) *os.File {
+	f, ok := r.(*os.File)
+	if !ok {
+		return nil
+	}
+	if pos, err := f.Seek(0, io.SeekCurrent); err != nil || pos != 0 {
+		return nil
+	}
+
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+
+	// Its name must be a path to the file itself, rather than a symlink to it (e.g. /dev/stdin)
+	named, err := os.Lstat(f.Name())
+	if err != nil || !os.SameFile(info, named) {
+		return nil
+	}
+	return f
+}
+
+// Hard links the source file to the destination of a write, in place of whatever was
+// written.  If it cannot be linked, its content is copied to the write instead.
+func linkContent(w *ManagedWrite, src *os.File, dest string) error {
+	tmp := filepath.Join(filepath.Dir(dest), AtomicPrefix+"link."+filepath.Base(dest))
+	_ = os.Remove(tmp)
+
+	if err := os.Link(src.Name(), tmp); err != nil {
+		if _, err := src.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, src); err != nil {
+			return err
+		}
+		return w.Close()
+	}
+	defer os.Remove(tmp) // Remains if dest was already a link to the source
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
@@ -0,0 +1,204 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	iofs "io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/birkland/ocfl/fspath"
+	"github.com/pkg/errors"
+)
+
+// LayoutFile is the file in an OCFL root declaring the storage layout of its objects
+const LayoutFile = "ocfl_layout.json"
+
+// extensionConfigFile is the config file within an extension's directory
+const extensionConfigFile = "config.json"
+
+// Layout is the content of an OCFL root's LayoutFile
+type Layout struct {
+	Extension   string `json:"extension"`
+	Description string `json:"description"`
+}
+
+// ReadLayout reads the storage layout declared by the OCFL root at the given path.
+// Returns nil if the root does not declare one.
+func ReadLayout(root string) (*Layout, error) {
+	return readLayout(os.DirFS(root), root)
+}
+
+// ReadLayoutFS reads the storage layout declared by the OCFL root at the root of the
+// given filesystem.  Returns nil if the root does not declare one.
+func ReadLayoutFS(fsys iofs.FS) (*Layout, error) {
+	return readLayout(fsys, ".")
+}
+
+// Reads the LayoutFile of a root, whose path is given for messages
+func readLayout(fsys iofs.FS, root string) (*Layout, error) {
+	path := filepath.Join(root, LayoutFile)
+	content, err := iofs.ReadFile(fsys, LayoutFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read %s", path)
+	}
+
+	var layout Layout
+	if err := json.Unmarshal(content, &layout); err != nil {
+		return nil, errors.Wrapf(err, "could not parse %s", path)
+	}
+	if layout.Extension == "" {
+		return nil, errors.Errorf("%s does not name a layout extension", path)
+	}
+	return &layout, nil
+}
+
+// ConfigPath is the path of the config of the layout's extension in the given OCFL root
+func (l *Layout) ConfigPath(root string) string {
+	return filepath.Join(root, extensionsDir, l.Extension, extensionConfigFile)
+}
+
+// Generator instantiates the layout, using the config of its extension in the
+// given OCFL root, if present.
+func (l *Layout) Generator(root string) (fspath.Layout, error) {
+	return l.generator(os.DirFS(root), root)
+}
+
+// GeneratorFS instantiates the layout, using the config of its extension in the
+// OCFL root at the root of the given filesystem, if present.
+func (l *Layout) GeneratorFS(fsys iofs.FS) (fspath.Layout, error) {
+	return l.generator(fsys, ".")
+}
+
+func (l *Layout) generator(fsys iofs.FS, root string) (fspath.Layout, error) {
+	config, err := iofs.ReadFile(fsys, path.Join(extensionsDir, l.Extension, extensionConfigFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "could not read %s", l.ConfigPath(root))
+	}
+
+	gen, err := fspath.NewLayout(l.Extension, config)
+	return gen, errors.Wrapf(err, "could not use the storage layout of %s", root)
+}
+
+// Determines whether the given generator is a layout of the same extension, with
+// the same parameters, as the declared layout
+func sameLayout(gen fspath.Generator, declared fspath.Layout) bool {
+	layout, ok := gen.(fspath.Layout)
+	if !ok || layout.Extension() != declared.Extension() {
+		return false
+	}
+
+	a, errA := json.Marshal(layout)
+	b, errB := json.Marshal(declared)
+	return errA == nil && errB == nil && string(a) == string(b)
+}
+
+// Writes the LayoutFile declaring the given layout, and the config of its extension
+func writeLayout(root string, layout fspath.Layout) error {
+	params, err := json.Marshal(layout)
+	if err != nil {
+		return errors.Wrapf(err, "could not serialize the config of %s", layout.Extension())
+	}
+	config := make(map[string]interface{})
+	if err := json.Unmarshal(params, &config); err != nil {
+		return errors.Wrapf(err, "could not serialize the config of %s", layout.Extension())
+	}
+	config["extensionName"] = layout.Extension()
+
+	configDir := filepath.Join(root, extensionsDir, layout.Extension())
+	if err := os.MkdirAll(configDir, dirPermission); err != nil {
+		return errors.Wrapf(err, "could not create extension directory %s", configDir)
+	}
+	if err := writeJSON(filepath.Join(configDir, extensionConfigFile), config); err != nil {
+		return err
+	}
+
+	return writeJSON(filepath.Join(root, LayoutFile), Layout{
+		Extension:   layout.Extension(),
+		Description: layout.Description(),
+	})
+}
+
+func writeJSON(path string, v interface{}) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "could not serialize %s", path)
+	}
+	return errors.Wrapf(ioutil.WriteFile(path, append(content, '\n'), filePermission), "could not write %s", path)
+}
+
+// Returns the canonical form of the given object ID, if the driver's object paths
+// accept several forms of IDs
+func (d *Driver) normalizeID(id string) string {
+	if n, ok := d.cfg.ObjectPaths.(fspath.Normalizer); ok {
+		return n.NormalizeID(id)
+	}
+	return id
+}
+
+// LayoutMismatch describes an object whose directory is not where the
+// driver's object paths would place it
+type LayoutMismatch struct {
+	ID       string // ID of the object
+	Path     string // Path of the object root, relative to the OCFL root
+	Expected string // Path generated from the object's ID
+}
+
+// ObjectPath computes the path of the root of the object with the given ID,
+// according to the driver's object paths (or the root's declared layout).
+// The object need not exist.
+func (d *Driver) ObjectPath(id string) (string, error) {
+	if d.root == nil {
+		return "", fmt.Errorf("cannot compute the path of %s: please define an OCFL root", id)
+	}
+	if d.cfg.ObjectPaths == nil {
+		return "", fmt.Errorf("cannot compute the path of %s: no object path generation function given", id)
+	}
+	id = d.normalizeID(id)
+	if v, ok := d.cfg.ObjectPaths.(fspath.IDValidator); ok {
+		if err := v.ValidateID(id); err != nil {
+			return "", errors.Wrapf(err, "cannot compute the path of %s", id)
+		}
+	}
+
+	return filepath.Join(d.root.Addr, filepath.FromSlash(d.cfg.ObjectPaths.Generate(id))), nil
+}
+
+// CheckLayout verifies that every object in the OCFL root is in the directory
+// that the driver's object paths (or the root's declared layout) generate from
+// its ID, invoking the callback with each object that is not.  Object IDs are
+// always read from inventories, never derived from paths.
+func (d *Driver) CheckLayout(ctx context.Context, cb func(LayoutMismatch) error) error {
+	if d.root == nil {
+		return fmt.Errorf("cannot check layout: please define an OCFL root")
+	}
+	if d.cfg.ObjectPaths == nil {
+		return fmt.Errorf("cannot check layout: no object path generation function given")
+	}
+
+	// Objects are visited one at a time, so mismatches are reported in the order of the walk
+	return visitObjects(ctx, 1, d.sendObjectRoots, func(ctx context.Context, objRoot string) (LayoutMismatch, bool, error) {
+		id, err := readInventoryID(objRoot)
+		if err != nil {
+			return LayoutMismatch{}, false, err
+		}
+
+		rel, err := filepath.Rel(d.root.Addr, objRoot)
+		if err != nil {
+			return LayoutMismatch{}, false, errors.Wrapf(err, "could not relativize %s", objRoot)
+		}
+
+		mismatch := LayoutMismatch{
+			ID:       id,
+			Path:     filepath.ToSlash(rel),
+			Expected: d.cfg.ObjectPaths.Generate(id),
+		}
+		return mismatch, mismatch.Path != mismatch.Expected, nil
+	}, cb)
+}
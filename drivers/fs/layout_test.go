@@ -0,0 +1,249 @@
+package fs_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/fspath"
+	"github.com/birkland/ocfl/objectid"
+	"github.com/go-test/deep"
+)
+
+// A root declaring a layout is written and read according to that layout,
+// regardless of the configured object paths.
+func TestDeclaredLayout(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		_ = fs.MkRoot(ocflRoot)
+
+		layout := `{"extension": "0004-hashed-n-tuple-storage-layout", "description": "hashed n-tuple"}`
+		config := `{"extensionName": "0004-hashed-n-tuple-storage-layout", "digestAlgorithm": "md5",
+			"tupleSize": 2, "numberOfTuples": 15, "shortObjectRoot": true}`
+		configDir := filepath.Join(ocflRoot, "extensions", fspath.HashedNTupleLayout)
+		_ = os.MkdirAll(configDir, 0755)
+		_ = ioutil.WriteFile(filepath.Join(ocflRoot, fs.LayoutFile), []byte(layout), 0644)
+		_ = ioutil.WriteFile(filepath.Join(configDir, "config.json"), []byte(config), 0644)
+
+		d, err := fs.NewDriver(fs.Config{
+			Root:        ocflRoot,
+			ObjectPaths: fspath.GeneratorFunc(url.QueryEscape),
+			FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
+		})
+		if err != nil {
+			t.Fatalf("could not create driver %+v", err)
+		}
+		driver := driverWrapper{driver: d, t: t, root: ocflRoot}
+
+		session := driver.Open("object-01", ocfl.Options{Create: true, Version: ocfl.NEW})
+		session.Put("hello.txt", strings.NewReader("hello"))
+		session.Commit(ocfl.CommitInfo{})
+
+		objRoot := filepath.Join(ocflRoot, "ff/75/53/44/92/48/5e/ab/b3/9f/86/35/67/28/88/4e")
+		if _, err := os.Stat(filepath.Join(objRoot, "inventory.json")); err != nil {
+			t.Fatalf("object was not written according to the declared layout %+v", err)
+		}
+
+		var refs []ocfl.EntityRef
+		driver.Walk(ocfl.Select{Type: ocfl.Object}, func(ref ocfl.EntityRef) error {
+			refs = append(refs, ref)
+			return nil
+		}, "object-01")
+		if len(refs) != 1 || refs[0].Addr != objRoot {
+			t.Errorf("object was not found at %s: %v", objRoot, refs)
+		}
+	})
+}
+
+func TestBadDeclaredLayout(t *testing.T) {
+	cases := map[string]string{
+		"badJSON":     `{`,
+		"noExtension": `{"description": "nothing"}`,
+		"unknownName": `{"extension": "9999-no-such-layout"}`,
+	}
+
+	for name, layout := range cases {
+		layout := layout
+		t.Run(name, func(t *testing.T) {
+			runInTempDir(t, func(ocflRoot string) {
+				_ = fs.MkRoot(ocflRoot)
+				_ = ioutil.WriteFile(filepath.Join(ocflRoot, fs.LayoutFile), []byte(layout), 0644)
+
+				if _, err := fs.NewDriver(fs.Config{Root: ocflRoot}); err == nil {
+					t.Errorf("Expected an error")
+				}
+			})
+		})
+	}
+}
+
+// A root initialized with a layout declares it, so that drivers use it
+func TestInitRootLayout(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		layout := fspath.NewHashedNTuple()
+		layout.DigestAlgorithm = "md5"
+		layout.TupleSize = 2
+
+		if err := fs.InitRoot(ocflRoot, layout); err != nil {
+			t.Fatalf("could not initialize root %+v", err)
+		}
+
+		declared, err := fs.ReadLayout(ocflRoot)
+		if err != nil {
+			t.Fatalf("could not read layout %+v", err)
+		}
+		if declared.Extension != fspath.HashedNTupleLayout {
+			t.Errorf("wrong extension %s", declared.Extension)
+		}
+
+		gen, err := declared.Generator(ocflRoot)
+		if err != nil {
+			t.Fatalf("could not instantiate layout %+v", err)
+		}
+		if diff := deep.Equal(gen, fspath.Generator(layout)); diff != nil {
+			t.Errorf("layout did not survive a round trip: %v", diff)
+		}
+	})
+}
+
+func TestFlatDirectLayout(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		_ = fs.InitRoot(ocflRoot, fspath.FlatDirect{})
+		d, err := fs.NewDriver(fs.Config{
+			Root:      ocflRoot,
+			FilePaths: fspath.GeneratorFunc(fs.Passthrough),
+		})
+		if err != nil {
+			t.Fatalf("could not create driver %+v", err)
+		}
+		driver := driverWrapper{driver: d, t: t, root: ocflRoot}
+
+		if _, err := d.Open("a/b", ocfl.Options{Create: true, Version: ocfl.NEW}); err == nil {
+			t.Errorf("an ID with a solidus should have been rejected")
+		}
+
+		session := driver.Open("urn:obj", ocfl.Options{Create: true, Version: ocfl.NEW})
+		session.Put("hello.txt", strings.NewReader("hello"))
+		session.Commit(ocfl.CommitInfo{})
+
+		if _, err := os.Stat(filepath.Join(ocflRoot, "urn:obj", "inventory.json")); err != nil {
+			t.Errorf("object was not written according to the declared layout %+v", err)
+		}
+	})
+}
+
+func TestCheckLayout(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		for _, id := range []string{"obj1", "urn:obj2"} {
+			session := driver.Open(id, ocfl.Options{Create: true, Version: ocfl.NEW})
+			session.Put("hello.txt", strings.NewReader("hello"))
+			session.Commit(ocfl.CommitInfo{})
+		}
+
+		d := driver.driver.(*fs.Driver)
+		path, err := d.ObjectPath("urn:obj2")
+		if err != nil {
+			t.Fatalf("could not compute object path %+v", err)
+		}
+		if path != filepath.Join(driver.root, "urn%3Aobj2") {
+			t.Errorf("wrong object path %s", path)
+		}
+
+		// Move an object somewhere the layout would not put it
+		_ = os.MkdirAll(filepath.Join(driver.root, "misplaced"), 0755)
+		_ = os.Rename(path, filepath.Join(driver.root, "misplaced", "obj2"))
+
+		var mismatches []fs.LayoutMismatch
+		err = d.CheckLayout(context.Background(), func(m fs.LayoutMismatch) error {
+			mismatches = append(mismatches, m)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("check failed %+v", err)
+		}
+
+		expected := []fs.LayoutMismatch{{ID: "urn:obj2", Path: "misplaced/obj2", Expected: "urn%3Aobj2"}}
+		if diff := deep.Equal(mismatches, expected); diff != nil {
+			t.Error(diff)
+		}
+	})
+}
+
+// A configured layout that specializes the declared one is used, as long as its parameters agree
+func TestFedoraLayout(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		_ = fs.InitRoot(ocflRoot, fspath.NewHashedNTuple())
+
+		d, err := fs.NewDriver(fs.Config{
+			Root:        ocflRoot,
+			ObjectPaths: fspath.NewFedora(),
+			FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
+		})
+		if err != nil {
+			t.Fatalf("could not create driver %+v", err)
+		}
+
+		path, _ := d.ObjectPath("a/b")
+		if expected := filepath.Join(ocflRoot, fspath.NewHashedNTuple().Generate("info:fedora/a/b")); path != expected {
+			t.Errorf("Expected %s, got %s", expected, path)
+		}
+
+		// Resource paths are recorded as Fedora IDs
+		driver := driverWrapper{driver: d, t: t, root: ocflRoot}
+		session := driver.Open("/a/b", ocfl.Options{Create: true, Version: ocfl.NEW})
+		session.Put("hello.txt", strings.NewReader("hello"))
+		session.Commit(ocfl.CommitInfo{})
+
+		inv, err := fs.ReadInventory(path)
+		if err != nil {
+			t.Fatalf("could not read inventory %+v", err)
+		}
+		if inv.ID != "info:fedora/a/b" {
+			t.Errorf("Expected a Fedora ID, got %s", inv.ID)
+		}
+
+		// Different parameters than the root declares
+		mismatched := fspath.NewFedora()
+		mismatched.TupleSize = 2
+		d, err = fs.NewDriver(fs.Config{Root: ocflRoot, ObjectPaths: mismatched})
+		if err != nil {
+			t.Fatalf("could not create driver %+v", err)
+		}
+
+		path, _ = d.ObjectPath("info:fedora/a/b")
+		if expected := filepath.Join(ocflRoot, fspath.NewHashedNTuple().Generate("info:fedora/a/b")); path != expected {
+			t.Errorf("Expected the declared layout to be used: %s, got %s", expected, path)
+		}
+	})
+}
+
+// Objects whose IDs are unsuitable for their object paths are rejected at Open
+func TestObjectIDConstraints(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		_ = fs.MkRoot(ocflRoot)
+		d, err := fs.NewDriver(fs.Config{
+			Root:        ocflRoot,
+			ObjectPaths: objectid.Generator{Encoding: objectid.Query, Constraints: objectid.Portable},
+			FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
+		})
+		if err != nil {
+			t.Fatalf("could not create driver %+v", err)
+		}
+		driver := driverWrapper{driver: d, t: t, root: ocflRoot}
+
+		session := driver.Open("urn:Obj", ocfl.Options{Create: true, Version: ocfl.NEW})
+		session.Put("hello.txt", strings.NewReader("hello"))
+		session.Commit(ocfl.CommitInfo{})
+
+		for _, id := range []string{"urn:obj", strings.Repeat("x", 300)} {
+			if _, err := d.Open(id, ocfl.Options{Create: true, Version: ocfl.NEW}); err == nil {
+				t.Errorf("%s should have been rejected", id)
+			}
+		}
+	})
+}
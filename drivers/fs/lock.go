@@ -0,0 +1,96 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// LockDir is the directory, relative to the OCFL root, containing object lock files.
+// It is kept within the driver's LocalExtension.
+const LockDir = extensionsDir + "/" + LocalExtension + "/" + locksDir
+
+const locksDir = "locks"
+
+// DefaultLockTimeout is how long Open waits for another process to release an object's
+// lock, if the driver's config does not specify a timeout
+const DefaultLockTimeout = 30 * time.Second
+
+// How often a held lock is checked for release
+const lockPollInterval = 50 * time.Millisecond
+
+// ErrLocked is returned (as the cause of an error) when an object's lock could not
+// be acquired before the timeout.
+var ErrLocked = errors.New("object is locked")
+
+// Acquires the advisory lock of the given object, by exclusively creating its lock file.
+// Waits until the lock is released, the configured timeout passes, or the context is done.
+// Returns a function that releases the lock.
+func (d *Driver) lock(ctx context.Context, id string) (func() error, error) {
+	dir, err := mkLocalExtensionDir(d.root.Addr, locksDir)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := d.cfg.LockTimeout
+	if timeout == 0 {
+		timeout = DefaultLockTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	path := filepath.Join(dir, url.QueryEscape(id)+".lock")
+	for {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, filePermission)
+		if err == nil {
+			host, _ := os.Hostname()
+			_, err = fmt.Fprintf(file, "pid %d on %s at %s\n", os.Getpid(), host, time.Now().Format(time.RFC3339))
+			if e := file.Close(); err == nil {
+				err = e
+			}
+			if err != nil {
+				_ = os.Remove(path)
+				return nil, errors.Wrapf(err, "could not write lock file %s", path)
+			}
+
+			return func() error {
+				err := os.Remove(path)
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return errors.Wrapf(err, "could not release lock %s", path)
+			}, nil
+		}
+
+		if !os.IsExist(err) {
+			return nil, errors.Wrapf(err, "could not create lock file %s", path)
+		}
+
+		if time.Now().After(deadline) {
+			return nil, errors.Wrapf(ErrLocked, "timed out waiting for lock on %s (if no other process holds it, remove %s)", id, path)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// Takes the advisory lock of an object about to be modified outside of a session (e.g.
+// by Squash or Purge), if the driver is configured to lock objects.  Returns a
+// function that releases the lock.
+func (d *Driver) lockObject(id string) (func() error, error) {
+	if !d.cfg.Lock {
+		return func() error { return nil }, nil
+	}
+	if d.root == nil {
+		return nil, fmt.Errorf("cannot lock %s: please define an OCFL root", id)
+	}
+	return d.lock(context.Background(), d.normalizeID(id))
+}
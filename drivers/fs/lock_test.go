@@ -0,0 +1,239 @@
+package fs_test
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/fspath"
+	"github.com/pkg/errors"
+)
+
+func lockingDriver(t *testing.T, ocflRoot string, timeout time.Duration) *fs.Driver {
+	_ = fs.MkRoot(ocflRoot)
+	d, err := fs.NewDriver(fs.Config{
+		Root:        ocflRoot,
+		ObjectPaths: fspath.GeneratorFunc(url.QueryEscape),
+		FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
+		Lock:        true,
+		LockTimeout: timeout,
+	})
+	if err != nil {
+		t.Fatalf("Error setting up driver %+v", err)
+	}
+	return d
+}
+
+func TestLockTimeout(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		driver := lockingDriver(t, ocflRoot, 100*time.Millisecond)
+
+		first, err := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		if err != nil {
+			t.Fatalf("Could not open session %+v", err)
+		}
+
+		_, err = driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		if errors.Cause(err) != fs.ErrLocked {
+			t.Fatalf("Expected a lock error, got %+v", err)
+		}
+
+		if _, err = os.Stat(filepath.Join(ocflRoot, filepath.FromSlash(fs.LockDir), url.QueryEscape(objectID)+".lock")); err != nil {
+			t.Errorf("Expected the lock file in %s: %v", fs.LockDir, err)
+		}
+
+		// Readers do not need the lock
+		if _, err = driver.Open(objectID, ocfl.Options{Version: ocfl.HEAD}); err != nil && errors.Cause(err) == fs.ErrLocked {
+			t.Errorf("Readers should not wait for the lock")
+		}
+
+		_ = first.Put("a", strings.NewReader("a"))
+		if err = first.Commit(ocfl.CommitInfo{}); err != nil {
+			t.Fatalf("Commit failed %+v", err)
+		}
+
+		second, err := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		if err != nil {
+			t.Fatalf("Lock should have been released by commit: %+v", err)
+		}
+		_ = second.Close()
+	})
+}
+
+func TestCloseUncommitted(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		driver := lockingDriver(t, ocflRoot, 100*time.Millisecond)
+
+		session, _ := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		_ = session.Put("a", strings.NewReader("a"))
+		if err := session.Close(); err != nil {
+			t.Fatalf("Close failed %+v", err)
+		}
+
+		if _, err := os.Stat(filepath.Join(ocflRoot, url.QueryEscape(objectID))); !os.IsNotExist(err) {
+			t.Errorf("Uncommitted object was not removed")
+		}
+		if err := session.Put("b", strings.NewReader("b")); err == nil {
+			t.Errorf("Put should fail after Close")
+		}
+
+		session, err := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		if err != nil {
+			t.Fatalf("Lock should have been released by close: %+v", err)
+		}
+		_ = session.Close()
+	})
+}
+
+func TestDiscardUnchangedReleasesLock(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		driver := lockingDriver(t, ocflRoot, 100*time.Millisecond)
+
+		session, _ := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		_ = session.Put("a", strings.NewReader("a"))
+		if err := session.Commit(ocfl.CommitInfo{}); err != nil {
+			t.Fatalf("Commit failed %+v", err)
+		}
+
+		session, _ = driver.Open(objectID, ocfl.Options{Version: ocfl.NEW, Unchanged: ocfl.DiscardUnchanged})
+		if err := session.Commit(ocfl.CommitInfo{}); err != nil {
+			t.Fatalf("Discarding an unchanged version failed %+v", err)
+		}
+
+		session, err := driver.Open(objectID, ocfl.Options{Version: ocfl.NEW})
+		if err != nil {
+			t.Fatalf("Lock should have been released by discarding: %+v", err)
+		}
+		_ = session.Close()
+	})
+}
+
+func TestConcurrentLockedWriters(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		driver := lockingDriver(t, ocflRoot, 10*time.Second)
+
+		const writers = 8
+		var wg sync.WaitGroup
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				session, err := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+				if err != nil {
+					t.Errorf("Could not open session %+v", err)
+					return
+				}
+				defer session.Close()
+
+				lpath := fmt.Sprintf("file%d", i)
+				_ = session.Put(lpath, strings.NewReader(lpath))
+				if err = session.Commit(ocfl.CommitInfo{}); err != nil {
+					t.Errorf("Commit failed %+v", err)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		var files int
+		_ = driver.Walk(ocfl.Select{Type: ocfl.File, Head: true}, func(ocfl.EntityRef) error {
+			files++
+			return nil
+		}, objectID)
+		if files != writers {
+			t.Errorf("Expected %d files in the head version, got %d", writers, files)
+		}
+	})
+}
+
+// Without locks, the second of two concurrent writers fails to commit
+func TestCommitConflict(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		v1 := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		v1.Put("file0", strings.NewReader("v1 content"))
+		v1.Commit(ocfl.CommitInfo{})
+
+		first := driver.Open(objectID, ocfl.Options{Version: ocfl.NEW})
+		second, err := driver.driver.Open(objectID, ocfl.Options{Version: ocfl.NEW})
+		if err != nil {
+			t.Fatalf("Could not open second session %+v", err)
+		}
+
+		first.Put("file1", strings.NewReader("first"))
+		first.Commit(ocfl.CommitInfo{})
+
+		_ = second.Put("file2", strings.NewReader("second"))
+		err = second.Commit(ocfl.CommitInfo{})
+		if errors.Cause(err) != ocfl.ErrConflict {
+			t.Fatalf("Expected a conflict, got %+v", err)
+		}
+
+		var files []string
+		_ = driver.driver.Walk(ocfl.Select{Type: ocfl.File, Head: true, Sorted: true}, func(ref ocfl.EntityRef) error {
+			files = append(files, ref.ID)
+			return nil
+		}, objectID)
+		if strings.Join(files, ",") != "file0,file1" {
+			t.Errorf("The first writer's version was not preserved: %v", files)
+		}
+
+		if err := second.Close(); err != nil {
+			t.Fatalf("Could not close conflicting session %+v", err)
+		}
+		if _, err := os.Stat(filepath.Join(driver.root, url.QueryEscape(objectID), "v2", "content", "file1")); err != nil {
+			t.Errorf("The first writer's content was removed: %+v", err)
+		}
+	})
+}
+
+func TestOperationsTakeLock(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		driver := lockingDriver(t, ocflRoot, 100*time.Millisecond)
+
+		for v := 0; v < 2; v++ {
+			session, _ := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+			_ = session.Put(fmt.Sprintf("file%d", v), strings.NewReader("content"))
+			if err := session.Commit(ocfl.CommitInfo{}); err != nil {
+				t.Fatalf("Commit failed %+v", err)
+			}
+		}
+
+		const other = "urn:test/other"
+		held, err := driver.Open(objectID, ocfl.Options{Version: ocfl.NEW})
+		if err != nil {
+			t.Fatalf("Could not open session %+v", err)
+		}
+		otherSession, err := driver.Open(other, ocfl.Options{Create: true, Version: ocfl.NEW})
+		if err != nil {
+			t.Fatalf("Could not open session %+v", err)
+		}
+
+		operations := map[string]func() error{
+			"rollback": func() error { return driver.Rollback(objectID) },
+			"purge":    func() error { return driver.Purge(objectID, ocfl.PurgeOptions{}) },
+			"squash":   func() error { return driver.Squash(objectID, "v1", "v2") },
+			"dedup": func() error {
+				_, err := driver.Dedup(objectID, fs.DedupOptions{})
+				return err
+			},
+			"clone": func() error { return driver.Clone(objectID, other, fs.CloneOptions{}) },
+		}
+		for name, op := range operations {
+			if err := op(); errors.Cause(err) != fs.ErrLocked {
+				t.Errorf("expected %s to wait for the lock, got %+v", name, err)
+			}
+		}
+
+		_ = held.Close()
+		_ = otherSession.Close()
+		if err := driver.Rollback(objectID); err != nil {
+			t.Errorf("rollback failed once the lock was released %+v", err)
+		}
+	})
+}
@@ -0,0 +1,82 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+)
+
+// MergeOptions configure how the histories of objects are merged
+type MergeOptions struct {
+	Order  metadata.MergeOrder // How versions of the merged objects are ordered
+	Prefix string              // If given, the logical paths of the second object are placed under this directory
+	Link   bool                // Hard link content rather than copying it, falling back to copying if linking fails
+}
+
+// Merge creates a new object with the given ID, combining the histories of two existing objects,
+// e.g. when consolidating repositories.  The merged object uses the content directory and
+// version padding of the first.  See metadata.Merge for how versions and content are combined.
+// Like a clone, the merged object is assembled in a temporary directory, and moved into place
+// only when complete.  The merged objects are left as they are.  If the driver locks objects,
+// the merged object's lock is held throughout.
+func (d *Driver) Merge(first, other, dest string, opts MergeOptions) (err error) {
+	if d.cfg.ObjectPaths == nil {
+		return fmt.Errorf("no object path generation function given!  (check driver config)")
+	}
+
+	dest = d.normalizeID(dest)
+
+	unlock, err := d.lockObject(dest)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := unlock(); err == nil {
+			err = e
+		}
+	}()
+
+	dirs := make(map[string]string, 2)
+	invs := make([]*metadata.Inventory, 0, 2)
+	for _, id := range []string{d.normalizeID(first), d.normalizeID(other)} {
+		obj, inv, err := d.readObject(context.Background(), id)
+		if err != nil {
+			return errors.Wrapf(err, "could not read object %s", id)
+		}
+		if obj == nil {
+			return errors.Wrap(ocfl.ErrNotFound, id)
+		}
+		dirs[inv.ID] = obj.Addr
+		invs = append(invs, inv)
+	}
+	if invs[0].ID == invs[1].ID {
+		return fmt.Errorf("cannot merge %s with itself", invs[0].ID)
+	}
+
+	destDir, err := d.newObjectDir(dest)
+	if err != nil {
+		return err
+	}
+
+	inv, contentSources, err := metadata.Merge(invs[0], invs[1], metadata.MergeOptions{
+		Order:  opts.Order,
+		Prefix: opts.Prefix,
+	})
+	if err != nil {
+		return err
+	}
+	inv.ID = dest
+
+	sources := make(map[string]string, len(contentSources))
+	for p, src := range contentSources {
+		sources[p] = filepath.Join(dirs[src.ID], filepath.FromSlash(src.Path))
+	}
+
+	err = createObject(destDir, inv, sources, opts.Link)
+	d.invalidateID(dest)
+	return err
+}
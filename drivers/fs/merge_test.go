@@ -0,0 +1,81 @@
+package fs_test
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/go-test/deep"
+)
+
+func TestMergeObjects(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		d := driver.driver.(*fs.Driver)
+		other, merged := "urn:test/other", "urn:test/merged"
+		start := time.Now()
+
+		commit := func(id string, at time.Duration, files ...string) {
+			session := driver.Open(id, ocfl.Options{Create: true, Version: ocfl.NEW})
+			for _, f := range files {
+				session.Put(f, strings.NewReader(f))
+			}
+			session.Commit(ocfl.CommitInfo{Message: id, Date: start.Add(at)})
+		}
+		commit(objectID, 0, "a")
+		commit(other, time.Hour, "a", "b")
+		commit(objectID, 2*time.Hour, "c")
+
+		err := d.Merge(objectID, other, merged, fs.MergeOptions{Order: metadata.InterleaveVersions, Prefix: "other"})
+		if err != nil {
+			t.Fatalf("merge failed %+v", err)
+		}
+		if err = d.Merge(objectID, other, merged, fs.MergeOptions{}); err == nil {
+			t.Errorf("merging into an existing object should fail")
+		}
+
+		path, _ := d.ObjectPath(merged)
+		inv, err := fs.ReadInventory(path)
+		if err != nil {
+			t.Fatalf("could not read merged inventory %+v", err)
+		}
+
+		files, _ := inv.Files(inv.Head)
+		var paths []string
+		for _, f := range files {
+			content, err := ioutil.ReadFile(filepath.Join(path, f.PhysicalPath))
+			if err != nil || string(content) != filepath.Base(f.LogicalPath) {
+				t.Errorf("bad content for %s: %v", f.LogicalPath, err)
+			}
+			paths = append(paths, f.LogicalPath)
+		}
+		sort.Strings(paths)
+		if diff := deep.Equal(paths, []string{"a", "c", "other/a", "other/b"}); diff != nil {
+			t.Errorf("bad files in %s: %s", inv.Head, diff)
+		}
+
+		var messages []string
+		for _, v := range inv.VersionNames() {
+			messages = append(messages, inv.Versions[v].Message)
+		}
+		if diff := deep.Equal(messages, []string{objectID, other, objectID}); diff != nil {
+			t.Errorf("versions should be interleaved: %s", diff)
+		}
+
+		// Identical content is stored once
+		if len(inv.Manifest) != 3 {
+			t.Errorf("expected 3 content files, got %v", inv.Manifest)
+		}
+
+		report, err := fs.ValidatePath(context.Background(), path, fs.ValidateOptions{})
+		if err != nil || !report.Valid() {
+			t.Errorf("merged object should be valid: %+v %+v", report, err)
+		}
+	})
+}
@@ -0,0 +1,411 @@
+package fs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+)
+
+// ArchiveFormat is the format of an archive containing a packed object
+type ArchiveFormat string
+
+// Supported archive formats
+const (
+	ZipArchive ArchiveFormat = "zip"
+	TarArchive ArchiveFormat = "tar"
+)
+
+// ArchiveFormatOf determines the format of an archive from its file name
+func ArchiveFormatOf(name string) (ArchiveFormat, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".zip":
+		return ZipArchive, nil
+	case ".tar":
+		return TarArchive, nil
+	}
+	return "", fmt.Errorf("cannot determine the archive format of %s (expected .zip or .tar)", name)
+}
+
+// PackOptions configure how an object is packed
+type PackOptions struct {
+	Format  ArchiveFormat
+	Version string // Pack only the versions up to and including this one (default: all)
+	Prefix  string // Name of a directory at the top of the archive containing the object root (default: none)
+}
+
+// Pack writes an object into a single archive, preserving the exact layout of its object
+// root, i.e. paths in the archive are relative to it (or to the given prefix within the
+// archive), and include every inventory, sidecar, and extension.  Temporary files of
+// interrupted operations are left out.  Content is streamed into the archive as it is
+// read, without creating any temporary files, so the writer may be e.g. a pipe or an HTTP
+// response.
+//
+// If a version is given, later versions are left out, and the root inventory (and its
+// sidecar) is that of the given version, so the archive contains the object as it was
+// when that version was committed.  The object should not be modified while it is packed.
+func (d *Driver) Pack(ctx context.Context, id string, w io.Writer, opts PackOptions) error {
+	var archive archiveWriter
+	switch opts.Format {
+	case ZipArchive:
+		archive = &zipWriter{zip.NewWriter(w)}
+	case TarArchive:
+		archive = &tarWriter{tar.NewWriter(w)}
+	default:
+		return fmt.Errorf("unsupported archive format %q", opts.Format)
+	}
+
+	if err := d.pack(ctx, id, archive, opts); err != nil {
+		return err
+	}
+	return errors.Wrapf(archive.Close(), "could not pack %s", id)
+}
+
+// WriteTar writes an object into a tar stream, as Pack does, but leaves the stream open
+// so that more may be written to it, e.g. several objects, each under its own prefix.
+// The format of the options is ignored.
+func (d *Driver) WriteTar(ctx context.Context, id string, w *tar.Writer, opts PackOptions) error {
+	return d.pack(ctx, id, &tarWriter{w}, opts)
+}
+
+func (d *Driver) pack(ctx context.Context, id string, archive archiveWriter, opts PackOptions) error {
+	obj, inv, err := d.readObject(ctx, id)
+	if err != nil {
+		return errors.Wrapf(err, "could not read object %s", id)
+	}
+	if obj == nil {
+		return errors.Wrap(ocfl.ErrNotFound, id)
+	}
+
+	last, err := metadata.VersionID(inv.Head).Int()
+	if opts.Version != "" {
+		if _, ok := inv.Versions[opts.Version]; !ok {
+			return errors.Wrapf(ocfl.ErrNotFound, "%s %s", id, opts.Version)
+		}
+		last, err = metadata.VersionID(opts.Version).Int()
+	}
+	if err != nil {
+		return errors.Wrapf(err, "could not pack %s", id)
+	}
+
+	prefix := strings.Trim(opts.Prefix, "/")
+	if strings.Contains(prefix, "/") {
+		return fmt.Errorf("cannot pack %s: prefix %s is not a single directory name", id, opts.Prefix)
+	}
+	if prefix != "" {
+		info, err := os.Stat(obj.Addr)
+		if err != nil {
+			return errors.Wrapf(err, "could not pack %s", id)
+		}
+		if err = archive.add(prefix+"/", info, nil); err != nil {
+			return errors.Wrapf(err, "could not pack %s", id)
+		}
+		prefix += "/"
+	}
+
+	err = filepath.Walk(obj.Addr, func(p string, info os.FileInfo, err error) error {
+		if err != nil || p == obj.Addr {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(obj.Addr, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		top := strings.SplitN(name, "/", 2)[0]
+
+		if strings.HasPrefix(info.Name(), AtomicPrefix) {
+			return skipEntry(info)
+		}
+		if n, err := metadata.VersionID(top).Int(); err == nil {
+			if _, ok := inv.Versions[top]; !ok || n > last {
+				return skipEntry(info)
+			}
+		}
+
+		// The root inventory is that of the last version packed
+		if top == name && strings.HasPrefix(name, metadata.InventoryFile) && opts.Version != "" {
+			p = filepath.Join(obj.Addr, opts.Version, name)
+			if info, err = os.Stat(p); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case info.IsDir():
+			return archive.add(prefix+name+"/", info, nil)
+		case info.Mode().IsRegular():
+			file, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			return archive.add(prefix+name, info, &contextReader{ctx: ctx, Reader: file})
+		}
+		return fmt.Errorf("cannot pack %s: not a regular file or directory", p)
+	})
+	return errors.Wrapf(err, "could not pack %s", id)
+}
+
+// Unpack creates an object from an archive written by Pack, whose format is determined
+// by its file name (see ArchiveFormatOf), and returns the object's ID.  The object root
+// may be the top of the archive, or a single directory within it.  The archive is
+// extracted into a temporary directory within the OCFL root, and must contain a valid
+// object which does not exist in the root; only then is it moved into place.  If the driver
+// locks objects, the object's lock is held while it is moved into place.
+func (d *Driver) Unpack(ctx context.Context, archive string) (id string, err error) {
+	if d.root == nil {
+		return "", fmt.Errorf("cannot unpack %s: please define an OCFL root", archive)
+	}
+	if d.cfg.ObjectPaths == nil {
+		return "", fmt.Errorf("no object path generation function given!  (check driver config)")
+	}
+
+	format, err := ArchiveFormatOf(archive)
+	if err != nil {
+		return "", err
+	}
+
+	staging, err := ioutil.TempDir(d.root.Addr, AtomicPrefix+"unpack.")
+	if err != nil {
+		return "", errors.Wrapf(err, "could not create staging directory in %s", d.root.Addr)
+	}
+	defer os.RemoveAll(staging)
+
+	switch format {
+	case ZipArchive:
+		err = unzip(ctx, archive, staging)
+	case TarArchive:
+		err = untar(ctx, archive, staging)
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "could not extract %s", archive)
+	}
+
+	objRoot, err := unpackedRoot(staging)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not find the object root in %s", archive)
+	}
+
+	report, err := ValidatePath(ctx, objRoot, ValidateOptions{})
+	if err != nil {
+		return "", errors.Wrapf(err, "could not validate the content of %s", archive)
+	}
+	if errs := report.Results.Filter(metadata.SeverityError); len(errs) > 0 {
+		return "", fmt.Errorf("%s does not contain a valid OCFL object (%d errors), e.g. %s", archive, len(errs), errs[0])
+	}
+
+	inv, err := ReadInventory(objRoot)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not read the inventory of %s", archive)
+	}
+
+	unlock, err := d.lockObject(inv.ID)
+	if err != nil {
+		return inv.ID, err
+	}
+	defer func() {
+		if e := unlock(); err == nil {
+			err = e
+		}
+	}()
+
+	dir, err := d.newObjectDir(d.normalizeID(inv.ID))
+	if err != nil {
+		return inv.ID, err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(dir), dirPermission); err != nil {
+		return inv.ID, errors.Wrapf(err, "could not create parent directory of %s", dir)
+	}
+
+	// The destination may be an empty directory, which can't be renamed over
+	_ = os.Remove(dir)
+	err = os.Rename(objRoot, dir)
+	d.invalidateID(inv.ID)
+	return inv.ID, errors.Wrapf(err, "could not move %s into place", inv.ID)
+}
+
+// Finds the object root within an extracted archive: either the top of the archive,
+// or a single directory within it (see PackOptions.Prefix)
+func unpackedRoot(dir string) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, metadata.InventoryFile)); err == nil {
+		return dir, nil
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) != 1 || !entries[0].IsDir() {
+		return "", fmt.Errorf("no root inventory, and not a single directory containing one")
+	}
+	return filepath.Join(dir, entries[0].Name()), nil
+}
+
+// Skips a file or directory when walking
+func skipEntry(info os.FileInfo) error {
+	if info.IsDir() {
+		return filepath.SkipDir
+	}
+	return nil
+}
+
+// archiveWriter adds files and directories (whose names end in a slash) to an archive
+type archiveWriter interface {
+	add(name string, info os.FileInfo, r io.Reader) error
+	Close() error
+}
+
+type zipWriter struct {
+	*zip.Writer
+}
+
+func (z *zipWriter) add(name string, info os.FileInfo, r io.Reader) error {
+	hdr, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if r != nil {
+		hdr.Method = zip.Deflate
+	}
+
+	w, err := z.CreateHeader(hdr)
+	if err != nil || r == nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+type tarWriter struct {
+	*tar.Writer
+}
+
+func (t *tarWriter) add(name string, info os.FileInfo, r io.Reader) error {
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+
+	if err = t.WriteHeader(hdr); err != nil || r == nil {
+		return err
+	}
+	_, err = io.Copy(t, r)
+	return err
+}
+
+// Determines the path an archive entry is extracted to, refusing any
+// that would be outside the given directory.
+func extractedPath(dir, name string) (string, error) {
+	p := path.Clean(name)
+	if path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+		return "", fmt.Errorf("refusing to extract %s outside of the archive's directory", name)
+	}
+	return filepath.Join(dir, filepath.FromSlash(p)), nil
+}
+
+// Extracts a regular file from an archive
+func extractFile(ctx context.Context, dest string, info os.FileInfo, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(dest), dirPermission); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(dest, os.O_CREATE|os.O_EXCL|os.O_WRONLY, filePermission)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, &contextReader{ctx: ctx, Reader: r})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(dest, info.ModTime(), info.ModTime())
+}
+
+func unzip(ctx context.Context, archive, dir string) error {
+	z, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer z.Close()
+
+	for _, f := range z.File {
+		dest, err := extractedPath(dir, f.Name)
+		if err != nil {
+			return err
+		}
+
+		info := f.FileInfo()
+		switch {
+		case info.IsDir():
+			err = os.MkdirAll(dest, dirPermission)
+		case info.Mode().IsRegular():
+			var r io.ReadCloser
+			if r, err = f.Open(); err == nil {
+				err = extractFile(ctx, dest, info, r)
+				r.Close()
+			}
+		default:
+			err = fmt.Errorf("refusing to extract %s: not a regular file or directory", f.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func untar(ctx context.Context, archive, dir string) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	t := tar.NewReader(file)
+	for {
+		hdr, err := t.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		dest, err := extractedPath(dir, hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(dest, dirPermission)
+		case tar.TypeReg:
+			err = extractFile(ctx, dest, hdr.FileInfo(), t)
+		default:
+			err = fmt.Errorf("refusing to extract %s: not a regular file or directory", hdr.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package fs_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/go-test/deep"
+)
+
+func TestPack(t *testing.T) {
+	for _, format := range []fs.ArchiveFormat{fs.ZipArchive, fs.TarArchive} {
+		for _, version := range []string{"", "v1"} {
+			t.Run(string(format)+"/"+version, func(t *testing.T) {
+				runWithDriverWrapper(t, func(driver driverWrapper) {
+					d := driver.driver.(*fs.Driver)
+
+					session := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+					session.Put("a", strings.NewReader("a"))
+					session.Commit(ocfl.CommitInfo{Message: "first"})
+
+					session = driver.Open(objectID, ocfl.Options{Version: ocfl.NEW})
+					session.Put("b", strings.NewReader("b"))
+					session.Commit(ocfl.CommitInfo{Message: "second"})
+
+					archive := driver.root + "_packed." + string(format)
+					defer os.Remove(archive)
+
+					file, err := os.Create(archive)
+					if err != nil {
+						t.Fatal(err)
+					}
+					err = d.Pack(context.Background(), objectID, file, fs.PackOptions{Format: format, Version: version})
+					file.Close()
+					if err != nil {
+						t.Fatalf("pack failed %+v", err)
+					}
+
+					if _, err := d.Unpack(context.Background(), archive); err == nil {
+						t.Errorf("unpacking an existing object should fail")
+					}
+
+					if err = d.Purge(objectID, ocfl.PurgeOptions{}); err != nil {
+						t.Fatalf("purge failed %+v", err)
+					}
+
+					id, err := d.Unpack(context.Background(), archive)
+					if err != nil {
+						t.Fatalf("unpack failed %+v", err)
+					}
+					if id != objectID {
+						t.Errorf("unpacked wrong object %s", id)
+					}
+
+					report, err := d.Validate(context.Background(), objectID, fs.ValidateOptions{})
+					if err != nil {
+						t.Fatalf("validation failed %+v", err)
+					}
+					if !report.Valid() {
+						t.Errorf("unpacked object should be valid: %v", report.Results)
+					}
+
+					expected := map[string][]string{"v1": {"a"}, "v2": {"a", "b"}}
+					if version == "v1" {
+						delete(expected, "v2")
+					}
+					versions := make(map[string][]string)
+					driver.Walk(ocfl.Select{Type: ocfl.File}, func(ref ocfl.EntityRef) error {
+						versions[ref.Parent.ID] = append(versions[ref.Parent.ID], ref.ID)
+						return nil
+					}, objectID)
+					for _, files := range versions {
+						sort.Strings(files)
+					}
+					if diff := deep.Equal(versions, expected); diff != nil {
+						t.Error(diff)
+					}
+				})
+			})
+		}
+	}
+}
+
+func TestUnpackOutsideArchive(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		d := driver.driver.(*fs.Driver)
+
+		archive := driver.root + "_evil.tar"
+		defer os.Remove(archive)
+
+		file, err := os.Create(archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := tar.NewWriter(file)
+		w.WriteHeader(&tar.Header{Name: "../escaped", Mode: 0644, Size: 1, Typeflag: tar.TypeReg})
+		w.Write([]byte("x"))
+		w.Close()
+		file.Close()
+
+		if _, err := d.Unpack(context.Background(), archive); err == nil {
+			t.Errorf("unpacking files outside the archive's directory should fail")
+		}
+		if _, err := os.Stat(filepath.Join(driver.root, "escaped")); !os.IsNotExist(err) {
+			t.Errorf("file was extracted outside of the archive's directory")
+		}
+	})
+}
+
+func TestWriteTar(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		d := driver.driver.(*fs.Driver)
+
+		for _, id := range []string{"urn:test/a", "urn:test/b"} {
+			session := driver.Open(id, ocfl.Options{Create: true, Version: ocfl.NEW})
+			session.Put("file", strings.NewReader(id))
+			session.Commit(ocfl.CommitInfo{})
+		}
+
+		// Several objects in one stream, each in its own directory
+		var buf bytes.Buffer
+		w := tar.NewWriter(&buf)
+		for _, prefix := range []string{"a", "b"} {
+			err := d.WriteTar(context.Background(), "urn:test/"+prefix, w, fs.PackOptions{Prefix: prefix})
+			if err != nil {
+				t.Fatalf("writing tar failed %+v", err)
+			}
+		}
+		w.Close()
+
+		content := make(map[string]string)
+		r := tar.NewReader(&buf)
+		for hdr, err := r.Next(); err == nil; hdr, err = r.Next() {
+			b, _ := ioutil.ReadAll(r)
+			content[hdr.Name] = string(b)
+		}
+		for _, prefix := range []string{"a", "b"} {
+			if _, ok := content[prefix+"/inventory.json"]; !ok {
+				t.Errorf("no inventory of %s in %v", prefix, content)
+			}
+			if c := content[prefix+"/v1/content/file"]; c != "urn:test/"+prefix {
+				t.Errorf("wrong content of %s: %q", prefix, c)
+			}
+		}
+
+		// An object in a directory of an archive can be unpacked
+		archive := driver.root + "_prefixed.tar"
+		defer os.Remove(archive)
+		file, err := os.Create(archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = d.Pack(context.Background(), "urn:test/a", file, fs.PackOptions{Format: fs.TarArchive, Prefix: "a"})
+		file.Close()
+		if err != nil {
+			t.Fatalf("pack failed %+v", err)
+		}
+
+		if err = d.Purge("urn:test/a", ocfl.PurgeOptions{}); err != nil {
+			t.Fatalf("purge failed %+v", err)
+		}
+		if id, err := d.Unpack(context.Background(), archive); err != nil || id != "urn:test/a" {
+			t.Errorf("unpacking from a directory failed: %s %+v", id, err)
+		}
+	})
+}
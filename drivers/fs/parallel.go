@@ -0,0 +1,139 @@
+package fs
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/birkland/ocfl"
+	"github.com/karrick/godirwalk"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+// Visits object roots concurrently, with up to the given number of workers (by
+// default, the number of CPUs).  Each object root sent by the producer is visited
+// by a worker, and its result (unless the visit declined to produce one) is passed
+// to the callback.  Callbacks are never invoked concurrently.  Any error, from the
+// producer, a visit, or a callback, stops the remaining work and is returned.
+func visitObjects[T any](ctx context.Context, workers int,
+	produce func(ctx context.Context, objects chan<- string) error,
+	visit func(ctx context.Context, objRoot string) (result T, ok bool, err error),
+	cb func(T) error) error {
+
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	g, ctx := errgroup.WithContext(ctx)
+
+	objects := make(chan string)
+	results := make(chan T)
+
+	g.Go(func() error {
+		defer close(objects)
+		return produce(ctx, objects)
+	})
+
+	var visiting sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		visiting.Add(1)
+		g.Go(func() error {
+			defer visiting.Done()
+			for objRoot := range objects {
+				result, ok, err := visit(ctx, objRoot)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					continue
+				}
+
+				select {
+				case results <- result:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		})
+	}
+
+	go func() {
+		visiting.Wait()
+		close(results)
+	}()
+
+	var cbErr error
+	for result := range results {
+		if cbErr != nil {
+			continue
+		}
+		if cbErr = cb(result); cbErr != nil {
+			cancel()
+		}
+	}
+
+	err := g.Wait()
+	if cbErr != nil {
+		return cbErr
+	}
+	return err
+}
+
+// Returns a producer that sends the roots of the objects with the given IDs, each of
+// which must exist
+func (d *Driver) sendObjects(ids []string) func(ctx context.Context, objects chan<- string) error {
+	return func(ctx context.Context, objects chan<- string) error {
+		for _, id := range ids {
+			obj, _, err := d.readObject(ctx, id)
+			if err != nil {
+				return errors.Wrapf(err, "could not read object %s", id)
+			}
+			if obj == nil {
+				return errors.Wrap(ocfl.ErrNotFound, id)
+			}
+
+			select {
+			case objects <- obj.Addr:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+}
+
+// Sends the root of every object in the OCFL root
+func (d *Driver) sendObjectRoots(ctx context.Context, objects chan<- string) error {
+	return fsWalk(d.root.Addr, d.cfg.Symlinks, true, func(ospath string, e *godirwalk.Dirent) (bool, error) {
+		if err := ctx.Err(); err != nil {
+			return dontGoDeeper, err
+		}
+
+		if !e.IsDir() && !e.IsSymlink() {
+			return dontGoDeeper, nil
+		}
+		if ospath == filepath.Join(d.root.Addr, extensionsDir) {
+			return dontGoDeeper, nil
+		}
+
+		isObject, _, err := isRoot(ospath, ocfl.Object)
+		if err != nil {
+			return dontGoDeeper, err
+		}
+		if !isObject {
+			return goDeeper, nil
+		}
+
+		select {
+		case objects <- ospath:
+			return dontGoDeeper, nil
+		case <-ctx.Done():
+			return dontGoDeeper, ctx.Err()
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package fs
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/birkland/ocfl/objectid"
+)
+
+// Device names reserved by Windows, which cannot be used as file names,
+// even with an extension
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// Returns the absolute form of a path, in the long path form (\\?\...) on Windows,
+// so that deep layouts are not limited by MAX_PATH.
+func absPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return longPath(abs), nil
+}
+
+// Determines if paths must be checked for portability.  On Windows they always
+// are, otherwise only if the driver is configured to.
+func (d *Driver) portable() bool {
+	return d.cfg.Portable || runtime.GOOS == "windows"
+}
+
+// Determines if paths that differ only by case refer to the same file
+// on the platform's default filesystems
+func (d *Driver) caseInsensitive() bool {
+	return d.portable() || runtime.GOOS == "darwin"
+}
+
+// Verifies that no directory on the path of a new object's root differs only by case
+// from an existing one, if the platform or object paths are case insensitive.
+func (d *Driver) checkObjectCaseConflict(id string) error {
+	ci, ok := d.cfg.ObjectPaths.(interface{ CaseInsensitive() bool })
+	if !d.caseInsensitive() && !(ok && ci.CaseInsensitive()) {
+		return nil
+	}
+
+	dir := d.root.Addr
+	for _, name := range strings.Split(d.cfg.ObjectPaths.Generate(id), "/") {
+		conflict, err := objectid.CaseConflict(dir, name)
+		if err != nil {
+			return err
+		}
+		if conflict != "" {
+			return fmt.Errorf("directory %s conflicts with %s, which differs only by case",
+				filepath.Join(dir, name), filepath.Join(dir, conflict))
+		}
+		dir = filepath.Join(dir, name)
+	}
+	return nil
+}
+
+// Verifies that a generated, slash separated path can be created on any
+// platform.  Paths with Windows reserved device names, or components ending
+// in a dot or space, are rejected.
+func checkPortable(path string) error {
+	for _, component := range strings.Split(path, "/") {
+		if component == "" {
+			continue
+		}
+
+		if strings.HasSuffix(component, ".") || strings.HasSuffix(component, " ") {
+			return fmt.Errorf("path %s contains non-portable name '%s' ending in a dot or space", path, component)
+		}
+
+		base := strings.ToUpper(strings.SplitN(component, ".", 2)[0])
+		if reservedNames[strings.TrimRight(base, " ")] {
+			return fmt.Errorf("path %s contains reserved device name '%s'", path, component)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package fs
+
+// Paths are not length limited outside of Windows
+func longPath(abs string) string {
+	return abs
+}
@@ -0,0 +1,16 @@
+package fs
+
+import "strings"
+
+// Prefixes an absolute path with \\?\ (or \\?\UNC\ for network paths), which
+// lifts the MAX_PATH limit of 260 characters.
+func longPath(abs string) string {
+	switch {
+	case strings.HasPrefix(abs, `\\?\`):
+		return abs
+	case strings.HasPrefix(abs, `\\`):
+		return `\\?\UNC\` + abs[2:]
+	default:
+		return `\\?\` + abs
+	}
+}
@@ -0,0 +1,165 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/birkland/ocfl"
+	"github.com/pkg/errors"
+)
+
+// TombstoneDir is the directory, relative to the OCFL root, where tombstones of purged objects are recorded.
+// It is kept within the driver's LocalExtension.
+const TombstoneDir = extensionsDir + "/" + LocalExtension + "/" + tombstonesDir
+
+const tombstonesDir = "tombstones"
+
+// Tombstone records the removal of an OCFL object
+type Tombstone struct {
+	ID      string    `json:"id"`
+	Path    string    `json:"path"` // Object root path, relative to the OCFL root
+	Head    string    `json:"head"` // Head version at the time the object was purged
+	Name    string    `json:"name"`
+	Address string    `json:"address"`
+	Message string    `json:"message"`
+	Date    time.Time `json:"date"`
+}
+
+// Purge permanently removes an OCFL object, and any intermediate directories
+// that become empty as a result.
+//
+// Before removing anything, Purge verifies that the object directory is an OCFL
+// object under the driver's root, and that its inventory has the given ID.  The object
+// directory is first renamed, so that it disappears atomically, then removed.  If requested,
+// a tombstone is written as JSON to TombstoneDir before the object is removed.  If the
+// driver locks objects, the object's lock is held throughout.
+func (d *Driver) Purge(id string, opts ocfl.PurgeOptions) (err error) {
+	if d.root == nil {
+		return fmt.Errorf("cannot purge %s: please define an OCFL root", id)
+	}
+
+	id = d.normalizeID(id)
+
+	unlock, err := d.lockObject(id)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := unlock(); err == nil {
+			err = e
+		}
+	}()
+
+	obj, inv, err := d.readObject(context.Background(), id)
+	if err != nil {
+		return errors.Wrapf(err, "could not read object %s", id)
+	}
+	if obj == nil {
+		return errors.Wrap(ocfl.ErrNotFound, id)
+	}
+
+	root, err := absPath(d.root.Addr)
+	if err != nil {
+		return errors.Wrapf(err, "could not determine absolute path of root")
+	}
+
+	objDir, err := absPath(obj.Addr)
+	if err != nil {
+		return errors.Wrapf(err, "could not determine absolute path of %s", obj.Addr)
+	}
+
+	rel, err := filepath.Rel(root, objDir)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("refusing to purge %s: %s is not under the OCFL root %s", id, objDir, root)
+	}
+
+	if isObj, _, err := isRoot(objDir, ocfl.Object); !isObj || err != nil {
+		return fmt.Errorf("refusing to purge %s: %s is not an OCFL object", id, objDir)
+	}
+
+	if inv.ID != id {
+		return fmt.Errorf("refusing to purge %s: object at %s has id %s", id, objDir, inv.ID)
+	}
+
+	if opts.Tombstone {
+		info, err := opts.Info.WithDefaults(d.cfg.Identity)
+		if err != nil {
+			return errors.Wrapf(err, "could not determine identity for tombstone of %s", id)
+		}
+
+		err = writeTombstone(root, Tombstone{
+			ID:      id,
+			Path:    filepath.ToSlash(rel),
+			Head:    inv.Head,
+			Name:    info.Name,
+			Address: info.Address,
+			Message: info.Message,
+			Date:    info.Date.UTC().Truncate(1 * time.Millisecond),
+		})
+		if err != nil {
+			return errors.Wrapf(err, "could not write tombstone for %s", id)
+		}
+	}
+
+	doomed := filepath.Join(filepath.Dir(objDir), AtomicPrefix+"purge."+filepath.Base(objDir))
+	err = os.Rename(objDir, doomed)
+	if err != nil {
+		return errors.Wrapf(err, "could not remove object %s at %s", id, objDir)
+	}
+	d.invalidate(obj.Addr)
+
+	err = os.RemoveAll(doomed)
+	if err != nil {
+		return errors.Wrapf(err, "could not remove content of object %s at %s", id, doomed)
+	}
+
+	return removeEmptyParents(filepath.Dir(objDir), root)
+}
+
+func writeTombstone(root string, t Tombstone) error {
+	dir, err := mkLocalExtensionDir(root, tombstonesDir)
+	if err != nil {
+		return err
+	}
+
+	w, err := AtomicWrite(filepath.Join(dir, url.QueryEscape(t.ID)+".json"))
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	if err = enc.Encode(t); err != nil {
+		_ = w.Rollback()
+		return err
+	}
+
+	return w.Close()
+}
+
+// Removes empty directories from dir up to (but not including) the root
+func removeEmptyParents(dir, root string) error {
+	for dir != root && strings.HasPrefix(dir, root) {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return errors.Wrapf(err, "could not read directory %s", dir)
+		}
+		if len(entries) > 0 {
+			return nil
+		}
+
+		if err = os.Remove(dir); err != nil {
+			return errors.Wrapf(err, "could not remove empty directory %s", dir)
+		}
+		dir = filepath.Dir(dir)
+	}
+
+	return nil
+}
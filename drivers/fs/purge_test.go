@@ -0,0 +1,112 @@
+package fs_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/fspath"
+	"github.com/pkg/errors"
+)
+
+func TestPurge(t *testing.T) {
+	for _, tombstone := range []bool{false, true} {
+		tombstone := tombstone
+		t.Run(map[bool]string{true: "tombstone", false: "noTombstone"}[tombstone], func(t *testing.T) {
+			runInTempDir(t, func(ocflRoot string) {
+				_ = fs.MkRoot(ocflRoot)
+				d, _ := fs.NewDriver(fs.Config{
+					Root: ocflRoot,
+					ObjectPaths: fspath.GeneratorFunc(func(id string) string {
+						return filepath.Join("a", "b", url.QueryEscape(id))
+					}),
+					FilePaths: fspath.GeneratorFunc(fs.Passthrough),
+				})
+				driver := driverWrapper{driver: d, t: t, root: ocflRoot}
+
+				session := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+				session.Put("file", strings.NewReader("content"))
+				session.Commit(ocfl.CommitInfo{})
+
+				err := d.Purge(objectID, ocfl.PurgeOptions{
+					Tombstone: tombstone,
+					Info:      ocfl.CommitInfo{Message: "bad ingest"},
+				})
+				if err != nil {
+					t.Fatalf("purge failed %+v", err)
+				}
+
+				var found int
+				driver.Walk(ocfl.Select{}, func(ref ocfl.EntityRef) error {
+					if ref.Type != ocfl.Root {
+						found++
+					}
+					return nil
+				})
+				if found != 0 {
+					t.Errorf("found %d entities after purge", found)
+				}
+
+				if _, err := os.Stat(filepath.Join(ocflRoot, "a")); !os.IsNotExist(err) {
+					t.Errorf("empty intermediate directories should have been removed")
+				}
+
+				content, err := ioutil.ReadFile(filepath.Join(ocflRoot, fs.TombstoneDir, url.QueryEscape(objectID)+".json"))
+				if tombstone != (err == nil) {
+					t.Fatalf("tombstone presence should be %t", tombstone)
+				}
+
+				if tombstone {
+					var ts fs.Tombstone
+					_ = json.Unmarshal(content, &ts)
+					if ts.ID != objectID || ts.Message != "bad ingest" || ts.Head != "v1" {
+						t.Errorf("unexpected tombstone content %+v", ts)
+					}
+
+					// Tombstones are kept in a local extension, which declares itself
+					var config map[string]string
+					content, _ = ioutil.ReadFile(filepath.Join(ocflRoot, "extensions", fs.LocalExtension, "config.json"))
+					if err := json.Unmarshal(content, &config); err != nil || config["extensionName"] != fs.LocalExtension {
+						t.Errorf("unexpected extension config %q", content)
+					}
+				}
+
+				if err := d.Purge(objectID, ocfl.PurgeOptions{}); errors.Cause(err) != ocfl.ErrNotFound {
+					t.Errorf("purging a nonexistent object should fail with ErrNotFound, got %v", err)
+				}
+			})
+		})
+	}
+}
+
+// Objects may be purged by any form of their ID the driver's object paths accept
+func TestPurgeNormalizesID(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		_ = fs.MkRoot(ocflRoot)
+		d, _ := fs.NewDriver(fs.Config{
+			Root:        ocflRoot,
+			ObjectPaths: fspath.NewFedora(),
+			FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
+		})
+		driver := driverWrapper{driver: d, t: t, root: ocflRoot}
+
+		session := driver.Open("a/b", ocfl.Options{Create: true, Version: ocfl.NEW})
+		session.Put("file", strings.NewReader("content"))
+		session.Commit(ocfl.CommitInfo{})
+
+		if err := d.Purge("/a/b", ocfl.PurgeOptions{}); err != nil {
+			t.Fatalf("purge failed %+v", err)
+		}
+
+		path, _ := d.ObjectPath("a/b")
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected the object to be purged, got %v", err)
+		}
+	})
+}
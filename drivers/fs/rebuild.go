@@ -0,0 +1,211 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+)
+
+// RebuiltMessage is the message of versions whose metadata could not be recovered
+// when rebuilding an inventory.
+const RebuiltMessage = "unknown: version metadata was lost, and its state was rebuilt from content"
+
+// RebuiltUser is the user of versions whose metadata could not be recovered when
+// rebuilding an inventory.
+var RebuiltUser = metadata.User{Name: "unknown"}
+
+// RebuildOptions configure the reconstruction of an object's inventory
+type RebuildOptions struct {
+	ID               string                   // ID of the object, needed if no version inventory can be recovered
+	DigestAlgorithm  metadata.DigestAlgorithm // Digest algorithm, if no version inventory can be recovered.  Defaults to sha512
+	ContentDirectory string                   // Content directory name, if no version inventory can be recovered.  Defaults to content
+	Write            bool                     // If true, write the rebuilt inventory to the head version and the object root
+}
+
+// RebuildInventory reconstructs a plausible inventory of the object at the given path,
+// whose inventory has been lost or corrupted, from the content on disk.
+//
+// The inventory of the latest version that can still be parsed is taken as is.
+// Subsequent versions are inferred from their content directories: each version's state
+// is that of the prior version, with the files in its content directory added (or
+// replacing those at the same logical path).  Their content is hashed, their creation
+// date is taken from the version directory's modification time, and their message and
+// user are RebuiltMessage and RebuiltUser.
+//
+// As inventories record changes that content directories cannot, rebuilt states may
+// differ from the originals: logical files deleted in a version are carried over, and
+// those whose content was deduplicated (i.e. not written again) or whose logical path
+// differed from their content path are missing.  Rebuilt inventories should be reviewed,
+// and objects validated, before they are relied upon.
+func RebuildInventory(ctx context.Context, objPath string, opts RebuildOptions) (*metadata.Inventory, error) {
+	versions, err := versionDirs(objPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no version directories found in %s", objPath)
+	}
+
+	inv, recovered := recoverInventory(objPath, versions)
+	if inv == nil {
+		if opts.ID == "" {
+			return nil, fmt.Errorf("no version inventory of %s could be recovered, so an ID must be given", objPath)
+		}
+		inv = &metadata.Inventory{
+			ID:              opts.ID,
+			Type:            metadata.InventoryType,
+			DigestAlgorithm: opts.DigestAlgorithm,
+			ContentDir:      opts.ContentDirectory,
+			Manifest:        make(metadata.Manifest),
+			Versions:        make(map[string]metadata.Version),
+		}
+		if inv.DigestAlgorithm == "" {
+			inv.DigestAlgorithm = "sha512"
+		}
+		if inv.ContentDir == "content" {
+			inv.ContentDir = ""
+		}
+	}
+
+	var state metadata.Manifest
+	if recovered >= 0 {
+		state = inv.Versions[versions[recovered]].State
+	}
+
+	for _, v := range versions[recovered+1:] {
+		state, err = rebuildVersion(ctx, inv, objPath, v, state)
+		if err != nil {
+			return nil, err
+		}
+	}
+	inv.Head = versions[len(versions)-1]
+
+	if !opts.Write {
+		return inv, nil
+	}
+
+	headDir := filepath.Join(objPath, inv.Head)
+	if recovered < len(versions)-1 {
+		if err = writeInventory(inv, headDir); err != nil {
+			return nil, errors.Wrapf(err, "could not write rebuilt inventory of %s", objPath)
+		}
+	}
+	if err = copyInventoryFiles(headDir, objPath); err != nil {
+		return nil, errors.Wrapf(err, "could not write rebuilt inventory of %s", objPath)
+	}
+
+	namaste := filepath.Join(objPath, ocflObjectRoot)
+	if _, err = os.Stat(namaste); os.IsNotExist(err) {
+		err = ioutil.WriteFile(namaste, []byte(objectRootNamasteContent), filePermission)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not write object declaration of %s", objPath)
+	}
+
+	return inv, nil
+}
+
+// Lists the version directories of an object, in order
+func versionDirs(objPath string) ([]string, error) {
+	entries, err := ioutil.ReadDir(objPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read object directory %s", objPath)
+	}
+
+	var versions []string
+	for _, e := range entries {
+		if e.IsDir() && metadata.VersionID(e.Name()).Valid() {
+			versions = append(versions, e.Name())
+		}
+	}
+	metadata.SortVersions(versions)
+	return versions, nil
+}
+
+// Finds the inventory of the latest version that can be parsed, and whose head is
+// that version.  Returns the index of that version, or -1 if there is none.
+func recoverInventory(objPath string, versions []string) (*metadata.Inventory, int) {
+	for i := len(versions) - 1; i >= 0; i-- {
+		inv, err := ReadInventory(filepath.Join(objPath, versions[i]))
+		if err != nil || inv.Head != versions[i] {
+			continue
+		}
+		if _, ok := inv.Versions[inv.Head]; !ok {
+			continue
+		}
+		return inv, i
+	}
+	return nil, -1
+}
+
+// Adds a version to the inventory, with the prior version's state updated by the files in
+// the version's content directory.  Returns the state of the version.
+func rebuildVersion(ctx context.Context, inv *metadata.Inventory, objPath, version string, prior metadata.Manifest) (metadata.Manifest, error) {
+	paths := make(map[string]metadata.Digest)
+	for digest, lpaths := range prior {
+		for _, p := range lpaths {
+			paths[p] = digest
+		}
+	}
+
+	contentDir := filepath.Join(objPath, version, inv.ContentDirectory())
+	var files []string
+	err := filepath.Walk(contentDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "could not read content of %s", version)
+	}
+	sort.Strings(files)
+
+	for _, path := range files {
+		digests, err := fileDigests(ctx, path, map[metadata.DigestAlgorithm]metadata.Digest{inv.DigestAlgorithm: ""}, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not digest %s", path)
+		}
+		digest := metadata.Digest(digests[inv.DigestAlgorithm])
+
+		rel, err := filepath.Rel(contentDir, path)
+		if err != nil {
+			return nil, err
+		}
+		lpath := filepath.ToSlash(rel)
+
+		inv.Manifest[digest] = append(inv.Manifest[digest], version+"/"+inv.ContentDirectory()+"/"+lpath)
+		paths[lpath] = digest
+	}
+
+	state := make(metadata.Manifest, len(paths))
+	for p, digest := range paths {
+		state[digest] = append(state[digest], p)
+	}
+	for _, lpaths := range state {
+		sort.Strings(lpaths)
+	}
+
+	var created time.Time
+	if info, err := os.Stat(filepath.Join(objPath, version)); err == nil {
+		created = info.ModTime().UTC().Truncate(1 * time.Millisecond)
+	}
+
+	inv.Versions[version] = metadata.Version{
+		Created: created,
+		Message: RebuiltMessage,
+		User:    RebuiltUser,
+		State:   state,
+	}
+	return state, nil
+}
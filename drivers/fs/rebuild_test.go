@@ -0,0 +1,79 @@
+package fs_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/go-test/deep"
+)
+
+func TestRebuildInventory(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		session := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		session.Put("a", strings.NewReader("a"))
+		session.Commit(ocfl.CommitInfo{Message: "first"})
+
+		session = driver.Open(objectID, ocfl.Options{Version: ocfl.NEW})
+		session.Put("a", strings.NewReader("a, again"))
+		session.Put("dir/b", strings.NewReader("b"))
+		session.Commit(ocfl.CommitInfo{Message: "second"})
+
+		path, _ := driver.driver.(*fs.Driver).ObjectPath(objectID)
+		original, _ := fs.ReadInventory(path)
+
+		// Lose the inventory of the head version, and of the object root
+		for _, dir := range []string{path, filepath.Join(path, "v2")} {
+			_ = os.Remove(filepath.Join(dir, metadata.InventoryFile))
+			_ = os.Remove(filepath.Join(dir, metadata.InventoryFile+".sha512"))
+		}
+
+		inv, err := fs.RebuildInventory(context.Background(), path, fs.RebuildOptions{Write: true})
+		if err != nil {
+			t.Fatalf("rebuild failed: %+v", err)
+		}
+
+		// v1 is recovered from its inventory, and v2's state is inferred from its content
+		if diff := deep.Equal(inv.Versions["v1"], original.Versions["v1"]); diff != nil {
+			t.Errorf("v1 should have been recovered: %s", diff)
+		}
+		if diff := deep.Equal(inv.Versions["v2"].State, original.Versions["v2"].State); diff != nil {
+			t.Errorf("v2 state should have been rebuilt: %s", diff)
+		}
+		if diff := deep.Equal(inv.Manifest, original.Manifest); diff != nil {
+			t.Errorf("manifest should have been rebuilt: %s", diff)
+		}
+		if v2 := inv.Versions["v2"]; v2.Message != fs.RebuiltMessage || v2.User != fs.RebuiltUser {
+			t.Errorf("v2 metadata should be marked as unknown, got %+v", v2)
+		}
+
+		report, err := fs.ValidatePath(context.Background(), path, fs.ValidateOptions{})
+		if err != nil || !report.Valid() {
+			t.Errorf("rebuilt object should be valid: %+v %+v", report, err)
+		}
+
+		// Without any version inventories, the ID must be given
+		for _, v := range []string{"v1", "v2"} {
+			_ = os.Remove(filepath.Join(path, v, metadata.InventoryFile))
+		}
+		if _, err = fs.RebuildInventory(context.Background(), path, fs.RebuildOptions{}); err == nil {
+			t.Errorf("expected an error rebuilding without an ID")
+		}
+
+		inv, err = fs.RebuildInventory(context.Background(), path, fs.RebuildOptions{ID: objectID})
+		if err != nil {
+			t.Fatalf("rebuild failed: %+v", err)
+		}
+		if diff := deep.Equal(inv.Versions["v1"].State, original.Versions["v1"].State); diff != nil {
+			t.Errorf("v1 state should have been rebuilt: %s", diff)
+		}
+		if inv.ID != objectID || inv.Head != "v2" || inv.DigestAlgorithm != "sha512" {
+			t.Errorf("bad rebuilt inventory %+v", inv)
+		}
+	})
+}
@@ -0,0 +1,26 @@
+package fs
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Clones the content of src into dst with clonefile(2), sharing the underlying blocks.
+// Fails if the filesystem (e.g. HFS+) does not support it, or if src and dst are on
+// different volumes.
+//
+// clonefile only creates new files, so src is cloned beside dst, then renamed over it.
+// The open dst is left referring to the replaced, empty file, which nothing writes to
+// once the content has been cloned.
+func reflink(dst, src *os.File) error {
+	clone := dst.Name() + ".clone"
+	if err := unix.Clonefile(src.Name(), clone, unix.CLONE_NOFOLLOW); err != nil {
+		return err
+	}
+	if err := os.Rename(clone, dst.Name()); err != nil {
+		_ = os.Remove(clone)
+		return err
+	}
+	return nil
+}
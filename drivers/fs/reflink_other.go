@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package fs
+
+import (
+	"fmt"
+	"os"
+)
+
+// Reflinks are only supported on linux and macOS; elsewhere, content is always copied
+func reflink(dst, src *os.File) error {
+	return fmt.Errorf("reflink is not supported on this platform")
+}
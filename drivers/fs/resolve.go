@@ -12,9 +12,15 @@ import (
 )
 
 const ocflObjectRoot = "0=ocfl_object_1.0"
-const ocflVersion = "1.0"
+const ocflVersion = metadata.SpecVersion
 const ocflRoot = "0=ocfl_" + ocflVersion
 
+// Storage root (and object root) directory reserved for extensions
+const extensionsDir = "extensions"
+
+// Object root directory reserved for logs
+const logsDir = "logs"
+
 // LocateRoot attempts find the first directory matching an OCFL root
 // in the given directory, or any parent directories.  The primary use case
 // is finding the identity of the ocfl root when given the location of some file
@@ -41,11 +47,11 @@ func LocateRoot(loc string) (string, error) {
 // Filesystem paths that point to individual files can actually alias to several
 // logical files within an OCFL object version, hence the need to return the result
 // as an array.
-func resolve(loc string) ([]ocfl.EntityRef, *metadata.Inventory, error) {
+func resolve(loc string, readInventory func(string) (*metadata.Inventory, error)) ([]ocfl.EntityRef, *metadata.Inventory, error) {
 	var refs []ocfl.EntityRef
 	var inv *metadata.Inventory
 
-	addr, err := filepath.Abs(loc)
+	addr, err := absPath(loc)
 	if err != nil {
 		return refs, nil, errors.Wrapf(err, "could not calculate absolute path of %s", loc)
 	}
@@ -57,7 +63,7 @@ func resolve(loc string) ([]ocfl.EntityRef, *metadata.Inventory, error) {
 	}
 
 	if rootRef.Type == ocfl.Object {
-		inv, err = ReadInventory(rootRef.Addr)
+		inv, err = readInventory(rootRef.Addr)
 		if err != nil {
 			return refs, inv, err
 		}
@@ -100,8 +106,8 @@ func resolve(loc string) ([]ocfl.EntityRef, *metadata.Inventory, error) {
 	// Otherwise, we have an individual file.  This is the difficult case,
 	// as a single physical file could map to multiple logical files
 
-	digest := findDigest(inv, strings.TrimPrefix(filepath.ToSlash(strings.TrimPrefix(addr, rootRef.Addr)), "/"))
-	for v, vmd := range inv.Versions {
+	digest, _ := inv.DigestOf(strings.TrimPrefix(filepath.ToSlash(strings.TrimPrefix(addr, rootRef.Addr)), "/"))
+	for _, v := range inv.VersionsWith(digest) {
 		inVersion := ocfl.EntityRef{
 			ID:     v,
 			Parent: rootRef,
@@ -109,35 +115,20 @@ func resolve(loc string) ([]ocfl.EntityRef, *metadata.Inventory, error) {
 			Addr:   filepath.Join(rootRef.Addr, v),
 		}
 
-		for d, paths := range vmd.State {
-			if d == digest {
-				for _, path := range paths {
-					refs = append(refs, ocfl.EntityRef{
-						ID:     path,
-						Parent: &inVersion,
-						Type:   ocfl.File,
-						Addr:   loc,
-					})
-				}
-			}
+		for _, path := range inv.LogicalPaths(v, digest) {
+			refs = append(refs, ocfl.EntityRef{
+				ID:     path,
+				Parent: &inVersion,
+				Type:   ocfl.File,
+				Addr:   loc,
+				Digest: digest,
+			})
 		}
 	}
 
 	return refs, inv, nil
 }
 
-func findDigest(inv *metadata.Inventory, path string) metadata.Digest {
-	for d, files := range inv.Manifest {
-		for _, f := range files {
-			if f == path {
-				return d
-			}
-		}
-	}
-
-	return ""
-}
-
 // Find the desired kind of root (ocfl object, ocfl root) of the
 // given entity. Returns an error if it cannot be found.
 func findRoot(ref *ocfl.EntityRef, t ocfl.Type) (*ocfl.EntityRef, error) {
@@ -165,7 +156,7 @@ func findRoot(ref *ocfl.EntityRef, t ocfl.Type) (*ocfl.EntityRef, error) {
 // Returns an error if no roots are found.
 func crawlForRoot(loc string, t ocfl.Type) (*ocfl.EntityRef, error) {
 
-	addr, err := filepath.Abs(loc)
+	addr, err := absPath(loc)
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not make absolute %s", addr)
 	}
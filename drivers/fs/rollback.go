@@ -0,0 +1,90 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/birkland/ocfl"
+	"github.com/pkg/errors"
+)
+
+// Rollback removes the head version of an object, restoring the previous
+// version as head.  This is intended for undoing a bad ingest.
+//
+// The previous version's inventory (which must match its sidecar digest)
+// is first restored to the object root, and then the head version directory is removed.
+// A crash in between leaves only an unreferenced version directory behind.  The
+// only version of an object cannot be rolled back; use Purge instead.  If the driver
+// locks objects, the object's lock is held throughout.
+func (d *Driver) Rollback(id string) (err error) {
+	unlock, err := d.lockObject(id)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := unlock(); err == nil {
+			err = e
+		}
+	}()
+
+	obj, inv, err := d.readObject(context.Background(), id)
+	if err != nil {
+		return errors.Wrapf(err, "could not read object %s", id)
+	}
+	if obj == nil {
+		return errors.Wrap(ocfl.ErrNotFound, id)
+	}
+
+	versions := inv.VersionNames()
+	if len(versions) < 2 {
+		return fmt.Errorf("cannot roll back %s, it only has one version", id)
+	}
+
+	head := versions[len(versions)-1]
+	prev := versions[len(versions)-2]
+	if head != inv.Head {
+		return fmt.Errorf("cannot roll back %s, its head %s is not its latest version %s", id, inv.Head, head)
+	}
+
+	prevDir := filepath.Join(obj.Addr, prev)
+	err = verifyInventory(prevDir)
+	if err != nil {
+		return errors.Wrapf(err, "cannot roll back %s to %s", id, prev)
+	}
+
+	prevInv, err := ReadInventory(prevDir)
+	if err != nil {
+		return errors.Wrapf(err, "cannot roll back %s to %s", id, prev)
+	}
+	if prevInv.ID != inv.ID || prevInv.Head != prev {
+		return fmt.Errorf("cannot roll back %s, inventory in %s has id %s and head %s", id, prev, prevInv.ID, prevInv.Head)
+	}
+
+	err = d.cfg.Faults.copyInventoryFiles(prevDir, obj.Addr)
+	d.invalidate(obj.Addr)
+	if err != nil {
+		return errors.Wrapf(err, "could not restore inventory of %s %s", id, prev)
+	}
+
+	headDir := filepath.Join(obj.Addr, head)
+	doomed := filepath.Join(obj.Addr, AtomicPrefix+"rollback."+head)
+	err = os.Rename(headDir, doomed)
+	if err != nil {
+		return errors.Wrapf(err, "could not remove version %s of %s", head, id)
+	}
+
+	err = os.RemoveAll(doomed)
+	if err != nil {
+		return errors.Wrapf(err, "could not remove content of %s %s", id, head)
+	}
+
+	// A signature of the removed version would be invalid for any future version of the same name
+	err = os.Remove(signaturePath(obj.Addr, head))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "could not remove signature of %s %s", id, head)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package fs_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+)
+
+func TestRollback(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		d := driver.driver.(*fs.Driver)
+
+		session := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		session.Put("file1", strings.NewReader("one"))
+		session.Commit(ocfl.CommitInfo{})
+
+		if err := d.Rollback(objectID); err == nil {
+			t.Errorf("should not be able to roll back the only version")
+		}
+
+		session = driver.Open(objectID, ocfl.Options{Version: ocfl.NEW})
+		session.Put("file2", strings.NewReader("two"))
+		session.Commit(ocfl.CommitInfo{})
+
+		if err := d.Rollback(objectID); err != nil {
+			t.Fatalf("rollback failed %+v", err)
+		}
+
+		var obj ocfl.EntityRef
+		var files []string
+		driver.Walk(ocfl.Select{}, func(ref ocfl.EntityRef) error {
+			switch ref.Type {
+			case ocfl.Object:
+				obj = ref
+			case ocfl.File:
+				files = append(files, ref.ID)
+			}
+			return nil
+		}, objectID)
+
+		inv, _ := fs.ReadInventory(obj.Addr)
+		if inv.Head != "v1" || len(files) != 1 {
+			t.Errorf("expected to be at v1 with one file, got %s with %s", inv.Head, files)
+		}
+
+		if _, err := os.Stat(filepath.Join(obj.Addr, "v2")); !os.IsNotExist(err) {
+			t.Errorf("v2 should have been removed")
+		}
+
+		// We should be able to make a new v2
+		session = driver.Open(objectID, ocfl.Options{Version: ocfl.NEW})
+		session.Put("file3", strings.NewReader("three"))
+		session.Commit(ocfl.CommitInfo{})
+	})
+}
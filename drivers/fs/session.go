@@ -1,6 +1,7 @@
 package fs
 
 import (
+	"context"
 	"crypto/sha512"
 	"encoding/hex"
 	"fmt"
@@ -10,9 +11,9 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/fspath"
 	"github.com/birkland/ocfl/metadata"
 	"github.com/pkg/errors"
 )
@@ -25,6 +26,19 @@ type session struct {
 	version    *ocfl.EntityRef
 	contentDir string
 	commitfunc func() error
+	rollback   func() error // Removes anything created by the session if commit fails
+	prev       string       // Previous version, when the session creates a new version
+	discarded  bool         // True if the session's new version was discarded as unchanged
+	unlock     func() error // Releases the object's lock, if held
+	base       string       // Digest of the object's root inventory when opened, if any
+
+	// Content written by the session, by path relative to the object root, and
+	// committed content it has deleted, which is only removed once it commits.
+	written map[string]bool
+	pruned  []string
+
+	progressLock sync.Mutex // Serializes progress callbacks, independently of the session lock
+	progress     ocfl.Progress
 }
 
 const hashSuffix = ".sha512"
@@ -42,24 +56,69 @@ const objectRootNamasteContent = "ocfl_object_" + ocflVersion + "\n"
 // never reference files that do not exist.  Crashes or other errors are allowed to create
 // "garbage" in the form of files that are not referenced by any inventory file.  These
 // files may be safely removed as part of a cleanup process.
-func (d *Driver) Open(id string, opts ocfl.Options) (sess ocfl.Session, err error) {
+func (d *Driver) Open(id string, opts ocfl.Options) (ocfl.Session, error) {
+	return d.OpenContext(context.Background(), id, opts)
+}
+
+// OpenContext creates a session providing read/write access to the specified OCFL object,
+// aborting if the given context is done before the session is established.
+func (d *Driver) OpenContext(ctx context.Context, id string, opts ocfl.Options) (sess ocfl.Session, err error) {
 
 	var obj *ocfl.EntityRef
 
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	id = d.normalizeID(id)
+
 	s := &session{
 		driver: d,
 		opts:   opts,
 	}
 
+	// Writers take the object's lock before reading its state, so that
+	// no other writer can commit in the meantime.
+	if d.cfg.Lock && opts.Version == ocfl.NEW {
+		if d.root == nil {
+			return nil, fmt.Errorf("cannot lock %s: please define an OCFL root", id)
+		}
+		if s.unlock, err = d.lock(ctx, id); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil {
+				_ = s.release()
+			}
+		}()
+	}
+
+	// Note the object's root inventory before reading it, so that Commit
+	// can detect whether another writer has committed in the meantime.
+	if d.cfg.ObjectPaths != nil && d.root != nil {
+		if s.base, err = inventoryDigest(filepath.Join(d.root.Addr, d.cfg.ObjectPaths.Generate(id))); err != nil {
+			return nil, err
+		}
+	}
+
 	// See if an object already exists
-	obj, s.inventory, err = d.readObject(id)
+	obj, s.inventory, err = d.readObject(ctx, id)
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not read object %s", id)
 	}
+	if s.inventory != nil {
+		s.inventory.TimestampPrecision = d.cfg.TimestampPrecision
+	}
+
+	if obj != nil && d.cfg.ObjectPaths == nil {
+		if s.base, err = inventoryDigest(obj.Addr); err != nil {
+			return nil, err
+		}
+	}
 
 	// If it does not exist, and opts.Create is false, then this is a problem
 	if obj == nil && !opts.Create {
-		return nil, fmt.Errorf("object does not exist: %s", id)
+		return nil, errors.Wrap(ocfl.ErrNotFound, id)
 	}
 
 	// If it does not exist, and the intent is Create, then create an empty object
@@ -89,10 +148,36 @@ func (d *Driver) Open(id string, opts ocfl.Options) (sess ocfl.Session, err erro
 	return s, nil
 }
 
+// CreateEmptyVersion commits a new version of the given object that contains no files.
+// If the object does not exist, a new, empty object is created whose v1 contains no files.
+//
+// Empty versions are permissible in OCFL.  They have an empty state in the inventory,
+// and no content directory.
+func (d *Driver) CreateEmptyVersion(id string, commit ocfl.CommitInfo) error {
+	sess, err := d.Open(id, ocfl.Options{
+		Create:  true,
+		Version: ocfl.NEW,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not open %s", id)
+	}
+
+	s := sess.(*session)
+	defer s.Close()
+
+	err = s.inventory.ClearHead()
+	if err != nil {
+		return errors.Wrapf(err, "could not clear state of new version of %s", id)
+	}
+
+	return s.Commit(commit)
+}
+
 // Find the OCFL object that corresponds to the given ID, and return its
 // ref and inventory.  Otherwise, nil if not found (which may be OK, like when
 // we're creating an entirely new object)
-func (d *Driver) readObject(id string) (*ocfl.EntityRef, *metadata.Inventory, error) {
+func (d *Driver) readObject(ctx context.Context, id string) (*ocfl.EntityRef, *metadata.Inventory, error) {
+	id = d.normalizeID(id)
 
 	if d.cfg.ObjectPaths != nil {
 
@@ -100,13 +185,15 @@ func (d *Driver) readObject(id string) (*ocfl.EntityRef, *metadata.Inventory, er
 		// and see if the resulting path points to a an ocfl object or not
 
 		objectRoot := filepath.Join(d.root.Addr, d.cfg.ObjectPaths.Generate(id))
-		refs, inv, err := resolve(objectRoot)
+		refs, inv, err := resolve(objectRoot, d.readInventory)
 
 		if err != nil && !os.IsNotExist(errors.Cause(err)) {
 			return nil, nil, errors.Wrapf(err, "Error opening %s at %s", id, objectRoot)
 		}
 
-		if err == nil && len(refs) > 0 {
+		// The path may also be an intermediate directory, e.g. a new object that
+		// has not yet been committed
+		if err == nil && len(refs) > 0 && refs[0].Type == ocfl.Object {
 			return &refs[0], inv, nil
 		}
 
@@ -115,7 +202,7 @@ func (d *Driver) readObject(id string) (*ocfl.EntityRef, *metadata.Inventory, er
 		// The "hard" way.  Brute force look for the matching OCFL object
 
 		var objects []ocfl.EntityRef
-		err := d.Walk(ocfl.Select{Type: ocfl.Object}, func(obj ocfl.EntityRef) error {
+		err := d.WalkContext(ctx, ocfl.Select{Type: ocfl.Object}, func(obj ocfl.EntityRef) error {
 			objects = append(objects, obj)
 			return nil
 		}, id)
@@ -126,7 +213,7 @@ func (d *Driver) readObject(id string) (*ocfl.EntityRef, *metadata.Inventory, er
 
 		if len(objects) == 1 {
 			object := &objects[0]
-			inv, err := ReadInventory(object.Addr)
+			inv, err := d.readInventory(object.Addr)
 			if err != nil {
 				return nil, nil, errors.Wrapf(err, "Could not read metadata of object %s under %s", id, object.Addr)
 			}
@@ -147,17 +234,40 @@ func (s *session) initObject(id string) error {
 		return fmt.Errorf("no object path generation function given!  (check driver config)")
 	}
 
-	objdir, err := filepath.Abs(filepath.Join(s.driver.root.Addr, s.driver.cfg.ObjectPaths.Generate(id)))
+	if v, ok := s.driver.cfg.ObjectPaths.(fspath.IDValidator); ok {
+		if err := v.ValidateID(id); err != nil {
+			return errors.Wrapf(err, "cannot create object %s", id)
+		}
+	}
+
+	if s.driver.portable() {
+		if err := checkPortable(filepath.ToSlash(s.driver.cfg.ObjectPaths.Generate(id))); err != nil {
+			return errors.Wrapf(err, "cannot create object %s", id)
+		}
+	}
+
+	if err := s.driver.checkObjectCaseConflict(id); err != nil {
+		return errors.Wrapf(err, "cannot create object %s", id)
+	}
+
+	objdir, err := absPath(filepath.Join(s.driver.root.Addr, s.driver.cfg.ObjectPaths.Generate(id)))
 	if err != nil {
 		return errors.Wrapf(err, "could not calculate absolute path of object dir %s", s.driver.cfg.ObjectPaths.Generate(id))
 	}
 
+	_, statErr := os.Stat(objdir)
+	preexisting := statErr == nil
+
 	err = os.MkdirAll(objdir, dirPermission)
 	if err != nil {
 		return errors.Wrapf(err, "Could not create OCFL object directory")
 	}
 
-	s.inventory = metadata.NewInventory(id)
+	s.inventory, err = metadata.NewPaddedInventory(id, s.driver.cfg.VersionPadding)
+	if err != nil {
+		return err
+	}
+	s.inventory.TimestampPrecision = s.driver.cfg.TimestampPrecision
 
 	err = s.setupVersion(&ocfl.EntityRef{
 		Type:   ocfl.Object,
@@ -176,6 +286,21 @@ func (s *session) initObject(id string) error {
 		return errors.Wrapf(err, "could not initialize new object %s", id)
 	}
 
+	// If we created the object directory, a failed commit removes it entirely.  Otherwise,
+	// there was something there already (e.g. garbage from an earlier failure), so just remove
+	// what we created.
+	s.rollback = func() error {
+		if preexisting {
+			for _, f := range []string{ocflObjectRoot, metadata.InventoryFile, metadata.InventoryFile + hashSuffix} {
+				if err := os.Remove(filepath.Join(objdir, f)); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+			return os.RemoveAll(s.version.Addr)
+		}
+		return os.RemoveAll(objdir)
+	}
+
 	return nil
 }
 
@@ -198,6 +323,11 @@ func (s *session) nextVersion(obj *ocfl.EntityRef) error {
 		return errors.Wrapf(err, "could not prepare object %s for writing", obj.ID)
 	}
 
+	s.rollback = func() error {
+		return os.RemoveAll(s.version.Addr)
+	}
+	s.prev = string(prev)
+
 	return nil
 }
 
@@ -215,32 +345,24 @@ func (s *session) setupVersion(obj *ocfl.EntityRef, prev, next metadata.VersionI
 		ID:     string(next),
 		Addr:   filepath.Join(obj.Addr, string(next)),
 	}
-	s.contentDir = filepath.Join(s.version.Addr, "content")
+	s.contentDir = filepath.Join(s.version.Addr, s.inventory.ContentDirectory())
 
-	err := os.MkdirAll(s.contentDir, dirPermission)
+	// The content directory is created lazily upon Put, since versions
+	// without new content should not have one.
+	err := os.MkdirAll(s.version.Addr, dirPermission)
 	if err != nil {
-		return errors.Wrapf(err, "error creating content directory %s", s.contentDir)
+		return errors.Wrapf(err, "error creating version directory %s", s.version.Addr)
 	}
 
 	s.inventory.Head = string(next)
 
-	// Copy the previous version's state to the new version's state
-	if prevVersion, ok := s.inventory.Versions[string(prev)]; ok {
-		prevState := prevVersion.State
-		s.inventory.Versions[string(next)] = metadata.Version{
-			State: make(map[metadata.Digest][]string, len(prevVersion.State)),
-		}
-
-		nextState := s.inventory.Versions[string(next)].State
-
-		for k, v := range prevState {
-			nextState[k] = v
-		}
-	} else {
-		s.inventory.Versions[string(next)] = metadata.Version{
-			State: make(map[metadata.Digest][]string, 10),
-		}
+	// Copy the previous version's state to the new version's state.  The copy
+	// is deep, since the new state's paths will be modified in place.
+	state := make(metadata.Manifest, 10)
+	if prevVersion, ok := s.inventory.Versions[string(prev)]; ok && prevVersion.State != nil {
+		state = prevVersion.State.Clone()
 	}
+	s.inventory.Versions[string(next)] = metadata.Version{State: state}
 
 	return nil
 }
@@ -271,16 +393,294 @@ func (s *session) prepareWrite() error {
 
 // writes the inventory file in the version directories, and in the ocfl root directory
 func (s *session) writeAllInventories() error {
-	err := s.writeInventory(s.version.Addr)
+	current, err := inventoryDigest(s.version.Parent.Addr)
+	if err != nil {
+		return err
+	}
+	if current != s.base {
+		return errors.Wrapf(ocfl.ErrConflict, "%s was committed to after it was opened", s.version.Parent.ID)
+	}
+
+	if s.driver.cfg.LinkUnchanged {
+		err = s.materialize()
+	}
+	if err == nil {
+		err = s.pruneContentDir()
+	}
+	if err == nil && s.driver.cfg.Sync {
+		err = s.syncContent()
+	}
+	if err != nil {
+		return err
+	}
+
+	// Stage the inventory in the version directory, and verify it
+	err = s.writeInventory(s.version.Addr)
 	if err == nil {
-		err = copyInventoryFiles(s.version.Addr, s.version.Parent.Addr)
+		err = verifyInventory(s.version.Addr)
+	}
+	if err == nil && s.driver.cfg.Signer != nil {
+		err = signInventory(s.driver.cfg.Signer, s.version.Parent.Addr, s.version.ID)
+	}
+	if err == nil && s.driver.cfg.Sync {
+		err = s.syncVersionInventory()
+	}
+	if err != nil {
+		return err
+	}
+
+	// Then promote it to the object root, restoring the previous root inventory
+	// if promotion fails partway through.
+	previous, err := snapshotInventory(s.version.Parent.Addr)
+	if err != nil {
+		return errors.Wrapf(err, "could not snapshot inventory of %s", s.version.Parent.ID)
+	}
+
+	err = s.driver.cfg.Faults.copyInventoryFiles(s.version.Addr, s.version.Parent.Addr)
+	if err != nil {
+		if e := previous.restore(); e != nil {
+			return errors.Wrapf(err, "failed restoring previous inventory (%s) after error", e)
+		}
+		return err
+	}
+
+	// Later commits in this session build upon this one
+	if s.base, err = inventoryDigest(s.version.Parent.Addr); err != nil {
+		return err
+	}
+
+	if s.driver.cfg.Sync {
+		return s.syncInventories()
+	}
+
+	return nil
+}
+
+// Computes the sha512 digest of the inventory in the given directory, or the empty
+// string if there is none
+func inventoryDigest(dir string) (string, error) {
+	file, err := os.Open(filepath.Join(dir, metadata.InventoryFile))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "could not open inventory in %s", dir)
+	}
+	defer file.Close()
+
+	hash := sha512.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return "", errors.Wrapf(err, "could not read inventory in %s", dir)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// inventorySnapshot contains the content of an inventory and its sidecar
+type inventorySnapshot struct {
+	dir       string
+	inventory []byte
+	sidecar   []byte
+}
+
+// Reads the inventory files in a directory, if present
+func snapshotInventory(dir string) (*inventorySnapshot, error) {
+	snap := &inventorySnapshot{dir: dir}
+
+	var err error
+	snap.inventory, err = ioutil.ReadFile(filepath.Join(dir, metadata.InventoryFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	snap.sidecar, err = ioutil.ReadFile(filepath.Join(dir, metadata.InventoryFile+hashSuffix))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return snap, nil
+}
+
+// Restores the snapshotted inventory files, removing any that didn't exist
+// at the time of the snapshot
+func (snap *inventorySnapshot) restore() error {
+	for name, content := range map[string][]byte{
+		metadata.InventoryFile:              snap.inventory,
+		metadata.InventoryFile + hashSuffix: snap.sidecar,
+	} {
+		path := filepath.Join(snap.dir, name)
+
+		if content == nil {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+
+		w, err := AtomicWrite(path)
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write(content); err != nil {
+			_ = w.Rollback()
+			return err
+		}
+		if err = w.Close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Verifies that the inventory in the given directory matches its sidecar digest
+func verifyInventory(dir string) error {
+	sidecar, err := ioutil.ReadFile(filepath.Join(dir, metadata.InventoryFile+hashSuffix))
+	if err != nil {
+		return errors.Wrapf(err, "could not read inventory sidecar in %s", dir)
+	}
+
+	inv, err := os.Open(filepath.Join(dir, metadata.InventoryFile))
+	if err != nil {
+		return errors.Wrapf(err, "could not read inventory in %s", dir)
+	}
+	defer inv.Close()
+
+	hash := sha512.New()
+	if _, err = io.Copy(hash, inv); err != nil {
+		return errors.Wrapf(err, "could not read inventory in %s", dir)
+	}
+
+	fields := strings.Fields(string(sidecar))
+	if len(fields) == 0 {
+		return fmt.Errorf("inventory sidecar in %s is empty", dir)
+	}
+	expected, err := metadata.ParseDigest("sha512", fields[0])
+	if err != nil {
+		return errors.Wrapf(err, "malformed inventory sidecar in %s", dir)
+	}
+	if !expected.Equal(metadata.Digest(hex.EncodeToString(hash.Sum(nil)))) {
+		return fmt.Errorf("inventory in %s does not match its sidecar digest", dir)
+	}
+
+	return nil
+}
+
+// Hard links every file in the version's state that lives outside of the version's
+// content directory into it, so that the version directory is self-contained.
+// Files are copied if they cannot be linked (e.g. across filesystems).
+func (s *session) materialize() error {
+	files, err := s.inventory.Files(s.version.ID)
+	if err != nil {
+		return err
+	}
+
+	physical := make(map[string]string, len(files))
+	for _, f := range files {
+		physical[f.LogicalPath] = f.PhysicalPath
+	}
+
+	versionPrefix := s.version.ID + "/"
+	for digest, lpaths := range s.inventory.Versions[s.version.ID].State {
+		for _, lpath := range lpaths {
+			if strings.HasPrefix(physical[lpath], versionPrefix) {
+				continue
+			}
+
+			relpath, ppath := s.filePaths(lpath)
+			src := filepath.Join(s.version.Parent.Addr, filepath.FromSlash(physical[lpath]))
+			if err = linkOrCopy(src, ppath); err != nil {
+				return errors.Wrapf(err, "could not link %s into %s", lpath, s.version.ID)
+			}
+
+			if err = s.inventory.PutFile(lpath, relpath, digest); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Removes the version's content directory if it contains no files, e.g. when
+// all content put in a session has subsequently been deleted.  Versions
+// without content should not have a content directory.
+func (s *session) pruneContentDir() error {
+	empty := true
+	err := filepath.Walk(s.contentDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			empty = false
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if os.IsNotExist(err) || !empty {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "could not inspect content directory %s", s.contentDir)
 	}
+
+	return errors.Wrapf(os.RemoveAll(s.contentDir), "could not remove empty content directory %s", s.contentDir)
+}
+
+// Determines if the session's new version has the same state as the previous version
+func (s *session) unchanged() bool {
+	if s.prev == "" || s.rollback == nil {
+		return false
+	}
+
+	prev, ok := s.inventory.Versions[s.prev]
+	return ok && s.inventory.Versions[s.inventory.Head].State.Equal(prev.State)
+}
+
+// Discards the session's new version, removing its directory and reverting the
+// inventory to the previous version.
+func (s *session) discard() error {
+	delete(s.inventory.Versions, s.inventory.Head)
+	s.inventory.Head = s.prev
+	s.commitfunc = nil
+	s.discarded = true
+
+	err := s.rollback()
+	s.rollback = nil
 	return err
 }
 
+// Validates the pending inventory, and verifies that every physical
+// path in its manifest exists on disk
+func (s *session) validate() error {
+	err := s.inventory.Validate()
+	if err != nil {
+		return err
+	}
+
+	for _, paths := range s.inventory.Manifest {
+		for _, p := range paths {
+			path := filepath.Join(s.version.Parent.Addr, filepath.FromSlash(p))
+			info, err := os.Stat(path)
+			if err != nil {
+				return errors.Wrapf(err, "manifest entry %s is not present", p)
+			}
+			if !info.Mode().IsRegular() {
+				return fmt.Errorf("manifest entry %s is not a regular file", p)
+			}
+		}
+	}
+
+	return nil
+}
+
 // safely copies inventory and hash files from one directory into another
 // With some thought, this could probably be made more pleasant
-func copyInventoryFiles(src, dest string) (err error) {
+func copyInventoryFiles(src, dest string) error {
+	return FaultInjector(nil).copyInventoryFiles(src, dest)
+}
+
+// Copies inventory and hash files, subject to the injector's faults
+func (f FaultInjector) copyInventoryFiles(src, dest string) (err error) {
 
 	srcInvName := filepath.Join(src, metadata.InventoryFile)
 	srcHashName := filepath.Join(src, metadata.InventoryFile+hashSuffix)
@@ -293,7 +693,7 @@ func copyInventoryFiles(src, dest string) (err error) {
 	}
 	defer srcInvFile.Close()
 
-	destInvWrite, err := AtomicWrite(destInvName)
+	destInvWrite, err := f.atomicWrite(destInvName)
 	if err != nil {
 		return err
 	}
@@ -310,7 +710,7 @@ func copyInventoryFiles(src, dest string) (err error) {
 	}
 	defer srcHashFile.Close()
 
-	destHashWrite, err := AtomicWrite(destHashName)
+	destHashWrite, err := f.atomicWrite(destHashName)
 	if err != nil {
 		return err
 	}
@@ -337,16 +737,26 @@ func copyInventoryFiles(src, dest string) (err error) {
 
 // Writes its inventory and sha512 files
 func (s *session) writeInventory(dir string) error {
+	return s.driver.cfg.Faults.writeInventory(s.inventory, dir)
+}
+
+// Writes the given inventory and its sha512 sidecar into a directory
+func writeInventory(inv *metadata.Inventory, dir string) error {
+	return FaultInjector(nil).writeInventory(inv, dir)
+}
+
+// Writes an inventory and its sidecar, subject to the injector's faults
+func (f FaultInjector) writeInventory(inv *metadata.Inventory, dir string) error {
 	invName := filepath.Join(dir, metadata.InventoryFile)
 	hash := sha512.New()
 
-	invWriter, err := AtomicWrite(invName)
+	invWriter, err := f.atomicWrite(invName)
 	if err != nil {
 		return errors.Wrapf(err, "could not initialize write to inventory file %s", invName)
 	}
-	defer invWriter.Close()
+	defer invWriter.Rollback()
 
-	err = s.inventory.Serialize(&TeeWriter{
+	err = inv.Serialize(&TeeWriter{
 		Writer: invWriter,
 		Tee:    hash,
 	})
@@ -354,21 +764,32 @@ func (s *session) writeInventory(dir string) error {
 		return errors.Wrapf(err, "Error writing version inventory at %s", invName)
 	}
 
+	err = invWriter.Close()
+	if err != nil {
+		return errors.Wrapf(err, "Error finalizing version inventory at %s", invName)
+	}
+
 	invHashName := invName + hashSuffix
-	err = ioutil.WriteFile(
-		invHashName,
-		[]byte(hex.EncodeToString(hash.Sum(nil))+" "+metadata.InventoryFile),
-		filePermission)
+	hashWriter, err := f.atomicWrite(invHashName)
 	if err != nil {
 		return errors.Wrapf(err, "Could not write inventory hash at %s", invHashName)
 	}
+	defer hashWriter.Rollback()
 
-	return nil
+	_, err = io.WriteString(hashWriter, hex.EncodeToString(hash.Sum(nil))+" "+metadata.InventoryFile)
+	if err == nil {
+		err = hashWriter.Close()
+	}
+	return errors.Wrapf(err, "Could not write inventory hash at %s", invHashName)
 }
 
 func (s *session) writeNamaste() error {
 	namasteFile := filepath.Join(s.version.Parent.Addr, ocflObjectRoot)
-	return ioutil.WriteFile(namasteFile, []byte(objectRootNamasteContent), filePermission)
+	err := ioutil.WriteFile(namasteFile, []byte(objectRootNamasteContent), filePermission)
+	if err == nil && s.driver.cfg.Sync {
+		err = syncPaths(namasteFile, s.version.Parent.Addr)
+	}
+	return err
 }
 
 func (s *session) openVersion(obj *ocfl.EntityRef, v string) error {
@@ -387,11 +808,35 @@ func (s *session) openVersion(obj *ocfl.EntityRef, v string) error {
 		Addr:   filepath.Join(obj.Addr, v),
 		Parent: obj,
 	}
-	s.contentDir = filepath.Join(s.version.Addr, "content")
+	s.contentDir = filepath.Join(s.version.Addr, s.inventory.ContentDirectory())
 
 	return nil
 }
 
+// PathPredictor is implemented by sessions that can report where content for
+// a given logical path would be stored, without actually writing anything.
+// This is useful for pre-staging content, or debugging a file path layout.
+//
+// Sessions returned by Driver.Open implement PathPredictor, e.g.
+//
+//	objectRelative, absolute, err := session.(fs.PathPredictor).PhysicalPath("foo/bar.txt")
+type PathPredictor interface {
+	PhysicalPath(lpath string) (objectRelative, absolute string, err error)
+}
+
+// PhysicalPath computes the object relative (e.g. v1/content/path/to/file), and
+// absolute physical paths that Put would use for the given logical path
+// in the session's version.  Note that if content is deduplicated upon Put,
+// nothing will be written to that path.
+func (s *session) PhysicalPath(lpath string) (objectRelative, absolute string, err error) {
+	if s.driver.cfg.FilePaths == nil {
+		return "", "", fmt.Errorf("no file path function given, cannot compute physical path of %s", lpath)
+	}
+
+	objectRelative, absolute = s.filePaths(lpath)
+	return objectRelative, absolute, nil
+}
+
 // Computes the object relative (e.g. v1/content/path/to/file), and
 // absolute physical paths for a given logical path.
 func (s *session) filePaths(lpath string) (objectRelative, absolute string) {
@@ -405,10 +850,34 @@ func (s *session) filePaths(lpath string) (objectRelative, absolute string) {
 // Put (safely) the content of the reader into the filesystem, and update
 // keep track of pending changes to inventory to be committed upon Commit()
 //
+// If the driver is configured with Dedup, content whose digest is already
+// present in the object's manifest is not retained; the logical path simply
+// references the existing content.
+//
 // This attempts a "safe" PUT which performs a write-to-temp-then-rename
 // if it is overwriting an existing file.  If an error is encountered, it
 // attempts cleanup by removing any written files.
-func (s *session) Put(lpath string, r io.Reader) (err error) {
+func (s *session) Put(lpath string, r io.Reader) error {
+	return s.PutContext(context.Background(), lpath, r)
+}
+
+// PutContext puts the content of the reader into the filesystem, like Put.
+// If the context is done before all content is copied, the write is rolled back
+// and the context's error is returned.
+func (s *session) PutContext(ctx context.Context, lpath string, r io.Reader) (err error) {
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	if s.discarded {
+		return fmt.Errorf("cannot put to %s, its new version was discarded", s.version.Parent.ID)
+	}
+
+	// Checked before anything is written, as a malformed path could escape the content directory
+	if verr := metadata.ValidateLogicalPath(lpath); verr != nil {
+		return verr
+	}
+
 	err = s.prepareWrite()
 	if err != nil {
 		return fmt.Errorf("could not execute put to %s", s.version.Parent.ID)
@@ -416,15 +885,31 @@ func (s *session) Put(lpath string, r io.Reader) (err error) {
 
 	relpath, ppath := s.filePaths(lpath)
 
+	if s.driver.portable() {
+		if err = checkPortable(relpath); err != nil {
+			return errors.Wrapf(err, "cannot put %s", lpath)
+		}
+	}
+	if s.driver.caseInsensitive() {
+		if err = s.checkCaseConflict(relpath); err != nil {
+			return err
+		}
+	}
+
+	// Content at a path that already exists is an overwrite, and is never deduplicated
+	_, statErr := os.Stat(ppath)
+	overwrite := statErr == nil
+
 	err = os.MkdirAll(filepath.Dir(ppath), dirPermission)
 	if err != nil {
 		return errors.Wrapf(err, "could not create content directory")
 	}
 
-	fw, err := SafeWrite(ppath)
+	fw, err := SafeStagedWrite(s.driver.cfg.Staging, ppath)
 	if err != nil {
 		return errors.Wrapf(err, "could not create file %s for %s", ppath, lpath)
 	}
+	fw = s.driver.cfg.Faults.wrap(fw, ppath)
 	defer func() {
 		e := fw.Rollback()
 		if e != nil {
@@ -434,28 +919,137 @@ func (s *session) Put(lpath string, r io.Reader) (err error) {
 
 	hash := sha512.New()
 
-	_, err = io.Copy(&TeeWriter{
-		Writer: fw,
-		Tee:    hash,
-	}, r)
+	// Content is linked or cloned from the file being put, if any, rather than what reads it
+	src := r
+	var linkable *os.File
+	if s.driver.cfg.Hardlink {
+		linkable = linkableFile(src)
+	}
+
+	if s.opts.Progress != nil {
+		s.updateProgress(func(p *ocfl.Progress) { p.Path = lpath })
+		r = &countingReader{Reader: r, count: func(n int) {
+			s.updateProgress(func(p *ocfl.Progress) { p.BytesRead += int64(n) })
+		}}
+	}
+
+	var size int64
+	if linkable != nil || (s.driver.cfg.Reflink && cloneFile(fw, src)) {
+		size, err = io.Copy(hash, &contextReader{ctx: ctx, Reader: r})
+	} else {
+		size, err = io.Copy(&TeeWriter{
+			Writer: fw,
+			Tee:    hash,
+		}, &contextReader{ctx: ctx, Reader: r})
+	}
 	if err != nil {
 		return errors.Wrapf(err, "could not copy content to filesystem")
 	}
 
+	digest := metadata.Digest(hex.EncodeToString(hash.Sum(nil)))
+
 	s.Lock()
 	defer s.Unlock()
 
-	err = fw.Close()
+	// Existing content with the same digest?  Then just reference it, and let the deferred
+	// rollback remove what we just wrote.
+	if _, exists := s.inventory.FindDigest(digest); exists && !overwrite && s.driver.cfg.Dedup {
+		if err = s.inventory.PutLogicalFile(lpath, digest); err == nil {
+			s.updateProgress(func(p *ocfl.Progress) { p.FilesPut++ })
+		}
+		return err
+	}
+
+	if linkable != nil {
+		err = linkContent(fw, linkable, ppath)
+	} else {
+		err = fw.Close()
+	}
 	if err != nil {
 		return errors.Wrapf(err, "error finalizing conttent for %s at %s", lpath, ppath)
 	}
 
-	err = s.inventory.PutFile(lpath, relpath, metadata.Digest(hex.EncodeToString(hash.Sum(nil))))
+	err = s.inventory.PutFile(lpath, relpath, digest)
+	if err == nil {
+		if s.written == nil {
+			s.written = make(map[string]bool)
+		}
+		s.written[relpath] = true
+		s.updateProgress(func(p *ocfl.Progress) {
+			p.FilesPut++
+			p.BytesWritten += size
+		})
+	}
 
 	return err
 }
 
+// Updates the session's progress, and reports it to the callback, if any
+func (s *session) updateProgress(f func(*ocfl.Progress)) {
+	if s.opts.Progress == nil {
+		return
+	}
+	s.progressLock.Lock()
+	defer s.progressLock.Unlock()
+	s.progress.Version = s.version.ID
+	f(&s.progress)
+	s.opts.Progress(s.progress)
+}
+
+// Reader that counts the bytes read from it
+type countingReader struct {
+	io.Reader
+	count func(int)
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	if n > 0 {
+		r.count(n)
+	}
+	return n, err
+}
+
+// Verifies that no content in the manifest has a physical path that differs from the given
+// one only by case, as both would refer to the same file on case insensitive filesystems.
+func (s *session) checkCaseConflict(relpath string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if p, conflict := s.inventory.CaseConflict(relpath); conflict {
+		return fmt.Errorf("physical path %s conflicts with %s, which differs only by case", relpath, p)
+	}
+	return nil
+}
+
+// Attempts to reflink the content of the reader into the write, if the reader is a
+// file positioned at its start.  Returns false if the content must be copied instead.
+func cloneFile(w *ManagedWrite, r io.Reader) bool {
+	src, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	out := w.WriteCloser
+	if faulty, ok := out.(*faultyWriter); ok {
+		out = faulty.WriteCloser
+	}
+	dst, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+
+	if pos, err := src.Seek(0, io.SeekCurrent); err != nil || pos != 0 {
+		return false
+	}
+
+	return reflink(dst, src) == nil
+}
+
 func (s *session) Delete(lpath string) (err error) {
+	if s.discarded {
+		return fmt.Errorf("cannot delete from %s, its new version was discarded", s.version.Parent.ID)
+	}
+
 	err = s.prepareWrite()
 	if err != nil {
 		return errors.Wrapf(err, "could not execute delete to %s", s.version.Parent.ID)
@@ -464,30 +1058,174 @@ func (s *session) Delete(lpath string) (err error) {
 	s.Lock()
 	defer s.Unlock()
 
-	err = s.inventory.DeleteFile(lpath)
+	// Content only written in this version, and referenced by nothing else, is removed too.
+	// When amending a committed version, its content may only be removed once the amended
+	// inventory is committed, since the committed inventory still references it.
+	pruned, err := s.inventory.RemoveLogicalPath(lpath, true)
 	if err != nil {
 		return errors.Wrapf(err, "Could not modify inventory %s", lpath)
 	}
 
+	for _, p := range pruned {
+		if !s.written[p] {
+			s.pruned = append(s.pruned, p)
+			continue
+		}
+		delete(s.written, p)
+		if err = s.removeContent(p); err != nil {
+			return errors.Wrapf(err, "could not remove content of %s", lpath)
+		}
+	}
+
 	return nil
 }
 
+// Removes content at the given path relative to the object root, and any
+// directories of the version left empty
+func (s *session) removeContent(p string) error {
+	ppath := filepath.Join(s.version.Parent.Addr, filepath.FromSlash(p))
+	if err := os.Remove(ppath); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "could not remove %s", ppath)
+	}
+	return removeEmptyParents(filepath.Dir(ppath), s.version.Addr)
+}
+
+// Move renames the logical file at src to dest, referencing the same content.  The content
+// itself is not moved or copied, so its physical path still reflects the logical path it
+// was put at.  It is an error if there is no file at src, or if dest conflicts with another
+// file (see metadata.Inventory.RenameLogicalPath).
+func (s *session) Move(src, dest string) error {
+	if s.discarded {
+		return fmt.Errorf("cannot move in %s, its new version was discarded", s.version.Parent.ID)
+	}
+
+	err := s.prepareWrite()
+	if err != nil {
+		return errors.Wrapf(err, "could not execute move in %s", s.version.Parent.ID)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	return s.inventory.RenameLogicalPath(src, dest)
+}
+
 func (s *session) Commit(commit ocfl.CommitInfo) error {
+	return s.CommitContext(context.Background(), commit)
+}
+
+// CommitContext commits the session, like Commit, unless the given context is
+// already done.
+func (s *session) CommitContext(ctx context.Context, commit ocfl.CommitInfo) error {
+	if err := ctx.Err(); err != nil {
+		return errors.Wrapf(err, "aborted commit of %s %s", s.version.Parent.ID, s.version.ID)
+	}
+
+	commit, err := commit.WithDefaults(s.driver.cfg.Identity)
+	if err != nil {
+		return errors.Wrapf(err, "could not determine commit identity of %s %s", s.version.Parent.ID, s.version.ID)
+	}
+
 	s.Lock()
 	defer s.Unlock()
 	v := s.inventory.Versions[s.inventory.Head]
-	v.Created = commit.Date.UTC().Truncate(1 * time.Millisecond)
+	v.Created = s.driver.cfg.TimestampPrecision.Truncate(commit.Date)
 	v.Message = commit.Message
 	v.User = metadata.User{
 		Name:    commit.Name,
 		Address: commit.Address,
 	}
 	s.inventory.Versions[s.inventory.Head] = v
+
+	if s.unchanged() {
+		switch s.opts.Unchanged {
+		case ocfl.RefuseUnchanged:
+			return errors.Wrapf(ocfl.ErrUnchanged, "refusing to commit %s %s", s.version.Parent.ID, s.version.ID)
+		case ocfl.DiscardUnchanged:
+			// Discarding is a successful commit, so releases the lock as one does
+			err := s.discard()
+			if e := s.release(); err == nil {
+				err = e
+			}
+			return errors.Wrapf(err, "could not discard unchanged %s %s", s.version.Parent.ID, s.version.ID)
+		}
+	}
+
 	if s.commitfunc != nil {
+		for _, hook := range s.opts.PreCommit {
+			if err := hook(s.inventory); err != nil {
+				return errors.Wrapf(err, "pre-commit hook rejected %s %s", s.version.Parent.ID, s.version.ID)
+			}
+		}
+
+		if s.opts.Validate {
+			if err := s.validate(); err != nil {
+				return errors.Wrapf(err, "refusing to commit invalid %s %s", s.version.Parent.ID, s.version.ID)
+			}
+		}
+
 		err := s.commitfunc()
+		s.driver.invalidate(s.version.Parent.Addr)
 		if err != nil {
+			// After a conflict, the version directory may hold another writer's
+			// committed version, so it must not be removed.  GC collects whatever
+			// this session left behind.
+			if errors.Cause(err) == ocfl.ErrConflict {
+				s.rollback = nil
+			}
+			if s.rollback != nil {
+				if e := s.rollback(); e != nil {
+					err = errors.Wrapf(err, "cleanup after failed commit also failed (%s)", e)
+				}
+				s.rollback = nil
+			}
 			return errors.Wrapf(err, "could not commit %s %s", s.version.Parent.ID, s.version.ID)
 		}
+
+		// Once committed, the version is no longer ours to remove
+		s.rollback = nil
+		s.updateProgress(func(p *ocfl.Progress) { p.Committed = true })
+
+		// Committed content deleted in the session is now unreferenced.  If it cannot
+		// be removed, it is merely garbage (see GC).
+		for _, p := range s.pruned {
+			if err := s.removeContent(p); err != nil {
+				_ = s.release()
+				return errors.Wrapf(err, "committed %s %s, but could not remove deleted content", s.version.Parent.ID, s.version.ID)
+			}
+		}
+		s.pruned = nil
 	}
-	return nil
+	return s.release()
+}
+
+// Close releases the session's lock on its object, if held.  If the session's new
+// version has not been committed, anything created for it is removed.  Closing a
+// committed session is harmless.
+func (s *session) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	var err error
+	if s.rollback != nil {
+		err = s.rollback()
+		s.rollback = nil
+		s.commitfunc = nil
+		s.discarded = true
+	}
+
+	if e := s.release(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// Releases the object lock, if held
+func (s *session) release() error {
+	if s.unlock == nil {
+		return nil
+	}
+	err := s.unlock()
+	s.unlock = nil
+	return err
 }
@@ -1,10 +1,14 @@
 package fs_test
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -12,7 +16,10 @@ import (
 	"github.com/birkland/ocfl"
 	"github.com/birkland/ocfl/drivers/fs"
 	"github.com/birkland/ocfl/fspath"
+	"github.com/birkland/ocfl/identity"
+	"github.com/birkland/ocfl/metadata"
 	"github.com/go-test/deep"
+	"github.com/pkg/errors"
 )
 
 const objectID = "urn:test/myObj"
@@ -207,6 +214,174 @@ func TestDelete(t *testing.T) {
 	})
 }
 
+// New versions start with a copy of the previous state that shares nothing with it,
+// since paths are removed from the new state in place
+func TestDeleteLeavesHistory(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		session := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		session.Put("a", strings.NewReader("same"))
+		session.Put("b", strings.NewReader("same"))
+		session.Put("c", strings.NewReader("same"))
+		session.Commit(ocfl.CommitInfo{})
+
+		session = driver.Open(objectID, ocfl.Options{Version: ocfl.NEW})
+		session.Delete("a")
+		session.Commit(ocfl.CommitInfo{})
+
+		var obj ocfl.EntityRef
+		driver.Walk(ocfl.Select{Type: ocfl.Object}, func(ref ocfl.EntityRef) error {
+			obj = ref
+			return nil
+		}, objectID)
+
+		inv, err := fs.ReadInventory(obj.Addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for version, expected := range map[string][]string{"v1": {"a", "b", "c"}, "v2": {"b", "c"}} {
+			var paths []string
+			for _, p := range inv.Versions[version].State {
+				paths = append(paths, p...)
+			}
+			sort.Strings(paths)
+			if d := deep.Equal(paths, expected); d != nil {
+				t.Errorf("unexpected state of %s: %v", version, d)
+			}
+		}
+	})
+}
+
+func TestMove(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		session := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		session.Put("file1", strings.NewReader("one"))
+		session.Put("file2", strings.NewReader("two"))
+		session.Commit(ocfl.CommitInfo{})
+
+		session = driver.Open(objectID, ocfl.Options{Version: ocfl.NEW})
+		session.Move("file1", "dir/file1")
+		if err := session.session.Move("file2", "dir/file1"); err == nil {
+			t.Errorf("moving onto an existing file should fail")
+		}
+		if err := session.session.Move("missing", "file3"); err == nil {
+			t.Errorf("moving a missing file should fail")
+		}
+		session.Commit(ocfl.CommitInfo{})
+
+		files := make(map[string]string)
+		driver.Walk(ocfl.Select{Type: ocfl.File, Head: true}, func(ref ocfl.EntityRef) error {
+			content, _ := ioutil.ReadFile(ref.Addr)
+			files[ref.ID] = string(content)
+			return nil
+		})
+		if diff := deep.Equal(files, map[string]string{"dir/file1": "one", "file2": "two"}); diff != nil {
+			t.Error(diff)
+		}
+
+		// No content was copied
+		path, _ := driver.driver.(*fs.Driver).ObjectPath(objectID)
+		if _, err := os.Stat(filepath.Join(path, "v2", "content")); !os.IsNotExist(err) {
+			t.Errorf("moving should not create content")
+		}
+	})
+}
+
+func TestPutProgress(t *testing.T) {
+	runWithDriverConfig(t, func(cfg *fs.Config) { cfg.Dedup = true }, func(driver driverWrapper) {
+		var reports []ocfl.Progress
+		session := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW, Progress: func(p ocfl.Progress) {
+			reports = append(reports, p)
+		}})
+		session.Put("a.txt", strings.NewReader("hello"))
+		session.Put("b.txt", strings.NewReader("hello"))
+
+		last := reports[len(reports)-1]
+		if diff := deep.Equal(last, ocfl.Progress{
+			Version:      "v1",
+			FilesPut:     2,
+			BytesRead:    10,
+			BytesWritten: 5, // The content of b.txt is deduplicated
+			Path:         "b.txt",
+		}); diff != nil {
+			t.Error(diff)
+		}
+
+		session.Commit(ocfl.CommitInfo{})
+		if last := reports[len(reports)-1]; !last.Committed || last.FilesPut != 2 {
+			t.Errorf("Expected a final report of the commit, got %+v", last)
+		}
+	})
+}
+
+func TestDeleteNewContent(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		session := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		session.Put("keep", strings.NewReader("keep"))
+		session.Put("dir/file", strings.NewReader("gone"))
+		session.Delete("dir/file")
+		session.Commit(ocfl.CommitInfo{})
+
+		d := driver.driver.(*fs.Driver)
+		path, _ := d.ObjectPath(objectID)
+		if _, err := os.Stat(filepath.Join(path, "v1", "content", "dir")); !os.IsNotExist(err) {
+			t.Errorf("content of the deleted file should have been removed")
+		}
+
+		report, err := d.Validate(context.Background(), objectID, fs.ValidateOptions{})
+		if err != nil {
+			t.Fatalf("validation failed %+v", err)
+		}
+		if !report.Valid() {
+			t.Errorf("object should be valid, got %v", report.Results)
+		}
+	})
+}
+
+// Deleting committed content when amending the head version only removes it upon commit
+func TestDeleteAmendedContent(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		session := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		session.Put("a", strings.NewReader("a"))
+		session.Commit(ocfl.CommitInfo{})
+
+		session = driver.Open(objectID, ocfl.Options{Version: ocfl.NEW})
+		session.Put("b", strings.NewReader("b"))
+		session.Commit(ocfl.CommitInfo{})
+
+		d := driver.driver.(*fs.Driver)
+		path, _ := d.ObjectPath(objectID)
+		content := filepath.Join(path, "v2", "content", "b")
+		validate := func(when string) {
+			report, err := d.Validate(context.Background(), objectID, fs.ValidateOptions{})
+			if err != nil {
+				t.Fatalf("validation failed %+v", err)
+			}
+			if !report.Valid() {
+				t.Errorf("object should be valid %s, got %v", when, report.Results)
+			}
+		}
+
+		// Abandoned, so the committed version is untouched
+		session = driver.Open(objectID, ocfl.Options{Version: "v2"})
+		session.Delete("b")
+		if err := session.session.Close(); err != nil {
+			t.Fatalf("close failed %+v", err)
+		}
+		if _, err := os.Stat(content); err != nil {
+			t.Errorf("committed content should remain until commit: %s", err)
+		}
+		validate("after an abandoned delete")
+
+		session = driver.Open(objectID, ocfl.Options{Version: "v2"})
+		session.Delete("b")
+		session.Commit(ocfl.CommitInfo{})
+		if _, err := os.Stat(content); !os.IsNotExist(err) {
+			t.Errorf("deleted content should have been removed upon commit")
+		}
+		validate("after a committed delete")
+	})
+}
+
 func TestNoObjectPathFunc(t *testing.T) {
 	runWithDriverWrapper(t, func(driver driverWrapper) {
 
@@ -254,6 +429,776 @@ func TestNoObjectPathFunc(t *testing.T) {
 	})
 }
 
+func TestPhysicalPath(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		session := driver.Open(objectID, ocfl.Options{
+			Create:  true,
+			Version: ocfl.NEW,
+		})
+
+		relative, absolute, err := session.session.(fs.PathPredictor).PhysicalPath("foo/bar.txt")
+		if err != nil {
+			t.Fatalf("Could not compute physical path %+v", err)
+		}
+
+		if relative != "v1/content/foo/bar.txt" {
+			t.Errorf("Unexpected object relative path %s", relative)
+		}
+
+		session.Put("foo/bar.txt", strings.NewReader("content"))
+		session.Commit(ocfl.CommitInfo{})
+
+		var found []string
+		driver.Walk(ocfl.Select{Type: ocfl.File}, func(ref ocfl.EntityRef) error {
+			found = append(found, ref.Addr)
+			return nil
+		}, objectID)
+
+		if len(found) != 1 || found[0] != absolute {
+			t.Errorf("Predicted path %s does not match actual %s", absolute, found)
+		}
+	})
+}
+
+func TestPutContextCancel(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		session := driver.Open(objectID, ocfl.Options{
+			Create:  true,
+			Version: ocfl.NEW,
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := session.session.PutContext(ctx, "file1", strings.NewReader("one"))
+		if err == nil {
+			t.Errorf("Put should have failed with a cancelled context")
+		}
+
+		err = session.session.CommitContext(ctx, ocfl.CommitInfo{})
+		if err == nil {
+			t.Errorf("Commit should have failed with a cancelled context")
+		}
+	})
+}
+
+func TestPutMalformedPaths(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		session := driver.Open(objectID, ocfl.Options{
+			Create:  true,
+			Version: ocfl.NEW,
+		})
+
+		for _, lpath := range []string{"../escape", "a//b", "/abs", "a\\b"} {
+			err := session.session.Put(lpath, strings.NewReader(lpath))
+			if _, ok := err.(*metadata.ValidationError); !ok {
+				t.Errorf("expected a validation error putting %s, got %v", lpath, err)
+			}
+		}
+
+		_ = filepath.Walk(driver.root, func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.Name() == "escape" {
+				t.Errorf("content should not have been written to %s", path)
+			}
+			return nil
+		})
+	})
+}
+
+func TestCommitIdentityDefaults(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		_ = fs.MkRoot(ocflRoot)
+
+		driver, err := fs.NewDriver(fs.Config{
+			Root:        ocflRoot,
+			ObjectPaths: fspath.GeneratorFunc(url.QueryEscape),
+			FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
+			Identity:    identity.Static{Name: "defaultName", Address: "default@ddress"},
+		})
+		if err != nil {
+			t.Fatalf("Error setting up driver %+v", err)
+		}
+
+		session, _ := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		_ = session.Put("file", strings.NewReader("content"))
+		err = session.Commit(ocfl.CommitInfo{Address: "explicit@ddress"})
+		if err != nil {
+			t.Fatalf("Commit failed %+v", err)
+		}
+
+		var objects []ocfl.EntityRef
+		_ = driver.Walk(ocfl.Select{Type: ocfl.Object}, func(ref ocfl.EntityRef) error {
+			objects = append(objects, ref)
+			return nil
+		}, objectID)
+
+		inv, err := fs.ReadInventory(objects[0].Addr)
+		if err != nil {
+			t.Fatalf("Could not read inventory %+v", err)
+		}
+
+		v1 := inv.Versions["v1"]
+		if v1.User.Name != "defaultName" || v1.User.Address != "explicit@ddress" {
+			t.Errorf("Unexpected commit user %+v", v1.User)
+		}
+		if v1.Created.IsZero() {
+			t.Errorf("Commit date should have defaulted to now")
+		}
+	})
+}
+
+func TestStagingDir(t *testing.T) {
+	runInTempDir(t, func(tempDir string) {
+		ocflRoot := filepath.Join(tempDir, "root")
+		staging := filepath.Join(tempDir, "staging")
+		_ = fs.MkRoot(ocflRoot)
+		_ = os.Mkdir(staging, 0755)
+
+		driver, err := fs.NewDriver(fs.Config{
+			Root:        ocflRoot,
+			ObjectPaths: fspath.GeneratorFunc(url.QueryEscape),
+			FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
+			Staging:     staging,
+		})
+		if err != nil {
+			t.Fatalf("Error setting up driver %+v", err)
+		}
+
+		session, _ := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		_ = session.Put("a/file", strings.NewReader("content"))
+		if err = session.Commit(ocfl.CommitInfo{}); err != nil {
+			t.Fatalf("Commit failed %+v", err)
+		}
+
+		var files []ocfl.EntityRef
+		_ = driver.Walk(ocfl.Select{Type: ocfl.File}, func(ref ocfl.EntityRef) error {
+			files = append(files, ref)
+			return nil
+		}, objectID)
+
+		if len(files) != 1 {
+			t.Fatalf("Expected one file, got %d", len(files))
+		}
+		content, _ := ioutil.ReadFile(files[0].Addr)
+		if string(content) != "content" {
+			t.Errorf("Unexpected content %s", content)
+		}
+
+		staged, _ := ioutil.ReadDir(staging)
+		if len(staged) > 0 {
+			t.Errorf("Staging directory was not cleaned up")
+		}
+	})
+}
+
+func TestStagingDirMissing(t *testing.T) {
+	_, err := fs.NewDriver(fs.Config{Staging: "DOES_NOT_EXIST"})
+	if err == nil {
+		t.Errorf("Expected an error for a nonexistent staging directory")
+	}
+}
+
+func TestSyncCommit(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		_ = fs.MkRoot(ocflRoot)
+
+		driver, err := fs.NewDriver(fs.Config{
+			Root:        ocflRoot,
+			ObjectPaths: fspath.GeneratorFunc(url.QueryEscape),
+			FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
+			Sync:        true,
+		})
+		if err != nil {
+			t.Fatalf("Error setting up driver %+v", err)
+		}
+
+		for _, content := range []string{"first", "second"} {
+			session, err := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+			if err != nil {
+				t.Fatalf("Could not open session %+v", err)
+			}
+			_ = session.Put("a/b/"+content, strings.NewReader(content))
+			if err = session.Commit(ocfl.CommitInfo{}); err != nil {
+				t.Fatalf("Commit failed %+v", err)
+			}
+		}
+
+		// An empty version has no content to sync
+		if err = driver.CreateEmptyVersion(objectID, ocfl.CommitInfo{}); err != nil {
+			t.Fatalf("Empty commit failed %+v", err)
+		}
+
+		var objects []ocfl.EntityRef
+		_ = driver.Walk(ocfl.Select{Type: ocfl.Object}, func(ref ocfl.EntityRef) error {
+			objects = append(objects, ref)
+			return nil
+		}, objectID)
+
+		inv, err := fs.ReadInventory(objects[0].Addr)
+		if err != nil {
+			t.Fatalf("Could not read inventory %+v", err)
+		}
+		if inv.Head != "v3" || len(inv.Versions["v2"].State) != 2 {
+			t.Errorf("Unexpected inventory state %+v", inv.Versions)
+		}
+	})
+}
+
+func TestLinkUnchanged(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		_ = fs.MkRoot(ocflRoot)
+
+		driver, err := fs.NewDriver(fs.Config{
+			Root:          ocflRoot,
+			ObjectPaths:   fspath.GeneratorFunc(url.QueryEscape),
+			FilePaths:     fspath.GeneratorFunc(fs.Passthrough),
+			LinkUnchanged: true,
+		})
+		if err != nil {
+			t.Fatalf("Error setting up driver %+v", err)
+		}
+
+		for _, files := range [][]string{{"a", "b"}, {"c"}} {
+			session, _ := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+			for _, f := range files {
+				_ = session.Put(f, strings.NewReader("content of "+f))
+			}
+			if err = session.Commit(ocfl.CommitInfo{}); err != nil {
+				t.Fatalf("Commit failed %+v", err)
+			}
+		}
+
+		var v2Files []ocfl.EntityRef
+		err = driver.Walk(ocfl.Select{Type: ocfl.File, Head: true}, func(ref ocfl.EntityRef) error {
+			v2Files = append(v2Files, ref)
+			return nil
+		}, objectID)
+		if err != nil {
+			t.Fatalf("Walk failed %+v", err)
+		}
+
+		if len(v2Files) != 3 {
+			t.Fatalf("Expected 3 files in v2, got %d", len(v2Files))
+		}
+
+		objRoot := v2Files[0].Parent.Parent.Addr
+		for _, f := range v2Files {
+			if !strings.HasPrefix(f.Addr, filepath.Join(objRoot, "v2")) {
+				t.Errorf("%s is not materialized in v2: %s", f.ID, f.Addr)
+			}
+		}
+
+		v1Info, _ := os.Stat(filepath.Join(objRoot, "v1", "content", "a"))
+		v2Info, _ := os.Stat(filepath.Join(objRoot, "v2", "content", "a"))
+		if !os.SameFile(v1Info, v2Info) {
+			t.Errorf("unchanged file was not hard linked")
+		}
+	})
+}
+
+// Reflinks may not be supported where the tests run, in which case
+// content is copied.  Either way, the content must be intact.
+func TestReflink(t *testing.T) {
+	runInTempDir(t, func(tempDir string) {
+		ocflRoot := filepath.Join(tempDir, "root")
+		_ = fs.MkRoot(ocflRoot)
+
+		driver, err := fs.NewDriver(fs.Config{
+			Root:        ocflRoot,
+			ObjectPaths: fspath.GeneratorFunc(url.QueryEscape),
+			FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
+			Reflink:     true,
+		})
+		if err != nil {
+			t.Fatalf("Error setting up driver %+v", err)
+		}
+
+		srcName := filepath.Join(tempDir, "src")
+		_ = ioutil.WriteFile(srcName, []byte("0123456789"), 0664)
+
+		session, _ := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+
+		whole, _ := os.Open(srcName)
+		defer whole.Close()
+		if err = session.Put("whole", whole); err != nil {
+			t.Fatalf("Put failed %+v", err)
+		}
+
+		// A partially consumed file cannot be cloned
+		partial, _ := os.Open(srcName)
+		defer partial.Close()
+		_, _ = partial.Seek(5, io.SeekStart)
+		if err = session.Put("partial", partial); err != nil {
+			t.Fatalf("Put failed %+v", err)
+		}
+
+		if err = session.Commit(ocfl.CommitInfo{}); err != nil {
+			t.Fatalf("Commit failed %+v", err)
+		}
+
+		expected := map[string]string{
+			"whole":   "0123456789",
+			"partial": "56789",
+		}
+
+		_ = driver.Walk(ocfl.Select{Type: ocfl.File}, func(ref ocfl.EntityRef) error {
+			content, _ := ioutil.ReadFile(ref.Addr)
+			if string(content) != expected[ref.ID] {
+				t.Errorf("Unexpected content of %s: %s", ref.ID, content)
+			}
+			return nil
+		}, objectID)
+	})
+}
+
+func TestHardlink(t *testing.T) {
+	runInTempDir(t, func(tempDir string) {
+		ocflRoot := filepath.Join(tempDir, "root")
+		_ = fs.MkRoot(ocflRoot)
+
+		driver, err := fs.NewDriver(fs.Config{
+			Root:        ocflRoot,
+			ObjectPaths: fspath.GeneratorFunc(url.QueryEscape),
+			FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
+			Hardlink:    true,
+		})
+		if err != nil {
+			t.Fatalf("Error setting up driver %+v", err)
+		}
+
+		srcName := filepath.Join(tempDir, "src")
+		_ = ioutil.WriteFile(srcName, []byte("0123456789"), 0664)
+
+		// Progress is reported as content is read, which must not prevent linking
+		session, _ := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW, Progress: func(ocfl.Progress) {}})
+
+		whole, _ := os.Open(srcName)
+		defer whole.Close()
+		if err = session.Put("whole", whole); err != nil {
+			t.Fatalf("Put failed %+v", err)
+		}
+
+		// A partially consumed file, or content that isn't a file, cannot be linked
+		partial, _ := os.Open(srcName)
+		defer partial.Close()
+		_, _ = partial.Seek(5, io.SeekStart)
+		if err = session.Put("partial", partial); err != nil {
+			t.Fatalf("Put failed %+v", err)
+		}
+		if err = session.Put("reader", strings.NewReader("abc")); err != nil {
+			t.Fatalf("Put failed %+v", err)
+		}
+
+		if err = session.Commit(ocfl.CommitInfo{}); err != nil {
+			t.Fatalf("Commit failed %+v", err)
+		}
+
+		expected := map[string]string{
+			"whole":   "0123456789",
+			"partial": "56789",
+			"reader":  "abc",
+		}
+
+		srcInfo, _ := os.Stat(srcName)
+		_ = driver.Walk(ocfl.Select{Type: ocfl.File}, func(ref ocfl.EntityRef) error {
+			content, _ := ioutil.ReadFile(ref.Addr)
+			if string(content) != expected[ref.ID] {
+				t.Errorf("Unexpected content of %s: %s", ref.ID, content)
+			}
+
+			info, _ := os.Stat(ref.Addr)
+			if linked := os.SameFile(info, srcInfo); linked != (ref.ID == "whole") {
+				t.Errorf("%s should be linked to its source: %t", ref.ID, !linked)
+			}
+			return nil
+		}, objectID)
+	})
+}
+
+func TestPortablePaths(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		_ = fs.MkRoot(ocflRoot)
+
+		driver, err := fs.NewDriver(fs.Config{
+			Root:        ocflRoot,
+			ObjectPaths: fspath.GeneratorFunc(url.QueryEscape),
+			FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
+			Portable:    true,
+		})
+		if err != nil {
+			t.Fatalf("Error setting up driver %+v", err)
+		}
+
+		if _, err = driver.Open("nul.txt", ocfl.Options{Create: true, Version: ocfl.NEW}); err == nil {
+			t.Errorf("Should not have been able to create an object at a reserved name")
+		}
+
+		session, err := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		if err != nil {
+			t.Fatalf("Could not open session %+v", err)
+		}
+
+		cases := []struct {
+			lpath string
+			ok    bool
+		}{
+			{"dir/File.txt", true},
+			{"dir/file.txt", false}, // differs only by case
+			{"dir/CON", false},
+			{"Com1.tar.gz", false},
+			{"lpt1/file", false},
+			{"trailing.", false},
+			{"trailing /file", false},
+			{"console/file", true},
+		}
+
+		for _, c := range cases {
+			err := session.Put(c.lpath, strings.NewReader(c.lpath))
+			if c.ok && err != nil {
+				t.Errorf("Put of %s should have succeeded, got %+v", c.lpath, err)
+			}
+			if !c.ok && err == nil {
+				t.Errorf("Put of %s should have failed", c.lpath)
+			}
+		}
+	})
+}
+
+func TestPreCommitHooks(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		var seen []string
+		session, _ := driver.driver.Open(objectID, ocfl.Options{
+			Create:  true,
+			Version: ocfl.NEW,
+			PreCommit: []ocfl.PreCommitHook{
+				func(inv *metadata.Inventory) error {
+					seen = append(seen, inv.Head)
+					v := inv.Versions[inv.Head]
+					v.Message = "enriched"
+					inv.Versions[inv.Head] = v
+					return nil
+				},
+			},
+		})
+		_ = session.Put("file", strings.NewReader("content"))
+		err := session.Commit(ocfl.CommitInfo{})
+		if err != nil {
+			t.Fatalf("commit failed %+v", err)
+		}
+
+		if len(seen) != 1 || seen[0] != "v1" {
+			t.Errorf("hook was not invoked as expected: %s", seen)
+		}
+
+		var obj ocfl.EntityRef
+		driver.Walk(ocfl.Select{Type: ocfl.Object}, func(ref ocfl.EntityRef) error {
+			obj = ref
+			return nil
+		}, objectID)
+
+		inv, _ := fs.ReadInventory(obj.Addr)
+		if inv.Versions["v1"].Message != "enriched" {
+			t.Errorf("hook modifications were not committed")
+		}
+
+		// Now a hook that rejects the commit
+		session, _ = driver.driver.Open(objectID, ocfl.Options{
+			Version: ocfl.NEW,
+			PreCommit: []ocfl.PreCommitHook{
+				func(inv *metadata.Inventory) error {
+					return fmt.Errorf("rejected")
+				},
+			},
+		})
+		_ = session.Put("file2", strings.NewReader("content2"))
+		err = session.Commit(ocfl.CommitInfo{})
+		if err == nil {
+			t.Fatalf("commit should have been rejected")
+		}
+
+		inv, _ = fs.ReadInventory(obj.Addr)
+		if inv.Head != "v1" {
+			t.Errorf("rejected commit should not have been written, head is %s", inv.Head)
+		}
+	})
+}
+
+func TestEmptyVersions(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		d := driver.driver.(*fs.Driver)
+
+		// Empty object
+		err := d.CreateEmptyVersion(objectID, ocfl.CommitInfo{})
+		if err != nil {
+			t.Fatalf("could not create empty object %+v", err)
+		}
+
+		// Add a file in v2, then an empty v3
+		session := driver.Open(objectID, ocfl.Options{Version: ocfl.NEW})
+		session.Put("file", strings.NewReader("content"))
+		session.Commit(ocfl.CommitInfo{})
+
+		err = d.CreateEmptyVersion(objectID, ocfl.CommitInfo{})
+		if err != nil {
+			t.Fatalf("could not create empty version %+v", err)
+		}
+
+		var obj ocfl.EntityRef
+		driver.Walk(ocfl.Select{Type: ocfl.Object}, func(ref ocfl.EntityRef) error {
+			obj = ref
+			return nil
+		}, objectID)
+
+		inv, err := fs.ReadInventory(obj.Addr)
+		if err != nil {
+			t.Fatalf("could not read inventory %+v", err)
+		}
+
+		if err = inv.Validate(); err != nil {
+			t.Errorf("inventory with empty versions should be valid: %+v", err)
+		}
+
+		for v, files := range map[string]int{"v1": 0, "v2": 1, "v3": 0} {
+			if len(inv.Versions[v].State) != files {
+				t.Errorf("expected %d files in %s, got %d", files, v, len(inv.Versions[v].State))
+			}
+
+			_, err := os.Stat(filepath.Join(obj.Addr, v, "content"))
+			if (files == 0) != os.IsNotExist(err) {
+				t.Errorf("content directory of %s should exist only if it has content", v)
+			}
+		}
+	})
+}
+
+func TestValidateOnCommit(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		session := driver.Open(objectID, ocfl.Options{
+			Create:   true,
+			Version:  ocfl.NEW,
+			Validate: true,
+		})
+		session.Put("file", strings.NewReader("content"))
+		session.Commit(ocfl.CommitInfo{})
+
+		// Remove content behind the session's back
+		s, err := driver.driver.Open(objectID, ocfl.Options{
+			Version:  ocfl.NEW,
+			Validate: true,
+		})
+		if err != nil {
+			t.Fatalf("could not open session %+v", err)
+		}
+		_ = s.Put("file2", strings.NewReader("content2"))
+		_, absolute, _ := s.(fs.PathPredictor).PhysicalPath("file2")
+		_ = os.Remove(absolute)
+
+		err = s.Commit(ocfl.CommitInfo{})
+		if err == nil {
+			t.Errorf("commit should have been refused")
+		}
+	})
+}
+
+func TestVersionPadding(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		_ = fs.MkRoot(ocflRoot)
+
+		if _, err := fs.NewDriver(fs.Config{Root: ocflRoot, VersionPadding: 1}); err == nil {
+			t.Errorf("padding to a single digit should not be allowed")
+		}
+
+		driver, err := fs.NewDriver(fs.Config{
+			Root:           ocflRoot,
+			ObjectPaths:    fspath.GeneratorFunc(url.QueryEscape),
+			FilePaths:      fspath.GeneratorFunc(fs.Passthrough),
+			VersionPadding: 2,
+		})
+		if err != nil {
+			t.Fatalf("Error setting up driver %+v", err)
+		}
+
+		for i := 1; i <= 9; i++ {
+			s, err := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+			if err != nil {
+				t.Fatalf("could not open version %d: %+v", i, err)
+			}
+			_ = s.Put("file", strings.NewReader(fmt.Sprint(i)))
+			if err = s.Commit(ocfl.CommitInfo{}); err != nil {
+				t.Fatalf("could not commit version %d: %+v", i, err)
+			}
+		}
+
+		path, _ := driver.ObjectPath(objectID)
+		inv, _ := fs.ReadInventory(path)
+		if inv.Head != "v09" {
+			t.Errorf("expected head v09, got %s", inv.Head)
+		}
+
+		if _, err := driver.Open(objectID, ocfl.Options{Version: ocfl.NEW}); err == nil {
+			t.Errorf("should not be able to create a version beyond the padding")
+		}
+	})
+}
+
+func TestCommitCleanupOnFailure(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		session := driver.Open(objectID, ocfl.Options{
+			Create:  true,
+			Version: ocfl.NEW,
+		})
+		session.Put("file1", strings.NewReader("one"))
+		session.Commit(ocfl.CommitInfo{})
+
+		var obj ocfl.EntityRef
+		driver.Walk(ocfl.Select{Type: ocfl.Object}, func(ref ocfl.EntityRef) error {
+			obj = ref
+			return nil
+		}, objectID)
+
+		// Sabotage promotion of the root inventory sidecar, by making the temp file
+		// used for atomic writes impossible to create.
+		sabotage := filepath.Join(obj.Addr, fs.AtomicPrefix+"inventory.json.sha512")
+		_ = os.MkdirAll(filepath.Join(sabotage, "blocker"), 0775)
+
+		session = driver.Open(objectID, ocfl.Options{Version: ocfl.NEW})
+		session.Put("file2", strings.NewReader("two"))
+		err := session.session.Commit(ocfl.CommitInfo{})
+		if err == nil {
+			t.Fatalf("commit should have failed")
+		}
+
+		if _, err := os.Stat(filepath.Join(obj.Addr, "v2")); !os.IsNotExist(err) {
+			t.Errorf("partially committed version directory should have been removed")
+		}
+
+		inv, err := fs.ReadInventory(obj.Addr)
+		if err != nil {
+			t.Fatalf("could not read root inventory %+v", err)
+		}
+		if inv.Head != "v1" {
+			t.Errorf("root inventory should still be at v1, but is at %s", inv.Head)
+		}
+
+		// Once the obstacle is removed, committing a new version should work
+		_ = os.RemoveAll(sabotage)
+		session = driver.Open(objectID, ocfl.Options{Version: ocfl.NEW})
+		session.Put("file2", strings.NewReader("two"))
+		session.Commit(ocfl.CommitInfo{})
+	})
+}
+
+func TestDedup(t *testing.T) {
+	for _, dedup := range []bool{false, true} {
+		dedup := dedup
+		t.Run(fmt.Sprintf("dedup=%t", dedup), func(t *testing.T) {
+			runInTempDir(t, func(ocflRoot string) {
+				_ = fs.MkRoot(ocflRoot)
+
+				d, _ := fs.NewDriver(fs.Config{
+					Root:        ocflRoot,
+					ObjectPaths: fspath.GeneratorFunc(url.QueryEscape),
+					FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
+					Dedup:       dedup,
+				})
+				driver := driverWrapper{driver: d, t: t, root: ocflRoot}
+
+				session := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+				session.Put("file1", strings.NewReader("same"))
+				session.Commit(ocfl.CommitInfo{})
+
+				session = driver.Open(objectID, ocfl.Options{Version: ocfl.NEW})
+				session.Put("file2", strings.NewReader("same"))
+				session.Commit(ocfl.CommitInfo{})
+
+				var obj ocfl.EntityRef
+				driver.Walk(ocfl.Select{Type: ocfl.Object}, func(ref ocfl.EntityRef) error {
+					obj = ref
+					return nil
+				}, objectID)
+
+				inv, _ := fs.ReadInventory(obj.Addr)
+				if len(inv.Manifest) != 1 {
+					t.Fatalf("expected one digest in manifest, got %d", len(inv.Manifest))
+				}
+
+				for _, paths := range inv.Manifest {
+					if !dedup && len(paths) != 2 {
+						t.Errorf("expected content to be materialized in each version, got %s", paths)
+					}
+					if dedup && len(paths) != 1 {
+						t.Errorf("expected content to be deduplicated, got %s", paths)
+					}
+				}
+
+				_, err := os.Stat(filepath.Join(obj.Addr, "v2", "content", "file2"))
+				if dedup != os.IsNotExist(err) {
+					t.Errorf("v2 content presence is incorrect, dedup is %t", dedup)
+				}
+
+				files, _ := inv.Files("v2")
+				if len(files) != 2 {
+					t.Errorf("expected two files in v2, got %d", len(files))
+				}
+			})
+		})
+	}
+}
+
+func TestUnchangedVersions(t *testing.T) {
+	cases := []struct {
+		name     string
+		policy   ocfl.UnchangedPolicy
+		head     string
+		isErr    bool
+		v2Exists bool
+	}{
+		{"commit", ocfl.CommitUnchanged, "v2", false, true},
+		{"refuse", ocfl.RefuseUnchanged, "v1", true, true},
+		{"discard", ocfl.DiscardUnchanged, "v1", false, false},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			runWithDriverWrapper(t, func(driver driverWrapper) {
+				session := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+				session.Put("file1", strings.NewReader("one"))
+				session.Put("file2", strings.NewReader("two"))
+				session.Commit(ocfl.CommitInfo{})
+
+				// Change, then change back
+				session = driver.Open(objectID, ocfl.Options{Version: ocfl.NEW, Unchanged: c.policy})
+				session.Delete("file2")
+				session.Put("file2", strings.NewReader("two"))
+				err := session.session.Commit(ocfl.CommitInfo{})
+				if (err != nil) != c.isErr {
+					t.Fatalf("expected error: %t, got %+v", c.isErr, err)
+				}
+				if c.isErr && errors.Cause(err) != ocfl.ErrUnchanged {
+					t.Errorf("expected ErrUnchanged, got %+v", err)
+				}
+
+				var obj ocfl.EntityRef
+				driver.Walk(ocfl.Select{Type: ocfl.Object}, func(ref ocfl.EntityRef) error {
+					obj = ref
+					return nil
+				}, objectID)
+
+				inv, _ := fs.ReadInventory(obj.Addr)
+				if inv.Head != c.head {
+					t.Errorf("expected head %s, got %s", c.head, inv.Head)
+				}
+
+				_, err = os.Stat(filepath.Join(obj.Addr, "v2"))
+				if c.v2Exists == os.IsNotExist(err) {
+					t.Errorf("v2 directory existence should be %t", c.v2Exists)
+				}
+			})
+		})
+	}
+}
+
 type driverWrapper struct {
 	driver ocfl.Driver
 	t      *testing.T
@@ -297,6 +1242,13 @@ func (s sessionWrapper) Delete(path string) {
 	}
 }
 
+func (s sessionWrapper) Move(src, dest string) {
+	err := s.session.Move(src, dest)
+	if err != nil {
+		s.t.Fatalf("Error moving content: %+v", err)
+	}
+}
+
 func (s sessionWrapper) Commit(c ocfl.CommitInfo) {
 	err := s.session.Commit(c)
 	if err != nil {
@@ -305,6 +1257,11 @@ func (s sessionWrapper) Commit(c ocfl.CommitInfo) {
 }
 
 func runWithDriverWrapper(t *testing.T, f func(driverWrapper)) {
+	runWithDriverConfig(t, func(*fs.Config) {}, f)
+}
+
+// Like runWithDriverWrapper, but the driver's configuration may be customized
+func runWithDriverConfig(t *testing.T, configure func(*fs.Config), f func(driverWrapper)) {
 	runInTempDir(t, func(ocflRoot string) {
 
 		err := fs.MkRoot(ocflRoot)
@@ -312,11 +1269,13 @@ func runWithDriverWrapper(t *testing.T, f func(driverWrapper)) {
 			t.Fatalf("could not initialize ocfl root %+v", err)
 		}
 
-		driver, err := fs.NewDriver(fs.Config{
+		cfg := fs.Config{
 			Root:        ocflRoot,
 			ObjectPaths: fspath.GeneratorFunc(url.QueryEscape),
 			FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
-		})
+		}
+		configure(&cfg)
+		driver, err := fs.NewDriver(cfg)
 		if err != nil {
 			t.Fatalf("Error setting up driver %+v", err)
 		}
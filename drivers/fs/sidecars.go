@@ -0,0 +1,98 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/birkland/ocfl/metadata"
+)
+
+// SidecarOptions configure checks of inventory sidecars
+type SidecarOptions struct {
+	Versions bool // Also check the inventory of each version, not only that of the object root
+	Workers  int  // Number of objects checked concurrently; defaults to the number of CPUs
+}
+
+// SidecarReport is the result of checking the inventory sidecars of an object
+type SidecarReport struct {
+	ID       string                     `json:"id,omitempty"` // ID of the object, if it could be determined
+	Path     string                     `json:"path"`         // Path of the object root
+	Checked  int                        `json:"checked"`      // Number of inventories checked
+	Failures metadata.ValidationResults `json:"failures,omitempty"`
+}
+
+// Passed indicates whether every inventory checked matched its sidecar digest
+func (r *SidecarReport) Passed() bool {
+	return !r.Failures.HasErrors()
+}
+
+// CheckSidecars verifies that the inventories of the objects with the given IDs, or every
+// object in the OCFL root if none are given, match the digests in their sidecars.  The
+// callback is invoked with the report of each object as soon as it is checked, in no
+// particular order.
+//
+// This is a quick check that inventories are intact.  Unlike validation, no content is
+// read, and the inventories of versions are only checked if requested.
+func (d *Driver) CheckSidecars(ctx context.Context, opts SidecarOptions, cb func(*SidecarReport) error, ids ...string) error {
+	if d.root == nil {
+		return fmt.Errorf("cannot check sidecars: please define an OCFL root")
+	}
+
+	produce := d.sendObjectRoots
+	if len(ids) > 0 {
+		produce = d.sendObjects(ids)
+	}
+
+	return visitObjects(ctx, opts.Workers, produce, func(ctx context.Context, objRoot string) (*SidecarReport, bool, error) {
+		report, err := checkSidecars(ctx, objRoot, opts)
+		return report, err == nil, err
+	}, cb)
+}
+
+func checkSidecars(ctx context.Context, objRoot string, opts SidecarOptions) (*SidecarReport, error) {
+	report := &ValidationReport{Path: objRoot, Results: metadata.ValidationResults{}}
+	sidecars := &SidecarReport{Path: objRoot}
+
+	inv, err := ReadInventory(objRoot)
+	if err != nil {
+		report.errorf(readErrorCode(err), metadata.InventoryFile, "could not read inventory: %s", err)
+		sidecars.Failures = report.Results
+		return sidecars, nil
+	}
+	report.ID, sidecars.ID = inv.ID, inv.ID
+
+	validateSidecar(report, objRoot, "", inv.DigestAlgorithm)
+	sidecars.Checked++
+
+	var versions []string
+	if opts.Versions {
+		versions = inv.VersionNames()
+	}
+
+	for _, v := range versions {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// Inventories of versions are optional, and prior versions may have used other
+		// digest algorithms, which are found from the names of their sidecars
+		if _, err := os.Stat(filepath.Join(objRoot, v, metadata.InventoryFile)); err != nil {
+			continue
+		}
+		names, _ := filepath.Glob(filepath.Join(objRoot, v, metadata.InventoryFile+".*"))
+		if len(names) != 1 {
+			report.errorf(metadata.E058, v+"/"+metadata.InventoryFile, "expected one inventory sidecar, found %d", len(names))
+			continue
+		}
+
+		alg := metadata.DigestAlgorithm(strings.TrimPrefix(filepath.Ext(names[0]), "."))
+		validateSidecar(report, objRoot, v, alg)
+		sidecars.Checked++
+	}
+
+	sidecars.Failures = report.Results
+	return sidecars, nil
+}
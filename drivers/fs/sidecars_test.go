@@ -0,0 +1,71 @@
+package fs_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/metadata"
+)
+
+func TestCheckSidecars(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		for _, id := range []string{"urn:test/a", "urn:test/b"} {
+			for _, name := range []string{"a.txt", "b.txt"} {
+				s := driver.Open(id, ocfl.Options{Create: true, Version: ocfl.NEW})
+				s.Put(name, strings.NewReader("hello "+name))
+				s.Commit(ocfl.CommitInfo{})
+			}
+		}
+
+		d := driver.driver.(*fs.Driver)
+		path, _ := d.ObjectPath("urn:test/b")
+		_ = ioutil.WriteFile(filepath.Join(path, "v1", "inventory.json.sha512"), []byte("abc123  inventory.json\n"), 0644)
+
+		check := func(opts fs.SidecarOptions, ids ...string) map[string]*fs.SidecarReport {
+			reports := make(map[string]*fs.SidecarReport)
+			err := d.CheckSidecars(context.Background(), opts, func(r *fs.SidecarReport) error {
+				reports[r.ID] = r
+				return nil
+			}, ids...)
+			if err != nil {
+				t.Fatalf("sidecar check failed %+v", err)
+			}
+			return reports
+		}
+
+		// Only the inventories of object roots are checked by default
+		reports := check(fs.SidecarOptions{})
+		if len(reports) != 2 || !reports["urn:test/a"].Passed() || !reports["urn:test/b"].Passed() {
+			t.Fatalf("Wrong sidecar results %+v", reports)
+		}
+		if reports["urn:test/a"].Checked != 1 {
+			t.Errorf("Expected 1 inventory checked, got %d", reports["urn:test/a"].Checked)
+		}
+
+		reports = check(fs.SidecarOptions{Versions: true})
+		if !reports["urn:test/a"].Passed() || reports["urn:test/a"].Checked != 3 {
+			t.Errorf("Expected 3 intact inventories in urn:test/a, got %+v", reports["urn:test/a"])
+		}
+		failures := reports["urn:test/b"].Failures
+		if len(failures) != 1 || failures[0].Code != metadata.E060 || failures[0].Path != "v1/inventory.json.sha512" {
+			t.Errorf("Expected a sidecar failure, got %v", failures)
+		}
+
+		// A changed root inventory no longer matches its sidecar
+		path, _ = d.ObjectPath("urn:test/a")
+		f, _ := os.OpenFile(filepath.Join(path, "inventory.json"), os.O_APPEND|os.O_WRONLY, 0644)
+		_, _ = f.WriteString("\n")
+		f.Close()
+
+		reports = check(fs.SidecarOptions{}, "urn:test/a")
+		if r := reports["urn:test/a"]; len(reports) != 1 || r.Passed() || r.Failures[0].Code != metadata.E060 {
+			t.Errorf("Expected the root inventory to fail, got %+v", reports)
+		}
+	})
+}
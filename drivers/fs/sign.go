@@ -0,0 +1,93 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/birkland/ocfl/signature"
+	"github.com/pkg/errors"
+)
+
+// SignatureDir is the directory, relative to an object root, containing detached
+// signatures of version inventories
+const SignatureDir = "logs/signatures"
+
+// Signs the inventory in the given version directory, writing the signature to the
+// object's signature directory
+func signInventory(signer signature.Signer, objRoot, version string) error {
+	content, err := ioutil.ReadFile(filepath.Join(objRoot, version, metadata.InventoryFile))
+	if err != nil {
+		return errors.Wrapf(err, "could not read inventory of %s to sign", version)
+	}
+
+	sig, err := signer.Sign(content)
+	if err != nil {
+		return errors.Wrapf(err, "could not sign inventory of %s", version)
+	}
+
+	dir := filepath.Join(objRoot, filepath.FromSlash(SignatureDir))
+	err = os.MkdirAll(dir, dirPermission)
+	if err != nil {
+		return errors.Wrapf(err, "could not create signature directory %s", dir)
+	}
+
+	w, err := AtomicWrite(signaturePath(objRoot, version))
+	if err != nil {
+		return err
+	}
+
+	if err = json.NewEncoder(w).Encode(sig); err != nil {
+		_ = w.Rollback()
+		return errors.Wrapf(err, "could not write signature of %s", version)
+	}
+
+	return w.Close()
+}
+
+func signaturePath(objRoot, version string) string {
+	return filepath.Join(objRoot, filepath.FromSlash(SignatureDir), version+"."+metadata.InventoryFile+".sig")
+}
+
+// VerifySignature verifies the detached signature of the inventory of the given version of an
+// object (or its head, if the version is ocfl.HEAD).  Returns an error if the version is not signed,
+// or its signature is invalid.
+func (d *Driver) VerifySignature(id, version string, verifier signature.Verifier) error {
+	obj, inv, err := d.readObject(context.Background(), id)
+	if err != nil {
+		return errors.Wrapf(err, "could not read object %s", id)
+	}
+	if obj == nil {
+		return errors.Wrap(ocfl.ErrNotFound, id)
+	}
+
+	if version == ocfl.HEAD {
+		version = inv.Head
+	}
+	if _, ok := inv.Versions[version]; !ok {
+		return fmt.Errorf("no version %s present in %s", version, id)
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(obj.Addr, version, metadata.InventoryFile))
+	if err != nil {
+		return errors.Wrapf(err, "could not read inventory of %s %s", id, version)
+	}
+
+	sigContent, err := ioutil.ReadFile(signaturePath(obj.Addr, version))
+	if err != nil {
+		return errors.Wrapf(err, "could not read signature of %s %s", id, version)
+	}
+
+	var sig signature.Signature
+	err = json.Unmarshal(sigContent, &sig)
+	if err != nil {
+		return errors.Wrapf(err, "could not parse signature of %s %s", id, version)
+	}
+
+	return errors.Wrapf(verifier.Verify(content, sig), "verification of %s %s failed", id, version)
+}
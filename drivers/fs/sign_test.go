@@ -0,0 +1,54 @@
+package fs_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/fspath"
+	"github.com/birkland/ocfl/signature"
+)
+
+func TestSignedCommits(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		_ = fs.MkRoot(ocflRoot)
+
+		pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+		otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+
+		d, _ := fs.NewDriver(fs.Config{
+			Root:        ocflRoot,
+			ObjectPaths: fspath.GeneratorFunc(url.QueryEscape),
+			FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
+			Signer:      signature.Ed25519Signer{Key: priv},
+		})
+		driver := driverWrapper{driver: d, t: t, root: ocflRoot}
+
+		for _, content := range []string{"one", "two"} {
+			session := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+			session.Put(content, strings.NewReader(content))
+			session.Commit(ocfl.CommitInfo{})
+		}
+
+		for _, v := range []string{"v1", "v2", ocfl.HEAD} {
+			if err := d.VerifySignature(objectID, v, signature.Ed25519Verifier{Key: pub}); err != nil {
+				t.Errorf("signature of %s should be valid %+v", v, err)
+			}
+		}
+
+		if err := d.VerifySignature(objectID, "v1", signature.Ed25519Verifier{Key: otherPub}); err == nil {
+			t.Errorf("signature should not verify with the wrong key")
+		}
+
+		if err := d.Rollback(objectID); err != nil {
+			t.Fatalf("rollback failed %+v", err)
+		}
+		if err := d.VerifySignature(objectID, "v2", signature.Ed25519Verifier{Key: pub}); err == nil {
+			t.Errorf("rolled back version should not verify")
+		}
+	})
+}
@@ -0,0 +1,349 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+)
+
+// Squash consolidates a range of versions of an object, from and to inclusive, into
+// a single version.  The squashed version has the state, user, and creation date of the
+// last version in the range, and the messages of all versions in the range.
+// Subsequent versions are renumbered to close the gap, and content that is no
+// longer referenced by any version is removed.
+//
+// The new versions are fully staged within the object directory before the old ones are
+// swapped out, but the swap itself is not atomic.  If the driver locks objects, the
+// object's lock is held throughout.  Signatures of the squashed and renumbered versions
+// no longer match their inventories, so they are removed, and written anew if the
+// driver has a Signer.
+func (d *Driver) Squash(id, from, to string) (err error) {
+	unlock, err := d.lockObject(id)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := unlock(); err == nil {
+			err = e
+		}
+	}()
+
+	obj, inv, err := d.readObject(context.Background(), id)
+	if err != nil {
+		return errors.Wrapf(err, "could not read object %s", id)
+	}
+	if obj == nil {
+		return errors.Wrap(ocfl.ErrNotFound, id)
+	}
+
+	squashed, affected, err := squashInventory(inv, from, to)
+	if err != nil {
+		return errors.Wrapf(err, "could not squash %s", id)
+	}
+
+	staging := filepath.Join(obj.Addr, AtomicPrefix+"squash")
+	trash := filepath.Join(obj.Addr, AtomicPrefix+"squash.old")
+	for _, dir := range []string{staging, trash} {
+		if _, err := os.Stat(dir); err == nil {
+			return fmt.Errorf("%s exists, possibly from a failed squash; refusing to squash %s", dir, id)
+		}
+	}
+
+	// Stage the new versions.  Content is hard linked (or copied) so the original versions stay intact
+	err = stageSquash(obj.Addr, staging, inv, squashed, affected)
+	if err != nil {
+		_ = os.RemoveAll(staging)
+		return errors.Wrapf(err, "could not stage squashed versions of %s", id)
+	}
+
+	// Swap out the old versions for the new ones, then write the root inventory
+	err = os.MkdirAll(trash, dirPermission)
+	if err != nil {
+		return errors.Wrapf(err, "could not create directory %s", trash)
+	}
+
+	for old := range affected {
+		err = os.Rename(filepath.Join(obj.Addr, old), filepath.Join(trash, old))
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "could not remove old version %s of %s", old, id)
+		}
+	}
+
+	for _, v := range squashed.VersionNames() {
+		if _, err := os.Stat(filepath.Join(staging, v)); err == nil {
+			err = os.Rename(filepath.Join(staging, v), filepath.Join(obj.Addr, v))
+			if err != nil {
+				return errors.Wrapf(err, "could not move squashed version %s of %s into place", v, id)
+			}
+		}
+	}
+
+	for old := range affected {
+		if err = os.Remove(signaturePath(obj.Addr, old)); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "could not remove signature of old version %s of %s", old, id)
+		}
+	}
+
+	err = d.cfg.Faults.copyInventoryFiles(filepath.Join(obj.Addr, squashed.Head), obj.Addr)
+	d.invalidate(obj.Addr)
+	if err != nil {
+		return errors.Wrapf(err, "could not write inventory of squashed %s", id)
+	}
+
+	if d.cfg.Signer != nil {
+		for _, v := range affected {
+			if err = signInventory(d.cfg.Signer, obj.Addr, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err = os.RemoveAll(trash); err == nil {
+		err = os.RemoveAll(staging)
+	}
+	return errors.Wrapf(err, "could not clean up after squashing %s", id)
+}
+
+// Computes the squashed inventory, and a mapping of affected (i.e. squashed or renumbered)
+// versions to their new names
+func squashInventory(inv *metadata.Inventory, from, to string) (*metadata.Inventory, map[string]string, error) {
+	fromN, errFrom := metadata.VersionID(from).Int()
+	toN, errTo := metadata.VersionID(to).Int()
+	_, fromOK := inv.Versions[from]
+	_, toOK := inv.Versions[to]
+
+	if errFrom != nil || errTo != nil || !fromOK || !toOK || fromN >= toN {
+		return nil, nil, fmt.Errorf("invalid version range %s to %s", from, to)
+	}
+
+	head := metadata.VersionID(inv.Head)
+	affected := make(map[string]string)
+	var messages []string
+
+	squashed := &metadata.Inventory{
+		ID:              inv.ID,
+		Type:            inv.Type,
+		DigestAlgorithm: inv.DigestAlgorithm,
+		ContentDir:      inv.ContentDir,
+		Versions:        make(map[string]metadata.Version, len(inv.Versions)),
+		Manifest:        make(metadata.Manifest, len(inv.Manifest)),
+	}
+
+	for _, name := range inv.VersionNames() {
+		n, _ := metadata.VersionID(name).Int()
+		v := inv.Versions[name]
+
+		switch {
+		case n < fromN:
+			squashed.Versions[name] = v
+			continue
+		case n <= toN:
+			if v.Message != "" {
+				messages = append(messages, v.Message)
+			}
+			affected[name] = string(head.Renumber(fromN))
+			if n < toN {
+				continue
+			}
+			v.Message = strings.Join(messages, "\n")
+		default:
+			affected[name] = string(head.Renumber(n - (toN - fromN)))
+		}
+
+		squashed.Versions[affected[name]] = v
+	}
+
+	squashed.Head = affected[inv.Head]
+	if squashed.Head == "" {
+		squashed.Head = inv.Head
+	}
+
+	referenced := make(map[metadata.Digest]bool)
+	for _, v := range squashed.Versions {
+		for digest := range v.State {
+			referenced[digest] = true
+		}
+	}
+
+	relocated := make(map[string]string)
+	for digest, paths := range inv.Manifest {
+		if !referenced[digest] {
+			continue
+		}
+
+		for _, p := range paths {
+			np := relocate(p, affected)
+			if _, conflict := relocated[np]; conflict {
+				return nil, nil, fmt.Errorf("squashing would place different content at the same path %s", np)
+			}
+			relocated[np] = p
+			squashed.Manifest[digest] = append(squashed.Manifest[digest], np)
+		}
+	}
+
+	for alg, manifest := range inv.Fixity {
+		for digest, paths := range manifest {
+			for _, p := range paths {
+				if np := relocate(p, affected); relocated[np] == p {
+					if squashed.Fixity == nil {
+						squashed.Fixity = make(metadata.Fixity)
+					}
+					if squashed.Fixity[alg] == nil {
+						squashed.Fixity[alg] = make(metadata.Manifest)
+					}
+					squashed.Fixity[alg][digest] = append(squashed.Fixity[alg][digest], np)
+				}
+			}
+		}
+	}
+
+	return squashed, affected, nil
+}
+
+// Relocates an object-relative path if its version has been renamed
+func relocate(path string, renamed map[string]string) string {
+	parts := strings.SplitN(path, "/", 2)
+	if newName, ok := renamed[parts[0]]; ok && len(parts) == 2 {
+		return newName + "/" + parts[1]
+	}
+	return path
+}
+
+// Stages the content and inventories of all affected versions.  Every path the squashed
+// manifest keeps within an affected version is staged, even if its name is unchanged, as
+// the directories of all affected versions are replaced.
+func stageSquash(objRoot, staging string, inv, squashed *metadata.Inventory, affected map[string]string) error {
+	staged := make(map[string]bool)
+
+	kept := make(map[string]bool)
+	for _, paths := range squashed.Manifest {
+		for _, p := range paths {
+			kept[p] = true
+		}
+	}
+
+	for _, paths := range inv.Manifest {
+		for _, p := range paths {
+			np := relocate(p, affected)
+			if _, moved := affected[strings.SplitN(p, "/", 2)[0]]; !moved || !kept[np] {
+				continue
+			}
+
+			dest := filepath.Join(staging, filepath.FromSlash(np))
+			err := linkOrCopy(filepath.Join(objRoot, filepath.FromSlash(p)), dest)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, newName := range affected {
+		if staged[newName] {
+			continue
+		}
+		staged[newName] = true
+
+		dir := filepath.Join(staging, newName)
+		if err := os.MkdirAll(dir, dirPermission); err != nil {
+			return err
+		}
+
+		if err := writeInventory(inventoryAt(squashed, newName), dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Produces the inventory of an object as it was at the given version,
+// i.e. without any subsequent versions or content.
+func inventoryAt(inv *metadata.Inventory, version string) *metadata.Inventory {
+	at := *inv
+	at.Head = version
+	at.Versions = make(map[string]metadata.Version)
+	at.Manifest = make(metadata.Manifest)
+	at.Fixity = nil
+
+	before := func(path string) bool {
+		pv := metadata.VersionID(strings.SplitN(path, "/", 2)[0])
+		return !metadata.VersionID(version).Less(pv)
+	}
+
+	for name, v := range inv.Versions {
+		if before(name) {
+			at.Versions[name] = v
+		}
+	}
+
+	for digest, paths := range inv.Manifest {
+		for _, p := range paths {
+			if before(p) {
+				at.Manifest[digest] = append(at.Manifest[digest], p)
+			}
+		}
+	}
+
+	for alg, manifest := range inv.Fixity {
+		for digest, paths := range manifest {
+			for _, p := range paths {
+				if !before(p) {
+					continue
+				}
+				if at.Fixity == nil {
+					at.Fixity = make(metadata.Fixity)
+				}
+				if at.Fixity[alg] == nil {
+					at.Fixity[alg] = make(metadata.Manifest)
+				}
+				at.Fixity[alg][digest] = append(at.Fixity[alg][digest], p)
+			}
+		}
+	}
+
+	return &at
+}
+
+// Hard links a file to a destination, or copies it if linking is not possible
+func linkOrCopy(src, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), dirPermission); err != nil {
+		return errors.Wrapf(err, "could not create directory for %s", dest)
+	}
+
+	if err := os.Link(src, dest); err == nil {
+		return nil
+	}
+
+	return copyFile(src, dest)
+}
+
+// Copies a file to a destination, which must not exist
+func copyFile(src, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), dirPermission); err != nil {
+		return errors.Wrapf(err, "could not create directory for %s", dest)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return errors.Wrapf(err, "could not open %s", src)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, filePermission)
+	if err != nil {
+		return errors.Wrapf(err, "could not create %s", dest)
+	}
+
+	_, err = io.Copy(out, in)
+	if e := out.Close(); err == nil {
+		err = e
+	}
+	return errors.Wrapf(err, "could not copy %s to %s", src, dest)
+}
@@ -0,0 +1,251 @@
+package fs_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/fspath"
+	"github.com/birkland/ocfl/signature"
+)
+
+func TestSquash(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		commit := func(msg string, f func(s sessionWrapper)) {
+			session := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+			f(session)
+			session.Commit(ocfl.CommitInfo{Message: msg})
+		}
+
+		commit("one", func(s sessionWrapper) { s.Put("a", strings.NewReader("a")) })
+		commit("two", func(s sessionWrapper) { s.Put("b", strings.NewReader("b")) })
+		commit("three", func(s sessionWrapper) {
+			s.Put("c", strings.NewReader("c"))
+			s.Delete("b")
+		})
+		commit("four", func(s sessionWrapper) { s.Put("d", strings.NewReader("d")) })
+
+		d := driver.driver.(*fs.Driver)
+		if err := d.Squash(objectID, "v2", "v3"); err != nil {
+			t.Fatalf("squash failed: %+v", err)
+		}
+
+		var obj ocfl.EntityRef
+		driver.Walk(ocfl.Select{Type: ocfl.Object}, func(ref ocfl.EntityRef) error {
+			obj = ref
+			return nil
+		}, objectID)
+
+		inv, err := fs.ReadInventory(obj.Addr)
+		if err != nil {
+			t.Fatalf("could not read inventory %+v", err)
+		}
+
+		if inv.Head != "v3" || len(inv.Versions) != 3 {
+			t.Fatalf("expected 3 versions with head v3, got %d with head %s", len(inv.Versions), inv.Head)
+		}
+
+		if inv.Versions["v2"].Message != "two\nthree" {
+			t.Errorf("unexpected squashed message %q", inv.Versions["v2"].Message)
+		}
+
+		if err := inv.Validate(); err != nil {
+			t.Errorf("squashed inventory is invalid %+v", err)
+		}
+
+		expected := map[string][]string{
+			"v1": {"a"},
+			"v2": {"a", "c"},
+			"v3": {"a", "c", "d"},
+		}
+
+		for v, files := range expected {
+			var found []string
+			driver.Walk(ocfl.Select{Type: ocfl.File}, func(ref ocfl.EntityRef) error {
+				content, err := ioutil.ReadFile(ref.Addr)
+				if err != nil || string(content) != ref.ID {
+					t.Errorf("bad content for %s in %s: %s", ref.ID, v, err)
+				}
+				found = append(found, ref.ID)
+				return nil
+			}, objectID, v)
+
+			if len(found) != len(files) {
+				t.Errorf("expected %s in %s, got %s", files, v, found)
+			}
+		}
+
+		for _, gone := range []string{"v4", fs.AtomicPrefix + "squash", fs.AtomicPrefix + "squash.old"} {
+			if _, err := os.Stat(filepath.Join(obj.Addr, gone)); !os.IsNotExist(err) {
+				t.Errorf("%s should not exist", gone)
+			}
+		}
+
+		// Old v2 content is no longer referenced, so it's gone
+		if _, err := os.Stat(filepath.Join(obj.Addr, "v2", "content", "b")); !os.IsNotExist(err) {
+			t.Errorf("unreferenced content should have been removed")
+		}
+
+		if err := d.Squash(objectID, "v3", "v1"); err == nil {
+			t.Errorf("bad range should have failed")
+		}
+	})
+}
+
+// Content already in the first squashed version stays where it is, and must survive
+func TestSquashKeepsFirstVersionContent(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		for _, content := range []string{"a", "b", "c"} {
+			session := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+			session.Put(content, strings.NewReader(content))
+			session.Commit(ocfl.CommitInfo{})
+		}
+
+		d := driver.driver.(*fs.Driver)
+		if err := d.Squash(objectID, "v2", "v3"); err != nil {
+			t.Fatalf("squash failed: %+v", err)
+		}
+
+		var found []string
+		driver.Walk(ocfl.Select{Type: ocfl.File}, func(ref ocfl.EntityRef) error {
+			content, err := ioutil.ReadFile(ref.Addr)
+			if err != nil || string(content) != ref.ID {
+				t.Errorf("bad content for %s: %s", ref.ID, err)
+			}
+			found = append(found, ref.ID)
+			return nil
+		}, objectID, "v2")
+		if len(found) != 3 {
+			t.Errorf("expected a, b, and c in v2, got %s", found)
+		}
+
+		report, err := d.Validate(context.Background(), objectID, fs.ValidateOptions{})
+		if err != nil {
+			t.Fatalf("validation failed %+v", err)
+		}
+		if !report.Valid() {
+			t.Errorf("squashed object should be valid: %v", report.Results)
+		}
+	})
+}
+
+// Objects declaring their own content directory keep it, and their content stays within it
+func TestSquashContentDirectory(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		const id = "ark:123/abc"
+		d := driver.driver.(*fs.Driver)
+		objPath, err := d.ObjectPath(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		copyDir(t, filepath.Join("testdata", "fixtures", "1.0", "good-objects", "minimal_content_dir_called_stuff"), objPath)
+
+		for _, content := range []string{"b", "c"} {
+			session := driver.Open(id, ocfl.Options{Version: ocfl.NEW})
+			session.Put(content, strings.NewReader(content))
+			session.Commit(ocfl.CommitInfo{})
+		}
+
+		if err = d.Squash(id, "v2", "v3"); err != nil {
+			t.Fatalf("squash failed: %+v", err)
+		}
+
+		inv, err := fs.ReadInventory(objPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inv.ContentDir != "stuff" {
+			t.Errorf("expected content directory stuff, got %q", inv.ContentDir)
+		}
+		for _, p := range []string{"v1/stuff/a_file.txt", "v2/stuff/b", "v2/stuff/c"} {
+			if _, err := os.Stat(filepath.Join(objPath, filepath.FromSlash(p))); err != nil {
+				t.Errorf("expected content at %s: %v", p, err)
+			}
+		}
+
+		// Later versions put content in the same directory
+		session := driver.Open(id, ocfl.Options{Version: ocfl.NEW})
+		session.Put("d", strings.NewReader("d"))
+		session.Commit(ocfl.CommitInfo{})
+		if _, err := os.Stat(filepath.Join(objPath, "v3", "stuff", "d")); err != nil {
+			t.Errorf("expected new content in v3/stuff: %v", err)
+		}
+
+		report, err := d.Validate(context.Background(), id, fs.ValidateOptions{})
+		if err != nil {
+			t.Fatalf("validation failed %+v", err)
+		}
+		if !report.Valid() {
+			t.Errorf("squashed object should be valid: %v", report.Results)
+		}
+	})
+}
+
+// Copies the files of a directory tree
+func copyDir(t *testing.T, src, dst string) {
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dst, rel), content, 0644)
+	})
+	if err != nil {
+		t.Fatalf("could not copy %s: %v", src, err)
+	}
+}
+
+func TestSquashSigned(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		_ = fs.MkRoot(ocflRoot)
+
+		pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+		d, _ := fs.NewDriver(fs.Config{
+			Root:        ocflRoot,
+			ObjectPaths: fspath.GeneratorFunc(url.QueryEscape),
+			FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
+			Signer:      signature.Ed25519Signer{Key: priv},
+			Lock:        true,
+		})
+		driver := driverWrapper{driver: d, t: t, root: ocflRoot}
+
+		for _, content := range []string{"a", "b", "c", "d"} {
+			session := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+			session.Put(content, strings.NewReader(content))
+			session.Commit(ocfl.CommitInfo{})
+		}
+
+		if err := d.Squash(objectID, "v2", "v3"); err != nil {
+			t.Fatalf("squash failed: %+v", err)
+		}
+
+		for _, v := range []string{"v1", "v2", "v3"} {
+			if err := d.VerifySignature(objectID, v, signature.Ed25519Verifier{Key: pub}); err != nil {
+				t.Errorf("signature of %s should be valid %+v", v, err)
+			}
+		}
+		if err := d.VerifySignature(objectID, "v4", signature.Ed25519Verifier{Key: pub}); err == nil {
+			t.Errorf("squashed away version should not verify")
+		}
+
+		// The lock was released
+		session := driver.Open(objectID, ocfl.Options{Version: ocfl.NEW})
+		session.Put("e", strings.NewReader("e"))
+		session.Commit(ocfl.CommitInfo{})
+	})
+}
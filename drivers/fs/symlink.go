@@ -0,0 +1,89 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/karrick/godirwalk"
+	"github.com/pkg/errors"
+)
+
+// SymlinkPolicy determines how symbolic links are treated when walking directories
+type SymlinkPolicy int
+
+// Symbolic link policies
+const (
+	FollowSymlinks SymlinkPolicy = iota // Follow symlinks, skipping those that would cause a cycle
+	SkipSymlinks                        // Ignore symlinks entirely
+	RejectSymlinks                      // Fail when a symlink is encountered
+)
+
+var symlinkPolicies = map[string]SymlinkPolicy{
+	"follow": FollowSymlinks,
+	"skip":   SkipSymlinks,
+	"error":  RejectSymlinks,
+}
+
+func (p SymlinkPolicy) String() string {
+	for name, policy := range symlinkPolicies {
+		if policy == p {
+			return name
+		}
+	}
+	return fmt.Sprintf("SymlinkPolicy(%d)", int(p))
+}
+
+// ParseSymlinkPolicy parses a symlink policy from its name: follow, skip, or error
+func ParseSymlinkPolicy(name string) (SymlinkPolicy, error) {
+	policy, ok := symlinkPolicies[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown symlink policy '%s', expecting one of follow, skip, error", name)
+	}
+	return policy, nil
+}
+
+// Determines whether the symlink at the given path is to be followed.  When following
+// links, those that point to an ancestor of themselves (thus causing a cycle), or that
+// are broken, are not followed.
+func (p SymlinkPolicy) follow(path string) (bool, error) {
+	switch p {
+	case SkipSymlinks:
+		return false, nil
+	case RejectSymlinks:
+		return false, fmt.Errorf("encountered symbolic link %s", path)
+	}
+
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false, nil
+	}
+	parent, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return false, errors.Wrapf(err, "could not resolve parent of %s", path)
+	}
+
+	cycle := parent == target || strings.HasPrefix(parent, target+string(filepath.Separator))
+	return !cycle, nil
+}
+
+// WalkFiles walks the directory tree under the given path, invoking the callback
+// with the path of each regular file found.  Symbolic links are handled according
+// to the given policy.
+func WalkFiles(dir string, policy SymlinkPolicy, f func(path string) error) error {
+	return fsWalk(dir, policy, false, func(ospath string, e *godirwalk.Dirent) (bool, error) {
+		if e.IsRegular() {
+			return dontGoDeeper, f(ospath)
+		}
+
+		// Links to regular files are walked as regular files
+		if e.IsSymlink() {
+			if info, err := os.Stat(ospath); err == nil && info.Mode().IsRegular() {
+				return dontGoDeeper, f(ospath)
+			}
+		}
+
+		return goDeeper, nil
+	})
+}
@@ -0,0 +1,105 @@
+package fs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/go-test/deep"
+)
+
+func TestWalkFilesSymlinks(t *testing.T) {
+	runInTempDir(t, func(tempDir string) {
+		tree := filepath.Join(tempDir, "tree")
+		outside := filepath.Join(tempDir, "outside")
+		_ = os.MkdirAll(filepath.Join(tree, "a"), 0755)
+		_ = os.MkdirAll(outside, 0755)
+		_ = ioutil.WriteFile(filepath.Join(tree, "a", "file"), []byte("file"), 0664)
+		_ = ioutil.WriteFile(filepath.Join(outside, "other"), []byte("other"), 0664)
+
+		links := map[string]string{
+			filepath.Join(tree, "a", "loop"):   tree,                          // cycle
+			filepath.Join(tree, "out"):         outside,                       // directory
+			filepath.Join(tree, "filelink"):    filepath.Join(tree, "a/file"), // regular file
+			filepath.Join(tree, "a", "broken"): filepath.Join(tempDir, "DOES_NOT_EXIST"),
+		}
+		for link, target := range links {
+			if err := os.Symlink(target, link); err != nil {
+				t.Skipf("cannot create symlinks: %s", err)
+			}
+		}
+
+		cases := map[fs.SymlinkPolicy][]string{
+			fs.FollowSymlinks: {"a/file", "filelink", "out/other"},
+			fs.SkipSymlinks:   {"a/file"},
+		}
+
+		for policy, expected := range cases {
+			policy, expected := policy, expected
+			t.Run(policy.String(), func(t *testing.T) {
+				var found []string
+				err := fs.WalkFiles(tree, policy, func(path string) error {
+					found = append(found, filepath.ToSlash(strings.TrimPrefix(path, tree+string(filepath.Separator))))
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("walk failed: %+v", err)
+				}
+
+				sort.Strings(found)
+				if diff := deep.Equal(found, expected); diff != nil {
+					t.Error(diff)
+				}
+			})
+		}
+
+		t.Run(fs.RejectSymlinks.String(), func(t *testing.T) {
+			err := fs.WalkFiles(tree, fs.RejectSymlinks, func(string) error { return nil })
+			if err == nil {
+				t.Errorf("walk should have failed upon encountering a symlink")
+			}
+		})
+	})
+}
+
+func TestWalkSymlinkPolicy(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		_ = fs.MkRoot(ocflRoot)
+		if err := os.Symlink(ocflRoot, filepath.Join(ocflRoot, "loop")); err != nil {
+			t.Skipf("cannot create symlinks: %s", err)
+		}
+
+		for policy, shouldFail := range map[fs.SymlinkPolicy]bool{
+			fs.FollowSymlinks: false,
+			fs.SkipSymlinks:   false,
+			fs.RejectSymlinks: true,
+		} {
+			driver, _ := fs.NewDriver(fs.Config{Root: ocflRoot, Symlinks: policy})
+			err := driver.Walk(ocfl.Select{}, func(ocfl.EntityRef) error { return nil })
+			if shouldFail && err == nil {
+				t.Errorf("Walk with policy %s should have failed", policy)
+			}
+			if !shouldFail && err != nil {
+				t.Errorf("Walk with policy %s failed: %+v", policy, err)
+			}
+		}
+	})
+}
+
+func TestParseSymlinkPolicy(t *testing.T) {
+	for _, name := range []string{"follow", "skip", "error"} {
+		policy, err := fs.ParseSymlinkPolicy(name)
+		if err != nil || policy.String() != name {
+			t.Errorf("could not parse %s: %v", name, err)
+		}
+	}
+
+	if _, err := fs.ParseSymlinkPolicy("bogus"); err == nil {
+		t.Errorf("should not have parsed a bogus policy")
+	}
+}
@@ -0,0 +1,102 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+)
+
+// Flushes the given files or directories to stable storage
+func syncPaths(paths ...string) error {
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return errors.Wrapf(err, "could not open %s for sync", path)
+		}
+
+		err = f.Sync()
+		_ = f.Close()
+
+		// Windows does not support syncing directories; their entries are
+		// made durable along with the files themselves
+		if err != nil && runtime.GOOS == "windows" {
+			if info, e := os.Stat(path); e == nil && info.IsDir() {
+				err = nil
+			}
+		}
+		if err != nil {
+			return errors.Wrapf(err, "could not sync %s", path)
+		}
+	}
+
+	return nil
+}
+
+// Flushes all content written in the session's version, along with the
+// directories containing it, so that inventories never reference content
+// that could be lost.
+func (s *session) syncContent() error {
+	var paths []string
+	err := filepath.Walk(s.contentDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "could not list content of %s", s.contentDir)
+	}
+
+	// Files before the directories that contain them
+	for i, j := 0, len(paths)-1; i < j; i, j = i+1, j-1 {
+		paths[i], paths[j] = paths[j], paths[i]
+	}
+
+	return syncPaths(paths...)
+}
+
+// Flushes the version inventory, its sidecar, any inventory signature, and the version
+// and object directories, before the inventory is promoted to the object root.  Otherwise,
+// after a crash, a durable root inventory could declare a version whose own inventory
+// was lost.
+func (s *session) syncVersionInventory() error {
+	var paths []string
+	if s.driver.cfg.Signer != nil {
+		sig := signaturePath(s.version.Parent.Addr, s.version.ID)
+		paths = append(paths, sig, filepath.Dir(sig))
+	}
+	paths = append(paths,
+		filepath.Join(s.version.Addr, metadata.InventoryFile),
+		filepath.Join(s.version.Addr, metadata.InventoryFile+hashSuffix),
+		s.version.Addr,
+		s.version.Parent.Addr)
+
+	return syncPaths(paths...)
+}
+
+// Flushes the promoted object root inventory and its sidecar, and every directory from
+// the object directory up to the OCFL root.
+func (s *session) syncInventories() error {
+	root, err := absPath(s.driver.root.Addr)
+	if err != nil {
+		return errors.Wrapf(err, "could not determine absolute path of %s", s.driver.root.Addr)
+	}
+
+	paths := []string{
+		filepath.Join(s.version.Parent.Addr, metadata.InventoryFile),
+		filepath.Join(s.version.Parent.Addr, metadata.InventoryFile+hashSuffix),
+	}
+
+	for dir := s.version.Parent.Addr; ; dir = filepath.Dir(dir) {
+		paths = append(paths, dir)
+		if dir == root || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	return syncPaths(paths...)
+}
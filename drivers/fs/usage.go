@@ -0,0 +1,114 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+)
+
+// UsageOptions configure reports of storage usage
+type UsageOptions struct {
+	Workers int // Number of objects measured concurrently; defaults to the number of CPUs
+}
+
+// UsageReport describes the storage used by an object.  Logical sizes count every
+// logical path, while content shared by several paths or versions is stored, and
+// counted in the physical size, only once.
+type UsageReport struct {
+	ID       string         `json:"id"`
+	Path     string         `json:"path"`              // Path of the object root
+	Logical  int64          `json:"logical"`           // Size of the logical files of the head version
+	Physical int64          `json:"physical"`          // Size of all content in the manifest
+	Total    int64          `json:"total"`             // Size of every file in the object root, including inventories
+	Missing  int            `json:"missing,omitempty"` // Number of content files in the manifest that are missing
+	Versions []VersionUsage `json:"versions,omitempty"`
+}
+
+// VersionUsage describes the storage used by a version of an object
+type VersionUsage struct {
+	Version string `json:"version"`
+	Files   int    `json:"files"`   // Number of logical files in the version
+	Logical int64  `json:"logical"` // Size of the logical files of the version
+	Added   int64  `json:"added"`   // Size of the content first stored in the version
+}
+
+// Usage measures the storage used by the objects with the given IDs, or every object
+// in the OCFL root if none are given.  The callback is invoked with the report of each
+// object as soon as it is measured, in no particular order.
+//
+// Only inventories are read, and the sizes of content files determined, so no content
+// is read.
+func (d *Driver) Usage(ctx context.Context, opts UsageOptions, cb func(*UsageReport) error, ids ...string) error {
+	if d.root == nil {
+		return fmt.Errorf("cannot measure usage: please define an OCFL root")
+	}
+
+	produce := d.sendObjectRoots
+	if len(ids) > 0 {
+		produce = d.sendObjects(ids)
+	}
+
+	return visitObjects(ctx, opts.Workers, produce, func(ctx context.Context, objRoot string) (*UsageReport, bool, error) {
+		report, err := objectUsage(ctx, objRoot)
+		return report, err == nil, err
+	}, cb)
+}
+
+func objectUsage(ctx context.Context, objRoot string) (*UsageReport, error) {
+	inv, err := ReadInventory(objRoot)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read inventory of %s", objRoot)
+	}
+
+	report := &UsageReport{ID: inv.ID, Path: objRoot}
+
+	// Content is measured once per digest, for computing logical sizes, and once per
+	// physical path, since each path is stored.
+	sizes := make(map[metadata.Digest]int64, len(inv.Manifest))
+	added := make(map[string]int64)
+	for digest, paths := range inv.Manifest {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		for _, p := range paths {
+			size := fileSize(filepath.Join(objRoot, filepath.FromSlash(p)))
+			if size < 0 {
+				report.Missing++
+				continue
+			}
+			sizes[digest.Normalize()] = size
+			report.Physical += size
+			added[strings.SplitN(p, "/", 2)[0]] += size
+		}
+	}
+
+	for _, v := range inv.VersionNames() {
+		usage := VersionUsage{Version: v, Added: added[v]}
+		for digest, paths := range inv.Versions[v].State {
+			usage.Files += len(paths)
+			usage.Logical += sizes[digest.Normalize()] * int64(len(paths))
+		}
+		report.Versions = append(report.Versions, usage)
+		if v == inv.Head {
+			report.Logical = usage.Logical
+		}
+	}
+
+	err = filepath.Walk(objRoot, func(p string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			report.Total += info.Size()
+		}
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not measure %s", objRoot)
+	}
+
+	return report, nil
+}
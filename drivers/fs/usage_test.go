@@ -0,0 +1,61 @@
+package fs_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/go-test/deep"
+)
+
+func TestUsage(t *testing.T) {
+	runWithDriverConfig(t, func(cfg *fs.Config) { cfg.Dedup = true }, func(driver driverWrapper) {
+		d := driver.driver.(*fs.Driver)
+
+		session := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		session.Put("a", strings.NewReader("aa"))
+		session.Put("b", strings.NewReader("bbb"))
+		session.Commit(ocfl.CommitInfo{})
+
+		// Duplicate content is not stored again
+		session = driver.Open(objectID, ocfl.Options{Version: ocfl.NEW})
+		session.Put("c", strings.NewReader("aa"))
+		session.Commit(ocfl.CommitInfo{})
+
+		session = driver.Open(objectID, ocfl.Options{Version: ocfl.NEW})
+		session.Put("d", strings.NewReader("dddd"))
+		session.Delete("b")
+		session.Commit(ocfl.CommitInfo{})
+
+		var reports []*fs.UsageReport
+		err := d.Usage(context.Background(), fs.UsageOptions{}, func(r *fs.UsageReport) error {
+			reports = append(reports, r)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("usage failed %+v", err)
+		}
+		if len(reports) != 1 {
+			t.Fatalf("expected one report, got %d", len(reports))
+		}
+
+		r := reports[0]
+		if r.ID != objectID || r.Logical != 8 || r.Physical != 9 || r.Missing != 0 {
+			t.Errorf("wrong usage %+v", r)
+		}
+		if r.Total <= r.Physical {
+			t.Errorf("total size %d should include inventories", r.Total)
+		}
+
+		expected := []fs.VersionUsage{
+			{Version: "v1", Files: 2, Logical: 5, Added: 5},
+			{Version: "v2", Files: 3, Logical: 7, Added: 0},
+			{Version: "v3", Files: 3, Logical: 8, Added: 4},
+		}
+		if diff := deep.Equal(r.Versions, expected); diff != nil {
+			t.Error(diff)
+		}
+	})
+}
@@ -1,13 +1,16 @@
 package fs
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"syscall"
 
 	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/fspath"
 	"github.com/birkland/ocfl/metadata"
 	"github.com/pkg/errors"
 )
@@ -40,6 +43,23 @@ func ReadInventory(objPath string) (*metadata.Inventory, error) {
 	return &inv, nil
 }
 
+// readInventoryID reads only the ID from the inventory of the OCFL object at the
+// given path.
+func readInventoryID(objPath string) (string, error) {
+	file, err := os.Open(filepath.Join(objPath, metadata.InventoryFile))
+	if err != nil {
+		return "", errors.Wrapf(err, "could not open manifest at %s", objPath)
+	}
+	defer file.Close()
+
+	id, err := metadata.ParseID(file)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not parse manifest at %s", objPath)
+	}
+
+	return id, nil
+}
+
 // ManagedWrite encapsulates an io.WriteCloser such that the write can be
 // rolled back upon error.
 type ManagedWrite struct {
@@ -50,9 +70,16 @@ type ManagedWrite struct {
 }
 
 // Close frees up any resources and performs the necessary actions to
-// commit the write.
+// commit the write.  If committing the write fails (e.g. a temporary file
+// cannot be renamed into place), the write is rolled back.
 func (w *ManagedWrite) Close() error {
-	return w.closeWith(w.closeFunc)
+	err := w.closeWith(w.closeFunc)
+	if err != nil && w.closed && w.rollbackFunc != nil {
+		if e := w.rollbackFunc(); e != nil && !os.IsNotExist(e) {
+			return errors.Wrapf(err, "could not roll back (%s) after error", e)
+		}
+	}
+	return err
 }
 
 // Rollback attempts to undo any tangible effects of an incomplete/errored write.
@@ -103,6 +130,74 @@ func AtomicWrite(path string) (*ManagedWrite, error) {
 	}, nil
 }
 
+// StagedWrite creates a uniquely named temporary file in the given staging
+// directory.  Once written and closed, the temp file is renamed to the given
+// path.  If the staging directory is on a different filesystem than the path,
+// the content is copied beside the path and atomically renamed from there.
+//
+// If staging is empty, StagedWrite is equivalent to AtomicWrite.
+func StagedWrite(staging, path string) (*ManagedWrite, error) {
+	if staging == "" {
+		return AtomicWrite(path)
+	}
+
+	tfile, err := ioutil.TempFile(staging, AtomicPrefix+filepath.Base(path)+".")
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not create temporary file in %s", staging)
+	}
+	tname := tfile.Name()
+
+	// TempFile creates files readable only by the owner
+	if err = tfile.Chmod(filePermission); err != nil {
+		_ = tfile.Close()
+		_ = os.Remove(tname)
+		return nil, errors.Wrapf(err, "could not set permissions of %s", tname)
+	}
+
+	return &ManagedWrite{
+		WriteCloser: tfile,
+		closeFunc: func() error {
+			err := os.Rename(tname, path)
+			if le, ok := err.(*os.LinkError); ok && le.Err == syscall.EXDEV {
+				err = moveAcross(tname, path)
+			}
+			return errors.Wrapf(err, "could not move %s to %s", tname, path)
+		},
+		rollbackFunc: func() error {
+			return os.Remove(tname)
+		},
+	}, nil
+}
+
+// moveAcross moves a file to a path on another filesystem, by copying it into
+// a temp file beside the path, and renaming it.
+func moveAcross(src, path string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := AtomicWrite(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = out.Rollback()
+		}
+	}()
+
+	if _, err = io.Copy(out, in); err != nil {
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
+
 // SafeWrite attempts to create a file at the given path to write to.  If
 // a file already exists there, it'll do an AtomicWrite which writes to
 // a temporary file, and atomically renames when successful.
@@ -123,6 +218,16 @@ func SafeWrite(path string) (*ManagedWrite, error) {
 	}, nil
 }
 
+// SafeStagedWrite writes to a temp file in the given staging directory, and
+// moves it into place upon Close, whether or not a file already exists at the
+// given path.  If staging is empty, it is equivalent to SafeWrite.
+func SafeStagedWrite(staging, path string) (*ManagedWrite, error) {
+	if staging == "" {
+		return SafeWrite(path)
+	}
+	return StagedWrite(staging, path)
+}
+
 // TeeWriter passes along bytes to a given "Tee" writer as it writes
 // to a Destination writer.
 type TeeWriter struct {
@@ -147,12 +252,35 @@ func (t *TeeWriter) Write(b []byte) (n int, err error) {
 	return wbytes, nil
 }
 
+// contextReader is an io.Reader that fails with the context's error
+// once the context is done.
+type contextReader struct {
+	io.Reader
+	ctx context.Context
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.Reader.Read(p)
+}
+
+// MkRoot initializes an OCFL root at the given path, without declaring a
+// storage layout.  See InitRoot.
+func MkRoot(path string) error {
+	return InitRoot(path, nil)
+}
+
 // InitRoot initializes an OCFL root at the given path.  If the path
 // does not exist, it creates a directory.  If the path is an empty
 // directory, it will place an OCFL Namaste file in it.  IIf the path
 // is already a root, this is a noop.  For all other cases (e.g. it's a
 // file, or a non-existent directory), an error will be thrown)
-func MkRoot(path string) (err error) {
+//
+// If a layout is given, the root declares it in LayoutFile, along with the
+// config of its extension, so that drivers and other OCFL clients use it.
+func InitRoot(path string, layout fspath.Layout) (err error) {
 
 	finfo, err := os.Stat(path)
 	if err != nil && os.IsNotExist(err) {
@@ -182,6 +310,12 @@ func MkRoot(path string) (err error) {
 		return fmt.Errorf("directory is not empty, refusing to create OCFL root at %s", path)
 	}
 
+	if layout != nil {
+		if err := writeLayout(path, layout); err != nil {
+			return err
+		}
+	}
+
 	namasteFile := filepath.Join(path, ocflRoot)
 	return ioutil.WriteFile(namasteFile, []byte(ocflRootNamasteContent), filePermission)
 }
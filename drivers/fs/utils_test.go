@@ -132,6 +132,62 @@ func TestSafeWriteRollback(t *testing.T) {
 	})
 }
 
+func TestStagedWrite(t *testing.T) {
+	runInTempDir(t, func(tempDir string) {
+		staging := filepath.Join(tempDir, "staging")
+		_ = os.Mkdir(staging, 0755)
+		fileName := filepath.Join(tempDir, "staged")
+
+		for _, content := range []string{"first", "second"} {
+			w, err := fs.StagedWrite(staging, fileName)
+			if err != nil {
+				t.Fatalf("staged write threw an error %+v", err)
+			}
+			_, _ = io.WriteString(w, content)
+
+			if _, err := os.Stat(fileName); err == nil && content == "first" {
+				t.Errorf("file should not exist until closed")
+			}
+
+			if err := w.Close(); err != nil {
+				t.Fatalf("Error closing! %+v", err)
+			}
+
+			written, _ := ioutil.ReadFile(fileName)
+			if string(written) != content {
+				t.Errorf("Expected content %s, got %s", content, written)
+			}
+		}
+
+		files, _ := ioutil.ReadDir(staging)
+		if len(files) > 0 {
+			t.Errorf("staging directory was not cleaned up")
+		}
+	})
+}
+
+func TestStagedWriteRollback(t *testing.T) {
+	runInTempDir(t, func(tempDir string) {
+		staging := filepath.Join(tempDir, "staging")
+		_ = os.Mkdir(staging, 0755)
+
+		w, _ := fs.StagedWrite(staging, filepath.Join(tempDir, "rollback"))
+		_, _ = io.WriteString(w, "something")
+		if err := w.Rollback(); err != nil {
+			t.Errorf("error rolling back! %s", err)
+		}
+
+		files, _ := ioutil.ReadDir(tempDir)
+		if len(files) != 1 {
+			t.Errorf("rollback left files behind")
+		}
+		files, _ = ioutil.ReadDir(staging)
+		if len(files) > 0 {
+			t.Errorf("rollback did not clean up staging directory")
+		}
+	})
+}
+
 func TestManagedWriteCloseError(t *testing.T) {
 	badCloser := &fs.ManagedWrite{WriteCloser: &errcloser{}}
 	if badCloser.Close() == nil {
@@ -0,0 +1,706 @@
+package fs
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/fspath"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+)
+
+// ValidationReport contains the results of validating an OCFL object
+type ValidationReport struct {
+	ID      string                     `json:"id,omitempty"` // ID of the object, if it could be determined
+	Path    string                     `json:"path"`         // Path of the object root
+	Results metadata.ValidationResults `json:"results"`
+}
+
+// Valid indicates whether validation found no errors
+func (r *ValidationReport) Valid() bool {
+	return !r.Results.HasErrors()
+}
+
+func (r *ValidationReport) errorf(code metadata.Code, path, format string, args ...interface{}) {
+	r.add(metadata.SeverityError, code, path, fmt.Sprintf(format, args...))
+}
+
+func (r *ValidationReport) warnf(code metadata.Code, path, format string, args ...interface{}) {
+	r.add(metadata.SeverityWarning, code, path, fmt.Sprintf(format, args...))
+}
+
+func (r *ValidationReport) add(severity metadata.Severity, code metadata.Code, path, message string) {
+	r.Results = append(r.Results, metadata.ValidationResult{
+		ObjectID: r.ID,
+		Code:     code,
+		Severity: severity,
+		Path:     path,
+		Message:  message,
+	})
+}
+
+// ValidationLevel determines how thoroughly objects are validated
+type ValidationLevel int
+
+// Validation levels
+const (
+	// Full validation verifies the digests of all content
+	Full ValidationLevel = iota
+
+	// Structure validation verifies inventories and their sidecars, and that the
+	// content in the manifest (and only that content) is present, without reading it
+	Structure
+)
+
+// ValidateOptions configure validation
+type ValidateOptions struct {
+	Level   ValidationLevel
+	Workers int // Number of objects validated concurrently by ValidateRoot; defaults to the number of CPUs
+
+	// Progress, if defined, is invoked as each content file is checked, and as its
+	// content is read.  It is never invoked concurrently, and should return quickly.
+	Progress func(ValidationProgress)
+}
+
+// ValidationProgress describes how far a validation has progressed.  Counts are
+// cumulative over all objects validated, e.g. by ValidateRoot.
+type ValidationProgress struct {
+	FilesChecked int    // Number of content files whose checks are complete
+	BytesHashed  int64  // Number of bytes of content read to compute digests
+	Path         string // Path of the content file currently being checked
+}
+
+// Tracks validation progress, and reports it to the callback, if any.
+// A nil *progress reports nothing.
+type progress struct {
+	sync.Mutex
+	cb    func(ValidationProgress)
+	state ValidationProgress
+}
+
+func newProgress(cb func(ValidationProgress)) *progress {
+	if cb == nil {
+		return nil
+	}
+	return &progress{cb: cb}
+}
+
+func (p *progress) update(f func(*ValidationProgress)) {
+	if p == nil {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	f(&p.state)
+	p.cb(p.state)
+}
+
+func (p *progress) checking(path string) {
+	p.update(func(s *ValidationProgress) { s.Path = path })
+}
+
+func (p *progress) checked() {
+	p.update(func(s *ValidationProgress) { s.FilesChecked++ })
+}
+
+// Reader that reports the bytes read from it as hashed
+type progressReader struct {
+	io.Reader
+	progress *progress
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	if n > 0 {
+		r.progress.update(func(s *ValidationProgress) { s.BytesHashed += int64(n) })
+	}
+	return n, err
+}
+
+// Validate performs a deep validation of the object with the given ID.  Beyond
+// the internal consistency of its inventory, it verifies that:
+//
+// The inventory of the object root, and of every version, matches its sidecar digest,
+// and the inventory of the head version is identical to that of the object root.
+//
+// Every path in the manifest exists, and (unless only validating its Structure)
+// its content matches its digest, as well as any digests in the fixity block
+// (for supported algorithms).
+//
+// Every file in version content directories is referenced by the manifest.
+//
+// Problems with the object are described by the returned report; an error is
+// only returned if the object could not be found, or the context is done, in
+// which case validation stops as soon as possible, even within a large file.
+func (d *Driver) Validate(ctx context.Context, id string, opts ValidateOptions) (*ValidationReport, error) {
+
+	// Locate the object by path if possible, since its inventory may be unreadable
+	if d.cfg.ObjectPaths != nil && d.root != nil {
+		objRoot := filepath.Join(d.root.Addr, d.cfg.ObjectPaths.Generate(d.normalizeID(id)))
+		if isObject, _, err := isRoot(objRoot, ocfl.Object); err == nil && isObject {
+			return validateObject(ctx, objRoot, opts.Level, newProgress(opts.Progress))
+		}
+	}
+
+	obj, _, err := d.readObject(ctx, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read object %s", id)
+	}
+	if obj == nil {
+		return nil, errors.Wrap(ocfl.ErrNotFound, id)
+	}
+
+	return validateObject(ctx, obj.Addr, opts.Level, newProgress(opts.Progress))
+}
+
+// ValidatePath performs a deep validation (see Validate) of the object whose
+// root is at the given path, which need not be within an OCFL root.  A directory
+// containing an inventory, but no object declaration, is validated (and found
+// invalid) as well.
+func ValidatePath(ctx context.Context, objRoot string, opts ValidateOptions) (*ValidationReport, error) {
+	isObject, _, err := isRoot(objRoot, ocfl.Object)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not validate %s", objRoot)
+	}
+	if _, statErr := os.Stat(filepath.Join(objRoot, metadata.InventoryFile)); !isObject && statErr != nil {
+		return nil, fmt.Errorf("%s is not an OCFL object root", objRoot)
+	}
+	return validateObject(ctx, objRoot, opts.Level, newProgress(opts.Progress))
+}
+
+// ValidateRoot performs a deep validation (see Validate) of every object in the
+// OCFL root, validating up to opts.Workers objects concurrently.  The callback
+// is invoked with the report of each object as soon as it is complete, so
+// reports arrive in no particular order.  Callbacks are never invoked concurrently.
+//
+// Problems with objects are described by their reports; an error is only returned
+// if the root could not be walked, the context is done, or the callback returns an
+// error, which stops validation.
+func (d *Driver) ValidateRoot(ctx context.Context, opts ValidateOptions, cb func(*ValidationReport) error) error {
+	if d.root == nil {
+		return fmt.Errorf("cannot validate: please define an OCFL root")
+	}
+
+	progress := newProgress(opts.Progress)
+	return visitObjects(ctx, opts.Workers, d.sendObjectRoots, func(ctx context.Context, objRoot string) (*ValidationReport, bool, error) {
+		report, err := validateObject(ctx, objRoot, opts.Level, progress)
+		return report, true, err
+	}, cb)
+}
+
+// Code identifying why an inventory could not be read; either the validation
+// error it was rejected with, or E033 if it could not be parsed at all
+func readErrorCode(err error) metadata.Code {
+	if verr, ok := errors.Cause(err).(*metadata.ValidationError); ok {
+		return verr.Code
+	}
+	return metadata.E033
+}
+
+// Validates the object rooted at the given path
+func validateObject(ctx context.Context, objRoot string, level ValidationLevel, progress *progress) (*ValidationReport, error) {
+	report := &ValidationReport{Path: objRoot, Results: metadata.ValidationResults{}}
+
+	inv, err := ReadInventory(objRoot)
+	if err != nil {
+		code := readErrorCode(err)
+		if _, statErr := os.Stat(filepath.Join(objRoot, metadata.InventoryFile)); os.IsNotExist(statErr) {
+			code = metadata.E063
+		}
+		report.errorf(code, metadata.InventoryFile, "could not read inventory: %s", err)
+		return report, nil
+	}
+	report.ID = inv.ID
+
+	results := inv.Check()
+	for _, result := range results {
+		report.add(result.Severity, result.Code, metadata.InventoryFile, result.Message)
+	}
+	if results.HasErrors() {
+		return report, nil
+	}
+
+	if inv.DigestAlgorithm != "sha512" {
+		report.warnf(metadata.W004, metadata.InventoryFile, "digest algorithm is %s rather than sha512", inv.DigestAlgorithm)
+	}
+	if _, supported := metadata.NewHash(inv.DigestAlgorithm); !supported {
+		report.warnf("", metadata.InventoryFile, "digest algorithm %s is not supported; content cannot be verified", inv.DigestAlgorithm)
+		return report, nil
+	}
+
+	validateObjectRoot(report, objRoot, inv)
+	rootDigest := validateSidecar(report, objRoot, "", inv.DigestAlgorithm)
+
+	for _, v := range inv.VersionNames() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if info, err := os.Stat(filepath.Join(objRoot, v)); err != nil || !info.IsDir() {
+			report.errorf(metadata.E046, v, "version directory %s is missing", v)
+			continue
+		}
+
+		invPath := filepath.Join(objRoot, v, metadata.InventoryFile)
+		if _, err := os.Stat(invPath); err != nil {
+			report.warnf(metadata.W010, v+"/"+metadata.InventoryFile, "version %s has no inventory", v)
+			validateVersionDir(report, objRoot, v, inv.ContentDirectory(), "")
+			continue
+		}
+
+		// Prior versions may have used other digest algorithms
+		vinv, err := ReadInventory(filepath.Join(objRoot, v))
+		if err != nil {
+			report.errorf(readErrorCode(err), v+"/"+metadata.InventoryFile, "could not read inventory: %s", err)
+			continue
+		}
+		validateVersionDir(report, objRoot, v, inv.ContentDirectory(), vinv.DigestAlgorithm)
+		if vinv.DigestAlgorithm != "sha512" && vinv.DigestAlgorithm != inv.DigestAlgorithm {
+			report.warnf(metadata.W004, v+"/"+metadata.InventoryFile, "digest algorithm is %s rather than sha512", vinv.DigestAlgorithm)
+		}
+
+		digest := validateSidecar(report, objRoot, v, vinv.DigestAlgorithm)
+		if v == inv.Head && digest != "" && rootDigest != "" && digest != rootDigest {
+			report.errorf(metadata.E064, v+"/"+metadata.InventoryFile, "inventory of head version %s differs from the root inventory", v)
+		}
+		if v != inv.Head {
+			validatePriorInventory(report, v, inv, vinv)
+		}
+	}
+
+	if err := validateContent(ctx, report, objRoot, inv, level, progress); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// Verifies the object's declaration, and that its root contains nothing but the
+// declaration, inventory, sidecar, versions, logs and extensions
+func validateObjectRoot(report *ValidationReport, objRoot string, inv *metadata.Inventory) {
+	declaration, err := ioutil.ReadFile(filepath.Join(objRoot, ocflObjectRoot))
+	if err != nil {
+		report.errorf(metadata.E003, ocflObjectRoot, "could not read object declaration: %s", err)
+	} else if string(declaration) != objectRootNamasteContent {
+		report.errorf(metadata.E007, ocflObjectRoot, "object declaration does not contain %q", objectRootNamasteContent)
+	}
+
+	entries, err := ioutil.ReadDir(objRoot)
+	if err != nil {
+		report.errorf("", "", "could not list object root: %s", err)
+		return
+	}
+
+	for _, e := range entries {
+		name := e.Name()
+		if _, isVersion := inv.Versions[name]; isVersion && e.IsDir() {
+			continue
+		}
+
+		switch name {
+		case ocflObjectRoot, metadata.InventoryFile, metadata.InventoryFile + "." + string(inv.DigestAlgorithm):
+			if !e.IsDir() {
+				continue
+			}
+		case logsDir, extensionsDir:
+			if e.IsDir() {
+				continue
+			}
+		}
+		report.errorf(metadata.E001, name, "object root contains unexpected %s", name)
+	}
+}
+
+// Verifies that a version directory contains nothing but its inventory, sidecar
+// (if the version has an inventory using the given digest algorithm), and content
+func validateVersionDir(report *ValidationReport, objRoot, version, contentDir string, alg metadata.DigestAlgorithm) {
+	entries, err := ioutil.ReadDir(filepath.Join(objRoot, version))
+	if err != nil {
+		report.errorf("", version, "could not list version directory: %s", err)
+		return
+	}
+
+	for _, e := range entries {
+		name, rel := e.Name(), version+"/"+e.Name()
+		switch {
+		case e.IsDir() && name == contentDir:
+		case e.IsDir():
+			report.warnf(metadata.W002, rel, "version directory contains unexpected directory %s", name)
+		case alg != "" && (name == metadata.InventoryFile || name == metadata.InventoryFile+"."+string(alg)):
+		default:
+			report.errorf(metadata.E015, rel, "version directory contains unexpected file %s", name)
+		}
+	}
+}
+
+// Verifies that each version in the inventory of a prior version is the same
+// as in the current inventory
+func validatePriorInventory(report *ValidationReport, version string, inv, prior *metadata.Inventory) {
+	path := version + "/" + metadata.InventoryFile
+	for _, name := range prior.VersionNames() {
+		current, ok := inv.Versions[name]
+		if !ok {
+			report.errorf(metadata.E066, path, "version %s is not in the current inventory", name)
+			continue
+		}
+		previous := prior.Versions[name]
+
+		// States can only be compared by digest if both inventories use the same algorithm
+		if !sameState(previous.State, current.State, prior.DigestAlgorithm == inv.DigestAlgorithm) {
+			report.errorf(metadata.E066, path, "state of version %s differs from the current inventory", name)
+		}
+
+		if !previous.Created.Equal(current.Created) || previous.Message != current.Message || previous.User != current.User {
+			report.warnf(metadata.W011, path, "metadata of version %s differs from the current inventory", name)
+		}
+	}
+}
+
+// Compares the logical paths of two states, and optionally their digests
+func sameState(a, b metadata.Manifest, compareDigests bool) bool {
+	index := func(state metadata.Manifest) map[string]string {
+		paths := make(map[string]string)
+		for digest, logical := range state {
+			for _, p := range logical {
+				if compareDigests {
+					paths[p] = string(digest.Normalize())
+				} else {
+					paths[p] = ""
+				}
+			}
+		}
+		return paths
+	}
+
+	pathsA, pathsB := index(a), index(b)
+	if len(pathsA) != len(pathsB) {
+		return false
+	}
+	for p, digest := range pathsA {
+		if other, ok := pathsB[p]; !ok || other != digest {
+			return false
+		}
+	}
+	return true
+}
+
+// Verifies that the inventory in the given version directory (or the object root,
+// if the version is empty) matches its sidecar.  Returns the inventory's digest,
+// or the empty string if it could not be computed.
+func validateSidecar(report *ValidationReport, objRoot, version string, alg metadata.DigestAlgorithm) string {
+	rel := func(name string) string {
+		if version == "" {
+			return name
+		}
+		return version + "/" + name
+	}
+
+	digest, err := fileDigest(filepath.Join(objRoot, version, metadata.InventoryFile), alg)
+	if err != nil {
+		report.errorf(metadata.E063, rel(metadata.InventoryFile), "%s", err)
+		return ""
+	}
+
+	sidecarName := metadata.InventoryFile + "." + string(alg)
+	sidecar, err := ioutil.ReadFile(filepath.Join(objRoot, version, sidecarName))
+	if err != nil {
+		report.errorf(metadata.E058, rel(sidecarName), "could not read inventory sidecar: %s", err)
+		return digest
+	}
+
+	fields := strings.Fields(string(sidecar))
+	if len(fields) != 2 || fields[1] != metadata.InventoryFile {
+		report.errorf(metadata.E061, rel(sidecarName), "inventory sidecar is malformed")
+	} else if !metadata.Digest(fields[0]).Equal(metadata.Digest(digest)) {
+		report.errorf(metadata.E060, rel(sidecarName), "inventory does not match its sidecar digest")
+	}
+
+	return digest
+}
+
+// Verifies the digests of every file in the manifest and fixity block (or only
+// their presence, when validating structure), and that every file in a version's
+// content directory is in the manifest
+func validateContent(ctx context.Context, report *ValidationReport, objRoot string, inv *metadata.Inventory, level ValidationLevel, progress *progress) error {
+	expected := make(map[string]map[metadata.DigestAlgorithm]metadata.Digest)
+	expect := func(alg metadata.DigestAlgorithm, manifest metadata.Manifest) {
+		for digest, paths := range manifest {
+			for _, p := range paths {
+				if expected[p] == nil {
+					expected[p] = make(map[metadata.DigestAlgorithm]metadata.Digest)
+				}
+				expected[p][alg] = digest
+			}
+		}
+	}
+	expect(inv.DigestAlgorithm, inv.Manifest)
+
+	manifest := make(map[string]bool)
+	for _, paths := range inv.Manifest {
+		for _, p := range paths {
+			manifest[p] = true
+		}
+	}
+
+	var fixityAlgs []metadata.DigestAlgorithm
+	for alg := range inv.Fixity {
+		fixityAlgs = append(fixityAlgs, alg)
+	}
+	sort.Slice(fixityAlgs, func(i, j int) bool { return fixityAlgs[i] < fixityAlgs[j] })
+
+	// Fixity digests using the manifest's algorithm are redundant, and not checked
+	algs := []metadata.DigestAlgorithm{inv.DigestAlgorithm}
+	for _, alg := range fixityAlgs {
+		if alg == inv.DigestAlgorithm {
+			continue
+		}
+		if _, supported := metadata.NewHash(alg); !supported {
+			report.warnf("", metadata.InventoryFile, "fixity digests using %s cannot be verified", alg)
+			continue
+		}
+		algs = append(algs, alg)
+		expect(alg, inv.Fixity[alg])
+	}
+
+	paths := make([]string, 0, len(expected))
+	for p := range expected {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if _, inManifest := manifest[p]; !inManifest {
+			report.errorf(metadata.E057, p, "fixity block references %s, which is not in the manifest", p)
+			continue
+		}
+
+		path := filepath.Join(objRoot, filepath.FromSlash(p))
+		progress.checking(path)
+		if level == Structure {
+			if _, err := os.Stat(path); err != nil {
+				report.errorf(metadata.E092, p, "content is missing: %s", err)
+			}
+			progress.checked()
+			continue
+		}
+
+		digests, err := fileDigests(ctx, path, expected[p], progress)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		progress.checked()
+		if err != nil {
+			report.errorf(metadata.E092, p, "content is missing or unreadable: %s", err)
+			continue
+		}
+
+		for _, alg := range algs {
+			if digest, ok := expected[p][alg]; ok && !digest.Equal(metadata.Digest(digests[alg])) {
+				code := metadata.E093
+				if alg == inv.DigestAlgorithm {
+					code = metadata.E092
+				}
+				report.errorf(code, p, "content does not match its %s digest %s (computed %s)", alg, digest, digests[alg])
+			}
+		}
+	}
+
+	for _, v := range inv.VersionNames() {
+		contentDir := filepath.Join(objRoot, v, inv.ContentDirectory())
+		err := filepath.Walk(contentDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, _ := filepath.Rel(objRoot, path)
+			if !manifest[filepath.ToSlash(rel)] {
+				report.errorf(metadata.E023, filepath.ToSlash(rel), "file is not in the manifest")
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			report.errorf("", v+"/"+inv.ContentDirectory(), "could not list content: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// Computes the hex digest of a file using the given algorithm
+func fileDigest(path string, alg metadata.DigestAlgorithm) (string, error) {
+	digests, err := fileDigests(context.Background(), path, map[metadata.DigestAlgorithm]metadata.Digest{alg: ""}, nil)
+	return digests[alg], err
+}
+
+// Computes hex digests of a file in a single pass, using each of the given algorithms,
+// and reporting the bytes read as progress.  Reading stops once the context is done.
+func fileDigests(ctx context.Context, path string, algs map[metadata.DigestAlgorithm]metadata.Digest, progress *progress) (map[metadata.DigestAlgorithm]string, error) {
+	hashes := make(map[metadata.DigestAlgorithm]hash.Hash)
+	var writers []io.Writer
+	for alg := range algs {
+		h, supported := metadata.NewHash(alg)
+		if !supported {
+			return nil, fmt.Errorf("unsupported digest algorithm %s", alg)
+		}
+		hashes[alg] = h
+		writers = append(writers, h)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := &progressReader{Reader: &contextReader{ctx: ctx, Reader: file}, progress: progress}
+	if _, err := io.Copy(io.MultiWriter(writers...), r); err != nil {
+		return nil, errors.Wrapf(err, "could not read %s", path)
+	}
+
+	digests := make(map[metadata.DigestAlgorithm]string)
+	for alg, h := range hashes {
+		digests[alg] = hex.EncodeToString(h.Sum(nil))
+	}
+	return digests, nil
+}
+
+// Files that may appear in the top level of an OCFL root
+var rootFiles = map[string]bool{
+	ocflRoot:                       true,
+	"ocfl_" + ocflVersion + ".txt": true,
+	LayoutFile:                     true,
+}
+
+// ValidateStorageRoot validates the structure of the OCFL root at the given
+// path, but not its objects (see ValidateRoot).  It verifies that:
+//
+// The root declaration is present and well formed, as is the layout declaration, if any.
+//
+// The extensions directory contains only extension directories, of known extensions.
+//
+// The directories leading to object roots contain no files, no symbolic links, and
+// no empty directories.  Unknown files in the root itself are reported as warnings.
+//
+// Every object is where the declared layout (if supported) places it.
+//
+// Problems with the root are described by the returned report; an error is only
+// returned if the root could not be read, or the context is done.
+func ValidateStorageRoot(ctx context.Context, root string) (*ValidationReport, error) {
+	report := &ValidationReport{Path: root, Results: metadata.ValidationResults{}}
+
+	declaration, err := ioutil.ReadFile(filepath.Join(root, ocflRoot))
+	if err != nil {
+		report.errorf(metadata.E069, ocflRoot, "could not read root declaration: %s", err)
+	} else if string(declaration) != ocflRootNamasteContent {
+		report.errorf(metadata.E080, ocflRoot, "root declaration does not contain %q", ocflRootNamasteContent)
+	}
+
+	var layout fspath.Generator
+	if declared, err := ReadLayout(root); err != nil {
+		report.errorf(metadata.E070, LayoutFile, "%s", err)
+	} else if declared != nil {
+		if layout, err = declared.Generator(root); err != nil {
+			report.warnf("", LayoutFile, "object placement cannot be verified: %s", err)
+		}
+	}
+
+	var walk func(dir, rel string) error
+	walk = func(dir, rel string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return errors.Wrapf(err, "could not read directory %s", dir)
+		}
+		if len(entries) == 0 && rel != "" {
+			report.errorf(metadata.E073, rel, "directory is empty")
+		}
+
+		for _, e := range entries {
+			path, entryRel := filepath.Join(dir, e.Name()), e.Name()
+			if rel != "" {
+				entryRel = rel + "/" + e.Name()
+			}
+
+			switch {
+			case e.Mode()&os.ModeSymlink != 0:
+				report.errorf(metadata.E090, entryRel, "symbolic link in storage hierarchy")
+			case !e.IsDir() && rel == "":
+				if !rootFiles[e.Name()] {
+					report.warnf("", entryRel, "unknown file in the storage root")
+				}
+			case !e.IsDir():
+				report.errorf(metadata.E084, entryRel, "file in storage hierarchy outside of any object")
+			case rel == "" && e.Name() == extensionsDir:
+				validateRootExtensions(report, path)
+			default:
+				isObject, _, err := isRoot(path, ocfl.Object)
+				if err != nil {
+					return err
+				}
+				if !isObject {
+					if err := walk(path, entryRel); err != nil {
+						return err
+					}
+					continue
+				}
+				if layout == nil {
+					continue
+				}
+
+				if id, err := readInventoryID(path); err != nil {
+					report.errorf(metadata.E033, entryRel+"/"+metadata.InventoryFile, "could not read object ID: %s", err)
+				} else if expected := layout.Generate(id); expected != entryRel {
+					report.errorf("", entryRel, "object %s is not where the layout places it, %s", id, expected)
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := walk(root, ""); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Verifies that the storage root extensions directory contains only directories, and
+// warns of those of extensions unknown to the driver: neither a registered storage
+// layout, nor the driver's LocalExtension.
+func validateRootExtensions(report *ValidationReport, dir string) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		report.errorf("", extensionsDir, "could not read extensions directory: %s", err)
+		return
+	}
+
+	known := map[string]bool{LocalExtension: true}
+	for _, name := range fspath.Layouts() {
+		known[name] = true
+	}
+
+	for _, e := range entries {
+		rel := extensionsDir + "/" + e.Name()
+		switch {
+		case !e.IsDir():
+			report.errorf(metadata.E086, rel, "extensions directory contains a file")
+		case !known[e.Name()]:
+			report.warnf("", rel, "unknown extension %s", e.Name())
+		}
+	}
+}
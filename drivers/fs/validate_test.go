@@ -0,0 +1,358 @@
+package fs_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/fspath"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/go-test/deep"
+)
+
+func TestValidate(t *testing.T) {
+	// md5 and sha1 of "hello"
+	fixity := func(inv *metadata.Inventory) error {
+		inv.Fixity = metadata.Fixity{
+			"md5":  metadata.Manifest{"5d41402abc4b2a76b9719d911017c592": {"v1/content/hello.txt"}},
+			"sha1": metadata.Manifest{"aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d": {"v1/content/hello.txt"}},
+		}
+		return nil
+	}
+
+	cases := []struct {
+		name     string
+		sabotage func(objRoot string)
+		errors   []string // codes and paths of expected errors
+	}{
+		{"valid", func(string) {}, nil},
+		{"corruptContent", func(objRoot string) {
+			_ = ioutil.WriteFile(filepath.Join(objRoot, "v1", "content", "hello.txt"), []byte("HELLO"), 0644)
+		}, []string{"E092 v1/content/hello.txt", "E093 v1/content/hello.txt", "E093 v1/content/hello.txt"}},
+		{"missingContent", func(objRoot string) {
+			_ = os.Remove(filepath.Join(objRoot, "v2", "content", "world.txt"))
+		}, []string{"E092 v2/content/world.txt"}},
+		{"strayContent", func(objRoot string) {
+			_ = ioutil.WriteFile(filepath.Join(objRoot, "v2", "content", "stray.txt"), []byte("stray"), 0644)
+		}, []string{"E023 v2/content/stray.txt"}},
+		{"badRootSidecar", func(objRoot string) {
+			_ = ioutil.WriteFile(filepath.Join(objRoot, "inventory.json.sha512"), []byte("abcd inventory.json"), 0644)
+		}, []string{"E060 inventory.json.sha512"}},
+		{"missingVersionSidecar", func(objRoot string) {
+			_ = os.Remove(filepath.Join(objRoot, "v1", "inventory.json.sha512"))
+		}, []string{"E058 v1/inventory.json.sha512"}},
+		{"headDiffers", func(objRoot string) {
+			v1 := filepath.Join(objRoot, "v1")
+			for _, f := range []string{"inventory.json", "inventory.json.sha512"} {
+				content, _ := ioutil.ReadFile(filepath.Join(v1, f))
+				_ = ioutil.WriteFile(filepath.Join(objRoot, "v2", f), content, 0644)
+			}
+		}, []string{"E064 v2/inventory.json"}},
+		{"missingVersion", func(objRoot string) {
+			_ = os.RemoveAll(filepath.Join(objRoot, "v2"))
+		}, []string{"E046 v2", "E092 v2/content/world.txt"}},
+		{"unparseableInventory", func(objRoot string) {
+			_ = ioutil.WriteFile(filepath.Join(objRoot, "inventory.json"), []byte("{"), 0644)
+		}, []string{"E033 inventory.json"}},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			runWithDriverWrapper(t, func(driver driverWrapper) {
+				v1 := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW, PreCommit: []ocfl.PreCommitHook{fixity}})
+				v1.Put("hello.txt", strings.NewReader("hello"))
+				v1.Commit(ocfl.CommitInfo{})
+
+				v2 := driver.Open(objectID, ocfl.Options{Version: ocfl.NEW})
+				v2.Put("world.txt", strings.NewReader("world"))
+				v2.Commit(ocfl.CommitInfo{})
+
+				d := driver.driver.(*fs.Driver)
+				path, _ := d.ObjectPath(objectID)
+				c.sabotage(path)
+
+				report, err := d.Validate(context.Background(), objectID, fs.ValidateOptions{})
+				if err != nil {
+					t.Fatalf("validation failed %+v", err)
+				}
+
+				var errors []string
+				for _, e := range report.Results.Filter(metadata.SeverityError) {
+					errors = append(errors, string(e.Code)+" "+e.Path)
+				}
+				if diff := deep.Equal(errors, c.errors); diff != nil {
+					t.Errorf("%v: %v", diff, report.Results)
+				}
+				if report.Valid() != (len(c.errors) == 0) {
+					t.Errorf("Report validity is wrong")
+				}
+			})
+		})
+	}
+}
+
+func TestValidateMissingObject(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		if _, err := driver.driver.(*fs.Driver).Validate(context.Background(), "does:not:exist", fs.ValidateOptions{}); err == nil {
+			t.Errorf("Expected an error validating an object that does not exist")
+		}
+	})
+}
+
+func TestValidateStructure(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		s := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		s.Put("hello.txt", strings.NewReader("hello"))
+		s.Put("world.txt", strings.NewReader("world"))
+		s.Commit(ocfl.CommitInfo{})
+
+		d := driver.driver.(*fs.Driver)
+		path, _ := d.ObjectPath(objectID)
+		_ = ioutil.WriteFile(filepath.Join(path, "v1", "content", "hello.txt"), []byte("corrupt"), 0644)
+		_ = os.Remove(filepath.Join(path, "v1", "content", "world.txt"))
+
+		report, err := fs.ValidatePath(context.Background(), path, fs.ValidateOptions{Level: fs.Structure})
+		if err != nil {
+			t.Fatalf("validation failed %+v", err)
+		}
+
+		// Corrupt content goes unnoticed, but missing content does not
+		var errors []string
+		for _, e := range report.Results.Filter(metadata.SeverityError) {
+			errors = append(errors, string(e.Code)+" "+e.Path)
+		}
+		if diff := deep.Equal(errors, []string{"E092 v1/content/world.txt"}); diff != nil {
+			t.Errorf("%v: %v", diff, report.Results)
+		}
+	})
+}
+
+func TestValidateRoot(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		ids := []string{"urn:test/a", "urn:test/b", "urn:test/c", "urn:test/d", "urn:test/e"}
+		for _, id := range ids {
+			s := driver.Open(id, ocfl.Options{Create: true, Version: ocfl.NEW})
+			s.Put("hello.txt", strings.NewReader("hello "+id))
+			s.Commit(ocfl.CommitInfo{})
+		}
+
+		d := driver.driver.(*fs.Driver)
+		path, _ := d.ObjectPath("urn:test/c")
+		_ = ioutil.WriteFile(filepath.Join(path, "v1", "content", "hello.txt"), []byte("corrupt"), 0644)
+
+		valid := make(map[string]bool)
+		err := d.ValidateRoot(context.Background(), fs.ValidateOptions{Workers: 3}, func(r *fs.ValidationReport) error {
+			if _, seen := valid[r.ID]; seen {
+				t.Errorf("Object %s was validated twice", r.ID)
+			}
+			valid[r.ID] = r.Valid()
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Validation failed: %+v", err)
+		}
+
+		expected := map[string]bool{
+			"urn:test/a": true,
+			"urn:test/b": true,
+			"urn:test/c": false,
+			"urn:test/d": true,
+			"urn:test/e": true,
+		}
+		if diff := deep.Equal(valid, expected); diff != nil {
+			t.Error(diff)
+		}
+	})
+}
+
+func TestValidateRootCallbackError(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		for _, id := range []string{"urn:test/a", "urn:test/b", "urn:test/c"} {
+			s := driver.Open(id, ocfl.Options{Create: true, Version: ocfl.NEW})
+			s.Put("hello.txt", strings.NewReader("hello"))
+			s.Commit(ocfl.CommitInfo{})
+		}
+
+		stop := errors.New("stop")
+		var calls int
+		err := driver.driver.(*fs.Driver).ValidateRoot(context.Background(), fs.ValidateOptions{Workers: 1}, func(*fs.ValidationReport) error {
+			calls++
+			return stop
+		})
+		if err != stop {
+			t.Errorf("Expected the callback's error, got %+v", err)
+		}
+		if calls != 1 {
+			t.Errorf("Expected validation to stop after the first callback, got %d calls", calls)
+		}
+	})
+}
+
+func TestValidateProgress(t *testing.T) {
+	runWithDriverWrapper(t, func(driver driverWrapper) {
+		s := driver.Open(objectID, ocfl.Options{Create: true, Version: ocfl.NEW})
+		s.Put("hello.txt", strings.NewReader("hello"))
+		s.Put("world.txt", strings.NewReader("world!"))
+		s.Commit(ocfl.CommitInfo{})
+
+		d := driver.driver.(*fs.Driver)
+		var last fs.ValidationProgress
+		paths := make(map[string]bool)
+		_, err := d.Validate(context.Background(), objectID, fs.ValidateOptions{Progress: func(p fs.ValidationProgress) {
+			if p.FilesChecked < last.FilesChecked || p.BytesHashed < last.BytesHashed {
+				t.Errorf("progress went backwards: %+v, then %+v", last, p)
+			}
+			paths[filepath.Base(p.Path)] = true
+			last = p
+		}})
+		if err != nil {
+			t.Fatalf("validation failed %+v", err)
+		}
+
+		if last.FilesChecked != 2 || last.BytesHashed != 11 {
+			t.Errorf("expected 2 files and 11 bytes checked, got %+v", last)
+		}
+		if diff := deep.Equal(paths, map[string]bool{"hello.txt": true, "world.txt": true}); diff != nil {
+			t.Error(diff)
+		}
+
+		// Cancelling from the progress callback stops validation
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		_, err = d.Validate(ctx, objectID, fs.ValidateOptions{Progress: func(fs.ValidationProgress) { cancel() }})
+		if err != context.Canceled {
+			t.Errorf("expected validation to be cancelled, got %+v", err)
+		}
+	})
+}
+
+// Fixtures are named after the codes they are expected to produce, in the manner
+// of the official OCFL fixtures (https://github.com/OCFL/fixtures), though they
+// were written for this repository rather than copied from it (see
+// testdata/fixtures/README.md).  Bad objects must be invalid with exactly the
+// error codes in their name, warn objects valid with exactly the warning codes
+// in their name, and good objects valid without warnings.
+func TestValidateFixtures(t *testing.T) {
+	fixtures, _ := filepath.Glob(filepath.Join("testdata", "fixtures", "1.0", "*-objects", "*"))
+	if len(fixtures) == 0 {
+		t.Fatal("No fixtures found")
+	}
+
+	for _, fixture := range fixtures {
+		fixture := fixture
+		kind := filepath.Base(filepath.Dir(fixture))
+		t.Run(kind+"/"+filepath.Base(fixture), func(t *testing.T) {
+			expected := make(map[metadata.Code]bool)
+			for _, token := range strings.Split(filepath.Base(fixture), "_") {
+				if len(token) == 4 && (token[0] == 'E' || token[0] == 'W') {
+					expected[metadata.Code(token)] = true
+				}
+			}
+
+			report, err := fs.ValidatePath(context.Background(), fixture, fs.ValidateOptions{})
+			if err != nil {
+				t.Fatalf("validation failed %+v", err)
+			}
+
+			found := make(map[metadata.Code]bool)
+			for _, r := range report.Results {
+				found[r.Code] = true
+			}
+
+			switch kind {
+			case "good-objects":
+				if len(report.Results) > 0 {
+					t.Errorf("Expected no problems, got %v", report.Results)
+				}
+			case "warn-objects":
+				if !report.Valid() {
+					t.Errorf("Expected a valid object, got %v", report.Results)
+				}
+				if diff := deep.Equal(found, expected); diff != nil {
+					t.Errorf("%v: %v", diff, report.Results)
+				}
+			case "bad-objects":
+				errs := make(map[metadata.Code]bool)
+				for code := range found {
+					if code.IsError() {
+						errs[code] = true
+					}
+				}
+				if diff := deep.Equal(errs, expected); diff != nil {
+					t.Errorf("%v: %v", diff, report.Results)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateStorageRoot(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		_ = fs.InitRoot(ocflRoot, fspath.FlatDirect{})
+		d, err := fs.NewDriver(fs.Config{
+			Root:      ocflRoot,
+			FilePaths: fspath.GeneratorFunc(fs.Passthrough),
+		})
+		if err != nil {
+			t.Fatalf("could not create driver %+v", err)
+		}
+		driver := driverWrapper{driver: d, t: t, root: ocflRoot}
+
+		for _, id := range []string{"a", "b"} {
+			s := driver.Open(id, ocfl.Options{Create: true, Version: ocfl.NEW})
+			s.Put("hello.txt", strings.NewReader("hello"))
+			s.Commit(ocfl.CommitInfo{})
+		}
+		_ = os.MkdirAll(filepath.Join(ocflRoot, filepath.FromSlash(fs.TombstoneDir)), 0755)
+
+		validate := func() []string {
+			report, err := fs.ValidateStorageRoot(context.Background(), ocflRoot)
+			if err != nil {
+				t.Fatalf("validation failed %+v", err)
+			}
+			var problems []string
+			for _, r := range report.Results {
+				problems = append(problems, string(r.Severity)+" "+string(r.Code)+" "+r.Path)
+			}
+			return problems
+		}
+
+		if problems := validate(); len(problems) != 0 {
+			t.Errorf("Expected a valid root, got %v", problems)
+		}
+
+		_ = os.MkdirAll(filepath.Join(ocflRoot, "misplaced"), 0755)
+		_ = os.Rename(filepath.Join(ocflRoot, "b"), filepath.Join(ocflRoot, "misplaced", "b"))
+		_ = ioutil.WriteFile(filepath.Join(ocflRoot, "misplaced", "stray.txt"), []byte("stray"), 0644)
+		_ = os.MkdirAll(filepath.Join(ocflRoot, "empty"), 0755)
+		_ = ioutil.WriteFile(filepath.Join(ocflRoot, "extensions", "stray.txt"), []byte("stray"), 0644)
+		_ = os.MkdirAll(filepath.Join(ocflRoot, "extensions", "unknown-extension"), 0755)
+		_ = ioutil.WriteFile(filepath.Join(ocflRoot, "README"), []byte("readme"), 0644)
+		_ = ioutil.WriteFile(filepath.Join(ocflRoot, "0=ocfl_1.0"), []byte("ocfl_1.0"), 0644)
+
+		expected := []string{
+			"error E080 0=ocfl_1.0",
+			"warning  README",
+			"error E073 empty",
+			"error E086 extensions/stray.txt",
+			"warning  extensions/unknown-extension",
+			"error  misplaced/b",
+			"error E084 misplaced/stray.txt",
+		}
+		if diff := deep.Equal(validate(), expected); diff != nil {
+			t.Error(diff)
+		}
+
+		_ = ioutil.WriteFile(filepath.Join(ocflRoot, fs.LayoutFile), []byte("{}"), 0644)
+		if problems := validate(); problems[1] != "error E070 ocfl_layout.json" {
+			t.Errorf("Expected an invalid layout, got %v", problems)
+		}
+	})
+}
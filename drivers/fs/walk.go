@@ -1,12 +1,15 @@
 package fs
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/fspath"
 	"github.com/birkland/ocfl/metadata"
 	"github.com/karrick/godirwalk"
 	"github.com/pkg/errors"
@@ -19,37 +22,55 @@ const (
 
 // Scope defines a bounded set of OCFL entries (e.g. everything under a given root)
 type scope struct {
+	ctx       context.Context
 	root      *ocfl.EntityRef
 	startFrom *ocfl.EntityRef
 	desired   ocfl.Select
+	symlinks  SymlinkPolicy
+
+	readInventory func(string) (*metadata.Inventory, error)
+	objectPaths   fspath.Generator
 }
 
 // NewScope defines a scope for ocfl entities underneath the given parent entity
 // Logical choices for a parent include an OCFL root, an ocfl object, or
 // an ocfl version.
-func newScope(under *ocfl.EntityRef, desired ocfl.Select) (*scope, error) {
+func newScope(ctx context.Context, under *ocfl.EntityRef, desired ocfl.Select) (*scope, error) {
 	root, err := findRoot(under, ocfl.Root)
 	if err != nil {
 		return nil, err
 	}
 
 	return &scope{
+		ctx:       ctx,
 		root:      root,
 		startFrom: under,
 		desired:   desired,
+
+		readInventory: ReadInventory,
 	}, nil
 }
 
 // Walk crawls the filesystem from a given starting point (physical path, or logical ID),
 // and invokes a callback that matches the criteria provided in the given selector.
 func (d *Driver) Walk(desired ocfl.Select, cb func(ocfl.EntityRef) error, loc ...string) error {
+	return d.WalkContext(context.Background(), desired, cb, loc...)
+}
+
+// WalkContext performs a Walk, terminating early with the context's error
+// if the given context is done before the walk completes.
+func (d *Driver) WalkContext(ctx context.Context, desired ocfl.Select, cb func(ocfl.EntityRef) error, loc ...string) error {
+	if err := desired.Validate(); err != nil {
+		return err
+	}
+
 	startFrom := &ocfl.EntityRef{}
 
 	switch len(loc) {
 	case 0: // No location provided, assume root
 		startFrom = d.root
 	case 1: // Single value.  Try resolving first, then presume it's an OCFL object if that fails
-		refs, _, err := resolve(loc[0])
+		refs, _, err := resolve(loc[0], d.readInventory)
 		if err != nil || len(refs) == 0 {
 
 			if d.root == nil {
@@ -58,7 +79,7 @@ func (d *Driver) Walk(desired ocfl.Select, cb func(ocfl.EntityRef) error, loc ..
 
 			// Nope, it's the ID of an OCFL object
 			startFrom.Type = ocfl.Object
-			startFrom.ID = loc[0]
+			startFrom.ID = d.normalizeID(loc[0])
 			startFrom.Parent = d.root
 			break
 		}
@@ -66,6 +87,12 @@ func (d *Driver) Walk(desired ocfl.Select, cb func(ocfl.EntityRef) error, loc ..
 			// Corner case: we dereferenced a physical file content that corresponds to multiple logical files
 			if desired.Type == ocfl.File || desired.Type == ocfl.Any {
 				for _, ref := range refs {
+					if !desired.MatchFile(ref.ID) || !desired.MatchObject(ref.Parent.Parent.ID) {
+						continue
+					}
+					if desired.Sizes {
+						ref.Size = fileSize(ref.Addr)
+					}
 					if err := cb(ref); err != nil {
 						return err
 					}
@@ -87,7 +114,7 @@ func (d *Driver) Walk(desired ocfl.Select, cb func(ocfl.EntityRef) error, loc ..
 			ID:   loc[1],
 			Parent: &ocfl.EntityRef{
 				Type:   ocfl.Object,
-				ID:     loc[0],
+				ID:     d.normalizeID(loc[0]),
 				Parent: d.root,
 			},
 		}
@@ -103,14 +130,22 @@ func (d *Driver) Walk(desired ocfl.Select, cb func(ocfl.EntityRef) error, loc ..
 		}
 	}
 
-	scope, err := newScope(startFrom, desired)
+	scope, err := newScope(ctx, startFrom, desired)
 	if err != nil {
 		return err
 	}
+	scope.symlinks = d.cfg.Symlinks
+	scope.readInventory = d.readInventory
+	scope.objectPaths = d.cfg.ObjectPaths
 
 	return scope.walk(cb)
 }
 
+// Inventory reads the inventory of an object, as visited by Walk
+func (d *Driver) Inventory(obj ocfl.EntityRef) (*metadata.Inventory, error) {
+	return d.readInventory(obj.Addr)
+}
+
 // Walk iterates through in-scope OCFL entities.
 // Uses a two-step algorithm for iterating entities:
 // (a) when starting from an ocfl root or intermediate node, walk directories until an object root is found
@@ -143,13 +178,22 @@ func (s *scope) walk(f func(ocfl.EntityRef) error) error {
 	}
 
 	// At this point, node points to an ocfl root, intermediate node, or an ocfl object root
-	err := fsWalk(startPath, func(ospath string, e *godirwalk.Dirent) (bool, error) {
+	err := fsWalk(startPath, s.symlinks, s.desired.Sorted, func(ospath string, e *godirwalk.Dirent) (bool, error) {
+
+		if err := s.ctx.Err(); err != nil {
+			return dontGoDeeper, err
+		}
 
 		// We don't' care about regular files
 		if !e.IsDir() && !e.IsSymlink() {
 			return dontGoDeeper, nil
 		}
 
+		// The storage root's extensions directory is not part of the object hierarchy
+		if ospath == filepath.Join(s.root.Addr, extensionsDir) {
+			return dontGoDeeper, nil
+		}
+
 		// An object?  If so, walk its manifest instead of the files under it
 		if objectRoot, _, err := isRoot(ospath, ocfl.Object); objectRoot && err == nil {
 
@@ -180,15 +224,40 @@ func (s *scope) walk(f func(ocfl.EntityRef) error) error {
 }
 
 // Walk the OCFL manifest
+//
+// Inventories are only fully parsed if versions or files are desired.  When only
+// objects are desired, just the object ID is read (or, if object paths are
+// reversible, derived from the object's path), and when only intermediate nodes
+// are desired, the inventory is not read at all.
 func (s *scope) walkObject(path string, f func(ocfl.EntityRef) error) (err error) {
 
-	inv, err := ReadInventory(path)
+	if s.desired.Type == ocfl.Intermediate {
+		return nil
+	}
+
+	var inv *metadata.Inventory
+	var id string
+	if s.desired.Type == ocfl.Object {
+		var derived bool
+		if id, derived = s.deriveID(path); !derived {
+			id, err = readInventoryID(path)
+		}
+	} else {
+		inv, err = s.readInventory(path)
+		if err == nil {
+			id = inv.ID
+		}
+	}
 	if err != nil {
 		return err
 	}
 
+	if !s.desired.MatchObject(id) {
+		return nil
+	}
+
 	object := ocfl.EntityRef{
-		ID:     inv.ID,
+		ID:     id,
 		Type:   ocfl.Object,
 		Parent: s.root,
 		Addr:   path,
@@ -208,11 +277,28 @@ func (s *scope) walkObject(path string, f func(ocfl.EntityRef) error) (err error
 	return nil
 }
 
+// Derives the ID of the object at the given path from the path itself, if
+// object paths are reversible
+func (s *scope) deriveID(path string) (string, bool) {
+	if s.objectPaths == nil {
+		return "", false
+	}
+
+	rel, err := filepath.Rel(s.root.Addr, path)
+	if err != nil {
+		return "", false
+	}
+
+	return fspath.Reverse(s.objectPaths, filepath.ToSlash(rel))
+}
+
 // Walk the versions in an OCFL manifest
 func (s *scope) walkVersions(inv *metadata.Inventory, object *ocfl.EntityRef, f func(ocfl.EntityRef) error) error {
-	versions := inv.Versions
+	for _, vID := range inv.VersionNames() {
 
-	for vID := range versions {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
 
 		if s.desired.Head && vID != inv.Head {
 			continue
@@ -233,10 +319,8 @@ func (s *scope) walkVersions(inv *metadata.Inventory, object *ocfl.EntityRef, f
 		}
 
 		if s.desired.Type <= ocfl.File {
-			files, _ := inv.Files(vID)
-
-			for _, file := range files {
-
+			var walkErr error
+			visit := func(file metadata.File) error {
 				fileRef := ocfl.EntityRef{
 					ID:     file.LogicalPath,
 					Type:   ocfl.File,
@@ -244,14 +328,37 @@ func (s *scope) walkVersions(inv *metadata.Inventory, object *ocfl.EntityRef, f
 					Addr:   filepath.Join(object.Addr, file.PhysicalPath),
 				}
 
-				if !s.contains(fileRef) {
-					continue
+				if !s.contains(fileRef) || !s.desired.MatchFile(file.LogicalPath) {
+					return nil
 				}
 
-				err := f(fileRef)
-				if err != nil {
-					return err
+				fileRef.Digest, _ = inv.DigestOf(file.PhysicalPath)
+
+				if s.desired.Sizes {
+					fileRef.Size = fileSize(fileRef.Addr)
 				}
+
+				walkErr = f(fileRef)
+				return walkErr
+			}
+
+			// Files whose content is missing from the manifest are skipped
+			if s.desired.Sorted {
+				files, _ := inv.Files(vID)
+				sort.Slice(files, func(i, j int) bool {
+					return files[i].LogicalPath < files[j].LogicalPath
+				})
+				for _, file := range files {
+					if visit(file) != nil {
+						break
+					}
+				}
+			} else {
+				_ = inv.FilesIter(vID, visit)
+			}
+
+			if walkErr != nil {
+				return walkErr
 			}
 		}
 	}
@@ -259,6 +366,15 @@ func (s *scope) walkVersions(inv *metadata.Inventory, object *ocfl.EntityRef, f
 	return nil
 }
 
+// Determines the size of a content file, or -1 if it cannot be determined (e.g. it is missing)
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return -1
+	}
+	return info.Size()
+}
+
 func (s scope) contains(entity ocfl.EntityRef) bool {
 	isUnderStart := s.startFrom.Type == ocfl.Root
 
@@ -294,7 +410,7 @@ func (skip) Error() string {
 // walked.  Any error will terminate a walk entirely.
 type fsCallback func(ospath string, e *godirwalk.Dirent) (terminal bool, err error)
 
-func fsWalk(dir string, f fsCallback) error {
+func fsWalk(dir string, symlinks SymlinkPolicy, sorted bool, f fsCallback) error {
 
 	if _, err := os.Stat(dir); err != nil {
 		return errors.Wrapf(err, "error walking directory %s", dir)
@@ -302,6 +418,16 @@ func fsWalk(dir string, f fsCallback) error {
 
 	return godirwalk.Walk(dir, &godirwalk.Options{
 		Callback: func(ospath string, dirent *godirwalk.Dirent) error {
+			if dirent.IsSymlink() && ospath != dir {
+				follow, err := symlinks.follow(ospath)
+				if err != nil {
+					return errors.Wrap(err, "terminating walk due to error")
+				}
+				if !follow {
+					return skip{godirwalk.SkipNode}
+				}
+			}
+
 			terminal, err := f(ospath, dirent)
 			if err != nil {
 				return errors.Wrap(err, "terminating walk due to error")
@@ -319,7 +445,7 @@ func fsWalk(dir string, f fsCallback) error {
 
 			return godirwalk.Halt
 		},
-		Unsorted:            true,
+		Unsorted:            !sorted,
 		FollowSymbolicLinks: true,
 	},
 	)
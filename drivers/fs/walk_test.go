@@ -1,14 +1,19 @@
 package fs_test
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
 	"testing"
 
 	"github.com/birkland/ocfl"
 	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/fspath"
 	"github.com/go-test/deep"
+	"github.com/pkg/errors"
 )
 
 // Test objects, 1 root, 4 objects, 12 versions, 20 files, 4 intermediate nodes
@@ -170,6 +175,7 @@ func TestWalkRefs(t *testing.T) {
 		Parent: &version,
 		Type:   ocfl.File,
 		Addr:   filepath.Join(object.Addr, "v1/content/1"),
+		Digest: "abc0",
 	}
 
 	// We're not doing an exhaustive search.  Just check that the expected sample
@@ -236,6 +242,32 @@ func TestWalkAbort(t *testing.T) {
 	}
 }
 
+// Make sure the walk aborts once its context is cancelled
+func TestWalkContextCancel(t *testing.T) {
+	root := root(t, testroot)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var count int
+	d := fs.Driver{}
+	err := d.WalkContext(ctx, ocfl.Select{}, func(ref ocfl.EntityRef) error {
+		count++
+		if ref.Type == ocfl.Object {
+			cancel()
+		}
+		return nil
+	}, root.Addr)
+
+	if errors.Cause(err) != context.Canceled {
+		t.Errorf("Expected a context cancellation error, got %+v", err)
+	}
+
+	if count >= TotalEntityCount {
+		t.Errorf("Got too many results, should have aborted sooner: %d", count)
+	}
+}
+
 // Make sure a path exists, fail if not.  Usually used to make sure the test is correct
 // i.e. if we're testing a path that is presumed to exist, make sure it does exist
 func assertExists(t *testing.T, path string) string {
@@ -266,3 +298,183 @@ func doWalk(t *testing.T, typ ocfl.Type, f func(ocfl.EntityRef) error, d fs.Driv
 		t.Error(err)
 	}
 }
+
+// Sorted walks visit entities in the same, lexical order every time
+func TestWalkSorted(t *testing.T) {
+	root := root(t, testroot)
+
+	walk := func() []string {
+		var visited []string
+		d := fs.Driver{}
+		err := d.Walk(ocfl.Select{Sorted: true}, func(ref ocfl.EntityRef) error {
+			visited = append(visited, ref.Type.String()+" "+ref.Addr+" "+ref.ID)
+			return nil
+		}, root.Addr)
+		if err != nil {
+			t.Fatalf("walk failed %+v", err)
+		}
+		return visited
+	}
+
+	first := walk()
+	if len(first) != TotalEntityCount {
+		t.Errorf("Expected %d entities, got %d", TotalEntityCount, len(first))
+	}
+
+	for i := 0; i < 5; i++ {
+		if diff := deep.Equal(first, walk()); diff != nil {
+			t.Fatalf("Sorted walks differ: %s", diff)
+		}
+	}
+}
+
+// Patterns in the selection restrict the entities visited
+func TestWalkPatterns(t *testing.T) {
+	root := root(t, testroot)
+
+	cases := map[string]struct {
+		desired  ocfl.Select
+		expected int
+	}{
+		"objectGlob":     {ocfl.Select{Type: ocfl.Object, ObjectID: "urn:/a/d/*"}, 2},
+		"objectVersions": {ocfl.Select{Type: ocfl.Version, ObjectID: "urn:/obj4"}, 3},
+		"objectFiles":    {ocfl.Select{Type: ocfl.File, ObjectID: "urn:/a/*/c/*"}, 5},
+		"pathGlob":       {ocfl.Select{Type: ocfl.File, LogicalPath: "obj1*.txt"}, 9},
+		"pathRegexp":     {ocfl.Select{Type: ocfl.File, PathRegexp: regexp.MustCompile(`-copy\.txt$`)}, 4},
+		"objectRegexp":   {ocfl.Select{Type: ocfl.Object, ObjectRegexp: regexp.MustCompile(`obj[34]$`)}, 2},
+		"recursiveGlob":  {ocfl.Select{Type: ocfl.Object, ObjectID: "urn:/**/obj3"}, 1},
+		"recursivePath":  {ocfl.Select{Type: ocfl.File, LogicalPath: "**/obj1*.txt"}, 9},
+		"noMatch":        {ocfl.Select{Type: ocfl.File, ObjectID: "nope", LogicalPath: "*"}, 0},
+		"headOnly":       {ocfl.Select{Type: ocfl.File, Head: true, ObjectID: "urn:/a/b/c/obj1"}, 2},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			var count int
+			d := fs.Driver{}
+			err := d.Walk(c.desired, func(ref ocfl.EntityRef) error {
+				count++
+				return nil
+			}, root.Addr)
+			if err != nil {
+				t.Fatalf("walk failed %+v", err)
+			}
+			if count != c.expected {
+				t.Errorf("Expected %d matches, got %d", c.expected, count)
+			}
+		})
+	}
+
+	d := fs.Driver{}
+	if err := d.Walk(ocfl.Select{ObjectID: "[bad"}, func(ocfl.EntityRef) error { return nil }, root.Addr); err == nil {
+		t.Errorf("Expected an error for a malformed pattern")
+	}
+}
+
+// Walking objects or intermediate nodes does not require fully parsing inventories
+func TestWalkSkipsInventoryParsing(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		_ = fs.MkRoot(ocflRoot)
+
+		// An inventory whose ID is readable, but which cannot otherwise be parsed
+		objDir := filepath.Join(ocflRoot, "a", "obj")
+		_ = os.MkdirAll(objDir, 0755)
+		_ = ioutil.WriteFile(filepath.Join(objDir, "0=ocfl_object_1.0"), []byte("ocfl_object_1.0\n"), 0664)
+		_ = ioutil.WriteFile(filepath.Join(objDir, "inventory.json"), []byte(`{"id": "test:obj", "versions": "bogus"}`), 0664)
+
+		d, _ := fs.NewDriver(fs.Config{Root: ocflRoot})
+
+		cases := map[ocfl.Type][]string{
+			ocfl.Object:       {"test:obj"},
+			ocfl.Intermediate: {"a"},
+		}
+
+		for typ, expected := range cases {
+			var found []string
+			err := d.Walk(ocfl.Select{Type: typ}, func(ref ocfl.EntityRef) error {
+				found = append(found, ref.ID)
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Walking %s failed: %+v", typ, err)
+			}
+			if diff := deep.Equal(found, expected); diff != nil {
+				t.Errorf("Walking %s: %s", typ, diff)
+			}
+		}
+
+		// Versions require the full inventory
+		err := d.Walk(ocfl.Select{Type: ocfl.Version}, func(ocfl.EntityRef) error { return nil })
+		if err == nil {
+			t.Errorf("Walking versions should have failed to parse the inventory")
+		}
+	})
+}
+
+// With reversible object paths, object IDs are derived from paths, without reading inventories
+func TestWalkReversiblePaths(t *testing.T) {
+	runInTempDir(t, func(ocflRoot string) {
+		_ = fs.MkRoot(ocflRoot)
+
+		// Objects whose inventories cannot be read at all
+		for _, dir := range []string{"test%3Aobj", "a"} {
+			objDir := filepath.Join(ocflRoot, dir)
+			_ = os.MkdirAll(objDir, 0755)
+			_ = ioutil.WriteFile(filepath.Join(objDir, "0=ocfl_object_1.0"), []byte("ocfl_object_1.0\n"), 0664)
+		}
+
+		d, _ := fs.NewDriver(fs.Config{Root: ocflRoot, ObjectPaths: fspath.QueryEscape{}})
+
+		var found []string
+		err := d.Walk(ocfl.Select{Type: ocfl.Object, Sorted: true}, func(ref ocfl.EntityRef) error {
+			found = append(found, ref.ID)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Walk failed: %+v", err)
+		}
+		if diff := deep.Equal(found, []string{"a", "test:obj"}); diff != nil {
+			t.Error(diff)
+		}
+
+		// Paths that the generator would not produce still require the inventory
+		_ = os.MkdirAll(filepath.Join(ocflRoot, "b c"), 0755)
+		_ = ioutil.WriteFile(filepath.Join(ocflRoot, "b c", "0=ocfl_object_1.0"), []byte("ocfl_object_1.0\n"), 0664)
+		err = d.Walk(ocfl.Select{Type: ocfl.Object}, func(ocfl.EntityRef) error { return nil })
+		if err == nil {
+			t.Errorf("Walking an object whose path is not reversible should have read its inventory")
+		}
+	})
+}
+
+// Files are given sizes only when they are selected
+func TestWalkSizes(t *testing.T) {
+	root := root(t, testroot)
+
+	d := fs.Driver{}
+	for _, sizes := range []bool{false, true} {
+		var count int
+		err := d.Walk(ocfl.Select{Sizes: sizes}, func(ref ocfl.EntityRef) error {
+			var expected int64
+			if sizes && ref.Type == ocfl.File {
+				info, err := os.Stat(ref.Addr)
+				if err != nil {
+					t.Fatalf("Could not stat %s: %+v", ref.Addr, err)
+				}
+				expected = info.Size()
+				count++
+			}
+			if ref.Size != expected {
+				t.Errorf("Expected size %d of %s %s, got %d", expected, ref.Type, ref.ID, ref.Size)
+			}
+			return nil
+		}, root.Addr)
+		if err != nil {
+			t.Fatalf("walk failed %+v", err)
+		}
+		if sizes && count != 20 {
+			t.Errorf("Expected 20 files, got %d", count)
+		}
+	}
+}
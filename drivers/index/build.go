@@ -0,0 +1,136 @@
+package index
+
+import (
+	"context"
+	"database/sql"
+	"os"
+
+	"github.com/birkland/ocfl"
+	"github.com/pkg/errors"
+
+	_ "github.com/mattn/go-sqlite3" // Registers the sqlite3 database driver
+)
+
+// Versions are numbered by seq, in the order they were visited, as walkers visit them
+// in order.  Files are keyed by the seq of their version.
+const schema = `
+CREATE TABLE root (
+	addr TEXT NOT NULL
+);
+CREATE TABLE objects (
+	id   TEXT PRIMARY KEY,
+	addr TEXT NOT NULL
+) WITHOUT ROWID;
+CREATE INDEX objects_addr ON objects (addr);
+CREATE TABLE versions (
+	object TEXT NOT NULL,
+	seq    INTEGER NOT NULL,
+	id     TEXT NOT NULL,
+	addr   TEXT NOT NULL,
+	head   INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (object, seq)
+) WITHOUT ROWID;
+CREATE UNIQUE INDEX versions_id ON versions (object, id);
+CREATE TABLE files (
+	object TEXT NOT NULL,
+	seq    INTEGER NOT NULL,
+	lpath  TEXT NOT NULL,
+	addr   TEXT NOT NULL,
+	size   INTEGER NOT NULL,
+	digest TEXT NOT NULL,
+	PRIMARY KEY (object, seq, lpath)
+) WITHOUT ROWID;
+`
+
+// Build indexes every object, version, and file visited by the given walker, and
+// writes the index to the given path, replacing any index already there.  The index
+// is written beside it, then renamed into place, so readers of the previous index are
+// not disturbed, and an interrupted build leaves the previous index as it was.
+func Build(ctx context.Context, path string, w ocfl.Walker) (err error) {
+	tmp := path + ".tmp"
+	if err = os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "could not remove stale index %s", tmp)
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	db, err := sql.Open("sqlite3", tmp)
+	if err != nil {
+		return errors.Wrapf(err, "could not create index %s", tmp)
+	}
+	defer db.Close()
+
+	if _, err = db.ExecContext(ctx, schema); err != nil {
+		return errors.Wrapf(err, "could not create index %s", tmp)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrapf(err, "could not write index %s", tmp)
+	}
+	defer tx.Rollback()
+
+	b := &builder{ctx: ctx, tx: tx}
+	if err = w.WalkContext(ctx, ocfl.Select{Sizes: true}, b.add); err != nil {
+		return errors.Wrapf(err, "could not index OCFL root")
+	}
+	if err = w.WalkContext(ctx, ocfl.Select{Type: ocfl.Version, Head: true}, b.head); err != nil {
+		return errors.Wrapf(err, "could not index head versions")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrapf(err, "could not write index %s", tmp)
+	}
+	if err = db.Close(); err != nil {
+		return errors.Wrapf(err, "could not write index %s", tmp)
+	}
+
+	return errors.Wrapf(os.Rename(tmp, path), "could not replace index %s", path)
+}
+
+// Inserts visited entities into the index
+type builder struct {
+	ctx     context.Context
+	tx      *sql.Tx
+	object  string // ID of the object last visited
+	version string // ID of the version last visited
+	seq     int    // seq of the version last visited
+}
+
+func (b *builder) add(ref ocfl.EntityRef) error {
+	var err error
+
+	switch ref.Type {
+	case ocfl.Root:
+		_, err = b.tx.ExecContext(b.ctx, "INSERT INTO root (addr) VALUES (?)", ref.Addr)
+	case ocfl.Object:
+		_, err = b.tx.ExecContext(b.ctx, "INSERT INTO objects (id, addr) VALUES (?, ?)", ref.ID, ref.Addr)
+	case ocfl.Version:
+		if ref.Parent.ID != b.object {
+			b.object, b.seq = ref.Parent.ID, 0
+		}
+		b.version, b.seq = ref.ID, b.seq+1
+		_, err = b.tx.ExecContext(b.ctx, "INSERT INTO versions (object, seq, id, addr) VALUES (?, ?, ?, ?)",
+			b.object, b.seq, ref.ID, ref.Addr)
+	case ocfl.File:
+		seq := b.seq
+		if ref.Parent.Parent.ID != b.object || ref.Parent.ID != b.version {
+			err = b.tx.QueryRowContext(b.ctx, "SELECT seq FROM versions WHERE object = ? AND id = ?",
+				ref.Parent.Parent.ID, ref.Parent.ID).Scan(&seq)
+		}
+		if err == nil {
+			_, err = b.tx.ExecContext(b.ctx, "INSERT INTO files (object, seq, lpath, addr, size, digest) VALUES (?, ?, ?, ?, ?, ?)",
+				ref.Parent.Parent.ID, seq, ref.ID, ref.Addr, ref.Size, string(ref.Digest))
+		}
+	}
+
+	return errors.Wrapf(err, "could not index %s %v", ref.Type, ref.Coords())
+}
+
+func (b *builder) head(ref ocfl.EntityRef) error {
+	_, err := b.tx.ExecContext(b.ctx, "UPDATE versions SET head = 1 WHERE object = ? AND id = ?", ref.Parent.ID, ref.ID)
+	return errors.Wrapf(err, "could not index head of %s", ref.Parent.ID)
+}
@@ -0,0 +1,13 @@
+// Package index contains a read only OCFL driver backed by a prebuilt SQLite index of
+// the objects, versions, and files of an OCFL root.  Listing or looking up entities in
+// the index is a matter of querying it, rather than of walking the disk and parsing
+// inventories, so takes milliseconds even for roots of many millions of objects.
+//
+// An index is built with Build, from any ocfl.Walker (typically a drivers/fs.Driver),
+// and reflects the root as it was then.  It may be rebuilt at any time, even while it
+// is being read; drivers read the rebuilt index from then on.  Entities are addressed
+// as the walker that built the index addressed them, so content may be read with the
+// driver that built it.
+//
+// The index is built with the github.com/mattn/go-sqlite3 driver, so requires cgo.
+package index
@@ -0,0 +1,354 @@
+package index
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+)
+
+// ErrReadOnly is returned when attempting to create or modify content through an index
+var ErrReadOnly = errors.New("the index is read only")
+
+// Config configures the index driver
+type Config struct {
+	Path string // Path of the index, as written by Build
+}
+
+// Driver reads OCFL entities from an index
+type Driver struct {
+	path string
+
+	mu   sync.Mutex
+	db   *sql.DB
+	info os.FileInfo // Of the index the database was opened from
+	root *ocfl.EntityRef
+}
+
+// NewDriver creates a driver reading the index at the given path.  If the index is
+// rebuilt, the driver reads the new index from the next walk or open on.  The driver
+// should be closed once it is no longer needed.
+func NewDriver(cfg Config) (*Driver, error) {
+	d := &Driver{path: cfg.Path}
+	if _, _, err := d.index(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Close releases the driver's connections to the index
+func (d *Driver) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.db.Close()
+}
+
+// Returns the database and root of the index, opening the index again if it has been
+// rebuilt since it was opened
+func (d *Driver) index() (*sql.DB, *ocfl.EntityRef, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "could not find index %s", d.path)
+	}
+	if d.db != nil && os.SameFile(info, d.info) {
+		return d.db, d.root, nil
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+(&url.URL{Path: d.path}).EscapedPath()+"?mode=ro")
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "could not open index %s", d.path)
+	}
+
+	root := &ocfl.EntityRef{Type: ocfl.Root}
+	err = db.QueryRow("SELECT addr FROM root").Scan(&root.Addr)
+	if err != nil && err != sql.ErrNoRows {
+		db.Close()
+		return nil, nil, errors.Wrapf(err, "could not read index %s", d.path)
+	}
+
+	// Walks of the previous index may still be in progress; closing waits for them
+	if prev := d.db; prev != nil {
+		go func() { _ = prev.Close() }()
+	}
+
+	d.db, d.info, d.root = db, info, root
+	return db, root, nil
+}
+
+// Walk visits the entities in the index.  Locations are given as for any ocfl.Walker:
+// nothing for the whole root, or the logical coordinates of an object, version, or
+// file.  A single location may also be the address of an object.
+//
+// Intermediate nodes are not indexed, so are not visited.  Entities are always visited
+// in lexical order of object IDs, then version order, then lexical order of logical
+// paths.  File sizes are those at the time the index was built.
+func (d *Driver) Walk(desired ocfl.Select, cb func(ocfl.EntityRef) error, loc ...string) error {
+	return d.WalkContext(context.Background(), desired, cb, loc...)
+}
+
+// WalkContext performs a Walk, terminating early with the context's error
+// if the given context is done before the walk completes.
+func (d *Driver) WalkContext(ctx context.Context, desired ocfl.Select, cb func(ocfl.EntityRef) error, loc ...string) error {
+	if err := desired.Validate(); err != nil {
+		return err
+	}
+
+	db, root, err := d.index()
+	if err != nil {
+		return err
+	}
+
+	w := walk{ctx: ctx, db: db, root: root, desired: desired, cb: cb, start: ocfl.Root}
+
+	if len(loc) > 0 {
+		var exists bool
+		err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM objects WHERE id = ? OR addr = ?)",
+			loc[0], loc[0]).Scan(&exists)
+		if err != nil {
+			return errors.Wrapf(err, "could not look up %s", loc[0])
+		}
+		if !exists {
+			return errors.Wrap(ocfl.ErrNotFound, loc[0])
+		}
+
+		w.loc = loc
+		w.start = ocfl.Type(ocfl.Object - ocfl.Type(len(loc)-1))
+	}
+
+	if w.visits(ocfl.Root) {
+		if err := cb(*root); err != nil {
+			return err
+		}
+	}
+
+	return w.walkObjects()
+}
+
+// Open opens a read only session on a version of an object in the index, which must
+// exist.  Sessions cannot create objects or versions, and refuse all writes with
+// ErrReadOnly.
+func (d *Driver) Open(id string, opts ocfl.Options) (ocfl.Session, error) {
+	return d.OpenContext(context.Background(), id, opts)
+}
+
+// OpenContext performs an Open, aborting if the given context is done before the
+// session is established.
+func (d *Driver) OpenContext(ctx context.Context, id string, opts ocfl.Options) (ocfl.Session, error) {
+	if opts.Version == ocfl.NEW {
+		return nil, errors.Wrapf(ErrReadOnly, "cannot create a new version of %s", id)
+	}
+
+	db, _, err := d.index()
+	if err != nil {
+		return nil, err
+	}
+
+	query, args := "SELECT id FROM versions WHERE object = ? AND head", []interface{}{id}
+	if opts.Version != ocfl.HEAD {
+		query, args = "SELECT id FROM versions WHERE object = ? AND id = ?", append(args, opts.Version)
+	}
+
+	var version string
+	err = db.QueryRowContext(ctx, query, args...).Scan(&version)
+	if err == sql.ErrNoRows {
+		var exists bool
+		if err = db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM objects WHERE id = ?)", id).Scan(&exists); err == nil {
+			if !exists && opts.Create {
+				return nil, errors.Wrapf(ErrReadOnly, "cannot create %s", id)
+			}
+			if !exists {
+				return nil, errors.Wrap(ocfl.ErrNotFound, id)
+			}
+			return nil, fmt.Errorf("%s has no version %s", id, opts.Version)
+		}
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not look up %s", id)
+	}
+
+	return session{}, nil
+}
+
+// Sessions are read only
+type session struct{}
+
+func (session) Put(string, io.Reader) error { return ErrReadOnly }
+
+func (session) PutContext(context.Context, string, io.Reader) error { return ErrReadOnly }
+
+func (session) Delete(string) error { return ErrReadOnly }
+
+func (session) Move(string, string) error { return ErrReadOnly }
+
+func (session) Commit(ocfl.CommitInfo) error { return ErrReadOnly }
+
+func (session) CommitContext(context.Context, ocfl.CommitInfo) error { return ErrReadOnly }
+
+func (session) Close() error { return nil }
+
+// A walk through the index, starting at the root or an entity within an object, given
+// by the logical coordinates in loc.
+type walk struct {
+	ctx     context.Context
+	db      *sql.DB
+	root    *ocfl.EntityRef
+	desired ocfl.Select
+	cb      func(ocfl.EntityRef) error
+	start   ocfl.Type // Type of the entity the walk starts from
+	loc     []string
+}
+
+// Determines whether an entity of the given type is visited
+func (w *walk) visits(t ocfl.Type) bool {
+	return (w.desired.Type == t || w.desired.Type == ocfl.Any) && t <= w.start
+}
+
+// Visits objects, and the versions and files within them, as selected.  All are read in
+// a single query, joining only the tables of the types selected.
+func (w *walk) walkObjects() error {
+	depth := w.desired.Type
+	if depth == ocfl.Any {
+		depth = ocfl.File
+	}
+	if depth > ocfl.Object {
+		return nil
+	}
+
+	var query strings.Builder
+	var args []interface{}
+
+	query.WriteString("SELECT o.id, o.addr")
+	if depth <= ocfl.Version {
+		query.WriteString(", v.id, v.addr")
+	}
+	if depth <= ocfl.File {
+		query.WriteString(", f.lpath, f.addr, f.size, f.digest")
+	}
+
+	query.WriteString(" FROM objects o")
+	if depth <= ocfl.Version {
+		query.WriteString(" LEFT JOIN versions v ON v.object = o.id")
+		if w.desired.Head {
+			query.WriteString(" AND v.head")
+		}
+		if len(w.loc) > 1 {
+			query.WriteString(" AND v.id = ?")
+			args = append(args, w.loc[1])
+		}
+	}
+	if depth <= ocfl.File {
+		query.WriteString(" LEFT JOIN files f ON f.object = v.object AND f.seq = v.seq")
+		if len(w.loc) > 2 {
+			query.WriteString(" AND f.lpath = ?")
+			args = append(args, w.loc[2])
+		}
+	}
+
+	if len(w.loc) > 0 {
+		query.WriteString(" WHERE (o.id = ? OR o.addr = ?)")
+		args = append(args, w.loc[0], w.loc[0])
+	} else if prefix := literalPrefix(w.desired.ObjectID); prefix != "" {
+		// Only objects whose IDs begin with the literal prefix of the pattern can match it
+		query.WriteString(" WHERE o.id >= ? AND o.id < ?")
+		args = append(args, prefix, prefix[:len(prefix)-1]+string([]byte{prefix[len(prefix)-1] + 1}))
+	}
+
+	query.WriteString(" ORDER BY o.id")
+	if depth <= ocfl.Version {
+		query.WriteString(", v.seq")
+	}
+	if depth <= ocfl.File {
+		query.WriteString(", f.lpath")
+	}
+
+	rows, err := w.db.QueryContext(w.ctx, query.String(), args...)
+	if err != nil {
+		return errors.Wrapf(err, "could not query index")
+	}
+	defer rows.Close()
+
+	columns := 8
+	switch depth {
+	case ocfl.Object:
+		columns = 2
+	case ocfl.Version:
+		columns = 4
+	}
+
+	var object, version *ocfl.EntityRef
+	for rows.Next() {
+		if err := w.ctx.Err(); err != nil {
+			return err
+		}
+
+		var o ocfl.EntityRef
+		var v, f struct {
+			id, addr, digest sql.NullString
+			size             sql.NullInt64
+		}
+		dest := []interface{}{&o.ID, &o.Addr, &v.id, &v.addr, &f.id, &f.addr, &f.size, &f.digest}
+		if err := rows.Scan(dest[:columns]...); err != nil {
+			return errors.Wrapf(err, "could not read index")
+		}
+
+		if object == nil || object.ID != o.ID {
+			object, version = &o, nil
+			object.Type, object.Parent = ocfl.Object, w.root
+			if w.desired.MatchObject(object.ID) && w.visits(ocfl.Object) {
+				if err := w.cb(*object); err != nil {
+					return err
+				}
+			}
+		}
+		if !w.desired.MatchObject(object.ID) || !v.id.Valid {
+			continue
+		}
+
+		if version == nil || version.ID != v.id.String {
+			version = &ocfl.EntityRef{ID: v.id.String, Addr: v.addr.String, Type: ocfl.Version, Parent: object}
+			if w.visits(ocfl.Version) {
+				if err := w.cb(*version); err != nil {
+					return err
+				}
+			}
+		}
+		if !f.id.Valid || !w.visits(ocfl.File) || !w.desired.MatchFile(f.id.String) {
+			continue
+		}
+
+		file := ocfl.EntityRef{
+			ID:     f.id.String,
+			Addr:   f.addr.String,
+			Type:   ocfl.File,
+			Parent: version,
+			Digest: metadata.Digest(f.digest.String),
+		}
+		if w.desired.Sizes {
+			file.Size = f.size.Int64
+		}
+		if err := w.cb(file); err != nil {
+			return err
+		}
+	}
+
+	return errors.Wrapf(rows.Err(), "could not read index")
+}
+
+// Returns the part of a glob pattern preceding its first special character
+func literalPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
@@ -0,0 +1,244 @@
+package index_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/drivers/index"
+	"github.com/birkland/ocfl/fspath"
+	"github.com/go-test/deep"
+	"github.com/pkg/errors"
+)
+
+func TestWalk(t *testing.T) {
+	runWithDrivers(t, func(fsDriver *fs.Driver, indexDriver *index.Driver) {
+		cases := []struct {
+			desired ocfl.Select
+			loc     []string
+		}{
+			{ocfl.Select{}, nil},
+			{ocfl.Select{Type: ocfl.Root}, nil},
+			{ocfl.Select{Type: ocfl.Object}, nil},
+			{ocfl.Select{Type: ocfl.Version}, nil},
+			{ocfl.Select{Type: ocfl.File, Head: true}, nil},
+			{ocfl.Select{Type: ocfl.Version, Head: true}, nil},
+			{ocfl.Select{Type: ocfl.File, LogicalPath: "dir/*"}, nil},
+			{ocfl.Select{Type: ocfl.Version, ObjectID: "urn:test/a"}, nil},
+			{ocfl.Select{Type: ocfl.Object, ObjectID: "urn:*/b"}, nil},
+			{ocfl.Select{Type: ocfl.File, ObjectID: "urn:test/*"}, nil},
+			{ocfl.Select{}, []string{"urn:test/b"}},
+			{ocfl.Select{}, []string{"urn:test/a", "v1"}},
+			{ocfl.Select{}, []string{"urn:test/a", "v2", "dir/b"}},
+			{ocfl.Select{Type: ocfl.File}, []string{"urn:test/a", "v3"}},
+		}
+
+		for _, c := range cases {
+			t.Run(fmt.Sprintf("%+v %s", c.desired, c.loc), func(t *testing.T) {
+				expected := walked(t, fsDriver, c.desired, c.loc)
+				found := walked(t, indexDriver, c.desired, c.loc)
+				if diff := deep.Equal(found, expected); diff != nil {
+					t.Error(diff)
+				}
+			})
+		}
+
+		err := indexDriver.Walk(ocfl.Select{}, func(ocfl.EntityRef) error { return nil }, "urn:test/missing")
+		if errors.Cause(err) != ocfl.ErrNotFound {
+			t.Errorf("expected walking a missing object to fail as not found, got %+v", err)
+		}
+	})
+}
+
+// Entities are addressed, and sized, as by the driver that built the index
+func TestWalkAddresses(t *testing.T) {
+	runWithDrivers(t, func(fsDriver *fs.Driver, indexDriver *index.Driver) {
+		refs := func(w ocfl.Walker, loc ...string) []ocfl.EntityRef {
+			var found []ocfl.EntityRef
+			err := w.Walk(ocfl.Select{Sizes: true, Sorted: true}, func(ref ocfl.EntityRef) error {
+				found = append(found, ref)
+				return nil
+			}, loc...)
+			if err != nil {
+				t.Fatalf("walk failed %+v", err)
+			}
+			return found
+		}
+
+		expected := refs(fsDriver, "urn:test/a")
+		if diff := deep.Equal(refs(indexDriver, "urn:test/a"), expected); diff != nil {
+			t.Error(diff)
+		}
+
+		// An object may also be given by its address
+		if diff := deep.Equal(refs(indexDriver, expected[0].Addr), expected); diff != nil {
+			t.Error(diff)
+		}
+	})
+}
+
+func TestWalkCancelled(t *testing.T) {
+	runWithDrivers(t, func(fsDriver *fs.Driver, indexDriver *index.Driver) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		var visited int
+		err := indexDriver.WalkContext(ctx, ocfl.Select{Type: ocfl.File}, func(ocfl.EntityRef) error {
+			visited++
+			cancel()
+			return nil
+		})
+		if errors.Cause(err) != context.Canceled {
+			t.Errorf("expected the walk to be cancelled, got %+v", err)
+		}
+		if visited != 1 {
+			t.Errorf("expected the walk to stop once cancelled, visited %d", visited)
+		}
+	})
+}
+
+func TestOpen(t *testing.T) {
+	runWithDrivers(t, func(fsDriver *fs.Driver, indexDriver *index.Driver) {
+		for _, version := range []string{ocfl.HEAD, "v1"} {
+			session, err := indexDriver.Open("urn:test/a", ocfl.Options{Version: version})
+			if err != nil {
+				t.Fatalf("could not open version '%s' %+v", version, err)
+			}
+			if err = session.Put("file", strings.NewReader("content")); errors.Cause(err) != index.ErrReadOnly {
+				t.Errorf("expected a put to be refused, got %+v", err)
+			}
+			if err = session.Commit(ocfl.CommitInfo{}); errors.Cause(err) != index.ErrReadOnly {
+				t.Errorf("expected a commit to be refused, got %+v", err)
+			}
+			if err = session.Close(); err != nil {
+				t.Error(err)
+			}
+		}
+
+		cases := map[string]struct {
+			id       string
+			opts     ocfl.Options
+			expected error
+		}{
+			"missing object":  {"urn:test/missing", ocfl.Options{}, ocfl.ErrNotFound},
+			"create object":   {"urn:test/missing", ocfl.Options{Create: true}, index.ErrReadOnly},
+			"new version":     {"urn:test/a", ocfl.Options{Version: ocfl.NEW}, index.ErrReadOnly},
+			"missing version": {"urn:test/a", ocfl.Options{Version: "v9"}, nil},
+		}
+		for name, c := range cases {
+			t.Run(name, func(t *testing.T) {
+				_, err := indexDriver.Open(c.id, c.opts)
+				if err == nil {
+					t.Fatal("expected open to fail")
+				}
+				if c.expected != nil && errors.Cause(err) != c.expected {
+					t.Errorf("expected %s, got %+v", c.expected, err)
+				}
+			})
+		}
+	})
+}
+
+// Rebuilding an index replaces it, without disturbing drivers reading it
+func TestRebuild(t *testing.T) {
+	runWithDrivers(t, func(fsDriver *fs.Driver, indexDriver *index.Driver) {
+		session, _ := fsDriver.Open("urn:test/c", ocfl.Options{Create: true, Version: ocfl.NEW})
+		_ = session.Put("d", strings.NewReader("d's content"))
+		if err := session.Commit(ocfl.CommitInfo{}); err != nil {
+			t.Fatalf("commit failed %+v", err)
+		}
+
+		var root string
+		_ = indexDriver.Walk(ocfl.Select{Type: ocfl.Root}, func(ref ocfl.EntityRef) error {
+			root = ref.Addr
+			return nil
+		})
+
+		path := filepath.Join(filepath.Dir(root), "index.db")
+		if err := index.Build(context.Background(), path, fsDriver); err != nil {
+			t.Fatalf("could not rebuild index %+v", err)
+		}
+
+		expected := walked(t, fsDriver, ocfl.Select{}, nil)
+		if diff := deep.Equal(walked(t, indexDriver, ocfl.Select{}, nil), expected); diff != nil {
+			t.Error(diff)
+		}
+
+		if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+			t.Errorf("expected no temporary index to remain, got %v", err)
+		}
+	})
+}
+
+// Describes each entity visited by a walk, other than intermediate nodes, sorted
+func walked(t *testing.T, w ocfl.Walker, desired ocfl.Select, loc []string) []string {
+	var found []string
+	err := w.Walk(desired, func(ref ocfl.EntityRef) error {
+		if ref.Type != ocfl.Intermediate {
+			found = append(found, fmt.Sprintf("%s %v %s %s", ref.Type, ref.Coords(), ref.Addr, ref.Digest))
+		}
+		return nil
+	}, loc...)
+	if err != nil {
+		t.Fatalf("walk failed %+v", err)
+	}
+	sort.Strings(found)
+	return found
+}
+
+// Creates objects in an OCFL root, and indexes them
+func runWithDrivers(t *testing.T, f func(*fs.Driver, *index.Driver)) {
+	dir, err := ioutil.TempDir("", "ocfl_index_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "root")
+	if err := fs.MkRoot(root); err != nil {
+		t.Fatalf("could not initialize ocfl root %+v", err)
+	}
+
+	fsDriver, err := fs.NewDriver(fs.Config{
+		Root:        root,
+		ObjectPaths: fspath.GeneratorFunc(url.QueryEscape),
+		FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
+	})
+	if err != nil {
+		t.Fatalf("Error setting up driver %+v", err)
+	}
+
+	put := func(id string, files map[string]string) {
+		session, _ := fsDriver.Open(id, ocfl.Options{Create: true, Version: ocfl.NEW})
+		for lpath, content := range files {
+			session.Put(lpath, strings.NewReader(content))
+		}
+		if err := session.Commit(ocfl.CommitInfo{}); err != nil {
+			t.Fatalf("commit failed %+v", err)
+		}
+	}
+	put("urn:test/a", map[string]string{"a": "a's content"})
+	put("urn:test/a", map[string]string{"dir/b": "b's content", "dir/c": "c's content"})
+	put("urn:test/a", map[string]string{})
+	put("urn:test/b", map[string]string{"c": "c's content"})
+
+	path := filepath.Join(dir, "index.db")
+	if err := index.Build(context.Background(), path, fsDriver); err != nil {
+		t.Fatalf("could not build index %+v", err)
+	}
+
+	indexDriver, err := index.NewDriver(index.Config{Path: path})
+	if err != nil {
+		t.Fatalf("Error setting up driver %+v", err)
+	}
+	defer indexDriver.Close()
+
+	f(fsDriver, indexDriver)
+}
@@ -0,0 +1,12 @@
+// Package iofs contains a read only OCFL driver whose backend is any io/fs.FS, e.g.
+// an embed.FS, an fstest.MapFS, or a zip.Reader.  This makes it simple to read OCFL
+// content from fixtures embedded in tests, from archives, or from custom virtual
+// filesystems.
+//
+// Entities are addressed by their slash separated paths within the filesystem, and
+// content is read with Driver.OpenFile.  Driver.Inventory and Driver.OpenFile may be
+// given to server.Options, so such content can be served as any other.
+//
+// Drivers that read each object from a filesystem of its own (e.g. the zip driver, with
+// one zip file per object) walk them with WalkObject.
+package iofs
@@ -0,0 +1,381 @@
+package iofs
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"sort"
+
+	"github.com/birkland/ocfl"
+	ocflfs "github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/fspath"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+)
+
+const (
+	ocflRoot       = "0=ocfl_1.0"
+	ocflObjectRoot = "0=ocfl_object_1.0"
+	extensionsDir  = "extensions"
+)
+
+// Config configures the io/fs driver
+type Config struct {
+	// ObjectPaths generates the paths of object roots, relative to the root of the
+	// filesystem.  By default, object IDs are query escaped.  If the root declares its
+	// storage layout (see fs.LayoutFile), the declared layout is used instead, as by
+	// fs.Driver.  Objects that are not where their paths are generated to be are
+	// searched for.
+	ObjectPaths fspath.Generator
+}
+
+// Driver reads OCFL content from an io/fs.FS, whose root is an OCFL root
+type Driver struct {
+	fsys fs.FS
+	root *ocfl.EntityRef
+	cfg  Config
+}
+
+// NewDriver creates a driver reading the OCFL root at the root of the given filesystem
+// (see fs.Sub for roots elsewhere within it)
+func NewDriver(fsys fs.FS, cfg Config) (*Driver, error) {
+	if _, err := fs.Stat(fsys, ocflRoot); err != nil {
+		return nil, errors.Wrapf(err, "the filesystem is not an OCFL root")
+	}
+
+	layout, err := ocflfs.ReadLayoutFS(fsys)
+	if err != nil {
+		return nil, err
+	}
+	if layout != nil {
+		if cfg.ObjectPaths, err = layout.GeneratorFS(fsys); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.ObjectPaths == nil {
+		cfg.ObjectPaths = fspath.GeneratorFunc(url.QueryEscape)
+	}
+
+	return &Driver{
+		fsys: fsys,
+		root: &ocfl.EntityRef{
+			Type: ocfl.Root,
+			Addr: ".",
+		},
+		cfg: cfg,
+	}, nil
+}
+
+// ObjectPath returns the path of the root of the object with the given ID
+func (d *Driver) ObjectPath(id string) string {
+	return path.Clean(d.cfg.ObjectPaths.Generate(id))
+}
+
+// Walk visits OCFL entities in the filesystem.  Locations are given as for any
+// ocfl.Walker: nothing for the whole root, or the logical coordinates of an object,
+// version, or file.  A single location may also be the path of an object root.
+// Entities are always visited in lexical order.
+func (d *Driver) Walk(desired ocfl.Select, cb func(ocfl.EntityRef) error, loc ...string) error {
+	return d.WalkContext(context.Background(), desired, cb, loc...)
+}
+
+// WalkContext performs a Walk, terminating early with the context's error
+// if the given context is done before the walk completes.
+func (d *Driver) WalkContext(ctx context.Context, desired ocfl.Select, cb func(ocfl.EntityRef) error, loc ...string) error {
+	if err := desired.Validate(); err != nil {
+		return err
+	}
+
+	w := walk{ctx: ctx, fsys: d.fsys, root: d.root, desired: desired, cb: cb}
+
+	if len(loc) == 0 {
+		return w.walkRoot()
+	}
+
+	objPath := d.ObjectPath(loc[0])
+	if fs.ValidPath(loc[0]) && isObject(d.fsys, loc[0]) {
+		objPath = loc[0]
+	}
+	if !isObject(d.fsys, objPath) {
+		var err error
+		if objPath, err = w.findObject(loc[0]); err != nil {
+			return err
+		}
+	}
+
+	return w.walkObject(objPath, loc[1:]...)
+}
+
+// Inventory reads the inventory of an object, as visited by Walk
+func (d *Driver) Inventory(obj ocfl.EntityRef) (*metadata.Inventory, error) {
+	return readInventory(d.fsys, obj.Addr)
+}
+
+// OpenFile opens a file within an object, given its entity as visited by Walk, i.e. a
+// logical file, or any other file addressed by its path within the filesystem.  The
+// returned file may be seeked, whether or not the filesystem supports it (see Open).
+func (d *Driver) OpenFile(file ocfl.EntityRef) (io.ReadSeekCloser, error) {
+	return Open(d.fsys, file.Addr)
+}
+
+func isObject(fsys fs.FS, dir string) bool {
+	_, err := fs.Stat(fsys, path.Join(dir, ocflObjectRoot))
+	return err == nil
+}
+
+func readInventory(fsys fs.FS, objPath string) (*metadata.Inventory, error) {
+	file, err := fsys.Open(path.Join(objPath, metadata.InventoryFile))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open inventory of %s", objPath)
+	}
+	defer file.Close()
+
+	inv := &metadata.Inventory{}
+	if err = metadata.Parse(file, inv); err != nil {
+		return nil, errors.Wrapf(err, "could not parse inventory of %s", objPath)
+	}
+	return inv, nil
+}
+
+// Object is an OCFL object whose root is the root of a filesystem of its own, such as
+// a zip file packaging it
+type Object struct {
+	FS        fs.FS
+	Inventory *metadata.Inventory // The object's inventory, if it has already been read
+	Parent    *ocfl.EntityRef     // The OCFL root containing the object
+	Addr      string              // Address of the object's entity
+
+	// Join joins the address of the object and the slash separated path of a version
+	// or file within it, giving the address of its entity.  By default, path.Join.
+	Join func(addr, name string) string
+}
+
+// WalkObject visits an object, and its versions and files, as selected, for drivers
+// that read objects from filesystems of their own.  A location, if given, is the version
+// and logical path of an entity within the object, as given to ocfl.Walker after the
+// object's ID.  Files are visited in lexical order of their logical paths.
+func WalkObject(ctx context.Context, obj Object, desired ocfl.Select, cb func(ocfl.EntityRef) error, loc ...string) error {
+	if desired.Type > ocfl.Object {
+		return nil
+	}
+
+	w := objectWalk{ctx: ctx, obj: obj, desired: desired, cb: cb, start: ocfl.Object - ocfl.Type(len(loc))}
+	if len(loc) > 0 {
+		w.version = loc[0]
+	}
+	if len(loc) > 1 {
+		w.lpath = loc[1]
+	}
+	if w.obj.Join == nil {
+		w.obj.Join = func(addr, name string) string { return path.Join(addr, name) }
+	}
+
+	return w.walkObject()
+}
+
+// A walk through the filesystem, visiting the root and the objects beneath it
+type walk struct {
+	ctx     context.Context
+	fsys    fs.FS
+	root    *ocfl.EntityRef
+	desired ocfl.Select
+	cb      func(ocfl.EntityRef) error
+}
+
+func (w *walk) walkRoot() error {
+	if w.desired.Type == ocfl.Root || w.desired.Type == ocfl.Any {
+		if err := w.cb(*w.root); err != nil {
+			return err
+		}
+	}
+
+	err := fs.WalkDir(w.fsys, ".", func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := w.ctx.Err(); err != nil {
+			return err
+		}
+		if !entry.IsDir() || p == "." {
+			return nil
+		}
+		if p == extensionsDir {
+			return fs.SkipDir
+		}
+
+		if isObject(w.fsys, p) {
+			if err := w.walkObject(p); err != nil {
+				return err
+			}
+			return fs.SkipDir
+		}
+
+		if w.desired.Type == ocfl.Intermediate || w.desired.Type == ocfl.Any {
+			return w.cb(ocfl.EntityRef{
+				ID:     p,
+				Addr:   p,
+				Type:   ocfl.Intermediate,
+				Parent: w.root,
+			})
+		}
+		return nil
+	})
+	return errors.Wrapf(err, "error performing walk")
+}
+
+// Walks the object at the given path, from the given location within it
+func (w *walk) walkObject(objPath string, loc ...string) error {
+	sub, err := fs.Sub(w.fsys, objPath)
+	if err != nil {
+		return errors.Wrapf(err, "could not read object %s", objPath)
+	}
+	return WalkObject(w.ctx, Object{FS: sub, Parent: w.root, Addr: objPath}, w.desired, w.cb, loc...)
+}
+
+// Searches the filesystem for the root of the object with the given ID
+func (w *walk) findObject(id string) (string, error) {
+	var found string
+	err := fs.WalkDir(w.fsys, ".", func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := w.ctx.Err(); err != nil {
+			return err
+		}
+		if p == extensionsDir {
+			return fs.SkipDir
+		}
+		if !entry.IsDir() || !isObject(w.fsys, p) {
+			return nil
+		}
+
+		if inv, err := readInventory(w.fsys, p); err == nil && inv.ID == id {
+			found = p
+			return fs.SkipAll
+		}
+		return fs.SkipDir
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "could not search for %s", id)
+	}
+	if found == "" {
+		return "", errors.Wrap(ocfl.ErrNotFound, id)
+	}
+	return found, nil
+}
+
+// A walk through a single object, starting at the object or an entity within it, given
+// by its version and logical path.
+type objectWalk struct {
+	ctx     context.Context
+	obj     Object
+	desired ocfl.Select
+	cb      func(ocfl.EntityRef) error
+
+	start   ocfl.Type // Type of the entity the walk starts from
+	version string
+	lpath   string
+}
+
+// Determines whether an entity of the given type is visited
+func (w *objectWalk) visits(t ocfl.Type) bool {
+	return (w.desired.Type == t || w.desired.Type == ocfl.Any) && t <= w.start
+}
+
+func (w *objectWalk) walkObject() error {
+	inv := w.obj.Inventory
+	if inv == nil {
+		var err error
+		if inv, err = readInventory(w.obj.FS, "."); err != nil {
+			return errors.Wrapf(err, "could not read object %s", w.obj.Addr)
+		}
+	}
+
+	if !w.desired.MatchObject(inv.ID) {
+		return nil
+	}
+
+	object := ocfl.EntityRef{
+		ID:     inv.ID,
+		Type:   ocfl.Object,
+		Parent: w.obj.Parent,
+		Addr:   w.obj.Addr,
+	}
+	if w.visits(ocfl.Object) {
+		if err := w.cb(object); err != nil {
+			return err
+		}
+	}
+
+	if w.desired.Type > ocfl.Version && w.desired.Type != ocfl.Any {
+		return nil
+	}
+
+	for _, vID := range inv.VersionNames() {
+		if err := w.ctx.Err(); err != nil {
+			return err
+		}
+		if (w.desired.Head && vID != inv.Head) || (w.version != "" && vID != w.version) {
+			continue
+		}
+
+		version := ocfl.EntityRef{
+			ID:     vID,
+			Type:   ocfl.Version,
+			Parent: &object,
+			Addr:   w.obj.Join(w.obj.Addr, vID),
+		}
+		if w.visits(ocfl.Version) {
+			if err := w.cb(version); err != nil {
+				return err
+			}
+		}
+
+		if w.visits(ocfl.File) {
+			if err := w.walkFiles(inv, &version); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (w *objectWalk) walkFiles(inv *metadata.Inventory, version *ocfl.EntityRef) error {
+	files, err := inv.Files(version.ID)
+	if err != nil {
+		return errors.Wrapf(err, "could not read files of %s %s", inv.ID, version.ID)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].LogicalPath < files[j].LogicalPath
+	})
+
+	for _, file := range files {
+		if (w.lpath != "" && file.LogicalPath != w.lpath) || !w.desired.MatchFile(file.LogicalPath) {
+			continue
+		}
+
+		ref := ocfl.EntityRef{
+			ID:     file.LogicalPath,
+			Type:   ocfl.File,
+			Parent: version,
+			Addr:   w.obj.Join(w.obj.Addr, file.PhysicalPath),
+		}
+		ref.Digest, _ = inv.DigestOf(file.PhysicalPath)
+
+		if w.desired.Sizes {
+			ref.Size = -1
+			if info, err := fs.Stat(w.obj.FS, file.PhysicalPath); err == nil {
+				ref.Size = info.Size()
+			}
+		}
+
+		if err := w.cb(ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package iofs_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	stdfs "io/fs"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/drivers/iofs"
+	"github.com/birkland/ocfl/fspath"
+	"github.com/go-test/deep"
+	"github.com/pkg/errors"
+)
+
+func TestWalk(t *testing.T) {
+	runWithDrivers(t, func(fsDriver *fs.Driver, fsysDriver *iofs.Driver) {
+		cases := []struct {
+			desired ocfl.Select
+			loc     []string
+		}{
+			{ocfl.Select{}, nil},
+			{ocfl.Select{Type: ocfl.Object}, nil},
+			{ocfl.Select{Type: ocfl.File, Head: true}, nil},
+			{ocfl.Select{Type: ocfl.File, LogicalPath: "dir/*"}, nil},
+			{ocfl.Select{Type: ocfl.Version, ObjectID: "urn:test/a"}, nil},
+			{ocfl.Select{}, []string{"urn:test/b"}},
+			{ocfl.Select{}, []string{"urn:test/a", "v1"}},
+			{ocfl.Select{}, []string{"urn:test/a", "v2", "dir/b"}},
+		}
+
+		for _, c := range cases {
+			t.Run(fmt.Sprintf("%+v %s", c.desired, c.loc), func(t *testing.T) {
+				expected := walked(t, fsDriver, c.desired, c.loc)
+				found := walked(t, fsysDriver, c.desired, c.loc)
+				if diff := deep.Equal(found, expected); diff != nil {
+					t.Error(diff)
+				}
+			})
+		}
+
+		err := fsysDriver.Walk(ocfl.Select{}, func(ocfl.EntityRef) error { return nil }, "urn:test/missing")
+		if errors.Cause(err) != ocfl.ErrNotFound {
+			t.Errorf("expected walking a missing object to fail as not found, got %+v", err)
+		}
+	})
+}
+
+func TestOpenFile(t *testing.T) {
+	runWithDrivers(t, func(fsDriver *fs.Driver, fsysDriver *iofs.Driver) {
+		var refs []ocfl.EntityRef
+		_ = fsysDriver.Walk(ocfl.Select{Type: ocfl.File, Sizes: true}, func(ref ocfl.EntityRef) error {
+			refs = append(refs, ref)
+			return nil
+		}, "urn:test/a", "v2", "dir/b")
+		if len(refs) != 1 {
+			t.Fatalf("expected one file, got %v", refs)
+		}
+		if refs[0].Size != 11 {
+			t.Errorf("wrong size %d", refs[0].Size)
+		}
+
+		f, err := fsysDriver.OpenFile(refs[0])
+		if err != nil {
+			t.Fatalf("could not open file %+v", err)
+		}
+		defer f.Close()
+
+		content, _ := ioutil.ReadAll(f)
+		if string(content) != "b's content" {
+			t.Errorf("wrong content %q", content)
+		}
+
+		if _, err = f.Seek(4, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		content, _ = ioutil.ReadAll(f)
+		if string(content) != "content" {
+			t.Errorf("wrong content after seeking %q", content)
+		}
+
+		if size, _ := f.Seek(0, io.SeekEnd); size != 11 {
+			t.Errorf("wrong size when seeking to the end %d", size)
+		}
+	})
+}
+
+func TestNotRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocfl_iofs_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err = iofs.NewDriver(os.DirFS(dir), iofs.Config{}); err == nil {
+		t.Error("expected a filesystem without an OCFL root to be rejected")
+	}
+}
+
+// Objects are found where the root's declared layout, with its config, places them
+func TestDeclaredLayout(t *testing.T) {
+	layout := &fspath.HashedNTuple{DigestAlgorithm: "md5", TupleSize: 2, NumberOfTuples: 2}
+	dir := rootWithObject(t, "urn:test/a", func(dir string) (fs.Config, error) {
+		return fs.Config{Root: dir, FilePaths: fspath.GeneratorFunc(fs.Passthrough)}, fs.InitRoot(dir, layout)
+	})
+	defer os.RemoveAll(dir)
+
+	fsysDriver, err := iofs.NewDriver(os.DirFS(dir), iofs.Config{})
+	if err != nil {
+		t.Fatalf("Error setting up driver %+v", err)
+	}
+
+	if path := fsysDriver.ObjectPath("urn:test/a"); path != layout.Generate("urn:test/a") {
+		t.Errorf("expected the declared layout to place the object, got %s", path)
+	}
+
+	found := walked(t, fsysDriver, ocfl.Select{Type: ocfl.File}, []string{"urn:test/a"})
+	if len(found) != 1 {
+		t.Errorf("expected to find the object's file, got %v", found)
+	}
+}
+
+// Objects that are not where their paths are generated to be are searched for
+func TestFindObject(t *testing.T) {
+	dir := rootWithObject(t, "urn:test/a", func(dir string) (fs.Config, error) {
+		return fs.Config{
+			Root:        dir,
+			ObjectPaths: fspath.GeneratorFunc(func(id string) string { return "objects/" + url.PathEscape(id) }),
+			FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
+		}, fs.MkRoot(dir)
+	})
+	defer os.RemoveAll(dir)
+
+	fsysDriver, err := iofs.NewDriver(os.DirFS(dir), iofs.Config{})
+	if err != nil {
+		t.Fatalf("Error setting up driver %+v", err)
+	}
+
+	found := walked(t, fsysDriver, ocfl.Select{Type: ocfl.File}, []string{"urn:test/a", "v1"})
+	if len(found) != 1 {
+		t.Errorf("expected to find the object's file, got %v", found)
+	}
+
+	err = fsysDriver.Walk(ocfl.Select{}, func(ocfl.EntityRef) error { return nil }, "urn:test/missing")
+	if errors.Cause(err) != ocfl.ErrNotFound {
+		t.Errorf("expected walking a missing object to fail as not found, got %+v", err)
+	}
+}
+
+// Initializes an OCFL root in a new directory, and writes an object with one file into it
+// with a driver configured by the given function
+func rootWithObject(t *testing.T, id string, setup func(dir string) (fs.Config, error)) string {
+	dir, err := ioutil.TempDir("", "ocfl_iofs_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := setup(dir)
+	if err != nil {
+		t.Fatalf("could not initialize ocfl root %+v", err)
+	}
+	driver, err := fs.NewDriver(cfg)
+	if err != nil {
+		t.Fatalf("Error setting up driver %+v", err)
+	}
+
+	session, _ := driver.Open(id, ocfl.Options{Create: true, Version: ocfl.NEW})
+	_ = session.Put("file", strings.NewReader("content"))
+	if err := session.Commit(ocfl.CommitInfo{}); err != nil {
+		t.Fatalf("commit failed %+v", err)
+	}
+	return dir
+}
+
+// Describes each entity visited by a walk, sorted
+func walked(t *testing.T, w ocfl.Walker, desired ocfl.Select, loc []string) []string {
+	var found []string
+	err := w.Walk(desired, func(ref ocfl.EntityRef) error {
+		found = append(found, fmt.Sprintf("%s %v %s", ref.Type, ref.Coords(), ref.Digest))
+		return nil
+	}, loc...)
+	if err != nil {
+		t.Fatalf("walk failed %+v", err)
+	}
+	sort.Strings(found)
+	return found
+}
+
+// Creates objects in an OCFL root, and runs the given function with drivers reading
+// the root directly, and from an io/fs filesystem: both the directory itself, and a
+// zip of it, whose files cannot be seeked
+func runWithDrivers(t *testing.T, f func(*fs.Driver, *iofs.Driver)) {
+	dir, err := ioutil.TempDir("", "ocfl_iofs_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := fs.MkRoot(dir); err != nil {
+		t.Fatalf("could not initialize ocfl root %+v", err)
+	}
+
+	fsDriver, err := fs.NewDriver(fs.Config{
+		Root:        dir,
+		ObjectPaths: fspath.GeneratorFunc(url.QueryEscape),
+		FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
+	})
+	if err != nil {
+		t.Fatalf("Error setting up driver %+v", err)
+	}
+
+	put := func(id string, files map[string]string) {
+		session, _ := fsDriver.Open(id, ocfl.Options{Create: true, Version: ocfl.NEW})
+		for lpath, content := range files {
+			session.Put(lpath, strings.NewReader(content))
+		}
+		if err := session.Commit(ocfl.CommitInfo{}); err != nil {
+			t.Fatalf("commit failed %+v", err)
+		}
+	}
+	put("urn:test/a", map[string]string{"a": "a's content"})
+	put("urn:test/a", map[string]string{"dir/b": "b's content", "dir/c": "c's content"})
+	put("urn:test/b", map[string]string{"c": "c's content"})
+
+	filesystems := map[string]stdfs.FS{
+		"dir": os.DirFS(dir),
+		"zip": zipOf(t, dir),
+	}
+
+	for name, fsys := range filesystems {
+		t.Run(name, func(t *testing.T) {
+			fsysDriver, err := iofs.NewDriver(fsys, iofs.Config{})
+			if err != nil {
+				t.Fatalf("Error setting up driver %+v", err)
+			}
+			f(fsDriver, fsysDriver)
+		})
+	}
+}
+
+// Zips the content of a directory into memory
+func zipOf(t *testing.T, dir string) *zip.Reader {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		dest, err := w.Create(filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		_, err = dest.Write(content)
+		return err
+	})
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		t.Fatalf("could not zip %s: %+v", dir, err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
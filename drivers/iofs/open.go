@@ -0,0 +1,101 @@
+package iofs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// Open opens a file of the filesystem for reading and seeking.  If the filesystem's
+// files do not support seeking (as those of a zip.Reader do not), seeking only records
+// the position, and content preceding the position sought is read again when reading
+// from it.
+func Open(fsys fs.FS, name string) (io.ReadSeekCloser, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if seeker, ok := file.(io.ReadSeekCloser); ok {
+		return seeker, nil
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, fmt.Errorf("cannot open %s: is a directory", name)
+	}
+
+	return &reopener{fsys: fsys, name: name, size: info.Size(), file: file}, nil
+}
+
+// reopener reads a file that does not support seeking, reopening it when reading from
+// a position before the last one read.
+type reopener struct {
+	fsys fs.FS
+	name string
+	size int64
+
+	file fs.File // The open file, if any
+	fpos int64   // Position of the open file
+	pos  int64   // Position to read from
+}
+
+func (r *reopener) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.file == nil || r.pos < r.fpos {
+		if r.file != nil {
+			r.file.Close()
+			r.file = nil
+		}
+		file, err := r.fsys.Open(r.name)
+		if err != nil {
+			return 0, err
+		}
+		r.file, r.fpos = file, 0
+	}
+
+	if r.pos > r.fpos {
+		n, err := io.CopyN(io.Discard, r.file, r.pos-r.fpos)
+		r.fpos += n
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Read(p)
+	r.fpos += int64(n)
+	r.pos = r.fpos
+	return n, err
+}
+
+func (r *reopener) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("cannot seek to negative position %d", offset)
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+func (r *reopener) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Close()
+}
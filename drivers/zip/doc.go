@@ -0,0 +1,11 @@
+// Package zip contains a read only OCFL driver for objects packaged as zip files,
+// one per object, beneath an OCFL root directory.  This layout is used for tape and
+// dark archive storage, where many small files are costly; such zip files are written
+// by fs.Driver.Pack (and ocfl pack).
+//
+// Objects are read from the central directory of each zip file, without extracting
+// them.  Entities within an object are addressed as if the zip file were a directory,
+// e.g. /root/obj.zip/v1/content/file.txt, and content is read with Driver.OpenFile.
+// Driver.Inventory and Driver.OpenFile may be given to server.Options, so zip packaged
+// objects can be served as any others.
+package zip
@@ -0,0 +1,212 @@
+package zip
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/iofs"
+	"github.com/birkland/ocfl/fspath"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+)
+
+// Extension is the file extension of zip packaged objects
+const Extension = ".zip"
+
+// Config configures the zip driver
+type Config struct {
+	Root string // OCFL root directory containing the zip packaged objects
+
+	// ObjectPaths generates the paths of objects' zip files, less their extension,
+	// relative to the root.  By default, object IDs are query escaped.
+	ObjectPaths fspath.Generator
+}
+
+// Driver reads OCFL objects packaged as zip files
+type Driver struct {
+	root *ocfl.EntityRef
+	cfg  Config
+}
+
+// NewDriver creates a driver reading the zip packaged objects beneath the given root
+func NewDriver(cfg Config) (*Driver, error) {
+	info, err := os.Stat(cfg.Root)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not find OCFL root %s", cfg.Root)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", cfg.Root)
+	}
+
+	if cfg.ObjectPaths == nil {
+		cfg.ObjectPaths = fspath.GeneratorFunc(url.QueryEscape)
+	}
+
+	return &Driver{
+		root: &ocfl.EntityRef{
+			Type: ocfl.Root,
+			Addr: cfg.Root,
+		},
+		cfg: cfg,
+	}, nil
+}
+
+// ObjectPath returns the path of the zip file of the object with the given ID
+func (d *Driver) ObjectPath(id string) string {
+	return filepath.Join(d.cfg.Root, filepath.FromSlash(d.cfg.ObjectPaths.Generate(id))) + Extension
+}
+
+// Walk visits the entities of zip packaged objects.  Locations are given as for any
+// ocfl.Walker: nothing for the whole root, or the logical coordinates of an object,
+// version, or file.  A single location may also be the path of an object's zip file.
+//
+// Intermediate nodes are not visited.  The sizes of files are always known, from the
+// zip central directory, so are provided whether or not they are selected.
+func (d *Driver) Walk(desired ocfl.Select, cb func(ocfl.EntityRef) error, loc ...string) error {
+	return d.WalkContext(context.Background(), desired, cb, loc...)
+}
+
+// WalkContext performs a Walk, terminating early with the context's error
+// if the given context is done before the walk completes.
+func (d *Driver) WalkContext(ctx context.Context, desired ocfl.Select, cb func(ocfl.EntityRef) error, loc ...string) error {
+	if err := desired.Validate(); err != nil {
+		return err
+	}
+
+	// Sizes are known from the zip central directory, so cost nothing to provide
+	desired.Sizes = true
+	w := walk{ctx: ctx, root: d.root, desired: desired, cb: cb}
+
+	if len(loc) == 0 {
+		return w.walkRoot()
+	}
+
+	zipPath := d.ObjectPath(loc[0])
+	if strings.HasSuffix(loc[0], Extension) {
+		if info, err := os.Stat(loc[0]); err == nil && !info.IsDir() {
+			zipPath = loc[0]
+		}
+	}
+	if _, err := os.Stat(zipPath); os.IsNotExist(err) {
+		return errors.Wrap(ocfl.ErrNotFound, loc[0])
+	}
+
+	return w.walkObject(zipPath, loc[1:]...)
+}
+
+// Inventory reads the inventory of a zip packaged object, as visited by Walk
+func (d *Driver) Inventory(obj ocfl.EntityRef) (*metadata.Inventory, error) {
+	o, err := openObject(obj.Addr)
+	if err != nil {
+		return nil, err
+	}
+	defer o.Close()
+	return o.inv, nil
+}
+
+// OpenFile opens a file within a zip packaged object, given its entity as visited by
+// Walk, i.e. a logical file, or any other file addressed by its path within the object.
+// The returned file may be seeked, but content preceding the position sought is
+// decompressed again (see iofs.Open).
+func (d *Driver) OpenFile(file ocfl.EntityRef) (io.ReadSeekCloser, error) {
+	obj := file.Parent
+	for obj != nil && obj.Type != ocfl.Object {
+		obj = obj.Parent
+	}
+	if obj == nil {
+		return nil, fmt.Errorf("cannot open %s: not within an object", file.Addr)
+	}
+
+	rel, err := filepath.Rel(obj.Addr, file.Addr)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return nil, fmt.Errorf("cannot open %s: not within %s", file.Addr, obj.Addr)
+	}
+
+	o, err := openObject(obj.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := o.files[filepath.ToSlash(rel)]; !ok {
+		o.Close()
+		return nil, errors.Wrapf(os.ErrNotExist, "no %s in %s", filepath.ToSlash(rel), obj.Addr)
+	}
+
+	f, err := iofs.Open(o, o.prefix+filepath.ToSlash(rel))
+	if err != nil {
+		o.Close()
+		return nil, errors.Wrapf(err, "could not open %s", file.Addr)
+	}
+	return &objectFile{ReadSeekCloser: f, object: o}, nil
+}
+
+// A walk through zip packaged objects, visiting the root and the objects beneath it
+type walk struct {
+	ctx     context.Context
+	root    *ocfl.EntityRef
+	desired ocfl.Select
+	cb      func(ocfl.EntityRef) error
+}
+
+func (w *walk) walkRoot() error {
+	if w.desired.Type == ocfl.Root || w.desired.Type == ocfl.Any {
+		if err := w.cb(*w.root); err != nil {
+			return err
+		}
+	}
+
+	// Zip files are walked in lexical order, so the walk is always sorted
+	extensions := filepath.Join(w.root.Addr, "extensions")
+	err := filepath.Walk(w.root.Addr, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := w.ctx.Err(); err != nil {
+			return err
+		}
+		if info.IsDir() && path == extensions {
+			return filepath.SkipDir
+		}
+		if info.Mode().IsRegular() && strings.HasSuffix(info.Name(), Extension) {
+			return w.walkObject(path)
+		}
+		return nil
+	})
+	return errors.Wrapf(err, "error walking %s", w.root.Addr)
+}
+
+// Walks the object packaged in the given zip file, from the given location within it
+func (w *walk) walkObject(zipPath string, loc ...string) error {
+	if w.desired.Type > ocfl.Object {
+		return nil
+	}
+
+	o, err := openObject(zipPath)
+	if err != nil {
+		return err
+	}
+	defer o.Close()
+
+	fsys, err := fs.Sub(o, path.Clean("./"+o.prefix))
+	if err != nil {
+		return errors.Wrapf(err, "could not read %s", zipPath)
+	}
+
+	return iofs.WalkObject(w.ctx, iofs.Object{
+		FS:        fsys,
+		Inventory: o.inv,
+		Parent:    w.root,
+		Addr:      zipPath,
+		Join: func(addr, name string) string {
+			return filepath.Join(addr, filepath.FromSlash(name))
+		},
+	}, w.desired, w.cb, loc...)
+}
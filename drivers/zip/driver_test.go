@@ -0,0 +1,197 @@
+package zip_test
+
+import (
+	stdzip "archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/drivers/zip"
+	"github.com/birkland/ocfl/fspath"
+	"github.com/go-test/deep"
+	"github.com/pkg/errors"
+)
+
+// Selections are tested with iofs, whose WalkObject walks each zip file
+func TestWalk(t *testing.T) {
+	runWithDrivers(t, func(fsDriver *fs.Driver, zipDriver *zip.Driver) {
+		cases := []struct {
+			desired ocfl.Select
+			loc     []string
+		}{
+			{ocfl.Select{}, nil},
+			{ocfl.Select{Type: ocfl.Version, Head: true}, nil},
+			{ocfl.Select{}, []string{"urn:test/b"}},
+			{ocfl.Select{}, []string{"urn:test/a", "v2", "dir/b"}},
+		}
+
+		for _, c := range cases {
+			t.Run(fmt.Sprintf("%+v %s", c.desired, c.loc), func(t *testing.T) {
+				expected := walked(t, fsDriver, c.desired, c.loc)
+				found := walked(t, zipDriver, c.desired, c.loc)
+				if diff := deep.Equal(found, expected); diff != nil {
+					t.Error(diff)
+				}
+			})
+		}
+
+		err := zipDriver.Walk(ocfl.Select{}, func(ocfl.EntityRef) error { return nil }, "urn:test/missing")
+		if errors.Cause(err) != ocfl.ErrNotFound {
+			t.Errorf("expected walking a missing object to fail as not found, got %+v", err)
+		}
+	})
+}
+
+// Files are sized from the zip central directory, whether or not sizes are selected,
+// and opened within their object's zip file
+func TestOpenFile(t *testing.T) {
+	runWithDrivers(t, func(fsDriver *fs.Driver, zipDriver *zip.Driver) {
+		var refs []ocfl.EntityRef
+		_ = zipDriver.Walk(ocfl.Select{Type: ocfl.File}, func(ref ocfl.EntityRef) error {
+			refs = append(refs, ref)
+			return nil
+		}, "urn:test/a", "v2", "dir/b")
+		if len(refs) != 1 {
+			t.Fatalf("expected one file, got %v", refs)
+		}
+		if refs[0].Size != 11 {
+			t.Errorf("wrong size %d", refs[0].Size)
+		}
+		if refs[0].Addr != filepath.Join(zipDriver.ObjectPath("urn:test/a"), "v2", "content", "dir", "b") {
+			t.Errorf("wrong address %s", refs[0].Addr)
+		}
+
+		f, err := zipDriver.OpenFile(refs[0])
+		if err != nil {
+			t.Fatalf("could not open file %+v", err)
+		}
+		defer f.Close()
+
+		content, _ := ioutil.ReadAll(f)
+		if string(content) != "b's content" {
+			t.Errorf("wrong content %q", content)
+		}
+	})
+}
+
+// Zip files whose object root is a directory within them can be read too
+func TestObjectInDirectory(t *testing.T) {
+	runWithDrivers(t, func(fsDriver *fs.Driver, zipDriver *zip.Driver) {
+		path := zipDriver.ObjectPath("urn:test/b")
+		src, err := stdzip.OpenReader(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer src.Close()
+
+		out, err := os.Create(filepath.Join(filepath.Dir(path), "nested.zip"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := stdzip.NewWriter(out)
+		for _, f := range src.File {
+			r, _ := f.Open()
+			dest, _ := w.Create("obj/" + f.Name)
+			_, _ = io.Copy(dest, r)
+			r.Close()
+		}
+		w.Close()
+		out.Close()
+
+		var files []string
+		err = zipDriver.Walk(ocfl.Select{Type: ocfl.File}, func(ref ocfl.EntityRef) error {
+			files = append(files, ref.ID)
+			f, err := zipDriver.OpenFile(ref)
+			if err != nil {
+				return err
+			}
+			return f.Close()
+		}, out.Name())
+		if err != nil {
+			t.Fatalf("walk failed %+v", err)
+		}
+		if diff := deep.Equal(files, []string{"c"}); diff != nil {
+			t.Error(diff)
+		}
+	})
+}
+
+// Describes each entity visited by a walk, sorted
+func walked(t *testing.T, w ocfl.Walker, desired ocfl.Select, loc []string) []string {
+	var found []string
+	err := w.Walk(desired, func(ref ocfl.EntityRef) error {
+		found = append(found, fmt.Sprintf("%s %v %s", ref.Type, ref.Coords(), ref.Digest))
+		return nil
+	}, loc...)
+	if err != nil {
+		t.Fatalf("walk failed %+v", err)
+	}
+	sort.Strings(found)
+	return found
+}
+
+// Creates objects in an OCFL root, and packs each into a zip file beneath another
+func runWithDrivers(t *testing.T, f func(*fs.Driver, *zip.Driver)) {
+	dir, err := ioutil.TempDir("", "ocfl_zip_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fsRoot, zipRoot := filepath.Join(dir, "fs"), filepath.Join(dir, "zip")
+	for _, d := range []string{fsRoot, zipRoot} {
+		if err := fs.MkRoot(d); err != nil {
+			t.Fatalf("could not initialize ocfl root %+v", err)
+		}
+	}
+
+	fsDriver, err := fs.NewDriver(fs.Config{
+		Root:        fsRoot,
+		ObjectPaths: fspath.GeneratorFunc(url.QueryEscape),
+		FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
+	})
+	if err != nil {
+		t.Fatalf("Error setting up driver %+v", err)
+	}
+
+	zipDriver, err := zip.NewDriver(zip.Config{Root: zipRoot})
+	if err != nil {
+		t.Fatalf("Error setting up driver %+v", err)
+	}
+
+	put := func(id string, files map[string]string) {
+		session, _ := fsDriver.Open(id, ocfl.Options{Create: true, Version: ocfl.NEW})
+		for lpath, content := range files {
+			session.Put(lpath, strings.NewReader(content))
+		}
+		if err := session.Commit(ocfl.CommitInfo{}); err != nil {
+			t.Fatalf("commit failed %+v", err)
+		}
+	}
+	put("urn:test/a", map[string]string{"a": "a's content"})
+	put("urn:test/a", map[string]string{"dir/b": "b's content", "dir/c": "c's content"})
+	put("urn:test/b", map[string]string{"c": "c's content"})
+
+	for _, id := range []string{"urn:test/a", "urn:test/b"} {
+		out, err := os.Create(zipDriver.ObjectPath(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = fsDriver.Pack(context.Background(), id, out, fs.PackOptions{Format: fs.ZipArchive})
+		out.Close()
+		if err != nil {
+			t.Fatalf("pack failed %+v", err)
+		}
+	}
+
+	f(fsDriver, zipDriver)
+}
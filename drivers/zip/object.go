@@ -0,0 +1,94 @@
+package zip
+
+import (
+	stdzip "archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+)
+
+// An open zip packaged object
+type object struct {
+	*stdzip.ReadCloser
+	inv    *metadata.Inventory
+	prefix string                  // Path of the object root within the zip file
+	files  map[string]*stdzip.File // Files, by path relative to the object root
+}
+
+// Opens the zip file of an object, and reads its inventory.  The object root is either
+// the top of the zip file, or a single directory within it.
+func openObject(zipPath string) (*object, error) {
+	z, err := stdzip.OpenReader(zipPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open %s", zipPath)
+	}
+
+	prefix, err := objectRoot(z.File)
+	if err != nil {
+		z.Close()
+		return nil, errors.Wrapf(err, "could not find the object root in %s", zipPath)
+	}
+
+	o := &object{ReadCloser: z, prefix: prefix, files: make(map[string]*stdzip.File, len(z.File))}
+	for _, f := range z.File {
+		if strings.HasPrefix(f.Name, prefix) && !strings.HasSuffix(f.Name, "/") {
+			o.files[strings.TrimPrefix(f.Name, prefix)] = f
+		}
+	}
+
+	r, err := o.files[metadata.InventoryFile].Open()
+	if err != nil {
+		z.Close()
+		return nil, errors.Wrapf(err, "could not open the inventory of %s", zipPath)
+	}
+	defer r.Close()
+
+	o.inv = &metadata.Inventory{}
+	if err = metadata.Parse(r, o.inv); err != nil {
+		z.Close()
+		return nil, errors.Wrapf(err, "could not parse the inventory of %s", zipPath)
+	}
+
+	return o, nil
+}
+
+// Finds the prefix of the object root within a zip file, i.e. the directory
+// containing the root inventory, if it is not at the top.
+func objectRoot(files []*stdzip.File) (string, error) {
+	var candidates []string
+	for _, f := range files {
+		dir, name := path.Split(f.Name)
+		if name != metadata.InventoryFile {
+			continue
+		}
+		if dir == "" {
+			return "", nil
+		}
+		if strings.Count(dir, "/") == 1 {
+			candidates = append(candidates, dir)
+		}
+	}
+
+	if len(candidates) != 1 {
+		return "", fmt.Errorf("expected one root inventory, found %d", len(candidates))
+	}
+	return candidates[0], nil
+}
+
+// A file within an object, which closes the object's zip file when closed
+type objectFile struct {
+	io.ReadSeekCloser
+	object *object
+}
+
+func (f *objectFile) Close() error {
+	err := f.ReadSeekCloser.Close()
+	if closeErr := f.object.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
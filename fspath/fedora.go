@@ -0,0 +1,76 @@
+package fspath
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FedoraPrefix is the prefix of the OCFL object IDs of Fedora resources
+const FedoraPrefix = "info:fedora"
+
+// Fedora is a Generator for roots shared with Fedora 6 repositories.  Fedora
+// stores each resource in an OCFL object whose ID is the resource's path
+// prefixed by FedoraPrefix, in the hashed n-tuple layout (with its default
+// parameters, unless configured otherwise).  IDs given to Generate may be
+// Fedora IDs, or paths relative to the Fedora base URI (e.g. "a/b", or
+// "/a/b"); either form is normalized by FedoraID, both before hashing and
+// (as a Normalizer) by drivers before recording IDs in inventories.
+//
+// Fedora declares the hashed n-tuple layout in its roots, so roots initialized
+// with this layout remain readable by Fedora and other OCFL clients.
+type Fedora struct {
+	HashedNTuple
+}
+
+// NewFedora creates a Fedora generator with Fedora 6's default parameters
+func NewFedora() *Fedora {
+	return &Fedora{HashedNTuple: *NewHashedNTuple()}
+}
+
+// Generate the object root path of the given Fedora ID or resource path
+func (f *Fedora) Generate(id string) string {
+	return f.HashedNTuple.Generate(FedoraID(id))
+}
+
+// ValidateID rejects resource paths that Fedora would not accept, i.e. those
+// with empty, "." or ".." segments.
+func (f *Fedora) ValidateID(id string) error {
+	path := strings.TrimPrefix(FedoraID(id), FedoraPrefix)
+	if path == "" {
+		return nil
+	}
+
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		switch segment {
+		case "", ".", "..":
+			return fmt.Errorf("'%s' is not a valid Fedora resource path", id)
+		}
+	}
+	return nil
+}
+
+// NormalizeID normalizes a Fedora ID or resource path to a Fedora ID (see FedoraID)
+func (f *Fedora) NormalizeID(id string) string {
+	return FedoraID(id)
+}
+
+// Description describes the layout's parameters
+func (f *Fedora) Description() string {
+	return "Fedora 6 " + f.HashedNTuple.Description()
+}
+
+// FedoraID normalizes a Fedora ID or resource path to a Fedora ID, by adding
+// FedoraPrefix if absent, and removing trailing solidi.  The ID of the Fedora
+// repository root is FedoraPrefix itself.
+func FedoraID(id string) string {
+	id = strings.TrimRight(id, "/")
+	if id == FedoraPrefix || strings.HasPrefix(id, FedoraPrefix+"/") {
+		return id
+	}
+
+	id = strings.TrimLeft(id, "/")
+	if id == "" {
+		return FedoraPrefix
+	}
+	return FedoraPrefix + "/" + id
+}
@@ -0,0 +1,47 @@
+package fspath_test
+
+import (
+	"testing"
+
+	"github.com/birkland/ocfl/fspath"
+)
+
+func TestFedoraID(t *testing.T) {
+	cases := map[string]string{
+		"":                   "info:fedora",
+		"/":                  "info:fedora",
+		"info:fedora":        "info:fedora",
+		"info:fedora/":       "info:fedora",
+		"a/b":                "info:fedora/a/b",
+		"/a/b/":              "info:fedora/a/b",
+		"info:fedora/a/b":    "info:fedora/a/b",
+		"info:fedoraxyz/a/b": "info:fedora/info:fedoraxyz/a/b",
+	}
+
+	for id, expected := range cases {
+		if normalized := fspath.FedoraID(id); normalized != expected {
+			t.Errorf("%s: expected %s, got %s", id, expected, normalized)
+		}
+	}
+}
+
+func TestFedora(t *testing.T) {
+	fedora := fspath.NewFedora()
+	hashed := fspath.NewHashedNTuple()
+
+	for _, id := range []string{"a/b", "/a/b", "info:fedora/a/b"} {
+		if path := fedora.Generate(id); path != hashed.Generate("info:fedora/a/b") {
+			t.Errorf("%s: expected the path of info:fedora/a/b, got %s", id, path)
+		}
+	}
+
+	if fedora.Extension() != fspath.HashedNTupleLayout {
+		t.Errorf("Fedora roots should declare the hashed n-tuple layout, not %s", fedora.Extension())
+	}
+
+	for id, invalid := range map[string]bool{"a/b": false, "info:fedora": false, "a//b": true, "a/../b": true} {
+		if err := fedora.ValidateID(id); (err != nil) != invalid {
+			t.Errorf("%s: expected invalid: %t, got %v", id, invalid, err)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package fspath
 
+import "net/url"
+
 // Generator generates a relative, solidus delimited file path
 // from a given identifier.  The resulting paths may be used for mapping
 // OCFL object identifiers to ocfl object root directories (possibly
@@ -16,3 +18,49 @@ type GeneratorFunc func(string) string
 func (g GeneratorFunc) Generate(id string) string {
 	return g(id)
 }
+
+// Reverser is implemented by Generators whose paths can be mapped back to the
+// identifiers they were generated from.  Reverse returns false if the given
+// relative, solidus delimited path is not of the form the generator produces.
+// Use the Reverse function to also verify that the identifier generates the path.
+type Reverser interface {
+	Reverse(path string) (id string, ok bool)
+}
+
+// Reverse maps a path back to an identifier using the given generator, if it
+// is a Reverser.  The identifier is only returned if it generates the same
+// path, so that paths produced by some other scheme are never misinterpreted.
+func Reverse(g Generator, path string) (string, bool) {
+	r, reversible := g.(Reverser)
+	if !reversible {
+		return "", false
+	}
+
+	id, ok := r.Reverse(path)
+	if !ok || g.Generate(id) != path {
+		return "", false
+	}
+	return id, true
+}
+
+// Normalizer is implemented by Generators that accept several forms of the same
+// identifier.  NormalizeID returns the canonical form, which is the identifier
+// recorded in an object's inventory.
+type Normalizer interface {
+	NormalizeID(id string) string
+}
+
+// QueryEscape is a reversible Generator that query escapes identifiers, so that
+// each identifier maps to a single directory name.
+type QueryEscape struct{}
+
+// Generate a query escaped path from the given id
+func (QueryEscape) Generate(id string) string {
+	return url.QueryEscape(id)
+}
+
+// Reverse a query escaped path
+func (QueryEscape) Reverse(path string) (string, bool) {
+	id, err := url.QueryUnescape(path)
+	return id, err == nil
+}
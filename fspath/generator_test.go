@@ -27,3 +27,37 @@ func ExampleGeneratorFunc() {
 	fmt.Println(pathgen.Generate("foo:bar"))
 	// Output: foo%3Abar
 }
+
+func TestReverse(t *testing.T) {
+	hashAndID, _ := fspath.NewLayout(fspath.HashAndIDNTupleLayout, nil)
+	hashed, _ := fspath.NewLayout(fspath.HashedNTupleLayout, nil)
+
+	cases := []struct {
+		name string
+		gen  fspath.Generator
+		path string
+		id   string
+		ok   bool
+	}{
+		{"queryEscape", fspath.QueryEscape{}, "urn%3Aa%2Fb", "urn:a/b", true},
+		{"queryEscapeNotGenerated", fspath.QueryEscape{}, "a b", "", false},
+		{"queryEscapeBadEscape", fspath.QueryEscape{}, "a%zz", "", false},
+		{"flatDirect", fspath.FlatDirect{}, "object-01", "object-01", true},
+		{"flatDirectNested", fspath.FlatDirect{}, "a/b", "", false},
+		{"hashAndID", hashAndID, "487/326/d8c/%2e%2ehor%2frib%3ale-%24id", "..hor/rib:le-$id", true},
+		{"hashAndIDWrongTuples", hashAndID, "000/326/d8c/%2e%2ehor%2frib%3ale-%24id", "", false},
+		{"hashAndIDTooShallow", hashAndID, "487/326/%2e%2ehor%2frib%3ale-%24id", "", false},
+		{"hashed", hashed, "3c0/ff4/240/3c0ff4240c1e116dba14c7627f2319b58aa3d77606d0d90dfc6161608ac987d4", "", false},
+		{"func", fspath.GeneratorFunc(url.QueryEscape), "foo%3Abar", "", false},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			id, ok := fspath.Reverse(c.gen, c.path)
+			if id != c.id || ok != c.ok {
+				t.Errorf("Expected (%s, %t), got (%s, %t)", c.id, c.ok, id, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package fspath
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+)
+
+// Names of the OCFL storage layout extensions implemented in this package
+const (
+	FlatDirectLayout      = "0002-flat-direct-storage-layout"
+	HashAndIDNTupleLayout = "0003-hash-and-id-n-tuple-storage-layout"
+	HashedNTupleLayout    = "0004-hashed-n-tuple-storage-layout"
+)
+
+// maxEncodedIDLength is the longest encapsulation directory name of the hash and
+// ID n-tuple layout, before it is truncated and suffixed with the ID's digest
+const maxEncodedIDLength = 100
+
+// maxNameLength is the longest directory name, in bytes, that layouts may generate
+const maxNameLength = 255
+
+// Layout is a Generator implementing an OCFL storage layout extension.  Its
+// parameters are serialized as the extension's JSON config.
+type Layout interface {
+	Generator
+	Extension() string   // Name of the extension, e.g. 0004-hashed-n-tuple-storage-layout
+	Description() string // Brief human readable description of the layout
+}
+
+// IDValidator is implemented by Generators that cannot generate a valid path
+// from every ID.  Such IDs must be rejected, rather than given to Generate.
+type IDValidator interface {
+	ValidateID(id string) error
+}
+
+// FlatDirect is a Generator implementing the OCFL flat direct storage layout
+// (extension 0002).  Object roots are directories directly under the storage
+// root, named by the object ID itself.  IDs that cannot form a single directory
+// name (e.g. those containing a solidus) are rejected by ValidateID.
+type FlatDirect struct{}
+
+// Generate the object root path of the given ID, which is the ID itself
+func (FlatDirect) Generate(id string) string {
+	return id
+}
+
+// ValidateID rejects IDs that are not usable as a directory name
+func (FlatDirect) ValidateID(id string) error {
+	switch {
+	case id == "", id == ".", id == "..":
+		return fmt.Errorf("id '%s' is not a valid directory name", id)
+	case strings.ContainsAny(id, "/\\\x00"):
+		return fmt.Errorf("id '%s' contains a path separator or NUL character", id)
+	case len(id) > maxNameLength:
+		return fmt.Errorf("id '%s' is longer than %d bytes", id, maxNameLength)
+	}
+	return nil
+}
+
+// Reverse maps a path to the ID it was generated from, which is the path itself
+func (f FlatDirect) Reverse(path string) (string, bool) {
+	return path, f.ValidateID(path) == nil
+}
+
+// Extension returns the name of the flat direct layout extension
+func (FlatDirect) Extension() string {
+	return FlatDirectLayout
+}
+
+// Description describes the layout
+func (FlatDirect) Description() string {
+	return "Flat direct layout, with object roots named by their ID"
+}
+
+// HashedNTuple is a Generator implementing the OCFL hashed n-tuple storage layout
+// (extension 0004).  Object IDs are digested, and the leading characters of the
+// hex digest form numberOfTuples directories of tupleSize characters each, beneath
+// which the object root is named by the full digest (or, if ShortObjectRoot is true,
+// the remainder of the digest not used in the tuples).
+type HashedNTuple struct {
+	DigestAlgorithm string `json:"digestAlgorithm"`
+	TupleSize       int    `json:"tupleSize"`
+	NumberOfTuples  int    `json:"numberOfTuples"`
+	ShortObjectRoot bool   `json:"shortObjectRoot"`
+}
+
+// NewHashedNTuple creates a hashed n-tuple generator with the extension's default
+// parameters: sha256 digests, and three tuples of three characters.
+func NewHashedNTuple() *HashedNTuple {
+	return &HashedNTuple{
+		DigestAlgorithm: "sha256",
+		TupleSize:       3,
+		NumberOfTuples:  3,
+	}
+}
+
+// Generate the object root path of the given ID
+func (h *HashedNTuple) Generate(id string) string {
+	digest := hexDigest(h.DigestAlgorithm, id)
+	segments := tuples(digest, h.TupleSize, h.NumberOfTuples)
+
+	if h.ShortObjectRoot {
+		segments = append(segments, digest[h.NumberOfTuples*h.TupleSize:])
+	} else {
+		segments = append(segments, digest)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// Extension returns the name of the hashed n-tuple layout extension
+func (h *HashedNTuple) Extension() string {
+	return HashedNTupleLayout
+}
+
+// Description describes the layout's parameters
+func (h *HashedNTuple) Description() string {
+	return fmt.Sprintf("Hashed (%s) n-tuple layout, with %d tuples of size %d",
+		h.DigestAlgorithm, h.NumberOfTuples, h.TupleSize)
+}
+
+func (h *HashedNTuple) validate() error {
+	digestLen, err := validateTuples(h.DigestAlgorithm, h.TupleSize, h.NumberOfTuples)
+	if err != nil {
+		return err
+	}
+	if h.ShortObjectRoot && h.TupleSize*h.NumberOfTuples == digestLen {
+		return fmt.Errorf("a short object root requires tuples shorter than the digest")
+	}
+	return nil
+}
+
+// HashAndIDNTuple is a Generator implementing the OCFL hashed truncated n-tuple
+// trees with object ID encapsulating directory layout (extension 0003).  Like
+// HashedNTuple, the leading characters of the hex digest of an object ID form
+// a tree of directories.  The object root beneath them is named by the ID itself,
+// with every byte other than ASCII letters, digits, '-' and '_' percent encoded.
+// Encoded IDs longer than 100 characters are truncated, and suffixed with '-'
+// and the ID's digest.
+type HashAndIDNTuple struct {
+	DigestAlgorithm string `json:"digestAlgorithm"`
+	TupleSize       int    `json:"tupleSize"`
+	NumberOfTuples  int    `json:"numberOfTuples"`
+}
+
+// NewHashAndIDNTuple creates a hash and ID n-tuple generator with the extension's
+// default parameters: sha256 digests, and three tuples of three characters.
+func NewHashAndIDNTuple() *HashAndIDNTuple {
+	return &HashAndIDNTuple{
+		DigestAlgorithm: "sha256",
+		TupleSize:       3,
+		NumberOfTuples:  3,
+	}
+}
+
+// Generate the object root path of the given ID
+func (h *HashAndIDNTuple) Generate(id string) string {
+	digest := hexDigest(h.DigestAlgorithm, id)
+
+	encoded := encodeID(id)
+	if len(encoded) > maxEncodedIDLength {
+		encoded = encoded[:maxEncodedIDLength] + "-" + digest
+	}
+
+	return strings.Join(append(tuples(digest, h.TupleSize, h.NumberOfTuples), encoded), "/")
+}
+
+// Reverse maps a path to the ID it was generated from by decoding its encapsulation
+// directory.  IDs whose encapsulation directory was truncated cannot be recovered.
+func (h *HashAndIDNTuple) Reverse(path string) (string, bool) {
+	segments := strings.Split(path, "/")
+	if len(segments) != h.NumberOfTuples+1 {
+		return "", false
+	}
+
+	encoded := segments[len(segments)-1]
+	if len(encoded) > maxEncodedIDLength {
+		return "", false
+	}
+
+	id, err := url.PathUnescape(encoded)
+	return id, err == nil
+}
+
+// Extension returns the name of the hash and ID n-tuple layout extension
+func (h *HashAndIDNTuple) Extension() string {
+	return HashAndIDNTupleLayout
+}
+
+// Description describes the layout's parameters
+func (h *HashAndIDNTuple) Description() string {
+	return fmt.Sprintf("Hashed (%s) n-tuple layout with ID encapsulation, with %d tuples of size %d",
+		h.DigestAlgorithm, h.NumberOfTuples, h.TupleSize)
+}
+
+func (h *HashAndIDNTuple) validate() error {
+	_, err := validateTuples(h.DigestAlgorithm, h.TupleSize, h.NumberOfTuples)
+	return err
+}
+
+// Percent encodes every byte of the given ID, other than ASCII letters, digits, '-' and '_',
+// using lower case hex digits.
+func encodeID(id string) string {
+	var encoded strings.Builder
+	for _, b := range []byte(id) {
+		switch {
+		case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9', b == '-', b == '_':
+			encoded.WriteByte(b)
+		default:
+			fmt.Fprintf(&encoded, "%%%02x", b)
+		}
+	}
+	return encoded.String()
+}
+
+// Computes the lower case hex digest of the given ID
+func hexDigest(alg, id string) string {
+	hash, _ := newHash(alg)
+	_, _ = hash.Write([]byte(id))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Splits the leading characters of a digest into n tuples of the given size
+func tuples(digest string, size, n int) []string {
+	var segments []string
+	for i := 0; i < n; i++ {
+		segments = append(segments, digest[i*size:(i+1)*size])
+	}
+	return segments
+}
+
+// Validates the parameters of n-tuple layouts, returning the length of a hex digest
+func validateTuples(alg string, size, n int) (int, error) {
+	hash, err := newHash(alg)
+	if err != nil {
+		return 0, err
+	}
+	if size < 0 || n < 0 {
+		return 0, fmt.Errorf("tuple size and number of tuples must not be negative")
+	}
+	if (size == 0) != (n == 0) {
+		return 0, fmt.Errorf("tuple size and number of tuples must both be zero, or both be positive")
+	}
+
+	digestLen := hex.EncodedLen(hash.Size())
+	if size*n > digestLen {
+		return 0, fmt.Errorf("%d tuples of size %d exceed the length of a %s digest", n, size, alg)
+	}
+	return digestLen, nil
+}
+
+func newHash(alg string) (hash.Hash, error) {
+	hash, ok := metadata.NewHash(metadata.DigestAlgorithm(alg))
+	if !ok {
+		return nil, fmt.Errorf("unsupported digest algorithm %s", alg)
+	}
+	return hash, nil
+}
+
+// LayoutConstructor creates a Layout from the content of its extension's
+// config.json.  Parameters absent from the config (or an empty config) should
+// take the extension's default values.
+type LayoutConstructor func(config []byte) (Layout, error)
+
+var layouts = struct {
+	sync.RWMutex
+	constructors map[string]LayoutConstructor
+}{
+	constructors: map[string]LayoutConstructor{
+		FlatDirectLayout: func(config []byte) (Layout, error) {
+			var layout FlatDirect
+			return layout, parseConfig(config, &layout)
+		},
+		HashAndIDNTupleLayout: func(config []byte) (Layout, error) {
+			layout := NewHashAndIDNTuple()
+			if err := parseConfig(config, layout); err != nil {
+				return nil, err
+			}
+			return layout, layout.validate()
+		},
+		TemplateLayout: func(config []byte) (Layout, error) {
+			var layout Template
+			if err := parseConfig(config, &layout); err != nil {
+				return nil, err
+			}
+			return &layout, layout.parse()
+		},
+		HashedNTupleLayout: func(config []byte) (Layout, error) {
+			layout := NewHashedNTuple()
+			if err := parseConfig(config, layout); err != nil {
+				return nil, err
+			}
+			return layout, layout.validate()
+		},
+	},
+}
+
+// RegisterLayout registers the constructor of a storage layout extension by name,
+// so that roots declaring it may be used.  Registering a name again replaces its
+// constructor.
+func RegisterLayout(extension string, constructor LayoutConstructor) {
+	layouts.Lock()
+	defer layouts.Unlock()
+	layouts.constructors[extension] = constructor
+}
+
+// Layouts lists the names of registered storage layout extensions, in order
+func Layouts() []string {
+	layouts.RLock()
+	defer layouts.RUnlock()
+
+	var names []string
+	for name := range layouts.constructors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewLayout creates a Layout for the named OCFL storage layout extension,
+// configured by the given extension config.json content, using the
+// constructor registered for the extension.
+func NewLayout(extension string, config []byte) (Layout, error) {
+	layouts.RLock()
+	constructor, ok := layouts.constructors[extension]
+	layouts.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported storage layout %s", extension)
+	}
+
+	layout, err := constructor(config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s config", extension)
+	}
+	return layout, nil
+}
+
+func parseConfig(config []byte, into interface{}) error {
+	if len(strings.TrimSpace(string(config))) == 0 {
+		return nil
+	}
+	return json.Unmarshal(config, into)
+}
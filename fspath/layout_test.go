@@ -0,0 +1,165 @@
+package fspath_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl/fspath"
+)
+
+// Examples from the 0004-hashed-n-tuple-storage-layout extension
+func TestHashedNTuple(t *testing.T) {
+	cases := []struct {
+		name   string
+		config string
+		id     string
+		path   string
+	}{
+		{"defaults", "", "object-01",
+			"3c0/ff4/240/3c0ff4240c1e116dba14c7627f2319b58aa3d77606d0d90dfc6161608ac987d4"},
+		{"defaultsSpecialChars", "{}", "..hor/rib:le-$id",
+			"487/326/d8c/487326d8c2a3c0b885e23da1469b4d6671fd4e76978924b4443e9e3c316cda6d"},
+		{"shortRoot", `{"digestAlgorithm": "md5", "tupleSize": 2, "numberOfTuples": 15, "shortObjectRoot": true}`,
+			"object-01", "ff/75/53/44/92/48/5e/ab/b3/9f/86/35/67/28/88/4e"},
+		{"noTuples", `{"tupleSize": 0, "numberOfTuples": 0}`, "object-01",
+			"3c0ff4240c1e116dba14c7627f2319b58aa3d77606d0d90dfc6161608ac987d4"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			gen, err := fspath.NewLayout(fspath.HashedNTupleLayout, []byte(c.config))
+			if err != nil {
+				t.Fatalf("could not create layout %+v", err)
+			}
+
+			if path := gen.Generate(c.id); path != c.path {
+				t.Errorf("Expected %s, got %s", c.path, path)
+			}
+		})
+	}
+}
+
+func TestBadLayouts(t *testing.T) {
+	cases := map[string]struct {
+		extension string
+		config    string
+	}{
+		"unknownExtension": {"9999-no-such-layout", ""},
+		"badJSON":          {fspath.HashedNTupleLayout, "{"},
+		"badAlgorithm":     {fspath.HashedNTupleLayout, `{"digestAlgorithm": "crc32"}`},
+		"tooManyTuples":    {fspath.HashedNTupleLayout, `{"tupleSize": 8, "numberOfTuples": 9}`},
+		"mismatchedZero":   {fspath.HashedNTupleLayout, `{"tupleSize": 0}`},
+		"idTooManyTuples":  {fspath.HashAndIDNTupleLayout, `{"digestAlgorithm": "md5", "tupleSize": 4, "numberOfTuples": 9}`},
+		"noShortRoot": {fspath.HashedNTupleLayout,
+			`{"digestAlgorithm": "md5", "tupleSize": 4, "numberOfTuples": 8, "shortObjectRoot": true}`},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			if _, err := fspath.NewLayout(c.extension, []byte(c.config)); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
+
+func TestFlatDirect(t *testing.T) {
+	cases := []struct {
+		id      string
+		invalid bool
+	}{
+		{"object-01", false},
+		{"urn:example:obj", false},
+		{"..hor_rib:le-$id", false},
+		{"..hor/rib:le-$id", true},
+		{"a\\b", true},
+		{"..", true},
+		{"", true},
+		{strings.Repeat("x", 256), true},
+	}
+
+	gen, err := fspath.NewLayout(fspath.FlatDirectLayout, nil)
+	if err != nil {
+		t.Fatalf("could not create layout %+v", err)
+	}
+
+	for _, c := range cases {
+		err := gen.(fspath.IDValidator).ValidateID(c.id)
+		if (err != nil) != c.invalid {
+			t.Errorf("%s: expected invalid: %t, got error %v", c.id, c.invalid, err)
+		}
+		if !c.invalid && gen.Generate(c.id) != c.id {
+			t.Errorf("Expected %s, got %s", c.id, gen.Generate(c.id))
+		}
+	}
+}
+
+// Examples from the 0003-hash-and-id-n-tuple-storage-layout extension
+func TestHashAndIDNTuple(t *testing.T) {
+	cases := []struct {
+		name   string
+		config string
+		id     string
+		path   string
+	}{
+		{"defaults", "", "object-01", "3c0/ff4/240/object-01"},
+		{"defaultsSpecialChars", "", "..hor/rib:le-$id", "487/326/d8c/%2e%2ehor%2frib%3ale-%24id"},
+		{"truncated", "", "۵ݨݯژښڙڜڛڝڠڱݰݣݫۯ۞ۆݰ",
+			"72d/744/ab2/%db%b5%dd%a8%dd%af%da%98%da%9a%da%99%da%9c%da%9b%da%9d%da%a0%da%b1%dd%b0%dd%a3%dd%ab%db%af%db%9e%db%-" +
+				"72d744ab28e696afd14423026efe0ca8954e8f1b3fd21e86f06e89375b4de005"},
+		{"md5", `{"digestAlgorithm": "md5", "tupleSize": 2, "numberOfTuples": 15}`, "object-01",
+			"ff/75/53/44/92/48/5e/ab/b3/9f/86/35/67/28/88/object-01"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			gen, err := fspath.NewLayout(fspath.HashAndIDNTupleLayout, []byte(c.config))
+			if err != nil {
+				t.Fatalf("could not create layout %+v", err)
+			}
+
+			if path := gen.Generate(c.id); path != c.path {
+				t.Errorf("Expected %s, got %s", c.path, path)
+			}
+		})
+	}
+}
+
+type prefixed struct {
+	Prefix string `json:"prefix"`
+}
+
+func (p prefixed) Generate(id string) string { return p.Prefix + "/" + id }
+func (p prefixed) Extension() string         { return "test-prefixed-layout" }
+func (p prefixed) Description() string       { return "IDs under a prefix" }
+
+func TestRegisterLayout(t *testing.T) {
+	fspath.RegisterLayout("test-prefixed-layout", func(config []byte) (fspath.Layout, error) {
+		var layout prefixed
+		return layout, json.Unmarshal(config, &layout)
+	})
+
+	found := false
+	for _, name := range fspath.Layouts() {
+		found = found || name == "test-prefixed-layout"
+	}
+	if !found {
+		t.Errorf("registered layout is not listed in %v", fspath.Layouts())
+	}
+
+	gen, err := fspath.NewLayout("test-prefixed-layout", []byte(`{"prefix": "objects"}`))
+	if err != nil {
+		t.Fatalf("could not create layout %+v", err)
+	}
+	if path := gen.Generate("obj"); path != "objects/obj" {
+		t.Errorf("Expected objects/obj, got %s", path)
+	}
+
+	if _, err := fspath.NewLayout("test-prefixed-layout", []byte(`{`)); err == nil {
+		t.Errorf("Expected an error from the registered constructor")
+	}
+}
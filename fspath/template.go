@@ -0,0 +1,125 @@
+package fspath
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// TemplateLayout is the name of the storage layout implemented by Template.  It
+// is not an OCFL extension, so other OCFL clients will not understand roots declaring it.
+const TemplateLayout = "template-storage-layout"
+
+// Template is a Generator whose paths are produced by executing a text/template
+// with the ID as its data (i.e. "{{.}}" is the ID).  In addition to the template
+// package's builtins, templates may use:
+//
+//	md5, sha1, sha256, sha512 ID  - lower case hex digest of a string
+//	substr START END S            - characters START to END of a string, clamped to its length
+//	tuples SIZE N S               - the first N tuples of SIZE characters of a string, delimited by solidi
+//	escape S                      - query escaping (e.g. "a/b c" becomes "a%2Fb+c")
+//	pathEscape S                  - path segment escaping (e.g. "a/b c" becomes "a%2Fb%20c")
+//	encode S                      - percent encoding of all but letters, digits, '-' and '_'
+//	lower S, upper S              - case conversion
+//
+// For example, "{{sha256 . | tuples 2 3}}/{{encode .}}" places objects in a tree
+// of three two character tuples of the ID's sha256 digest, in directories named by
+// the encoded ID.
+type Template struct {
+	Text string `json:"template"`
+
+	tmpl *template.Template
+}
+
+// NewTemplate parses the given template text into a Generator
+func NewTemplate(text string) (*Template, error) {
+	t := &Template{Text: text}
+	return t, t.parse()
+}
+
+// Generate the path of the given ID by executing the template.  Generates the
+// empty string if execution fails; use ValidateID to find out why.
+func (t *Template) Generate(id string) string {
+	path, _ := t.execute(id)
+	return path
+}
+
+// ValidateID rejects IDs for which the template fails, or generates an empty
+// path or a path with empty, "." or ".." segments
+func (t *Template) ValidateID(id string) error {
+	path, err := t.execute(id)
+	if err != nil {
+		return err
+	}
+
+	for _, segment := range strings.Split(path, "/") {
+		switch segment {
+		case "", ".", "..":
+			return fmt.Errorf("template generated invalid path '%s' for id '%s'", path, id)
+		}
+	}
+	return nil
+}
+
+// Extension returns the name of the template layout
+func (t *Template) Extension() string {
+	return TemplateLayout
+}
+
+// Description describes the layout's template
+func (t *Template) Description() string {
+	return "Layout generated by the template " + t.Text
+}
+
+func (t *Template) parse() error {
+	if t.Text == "" {
+		return fmt.Errorf("no template given")
+	}
+
+	tmpl, err := template.New("path").Option("missingkey=error").Funcs(templateFuncs).Parse(t.Text)
+	if err != nil {
+		return err
+	}
+	t.tmpl = tmpl
+	return nil
+}
+
+func (t *Template) execute(id string) (string, error) {
+	var path strings.Builder
+	if err := t.tmpl.Execute(&path, id); err != nil {
+		return "", fmt.Errorf("could not generate a path for id '%s': %s", id, err)
+	}
+	return path.String(), nil
+}
+
+var templateFuncs = template.FuncMap{
+	"md5":        func(s string) string { return hexDigest("md5", s) },
+	"sha1":       func(s string) string { return hexDigest("sha1", s) },
+	"sha256":     func(s string) string { return hexDigest("sha256", s) },
+	"sha512":     func(s string) string { return hexDigest("sha512", s) },
+	"substr":     substr,
+	"tuples":     templateTuples,
+	"escape":     url.QueryEscape,
+	"pathEscape": url.PathEscape,
+	"encode":     encodeID,
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+}
+
+// The first n tuples of the given size of a string, delimited by solidi.  Stops
+// early if the string is too short.
+func templateTuples(size, n int, s string) string {
+	if size <= 0 {
+		return ""
+	}
+	return strings.Join(tuples(s, size, min(n, len(s)/size)), "/")
+}
+
+// Characters start to end of a string, clamped to its length
+func substr(start, end int, s string) string {
+	runes := []rune(s)
+	start = max(0, min(start, len(runes)))
+	end = max(start, min(end, len(runes)))
+	return string(runes[start:end])
+}
@@ -0,0 +1,61 @@
+package fspath_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/birkland/ocfl/fspath"
+)
+
+func TestTemplate(t *testing.T) {
+	cases := []struct {
+		name     string
+		template string
+		id       string
+		path     string
+	}{
+		{"identity", "{{.}}", "object-01", "object-01"},
+		{"hashedTuples", "{{sha256 . | tuples 3 3}}/{{sha256 .}}", "object-01",
+			"3c0/ff4/240/3c0ff4240c1e116dba14c7627f2319b58aa3d77606d0d90dfc6161608ac987d4"},
+		{"hashAndID", "{{md5 . | tuples 2 2}}/{{encode .}}", "..hor/rib:le-$id", "08/31/%2e%2ehor%2frib%3ale-%24id"},
+		{"tooFewTuples", "{{tuples 2 3 .}}", "abcde", "ab/cd"},
+		{"substr", "{{substr 0 3 .}}/{{substr 3 100 .}}", "abcdefg", "abc/defg"},
+		{"escape", "{{escape .}}", "a/b c", "a%2Fb+c"},
+		{"pathEscape", "{{pathEscape .}}", "a/b c", "a%2Fb%20c"},
+		{"case", "{{lower .}}-{{upper .}}", "AbC", "abc-ABC"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			config, _ := json.Marshal(map[string]string{"template": c.template})
+			gen, err := fspath.NewLayout(fspath.TemplateLayout, config)
+			if err != nil {
+				t.Fatalf("could not create layout %+v", err)
+			}
+
+			if path := gen.Generate(c.id); path != c.path {
+				t.Errorf("Expected %s, got %s", c.path, path)
+			}
+		})
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	if _, err := fspath.NewTemplate(""); err == nil {
+		t.Errorf("an empty template should be rejected")
+	}
+	if _, err := fspath.NewTemplate("{{sha256 ."); err == nil {
+		t.Errorf("an unparseable template should be rejected")
+	}
+	if _, err := fspath.NewTemplate("{{nosuchfunc .}}"); err == nil {
+		t.Errorf("a template with an unknown function should be rejected")
+	}
+
+	gen, _ := fspath.NewTemplate("{{substr 0 2 .}}/{{substr 2 4 .}}")
+	for id, invalid := range map[string]bool{"abcd": false, "ab": true, "": true, "..cd": true} {
+		if err := gen.ValidateID(id); (err != nil) != invalid {
+			t.Errorf("%s: expected invalid: %t, got %v", id, invalid, err)
+		}
+	}
+}
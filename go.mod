@@ -1,9 +1,14 @@
 module github.com/birkland/ocfl
 
+go 1.22
+
 require (
 	github.com/go-test/deep v1.0.4
 	github.com/karrick/godirwalk v1.13.0
 	github.com/pkg/errors v0.8.1
 	github.com/urfave/cli v1.20.0
 	golang.org/x/sync v0.0.0-20181221193216-37e7f081c4d4
+	golang.org/x/sys v0.15.0
 )
+
+require github.com/mattn/go-sqlite3 v1.14.33
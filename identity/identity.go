@@ -0,0 +1,141 @@
+// Package identity contains ocfl.IdentityProvider implementations, which supply
+// default user names and addresses for commits when none are given explicitly.
+package identity
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/birkland/ocfl"
+	"github.com/pkg/errors"
+)
+
+// Static is an identity provider that always provides the same, configured identity.
+type Static ocfl.Identity
+
+// Identity returns the static identity
+func (s Static) Identity() (ocfl.Identity, error) {
+	return ocfl.Identity(s), nil
+}
+
+// OSUser provides the identity of the user running the current process.
+// The name is the full name of the user if known, and the address is of
+// the form username@hostname
+type OSUser struct{}
+
+// Identity looks up the current OS user
+func (OSUser) Identity() (ocfl.Identity, error) {
+	var id ocfl.Identity
+	var login string
+
+	sysUser, err := user.Current()
+	if err == nil {
+		id.Name = sysUser.Name
+		login = sysUser.Username
+	}
+
+	// Last ditch, on Windows
+	if id.Name == "" {
+		id.Name, _ = os.LookupEnv("USERNAME")
+	}
+	if login == "" {
+		login = id.Name
+	}
+
+	host, _ := os.Hostname()
+	if login != "" && host != "" {
+		id.Address = login + "@" + host
+	}
+
+	return id, nil
+}
+
+// OIDCToken provides an identity from the claims of an OpenID Connect ID token.
+//
+// The token signature is NOT verified; it is presumed that the token
+// was obtained from a trusted source.  The name is taken from the "name" claim,
+// falling back to "preferred_username" or "sub".  The address is taken from the "email" claim,
+// falling back to the "iss" and "sub" claims joined by a solidus.
+type OIDCToken string
+
+// Identity decodes the claims of the ID token
+func (t OIDCToken) Identity() (ocfl.Identity, error) {
+	parts := strings.Split(strings.TrimSpace(string(t)), ".")
+	if len(parts) != 3 {
+		return ocfl.Identity{}, fmt.Errorf("malformed ID token: expected 3 parts, got %d", len(parts))
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return ocfl.Identity{}, errors.Wrapf(err, "could not decode ID token payload")
+	}
+
+	var claims struct {
+		Name              string `json:"name"`
+		PreferredUsername string `json:"preferred_username"`
+		Email             string `json:"email"`
+		Subject           string `json:"sub"`
+		Issuer            string `json:"iss"`
+	}
+
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return ocfl.Identity{}, errors.Wrapf(err, "could not parse ID token claims")
+	}
+
+	id := ocfl.Identity{
+		Name:    firstOf(claims.Name, claims.PreferredUsername, claims.Subject),
+		Address: claims.Email,
+	}
+
+	if id.Address == "" && claims.Subject != "" {
+		id.Address = strings.TrimRight(claims.Issuer, "/") + "/" + claims.Subject
+	}
+
+	return id, nil
+}
+
+// Chain consults each provider in order, using the first non-empty
+// value for each identity field.  Errors are returned only if no provider
+// produced a complete identity.
+type Chain []ocfl.IdentityProvider
+
+// Identity merges the identities of all providers in the chain
+func (c Chain) Identity() (ocfl.Identity, error) {
+	var id ocfl.Identity
+	var errs []string
+
+	for _, p := range c {
+		if id.Name != "" && id.Address != "" {
+			break
+		}
+
+		next, err := p.Identity()
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+
+		id.Name = firstOf(id.Name, next.Name)
+		id.Address = firstOf(id.Address, next.Address)
+	}
+
+	if len(errs) > 0 && (id.Name == "" || id.Address == "") {
+		return id, fmt.Errorf("could not determine identity: %s", strings.Join(errs, "; "))
+	}
+
+	return id, nil
+}
+
+func firstOf(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
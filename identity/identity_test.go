@@ -0,0 +1,97 @@
+package identity_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/identity"
+	"github.com/go-test/deep"
+)
+
+func TestOIDCToken(t *testing.T) {
+	token := func(claims string) identity.OIDCToken {
+		return identity.OIDCToken("eyJhbGciOiJub25lIn0." +
+			base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".sig")
+	}
+
+	cases := []struct {
+		name     string
+		token    identity.OIDCToken
+		expected ocfl.Identity
+		isErr    bool
+	}{
+		{"nameAndEmail", token(`{"name": "Jo Doe", "email": "jo@example.org"}`),
+			ocfl.Identity{Name: "Jo Doe", Address: "jo@example.org"}, false},
+		{"preferredUsername", token(`{"preferred_username": "jdoe", "email": "jo@example.org"}`),
+			ocfl.Identity{Name: "jdoe", Address: "jo@example.org"}, false},
+		{"subjectOnly", token(`{"sub": "1234", "iss": "https://id.example.org/"}`),
+			ocfl.Identity{Name: "1234", Address: "https://id.example.org/1234"}, false},
+		{"notAToken", identity.OIDCToken("foo"), ocfl.Identity{}, true},
+		{"badClaims", token(`not json`), ocfl.Identity{}, true},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			id, err := c.token.Identity()
+			if (err != nil) != c.isErr {
+				t.Fatalf("expected error: %t, got %v", c.isErr, err)
+			}
+
+			if diffs := deep.Equal(c.expected, id); !c.isErr && len(diffs) > 0 {
+				t.Errorf("%s", diffs)
+			}
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	failing := identity.OIDCToken("bad")
+
+	cases := []struct {
+		name     string
+		chain    identity.Chain
+		expected ocfl.Identity
+		isErr    bool
+	}{
+		{"merged", identity.Chain{
+			identity.Static{Name: "first"},
+			identity.Static{Name: "second", Address: "second@"},
+		}, ocfl.Identity{Name: "first", Address: "second@"}, false},
+		{"errorIgnored", identity.Chain{
+			failing,
+			identity.Static{Name: "name", Address: "address"},
+		}, ocfl.Identity{Name: "name", Address: "address"}, false},
+		{"incompleteWithError", identity.Chain{
+			identity.Static{Name: "name"},
+			failing,
+		}, ocfl.Identity{Name: "name"}, true},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			id, err := c.chain.Identity()
+			if (err != nil) != c.isErr {
+				t.Fatalf("expected error: %t, got %v", c.isErr, err)
+			}
+
+			if diffs := deep.Equal(c.expected, id); len(diffs) > 0 {
+				t.Errorf("%s", diffs)
+			}
+		})
+	}
+}
+
+// Explicit values in the commit info are preferred over provided defaults
+func ExampleCommitInfo_WithDefaults() {
+	commit, _ := ocfl.CommitInfo{Name: "explicit"}.WithDefaults(identity.Static{
+		Name:    "default",
+		Address: "default@example.org",
+	})
+
+	fmt.Println(commit.Name, commit.Address)
+	// Output: explicit default@example.org
+}
@@ -0,0 +1,141 @@
+package metadata
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Code is a validation code defined by the OCFL spec (see
+// https://ocfl.io/1.0/spec/validation-codes.html).  Error codes (E001...)
+// identify violations of MUST requirements, and warning codes (W001...)
+// of SHOULD requirements, so that reports line up with those of other OCFL
+// validators.
+type Code string
+
+// Validation codes reported by this library
+const (
+	E001 Code = "E001" // An object root must contain only the files and directories defined by the spec
+	E003 Code = "E003" // An object root must contain a version declaration
+	E007 Code = "E007" // The version declaration must contain its own name, followed by a newline
+	E008 Code = "E008" // An object must contain one or more versions
+	E009 Code = "E009" // Version numbers must start at 1, and be continuous
+	E011 Code = "E011" // Versions must be either all unpadded, or all padded to the same width
+	E015 Code = "E015" // A version directory must contain no files other than its inventory and sidecar
+	E017 Code = "E017" // The content directory must be a single, valid directory name
+	E023 Code = "E023" // Every file in a content directory must be in the manifest
+	E033 Code = "E033" // An inventory must be well formed JSON
+	E036 Code = "E036" // An inventory must have an id, type, digestAlgorithm, and head
+	E038 Code = "E038" // The inventory type must be the URI of the inventory section of the spec
+	E039 Code = "E039" // Manifest and state digests must use the inventory's digest algorithm
+	E040 Code = "E040" // The head must be the highest version
+	E041 Code = "E041" // An inventory must have a manifest and versions
+	E046 Code = "E046" // Every version in the inventory must have a version directory
+	E048 Code = "E048" // Every version must have a state
+	E050 Code = "E050" // Every digest in a version's state must be in the manifest
+	E051 Code = "E051" // A logical path must be a sequence of path elements joined by a / separator
+	E052 Code = "E052" // Logical path elements must not be ., .., or empty
+	E053 Code = "E053" // A logical path must not begin or end with a /
+	E057 Code = "E057" // The fixity block must be structured like the manifest
+	E058 Code = "E058" // Every inventory must have a sidecar
+	E060 Code = "E060" // The sidecar must contain the digest of the inventory
+	E061 Code = "E061" // The sidecar must be of the form "DIGEST inventory.json"
+	E063 Code = "E063" // An object root must contain an inventory
+	E064 Code = "E064" // The root inventory must be identical to that of the head version
+	E066 Code = "E066" // The state of each version must be the same in every inventory containing it
+	E069 Code = "E069" // A storage root must contain a root declaration
+	E070 Code = "E070" // A storage root's ocfl_layout.json must name its layout extension, and describe it
+	E073 Code = "E073" // Empty directories must not appear under a storage root
+	E080 Code = "E080" // The root declaration must contain its own name, followed by a newline
+	E084 Code = "E084" // Storage hierarchies must not contain files in intermediate directories
+	E086 Code = "E086" // The storage root extensions directory must contain only extension directories
+	E090 Code = "E090" // Symbolic links must not be used within storage roots
+	E092 Code = "E092" // Content must match its manifest digest
+	E093 Code = "E093" // Content must match its fixity digests
+	E095 Code = "E095" // Logical paths within a version must be unique, and none may be a directory of another
+
+	W001 Code = "W001" // Versions should not be zero padded
+	W002 Code = "W002" // A version directory should contain no directories other than the content directory
+	W004 Code = "W004" // Objects should use sha512 digests
+	W005 Code = "W005" // The object ID should be a URI
+	W007 Code = "W007" // Every version should have a message and user
+	W008 Code = "W008" // Every user should have an address
+	W009 Code = "W009" // User addresses should be URIs
+	W010 Code = "W010" // Every version should contain an inventory
+	W011 Code = "W011" // The metadata of each version should be the same in every inventory containing it
+)
+
+// Sections of the OCFL spec referenced by validation codes
+var codeSections = map[Code]string{
+	E001: "3.1 Object Structure",
+	E003: "3.2 Object Conformance Declaration",
+	E007: "3.2 Object Conformance Declaration",
+	E008: "3.3 Version Directories",
+	E009: "3.3 Version Directories",
+	E011: "3.3 Version Directories",
+	E015: "3.3 Version Directories",
+	E017: "3.3.1 Content Directory",
+	E023: "3.3.1 Content Directory",
+	E033: "3.5 Inventory",
+	E036: "3.5.1 Basic Structure",
+	E038: "3.5.1 Basic Structure",
+	E039: "3.5.1 Basic Structure",
+	E040: "3.5.1 Basic Structure",
+	E041: "3.5.1 Basic Structure",
+	E046: "3.5.3 Versions",
+	E048: "3.5.3.1 Version",
+	E050: "3.5.3.1 Version",
+	E051: "3.5.3.1 Version",
+	E052: "3.5.3.1 Version",
+	E053: "3.5.3.1 Version",
+	E057: "3.5.4 Fixity",
+	E058: "3.5.5 Inventory Digest",
+	E060: "3.5.5 Inventory Digest",
+	E061: "3.5.5 Inventory Digest",
+	E063: "3.5 Inventory",
+	E064: "3.5.6 Previous Version Inventories",
+	E066: "3.5.6 Previous Version Inventories",
+	E069: "4.2 Root Conformance Declaration",
+	E070: "4.1 Root Structure",
+	E073: "4.3 Root Hierarchies",
+	E080: "4.2 Root Conformance Declaration",
+	E084: "4.3 Root Hierarchies",
+	E086: "4.1 Root Structure",
+	E090: "4.3 Root Hierarchies",
+	E092: "3.5.2 Manifest",
+	E093: "3.5.4 Fixity",
+	E095: "3.5.3.1 Version",
+	W001: "3.3 Version Directories",
+	W002: "3.3 Version Directories",
+	W004: "3.4 Digests",
+	W005: "3.5.1 Basic Structure",
+	W007: "3.5.3.1 Version",
+	W008: "3.5.3.1 Version",
+	W009: "3.5.3.1 Version",
+	W010: "3.5.6 Previous Version Inventories",
+	W011: "3.5.6 Previous Version Inventories",
+}
+
+// IsError indicates whether the code identifies an error, rather than a warning
+func (c Code) IsError() bool {
+	return strings.HasPrefix(string(c), "E")
+}
+
+// Section returns the number and title of the section of the OCFL spec that
+// the code references, or the empty string if unknown
+func (c Code) Section() string {
+	return codeSections[c]
+}
+
+// ValidationError is a violation of the OCFL spec, identified by its code
+type ValidationError struct {
+	Code    Code
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("[%s] %s", e.Code, e.Message)
+}
+
+func invalid(code Code, format string, args ...interface{}) *ValidationError {
+	return &ValidationError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,117 @@
+package metadata
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// VersionDiff describes the changes to logical files between two versions of an object.
+// All paths are sorted.
+type VersionDiff struct {
+	Added    []string `json:"added,omitempty"`    // Paths only in the newer version
+	Removed  []string `json:"removed,omitempty"`  // Paths only in the older version
+	Modified []string `json:"modified,omitempty"` // Paths in both versions, with different content
+	Renamed  []Rename `json:"renamed,omitempty"`  // Content moved from a path only in the older version, to one only in the newer
+}
+
+// Rename is a logical file whose path changed between versions, but whose content did not
+type Rename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Empty indicates whether there are no differences
+func (d *VersionDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0 && len(d.Renamed) == 0
+}
+
+// Diff compares the state of two versions of the inventory, from an older to a newer
+// one; given the other way around, additions are reported as removals.  A path removed, and another
+// added with the same content, is considered to be renamed.  If several paths with the
+// same content are removed and added, those with the same file name are paired first,
+// then the rest in sorted order.
+func (i *Inventory) Diff(from, to string) (*VersionDiff, error) {
+	old, ok := i.Versions[from]
+	if !ok {
+		return nil, errors.Wrapf(ErrNotFound, "version %s of %s", from, i.ID)
+	}
+	current, ok := i.Versions[to]
+	if !ok {
+		return nil, errors.Wrapf(ErrNotFound, "version %s of %s", to, i.ID)
+	}
+
+	oldPaths, err := index(old.State)
+	if err != nil {
+		return nil, fmt.Errorf("could not index state of %s %s: %s", i.ID, from, err)
+	}
+	newPaths, err := index(current.State)
+	if err != nil {
+		return nil, fmt.Errorf("could not index state of %s %s: %s", i.ID, to, err)
+	}
+
+	diff := &VersionDiff{}
+	removed := make(map[Digest][]string)
+	for p, digest := range oldPaths {
+		newDigest, exists := newPaths[p]
+		switch {
+		case !exists:
+			removed[digest] = append(removed[digest], p)
+		case newDigest != digest:
+			diff.Modified = append(diff.Modified, p)
+		}
+	}
+
+	added := make(map[Digest][]string)
+	for p, digest := range newPaths {
+		if _, exists := oldPaths[p]; !exists {
+			added[digest] = append(added[digest], p)
+		}
+	}
+
+	for digest, paths := range removed {
+		sort.Strings(paths)
+		candidates := added[digest]
+		sort.Strings(candidates)
+
+		// Pair paths with the same file name first, then any others in order
+		var unpaired []string
+		for _, p := range paths {
+			if j := indexOfName(candidates, path.Base(p)); j >= 0 {
+				diff.Renamed = append(diff.Renamed, Rename{From: p, To: candidates[j]})
+				candidates = append(candidates[:j], candidates[j+1:]...)
+			} else {
+				unpaired = append(unpaired, p)
+			}
+		}
+
+		n := min(len(unpaired), len(candidates))
+		for j := 0; j < n; j++ {
+			diff.Renamed = append(diff.Renamed, Rename{From: unpaired[j], To: candidates[j]})
+		}
+		diff.Removed = append(diff.Removed, unpaired[n:]...)
+		added[digest] = candidates[n:]
+	}
+	for _, paths := range added {
+		diff.Added = append(diff.Added, paths...)
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Modified)
+	sort.Slice(diff.Renamed, func(a, b int) bool { return diff.Renamed[a].From < diff.Renamed[b].From })
+
+	return diff, nil
+}
+
+// Finds the first path with the given file name
+func indexOfName(paths []string, name string) int {
+	for j, p := range paths {
+		if path.Base(p) == name {
+			return j
+		}
+	}
+	return -1
+}
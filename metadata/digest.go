@@ -0,0 +1,91 @@
+package metadata
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// digestLengths contains the expected hex string length of digests for known algorithms
+var digestLengths = map[DigestAlgorithm]int{
+	"md5":         32,
+	"sha1":        40,
+	"sha256":      64,
+	"sha512":      128,
+	"blake2b-512": 128,
+}
+
+// NewHash creates a hash computing digests with the given algorithm, if it is supported
+func NewHash(alg DigestAlgorithm) (hash.Hash, bool) {
+	switch alg {
+	case "md5":
+		return md5.New(), true
+	case "sha1":
+		return sha1.New(), true
+	case "sha256":
+		return sha256.New(), true
+	case "sha512":
+		return sha512.New(), true
+	default:
+		return nil, false
+	}
+}
+
+// ParseDigest parses a hex digest computed with the given algorithm, as written by this
+// or another tool (e.g. in a sidecar), and normalizes it to lowercase.  The digest must
+// be a hex string of the length implied by the algorithm, if known.
+func ParseDigest(alg DigestAlgorithm, s string) (Digest, error) {
+	d := Digest(strings.TrimSpace(s)).Normalize()
+	if err := d.Validate(alg); err != nil {
+		return "", err
+	}
+	return d, nil
+}
+
+// Normalize returns the digest in lowercase, the form in which this library records digests
+func (d Digest) Normalize() Digest {
+	return Digest(strings.ToLower(string(d)))
+}
+
+// Equal compares digests case insensitively, as other tools may record them in uppercase
+func (d Digest) Equal(other Digest) bool {
+	return strings.EqualFold(string(d), string(other))
+}
+
+// Validate verifies that the digest is a hex string of the length implied by the given
+// algorithm, if known.  Case is not significant.
+func (d Digest) Validate(alg DigestAlgorithm) error {
+	if _, err := hex.DecodeString(string(d)); err != nil {
+		return fmt.Errorf("%s digest '%s' is not a hex string", alg, d)
+	}
+
+	if length, known := digestLengths[alg]; known && len(d) != length {
+		return fmt.Errorf("%s digest '%s' has length %d, expected %d", alg, d, len(d), length)
+	}
+
+	return nil
+}
+
+// Find looks up a digest in the manifest, compared case insensitively, and returns it
+// in the form in which it appears in the manifest.  Digests computed by this library can
+// thus be matched against manifests written by tools that use uppercase, or mixed case.
+// Digests in neither lowercase nor uppercase are found by scanning the manifest; see
+// Inventory.FindDigest for repeated lookups in a large manifest.
+func (m Manifest) Find(d Digest) (Digest, bool) {
+	for _, digest := range []Digest{d, d.Normalize(), Digest(strings.ToUpper(string(d)))} {
+		if _, ok := m[digest]; ok {
+			return digest, true
+		}
+	}
+	for digest := range m {
+		if digest.Equal(d) {
+			return digest, true
+		}
+	}
+	return "", false
+}
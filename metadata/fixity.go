@@ -0,0 +1,115 @@
+package metadata
+
+import (
+	"fmt"
+)
+
+// AddFixity records a digest of the content file at the given physical path (relative
+// to the object root), using the given algorithm, in the fixity block.  Any digest
+// of the file already recorded for that algorithm is replaced.  The file must be in
+// the manifest.
+func (i *Inventory) AddFixity(path string, alg DigestAlgorithm, digest Digest) error {
+	if err := i.indexHead(); err != nil {
+		return err
+	}
+
+	if _, ok := i.manifestIndex[path]; !ok {
+		return fmt.Errorf("cannot add fixity for %s, it is not in the manifest of %s", path, i.ID)
+	}
+	if err := digest.Validate(alg); err != nil {
+		return err
+	}
+
+	i.removeFixity(path, alg)
+
+	if i.Fixity == nil {
+		i.Fixity = make(Fixity)
+	}
+	if i.Fixity[alg] == nil {
+		i.Fixity[alg] = make(Manifest)
+	}
+	i.Fixity[alg][digest] = append(i.Fixity[alg][digest], path)
+	return nil
+}
+
+// GetFixity returns the digests of the content file at the given physical path
+// recorded in the fixity block, by algorithm, or nil if there are none.
+func (i *Inventory) GetFixity(path string) map[DigestAlgorithm]Digest {
+	var digests map[DigestAlgorithm]Digest
+	for alg := range i.Fixity {
+		for digest, paths := range i.Fixity[alg] {
+			if contains(paths, path) {
+				if digests == nil {
+					digests = make(map[DigestAlgorithm]Digest)
+				}
+				digests[alg] = digest
+				break
+			}
+		}
+	}
+	return digests
+}
+
+// RemoveFixity removes the digests of the content file at the given physical path
+// from the fixity block, for the given algorithms, or for all algorithms if none are
+// given.  It is not an error if there are no such digests.
+func (i *Inventory) RemoveFixity(path string, algs ...DigestAlgorithm) {
+	if len(algs) == 0 {
+		for alg := range i.Fixity {
+			algs = append(algs, alg)
+		}
+	}
+
+	for _, alg := range algs {
+		i.removeFixity(path, alg)
+	}
+}
+
+// Removes the digest of a path for the given algorithm, and the algorithm itself if
+// no other paths have digests using it
+func (i *Inventory) removeFixity(path string, alg DigestAlgorithm) {
+	manifest := i.Fixity[alg]
+	for digest, paths := range manifest {
+		for j := 0; j < len(paths); j++ {
+			if paths[j] == path {
+				paths = append(paths[:j], paths[j+1:]...)
+				j--
+			}
+		}
+
+		if len(paths) == 0 {
+			delete(manifest, digest)
+		} else {
+			manifest[digest] = paths
+		}
+	}
+
+	if len(manifest) == 0 {
+		delete(i.Fixity, alg)
+	}
+}
+
+// Indexes the fixity block by path, for looking up the fixity of many files
+func (i *Inventory) fixityIndex() map[string]map[DigestAlgorithm]Digest {
+	index := make(map[string]map[DigestAlgorithm]Digest)
+	for alg, manifest := range i.Fixity {
+		for digest, paths := range manifest {
+			for _, p := range paths {
+				if index[p] == nil {
+					index[p] = make(map[DigestAlgorithm]Digest)
+				}
+				index[p][alg] = digest
+			}
+		}
+	}
+	return index
+}
+
+func contains(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
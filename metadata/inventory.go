@@ -13,14 +13,22 @@ import (
 	"github.com/pkg/errors"
 )
 
-// InventoryType contains the expected "type" value of an OCFL object
-const InventoryType = "https://ocfl.io/1.0/spec/#inventory"
+// SpecVersion is the version of the OCFL spec implemented by this package
+const SpecVersion = "1.0"
+
+// InventoryType contains the expected "type" value of an OCFL inventory; the URI
+// of the inventory section of the spec
+const InventoryType = "https://ocfl.io/" + SpecVersion + "/spec/#inventory"
 
 // InventoryFile contains the name of OCFL inventory files
 const InventoryFile = "inventory.json"
 
 const vfmt = "v%d"
 
+// ErrNotFound is returned (as the cause of an error) when a version or logical path
+// is not present in an inventory
+var ErrNotFound = errors.New("not present in the inventory")
+
 const contentDir = "content"
 
 // Inventory defines the contents of an OCFL object, as defined by inventory.json in the OCFL spec
@@ -29,18 +37,26 @@ type Inventory struct {
 	Type            string             `json:"type"`
 	DigestAlgorithm DigestAlgorithm    `json:"digestAlgorithm"`
 	Head            string             `json:"head"`
+	ContentDir      string             `json:"contentDirectory,omitempty"`
 	Manifest        Manifest           `json:"manifest"`
 	Versions        map[string]Version `json:"versions"`
 	Fixity          Fixity             `json:"fixity"`
-	stateIndex      map[string]Digest  // internal accounting for managing updates
-	manifestIndex   map[string]Digest  // internal accounting for managing updates
-	headContentDir  string             // internal accounting for managing updates
+
+	// TimestampPrecision determines how the creation dates of new or changed versions are written
+	TimestampPrecision TimestampPrecision `json:"-"`
+
+	stateIndex     map[string]Digest   // internal accounting for managing updates
+	manifestIndex  map[string]Digest   // internal accounting for managing updates
+	foldedIndex    map[string][]string // manifest paths by their lower case, for finding case conflicts
+	digestIndex    map[Digest]Digest   // manifest digests by their lower case, for finding digests in any case
+	headContentDir string              // internal accounting for managing updates
 }
 
 // DigestAlgorithm is identifier for an ocfl-approved digest algorithm, as defined by inventory.json in the OCFL spec
 type DigestAlgorithm string
 
-// Digest is a lowercase hex string representing a digest, as defined by inventory.json in the OCFL spec
+// Digest is a hex string representing a digest, as defined by inventory.json in the OCFL spec.
+// This library writes digests in lowercase, but other tools may not.
 type Digest string
 
 // Manifest is a mapping of digests to physical file paths, as defined by inventory.json in the OCFL spec
@@ -56,6 +72,9 @@ type Version struct {
 	User    User      `json:"user"`
 	State   Manifest  `json:"state"`
 	Type    string    `json:"type"`
+
+	created   string             // created date as parsed, written as is if Created is unchanged
+	precision TimestampPrecision // precision of the created date, if written anew
 }
 
 // VersionID contains a version ID representation as consistent with the OCFL spec.
@@ -68,12 +87,12 @@ type User struct {
 	Address string `json:"address"`
 }
 
-// NewInventory creates a new, empty inventory with reasonable defaults:
-// sha512 digest algorithm (can be changed by callee if need be),
-// with an empty "v1" version.  The manifest and v1's state are initialized
-// with empty maps.
-func NewInventory(id string) *Inventory {
-	return &Inventory{
+// NewInventory creates a new, empty inventory with reasonable defaults, which may
+// be changed by the given options: sha512 digest algorithm, the default content
+// directory, the type of the implemented spec version, and an empty "v1" version
+// created now.  The manifest and v1's state are initialized with empty maps.
+func NewInventory(id string, opts ...InventoryOption) *Inventory {
+	inv := &Inventory{
 		ID:              id,
 		Type:            InventoryType,
 		Head:            "v1",
@@ -81,10 +100,16 @@ func NewInventory(id string) *Inventory {
 		Versions: map[string]Version{
 			"v1": {
 				Created: time.Now().UTC().Truncate(1 * time.Millisecond),
+				State:   make(map[Digest][]string, 10),
 			},
 		},
 		Manifest: make(map[Digest][]string, 10),
 	}
+
+	for _, opt := range opts {
+		opt(inv)
+	}
+	return inv
 }
 
 // File describes individual files within an OCFL object.  It is constructed from the contents if an
@@ -104,9 +129,89 @@ func Parse(r io.Reader, i *Inventory) error {
 	if err != nil {
 		return errors.Wrap(err, "Could not decode json inventory")
 	}
+
+	// Malformed logical paths cannot be safely mapped to files, so are rejected outright
+	if err := i.validateLogicalPaths(); err != nil {
+		return err
+	}
 	return nil
 }
 
+// ParseID reads only the id of an inventory from a byte stream, without decoding
+// the rest of it.  This is much cheaper than Parse for large inventories,
+// as the id conventionally appears first.
+func ParseID(r io.Reader) (string, error) {
+	dec := json.NewDecoder(r)
+
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return "", errors.Errorf("Could not decode json inventory: expected an object")
+	}
+
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return "", errors.Wrap(err, "Could not decode json inventory")
+		}
+
+		if key == "id" {
+			var id string
+			if err := dec.Decode(&id); err != nil {
+				return "", errors.Wrap(err, "Could not decode inventory id")
+			}
+			return id, nil
+		}
+
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return "", errors.Wrap(err, "Could not decode json inventory")
+		}
+	}
+
+	return "", errors.New("inventory has no id")
+}
+
+// Equal determines whether two manifests (or states) contain the same digests,
+// each mapped to the same set of paths, regardless of path order.
+func (m Manifest) Equal(other Manifest) bool {
+	if len(m) != len(other) {
+		return false
+	}
+
+	for digest, paths := range m {
+		otherPaths, ok := other[digest]
+		if !ok || len(paths) != len(otherPaths) {
+			return false
+		}
+
+		a := append([]string(nil), paths...)
+		b := append([]string(nil), otherPaths...)
+		sort.Strings(a)
+		sort.Strings(b)
+
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Clone makes a deep copy of the manifest (or state), so that modifying either the
+// copy or its paths does not affect the original.
+func (m Manifest) Clone() Manifest {
+	if m == nil {
+		return nil
+	}
+
+	cp := make(Manifest, len(m))
+	for digest, paths := range m {
+		cp[digest] = append([]string(nil), paths...)
+	}
+	return cp
+}
+
 // Serialize writes the contents of the inventory to json
 func (i *Inventory) Serialize(w io.Writer) error {
 	enc := json.NewEncoder(w)
@@ -123,9 +228,25 @@ func (i *Inventory) Serialize(w io.Writer) error {
 // and the given version is v2, then  it'll return v2/foo.txt
 func (i *Inventory) Files(version string) ([]File, error) {
 	var files []File
+	err := i.FilesIter(version, func(f File) error {
+		files = append(files, f)
+		return nil
+	})
+	return files, err
+}
+
+// FilesIter invokes the callback with the metadata of each logical file in a version
+// (see Files), in no particular order, without collecting them all in memory.  If the
+// callback returns an error, iteration stops, and the error is returned.
+func (i *Inventory) FilesIter(version string, f func(File) error) error {
 	v, ok := i.Versions[version]
 	if !ok {
-		return files, fmt.Errorf("no version present named %s in %s", version, i.ID)
+		return errors.Wrapf(ErrNotFound, "version %s of %s", version, i.ID)
+	}
+
+	var fixity map[string]map[DigestAlgorithm]Digest
+	if len(i.Fixity) > 0 {
+		fixity = i.fixityIndex()
 	}
 
 	for digest, state := range v.State {
@@ -133,50 +254,58 @@ func (i *Inventory) Files(version string) ([]File, error) {
 
 			ppaths, ok := i.Manifest[digest]
 			if !ok {
-				return files, fmt.Errorf("no manifest entry for file %s (%s: %s) in %s of %s",
+				return fmt.Errorf("no manifest entry for file %s (%s: %s) in %s of %s",
 					lpath, i.DigestAlgorithm, digest, version, i.ID)
 			}
 			if len(ppaths) == 0 {
-				return files, fmt.Errorf("no physical files for %s (%s: %s) in %s of %s",
+				return fmt.Errorf("no physical files for %s (%s: %s) in %s of %s",
 					lpath, i.DigestAlgorithm, digest, version, i.ID)
 			}
 
 			ppath := ppaths[0]
 
 			// If there is more than one path, then return the
-			// lexically greatest one that starts with the current version
-			// prefix, or an earlier version prefix
+			// one from the numerically greatest version that is the current
+			// version, or an earlier version.  Version numbers are compared by
+			// value, so that mixed padding (v2 vs v0010) is handled sensibly.
 			if len(ppaths) > 1 {
-				spaths := make([]string, len(ppaths))
-				prefix := version + "/"
+				var best VersionID
 				for _, p := range ppaths {
-					if version > p || strings.HasPrefix(p, prefix) {
-						spaths = append(spaths, p)
+					pv := contentVersion(p)
+					if VersionID(version).Less(pv) {
+						continue
+					}
+					if best == "" || best.Less(pv) || (best.Compare(pv) == 0 && p > ppath) {
+						best = pv
+						ppath = p
 					}
-				}
-				sort.Strings(spaths)
-
-				if len(spaths) > 0 {
-					ppath = spaths[len(spaths)-1]
 				}
 			}
 
-			files = append(files, File{
+			err := f(File{
 				Version:      &v,
 				Inventory:    i,
 				LogicalPath:  lpath,
 				PhysicalPath: ppath,
+				Fixity:       fixity[ppath],
 			})
+			if err != nil {
+				return err
+			}
 		}
 	}
 
-	return files, nil
+	return nil
 }
 
 // PutFile adds a logical file to the OCFL manifest and HEAD version state,
 // overwriting any existing entries if present.
 func (i *Inventory) PutFile(logicalPath, relativePhysicalPath string, digest Digest) error {
 
+	if err := ValidateLogicalPath(logicalPath); err != nil {
+		return err
+	}
+
 	err := i.indexHead()
 	if err != nil {
 		return err
@@ -187,6 +316,11 @@ func (i *Inventory) PutFile(logicalPath, relativePhysicalPath string, digest Dig
 			i.headContentDir, relativePhysicalPath)
 	}
 
+	// Digests already in the manifest are recorded in the same case
+	if existing, ok := i.FindDigest(digest); ok {
+		digest = existing
+	}
+
 	// See if the physical path is already in the manifest.
 	manifestDigest, manifestConflict := i.manifestIndex[relativePhysicalPath]
 	if manifestConflict && manifestDigest != digest {
@@ -207,22 +341,196 @@ func (i *Inventory) PutFile(logicalPath, relativePhysicalPath string, digest Dig
 
 	if !manifestConflict {
 		i.addPathMapping(relativePhysicalPath, digest, i.manifestIndex, i.Manifest)
+		i.foldPath(relativePhysicalPath)
+	}
+
+	if i.digestIndex != nil {
+		i.digestIndex[digest.Normalize()] = digest
 	}
 
 	return nil
 }
 
+// PutLogicalFile adds a logical file to the HEAD version state that references content
+// already present in the manifest with the given digest, overwriting any existing
+// entry for that logical path.  The manifest is not modified.
+func (i *Inventory) PutLogicalFile(logicalPath string, digest Digest) error {
+	if err := ValidateLogicalPath(logicalPath); err != nil {
+		return err
+	}
+
+	err := i.indexHead()
+	if err != nil {
+		return err
+	}
+
+	digest, ok := i.FindDigest(digest)
+	if !ok {
+		return fmt.Errorf("no content with digest %s present in the manifest of %s", digest, i.ID)
+	}
+
+	stateDigest, stateConflict := i.stateIndex[logicalPath]
+	if stateConflict && stateDigest == digest {
+		return nil
+	}
+
+	if stateConflict {
+		i.removePathMapping(logicalPath, stateDigest, i.stateIndex, i.Versions[i.Head].State)
+	}
+
+	i.addPathMapping(logicalPath, digest, i.stateIndex, i.Versions[i.Head].State)
+	return nil
+}
+
+// DeleteFile removes a logical file from the HEAD version state.  It is not an error
+// if the file does not exist.
 func (i *Inventory) DeleteFile(logicalPath string) error {
+	_, err := i.RemoveLogicalPath(logicalPath, false)
+	return err
+}
+
+// RemoveLogicalPath removes a logical path from the HEAD version state, the inverse of
+// PutFile.  It is not an error if the path does not exist.
+//
+// If prune is true, and the path's content is no longer referenced by the state of any
+// version, its manifest (and fixity) entries are removed as well, provided that they are all
+// in the HEAD version's content directory.  The pruned physical paths are returned, so that
+// the content can be removed.
+func (i *Inventory) RemoveLogicalPath(logicalPath string, prune bool) ([]string, error) {
+	err := i.indexHead()
+	if err != nil {
+		return nil, err
+	}
+
+	digest, exists := i.stateIndex[logicalPath]
+	if !exists {
+		return nil, nil
+	}
+	i.removePathMapping(logicalPath, digest, i.stateIndex, i.Versions[i.Head].State)
+
+	if !prune {
+		return nil, nil
+	}
+
+	for _, v := range i.Versions {
+		if _, referenced := v.State[digest]; referenced {
+			return nil, nil
+		}
+	}
+
+	ppaths := append([]string(nil), i.Manifest[digest]...)
+	for _, p := range ppaths {
+		if !strings.HasPrefix(p, i.headContentDir+"/") {
+			return nil, nil
+		}
+	}
+
+	for _, p := range ppaths {
+		i.removePathMapping(p, digest, i.manifestIndex, i.Manifest)
+		i.unfoldPath(p)
+		i.RemoveFixity(p)
+	}
+
+	return ppaths, nil
+}
+
+// ClearHead removes all logical files from the HEAD version state, leaving
+// an empty version.  Empty versions are allowed by OCFL, and are valid as long
+// as their state is present (i.e. an empty map rather than nil).
+func (i *Inventory) ClearHead() error {
 	err := i.indexHead()
 	if err != nil {
 		return err
 	}
 
-	hash, exists := i.stateIndex[logicalPath]
-	if exists {
-		i.removePathMapping(logicalPath, hash, i.stateIndex, i.Versions[i.Head].State)
+	v, ok := i.Versions[i.Head]
+	if !ok {
+		return fmt.Errorf("no head version %s present in %s", i.Head, i.ID)
 	}
 
+	v.State = make(map[Digest][]string, 10)
+	i.Versions[i.Head] = v
+	i.stateIndex = make(map[string]Digest, 10)
+
+	return nil
+}
+
+// DedupManifest removes all but one physical path of each digest in the manifest (and
+// any fixity of the removed paths).  The path in the earliest version is kept, as
+// every version whose state references the content can find it there.  Returns the
+// removed paths, in sorted order, whose content is now redundant.
+func (i *Inventory) DedupManifest() []string {
+	var removed []string
+	for digest, paths := range i.Manifest {
+		if len(paths) < 2 {
+			continue
+		}
+
+		keep := paths[0]
+		for _, p := range paths[1:] {
+			if c := contentVersion(p).Compare(contentVersion(keep)); c < 0 || (c == 0 && p < keep) {
+				keep = p
+			}
+		}
+
+		for _, p := range paths {
+			if p != keep {
+				removed = append(removed, p)
+				i.RemoveFixity(p)
+			}
+		}
+		i.Manifest[digest] = []string{keep}
+	}
+
+	// The manifest index is rebuilt upon the next update
+	i.manifestIndex = nil
+	i.foldedIndex = nil
+	i.digestIndex = nil
+
+	sort.Strings(removed)
+	return removed
+}
+
+// The version containing the content at the given physical path
+func contentVersion(path string) VersionID {
+	return VersionID(strings.SplitN(path, "/", 2)[0])
+}
+
+// RenameLogicalPath moves a logical file in the HEAD version state to a new logical path,
+// referencing the same content.  It is an error if there is no file at the old path, or if
+// the new path is malformed, or conflicts with another file; i.e. is the path of another
+// file, or of a directory containing one, or is within a directory that is another file's path.
+func (i *Inventory) RenameLogicalPath(from, to string) error {
+	if err := ValidateLogicalPath(to); err != nil {
+		return err
+	}
+
+	err := i.indexHead()
+	if err != nil {
+		return err
+	}
+
+	digest, exists := i.stateIndex[from]
+	if !exists {
+		return errors.Wrapf(ErrNotFound, "cannot rename %s in %s %s", from, i.ID, i.Head)
+	}
+	if from == to {
+		return nil
+	}
+
+	for p := range i.stateIndex {
+		switch {
+		case p == from:
+		case p == to:
+			return invalid(E095, "cannot rename %s to %s, which already exists in %s %s", from, to, i.ID, i.Head)
+		case strings.HasPrefix(p, to+"/"), strings.HasPrefix(to, p+"/"):
+			return invalid(E095, "cannot rename %s to %s, which conflicts with %s in %s %s", from, to, p, i.ID, i.Head)
+		}
+	}
+
+	state := i.Versions[i.Head].State
+	i.removePathMapping(from, digest, i.stateIndex, state)
+	i.addPathMapping(to, digest, i.stateIndex, state)
 	return nil
 }
 
@@ -264,6 +572,18 @@ func (i *Inventory) indexHead() error {
 		i.stateIndex = index
 	}
 
+	if err := i.indexManifest(); err != nil {
+		return err
+	}
+
+	if i.headContentDir == "" {
+		i.headContentDir = filepath.ToSlash(filepath.Join(i.Head, i.ContentDirectory()))
+	}
+
+	return nil
+}
+
+func (i *Inventory) indexManifest() error {
 	if i.manifestIndex == nil {
 		index, err := index(i.Manifest)
 		if err != nil {
@@ -271,12 +591,37 @@ func (i *Inventory) indexHead() error {
 		}
 		i.manifestIndex = index
 	}
+	return nil
+}
 
-	if i.headContentDir == "" {
-		i.headContentDir = filepath.ToSlash(filepath.Join(i.Head, contentDir))
+// Adds a manifest path to the index of paths by their lower case, if it has been built
+func (i *Inventory) foldPath(path string) {
+	if i.foldedIndex != nil {
+		folded := strings.ToLower(path)
+		i.foldedIndex[folded] = append(i.foldedIndex[folded], path)
 	}
+}
 
-	return nil
+// Removes a manifest path from the index of paths by their lower case, if it has been built
+func (i *Inventory) unfoldPath(path string) {
+	if i.foldedIndex == nil {
+		return
+	}
+
+	folded := strings.ToLower(path)
+	paths := i.foldedIndex[folded]
+	for n, p := range paths {
+		if p == path {
+			paths = append(paths[:n], paths[n+1:]...)
+			break
+		}
+	}
+
+	if len(paths) == 0 {
+		delete(i.foldedIndex, folded)
+	} else {
+		i.foldedIndex[folded] = paths
+	}
 }
 
 // create transient indexes to support updates
@@ -316,8 +661,75 @@ func (v VersionID) Int() (int, error) {
 	return int(i), nil
 }
 
+// Compare compares two version IDs by numeric value, returning -1, 0, or 1 if
+// v is less than, equal to, or greater than other.  Version IDs with equal
+// numeric value but different padding (e.g. v2 and v002) are ordered by string
+// value so that the ordering is total.  Invalid version IDs sort before valid ones.
+func (v VersionID) Compare(other VersionID) int {
+	a, errA := v.Int()
+	b, errB := other.Int()
+
+	switch {
+	case errA != nil && errB == nil:
+		return -1
+	case errA == nil && errB != nil:
+		return 1
+	case errA == nil && a < b:
+		return -1
+	case errA == nil && a > b:
+		return 1
+	}
+
+	return strings.Compare(string(v), string(other))
+}
+
+// Less determines whether version ID v is numerically less than other
+func (v VersionID) Less(other VersionID) bool {
+	return v.Compare(other) < 0
+}
+
+// SortVersions sorts a slice of version names in place by numeric value,
+// rather than lexically.
+func SortVersions(versions []string) {
+	sort.Slice(versions, func(a, b int) bool {
+		return VersionID(versions[a]).Less(VersionID(versions[b]))
+	})
+}
+
+// ContentDirectory returns the name of the content directory of each version,
+// which is "content" unless the inventory gives another
+func (i *Inventory) ContentDirectory() string {
+	if i.ContentDir == "" {
+		return contentDir
+	}
+	return i.ContentDir
+}
+
+// VersionNames returns the names of all versions in the inventory, in ascending
+// numeric order.
+func (i *Inventory) VersionNames() []string {
+	names := make([]string, 0, len(i.Versions))
+	for name := range i.Versions {
+		names = append(names, name)
+	}
+
+	SortVersions(names)
+	return names
+}
+
+// Renumber creates a version ID with the given number, using the same
+// zero padding convention as v.
+func (v VersionID) Renumber(n int) VersionID {
+	if len(v) > 2 && v[1] == '0' { // Padded!
+		return VersionID(fmt.Sprintf(fmt.Sprintf("v%%0%dd", len(v)-1), n))
+	}
+
+	return VersionID(fmt.Sprintf(vfmt, n))
+}
+
 // Increment increments an OCFL version, respecting padding if a given
-// version ID is padded
+// version ID is padded.  Padded versions must retain a leading zero, so
+// incrementing the highest version a padding allows (e.g. v0999) is an error.
 func (v VersionID) Increment() (VersionID, error) {
 	var fmts = vfmt
 
@@ -325,11 +737,14 @@ func (v VersionID) Increment() (VersionID, error) {
 		return "", fmt.Errorf("version %s is not a valid OCFL version", v)
 	}
 
+	i, _ := v.Int()
+
 	if v[1] == '0' { // Padded!
 		fmts = fmt.Sprintf("v%%0%dd", len(v)-1)
+		if next := fmt.Sprintf(fmts, i+1); next[1] != '0' {
+			return "", fmt.Errorf("version %s cannot be incremented: %s would exceed its zero padding", v, next)
+		}
 	}
 
-	i, _ := v.Int()
-
 	return VersionID(fmt.Sprintf(fmts, i+1)), nil
 }
@@ -3,19 +3,22 @@ package metadata_test
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/birkland/ocfl/metadata"
 	"github.com/go-test/deep"
+	"github.com/pkg/errors"
 )
 
 var testInventory = metadata.Inventory{
 	ID:              "test://myOcflObject",
 	DigestAlgorithm: "sha512",
 	Head:            "v2",
-	Type:            "Object",
+	Type:            metadata.InventoryType,
 	Manifest: metadata.Manifest{
 		"a": {"v1/content/physical/1", "v3/content/physical/1"},
 		"b": {"v2/content/physical/2"},
@@ -23,7 +26,7 @@ var testInventory = metadata.Inventory{
 	},
 	Versions: map[string]metadata.Version{
 		"v1": {
-			Created: time.Now(),
+			Created: time.Now().UTC().Truncate(time.Millisecond),
 			User: metadata.User{
 				Name:    "孔子",
 				Address: "⌖",
@@ -35,7 +38,7 @@ var testInventory = metadata.Inventory{
 			},
 		},
 		"v2": {
-			Created: time.Now(),
+			Created: time.Now().UTC().Truncate(time.Millisecond),
 			User: metadata.User{
 				Name:    "Khổng Tử",
 				Address: "Here",
@@ -47,7 +50,7 @@ var testInventory = metadata.Inventory{
 			},
 		},
 		"v3": {
-			Created: time.Now(),
+			Created: time.Now().UTC().Truncate(time.Millisecond),
 			User: metadata.User{
 				Name:    "Third Editor",
 				Address: "There",
@@ -100,6 +103,40 @@ func TestParseBadInput(t *testing.T) {
 	}
 }
 
+func TestParseID(t *testing.T) {
+	var buf bytes.Buffer
+	_ = testInventory.Serialize(&buf)
+
+	cases := map[string]struct {
+		json     string
+		expected string
+	}{
+		"serialized":    {buf.String(), testInventory.ID},
+		"idLast":        {`{"manifest": {"a": ["b"]}, "versions": {}, "id": "last"}`, "last"},
+		"ignoresNested": {`{"versions": {"v1": {"id": "nested"}}, "id": "top"}`, "top"},
+		"trailingJunk":  {`{"id": "first", "manifest": {{{`, "first"},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			id, err := metadata.ParseID(strings.NewReader(c.json))
+			if err != nil {
+				t.Fatalf("Error parsing id: %+v", err)
+			}
+			if id != c.expected {
+				t.Errorf("Expected id %s, got %s", c.expected, id)
+			}
+		})
+	}
+
+	for _, bad := range []string{`[]`, `{"manifest": {}}`, `{"id": 5}`, `not json`} {
+		if _, err := metadata.ParseID(strings.NewReader(bad)); err == nil {
+			t.Errorf("Expected an error parsing id from %s", bad)
+		}
+	}
+}
+
 func TestInventoryFiles(t *testing.T) {
 	v1 := testInventory.Versions["v1"]
 	v2 := testInventory.Versions["v2"]
@@ -110,12 +147,14 @@ func TestInventoryFiles(t *testing.T) {
 				Version:      &v1,
 				Inventory:    &testInventory,
 				PhysicalPath: "v1/content/physical/1",
+				Fixity:       map[metadata.DigestAlgorithm]metadata.Digest{"sha256": "aa"},
 				LogicalPath:  "logical/1",
 			},
 			{
 				Version:      &v1,
 				Inventory:    &testInventory,
 				PhysicalPath: "v2/content/physical/2",
+				Fixity:       map[metadata.DigestAlgorithm]metadata.Digest{"sha256": "bb"},
 				LogicalPath:  "logical/2",
 			},
 		},
@@ -125,6 +164,7 @@ func TestInventoryFiles(t *testing.T) {
 				Inventory:    &testInventory,
 				LogicalPath:  "logical/1",
 				PhysicalPath: "v1/content/physical/1",
+				Fixity:       map[metadata.DigestAlgorithm]metadata.Digest{"sha256": "aa"},
 			},
 			{
 				Version:      &v2,
@@ -139,6 +179,7 @@ func TestInventoryFiles(t *testing.T) {
 				Inventory:    &testInventory,
 				LogicalPath:  "logical/1",
 				PhysicalPath: "v2/content/physical/2",
+				Fixity:       map[metadata.DigestAlgorithm]metadata.Digest{"sha256": "bb"},
 			},
 			{
 				Version:      &v3,
@@ -177,6 +218,41 @@ func TestInventoryFiles(t *testing.T) {
 	}
 }
 
+func TestFilesIter(t *testing.T) {
+	files, _ := testInventory.Files("v3")
+
+	var iterated []metadata.File
+	err := testInventory.FilesIter("v3", func(f metadata.File) error {
+		iterated = append(iterated, f)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(iterated) != len(files) {
+		t.Fatalf("expected %d files, got %d", len(files), len(iterated))
+	}
+	for _, f := range files {
+		if !foundFile(f, iterated) {
+			t.Errorf("did not find %s", f.LogicalPath)
+		}
+	}
+
+	stop := fmt.Errorf("stop")
+	calls := 0
+	err = testInventory.FilesIter("v3", func(metadata.File) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("expected iteration to stop with the callback's error, got %v after %d calls", err, calls)
+	}
+
+	if err = testInventory.FilesIter("v9", func(metadata.File) error { return nil }); err == nil {
+		t.Errorf("expected an error iterating a missing version")
+	}
+}
+
 func foundFile(file metadata.File, files []metadata.File) bool {
 	for _, f := range files {
 		if deep.Equal(file, f) == nil {
@@ -290,7 +366,9 @@ func TestVersionIncrement(t *testing.T) {
 		"v1":    {"v2", false},
 		"v9":    {"v10", false},
 		"v01":   {"v02", false},
-		"v0999": {"v1000", false},
+		"v0998": {"v0999", false},
+		"v0999": {"", true},
+		"v09":   {"", true},
 	}
 
 	for before, expected := range cases {
@@ -465,6 +543,286 @@ func TestPutBadPath(t *testing.T) {
 	}
 }
 
+func TestLogicalPaths(t *testing.T) {
+	cases := []struct {
+		lpath string
+		code  metadata.Code
+	}{
+		{"foo", ""},
+		{"a/b/c.txt", ""},
+		{"..foo/bar.", ""},
+		{"", metadata.E052},
+		{"a//b", metadata.E052},
+		{"a/./b", metadata.E052},
+		{"../b", metadata.E052},
+		{"a/..", metadata.E052},
+		{"/a/b", metadata.E053},
+		{"a/b/", metadata.E053},
+		{"a\\b", metadata.E051},
+	}
+
+	digest := metadata.Digest(strings.Repeat("ab", 64))
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.lpath, func(t *testing.T) {
+			var code metadata.Code
+			if err := metadata.ValidateLogicalPath(c.lpath); err != nil {
+				code = err.Code
+			}
+			if code != c.code {
+				t.Fatalf("expected code '%s' for %s, got '%s'", c.code, c.lpath, code)
+			}
+
+			inv := metadata.NewInventory("foo")
+			err := inv.PutFile(c.lpath, "v1/content/foo", digest)
+			if (err == nil) != (c.code == "") {
+				t.Errorf("unexpected result of PutFile: %v", err)
+			}
+
+			inv = metadata.NewInventory("foo")
+			inv.Manifest[digest] = []string{"v1/content/foo"}
+			err = inv.PutLogicalFile(c.lpath, digest)
+			if (err == nil) != (c.code == "") {
+				t.Errorf("unexpected result of PutLogicalFile: %v", err)
+			}
+
+			// Smuggle the path into a serialized inventory
+			inv.Versions["v1"].State[digest] = []string{c.lpath}
+
+			buf := &bytes.Buffer{}
+			if err := inv.Serialize(buf); err != nil {
+				t.Fatal(err)
+			}
+			if err = inv.Validate(); (err == nil) != (c.code == "") {
+				t.Errorf("unexpected result of Validate: %v", err)
+			}
+			err = metadata.Parse(buf, &metadata.Inventory{})
+			if verr, ok := err.(*metadata.ValidationError); (err == nil) != (c.code == "") || (err != nil && (!ok || verr.Code != c.code)) {
+				t.Errorf("unexpected result of Parse: %v", err)
+			}
+		})
+	}
+}
+
+func TestRemoveLogicalPath(t *testing.T) {
+	inv := metadata.NewInventory("foo")
+	inv.Manifest = metadata.Manifest{"aa": {"v1/content/a"}, "bb": {"v1/content/b"}}
+	inv.Versions["v1"] = metadata.Version{State: metadata.Manifest{"aa": {"a"}, "bb": {"b"}}}
+	inv.Versions["v2"] = metadata.Version{State: metadata.Manifest{"aa": {"a"}, "bb": {"b", "b.copy"}}}
+	inv.Head = "v2"
+
+	if pruned, err := inv.RemoveLogicalPath("does/not/exist", true); err != nil || pruned != nil {
+		t.Errorf("removing a missing path should do nothing, got %v, %+v", pruned, err)
+	}
+
+	// Content that is still referenced by v1 is never pruned
+	if pruned, _ := inv.RemoveLogicalPath("a", true); pruned != nil {
+		t.Errorf("content of v1 should not have been pruned: %v", pruned)
+	}
+
+	_ = inv.PutFile("c", "v2/content/c", "cc")
+	_ = inv.PutLogicalFile("c.copy", "cc")
+
+	if pruned, _ := inv.RemoveLogicalPath("c", true); pruned != nil {
+		t.Errorf("content still referenced by c.copy should not have been pruned: %v", pruned)
+	}
+	pruned, err := inv.RemoveLogicalPath("c.copy", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(pruned, []string{"v2/content/c"}); diff != nil {
+		t.Error(diff)
+	}
+
+	expected := metadata.Manifest{"aa": {"v1/content/a"}, "bb": {"v1/content/b"}}
+	if diff := deep.Equal(inv.Manifest, expected); diff != nil {
+		t.Errorf("unexpected manifest: %v", diff)
+	}
+	if diff := deep.Equal(inv.Versions["v2"].State, metadata.Manifest{"bb": {"b", "b.copy"}}); diff != nil {
+		t.Errorf("unexpected state: %v", diff)
+	}
+
+	// The indexes remain coherent, so the same paths can be put again
+	if err := inv.PutFile("c", "v2/content/c", "dd"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(inv.Manifest["dd"], []string{"v2/content/c"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestRenameLogicalPath(t *testing.T) {
+	cases := []struct {
+		name string
+		from string
+		to   string
+		code metadata.Code
+		ok   bool
+	}{
+		{"rename", "a", "z", "", true},
+		{"intoDir", "a", "dir/z", "", true},
+		{"intoOwnDir", "a", "a/z", "", true},
+		{"same", "a", "a", "", true},
+		{"missing", "nope", "z", "", false},
+		{"malformed", "a", "../z", metadata.E052, false},
+		{"exists", "a", "b", metadata.E095, false},
+		{"isDir", "a", "dir", metadata.E095, false},
+		{"underFile", "a", "b/z", metadata.E095, false},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			inv := metadata.NewInventory("foo")
+			_ = inv.PutFile("a", "v1/content/a", "aa")
+			_ = inv.PutFile("b", "v1/content/b", "bb")
+			_ = inv.PutFile("dir/c", "v1/content/dir/c", "cc")
+
+			err := inv.RenameLogicalPath(c.from, c.to)
+			if (err == nil) != c.ok {
+				t.Fatalf("expected success: %t, got %v", c.ok, err)
+			}
+			if verr, ok := err.(*metadata.ValidationError); c.code != "" && (!ok || verr.Code != c.code) {
+				t.Errorf("expected code %s, got %v", c.code, err)
+			}
+			if missing := errors.Cause(err) == metadata.ErrNotFound; missing != (c.from == "nope") {
+				t.Errorf("expected not found only for a missing path, got %v", err)
+			}
+
+			expected := metadata.Manifest{"aa": {"a"}, "bb": {"b"}, "cc": {"dir/c"}}
+			if c.ok {
+				expected["aa"] = []string{c.to}
+			}
+			if diff := deep.Equal(inv.Versions["v1"].State, expected); diff != nil {
+				t.Errorf("unexpected state: %v", diff)
+			}
+			if len(inv.Manifest) != 3 {
+				t.Errorf("manifest should not have changed: %v", inv.Manifest)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	inv := metadata.NewInventory("foo")
+	inv.Versions["v1"] = metadata.Version{State: metadata.Manifest{
+		"aa": {"same", "moved", "copied"},
+		"bb": {"changed"},
+		"cc": {"removed"},
+		"dd": {"dup/1", "dup/2"},
+	}}
+	inv.Versions["v2"] = metadata.Version{State: metadata.Manifest{
+		"aa": {"same", "elsewhere/moved", "copied", "copied.2"},
+		"ee": {"changed", "added"},
+		"dd": {"dup/3"},
+	}}
+	inv.Head = "v2"
+
+	diff, err := inv.Diff("v1", "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &metadata.VersionDiff{
+		Added:    []string{"added", "copied.2"},
+		Removed:  []string{"dup/2", "removed"},
+		Modified: []string{"changed"},
+		Renamed: []metadata.Rename{
+			{From: "dup/1", To: "dup/3"},
+			{From: "moved", To: "elsewhere/moved"},
+		},
+	}
+	if d := deep.Equal(diff, expected); d != nil {
+		t.Error(d)
+	}
+
+	if diff, _ = inv.Diff("v2", "v2"); !diff.Empty() {
+		t.Errorf("a version should not differ from itself: %+v", diff)
+	}
+	if _, err = inv.Diff("v1", "v3"); errors.Cause(err) != metadata.ErrNotFound {
+		t.Errorf("expected not found comparing with a missing version, got %v", err)
+	}
+}
+
+func TestDedupManifest(t *testing.T) {
+	sha256 := metadata.Digest(strings.Repeat("ab", 32))
+
+	inv := metadata.NewInventory("foo")
+	inv.Manifest = metadata.Manifest{
+		"aa": {"v10/content/a", "v2/content/a", "v9/content/a"},
+		"bb": {"v1/content/b"},
+	}
+	inv.Fixity = metadata.Fixity{"sha256": {sha256: {"v2/content/a", "v9/content/a", "v10/content/a"}}}
+
+	removed := inv.DedupManifest()
+	if diff := deep.Equal(removed, []string{"v10/content/a", "v9/content/a"}); diff != nil {
+		t.Error(diff)
+	}
+
+	expected := metadata.Manifest{"aa": {"v2/content/a"}, "bb": {"v1/content/b"}}
+	if diff := deep.Equal(inv.Manifest, expected); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(inv.Fixity, metadata.Fixity{"sha256": {sha256: {"v2/content/a"}}}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestFixity(t *testing.T) {
+	sha256 := metadata.Digest(strings.Repeat("ab", 32))
+	md5 := metadata.Digest(strings.Repeat("cd", 16))
+	other := metadata.Digest(strings.Repeat("ef", 16))
+
+	inv := metadata.NewInventory("foo")
+	_ = inv.PutFile("a", "v1/content/a", "aa")
+	_ = inv.PutFile("b", "v1/content/b", "bb")
+
+	if err := inv.AddFixity("v1/content/c", "md5", md5); err == nil {
+		t.Errorf("should not be able to add fixity for content not in the manifest")
+	}
+	if err := inv.AddFixity("v1/content/a", "md5", sha256); err == nil {
+		t.Errorf("should not be able to add a digest of the wrong length")
+	}
+
+	for _, err := range []error{
+		inv.AddFixity("v1/content/a", "sha256", sha256),
+		inv.AddFixity("v1/content/a", "md5", other),
+		inv.AddFixity("v1/content/a", "md5", md5), // replaces the previous md5 digest
+		inv.AddFixity("v1/content/b", "md5", md5),
+	} {
+		if err != nil {
+			t.Fatalf("could not add fixity: %+v", err)
+		}
+	}
+
+	expected := metadata.Fixity{
+		"sha256": {sha256: {"v1/content/a"}},
+		"md5":    {md5: {"v1/content/a", "v1/content/b"}},
+	}
+	if diff := deep.Equal(inv.Fixity, expected); diff != nil {
+		t.Errorf("unexpected fixity block: %v", diff)
+	}
+
+	files, _ := inv.Files("v1")
+	for _, f := range files {
+		if diff := deep.Equal(f.Fixity, inv.GetFixity(f.PhysicalPath)); diff != nil {
+			t.Errorf("fixity of file %s differs from GetFixity: %v", f.LogicalPath, diff)
+		}
+	}
+
+	inv.RemoveFixity("v1/content/a", "md5")
+	if diff := deep.Equal(inv.GetFixity("v1/content/a"), map[metadata.DigestAlgorithm]metadata.Digest{"sha256": sha256}); diff != nil {
+		t.Error(diff)
+	}
+
+	inv.RemoveFixity("v1/content/a")
+	inv.RemoveFixity("v1/content/b")
+	if inv.GetFixity("v1/content/a") != nil || len(inv.Fixity) != 0 {
+		t.Errorf("expected no fixity left, got %v", inv.Fixity)
+	}
+}
+
 func TestDeleteFile(t *testing.T) {
 	before := func() *metadata.Inventory {
 		return &metadata.Inventory{
@@ -609,3 +967,676 @@ func TestNewInventory(t *testing.T) {
 		})
 	}
 }
+
+func TestInventoryType(t *testing.T) {
+	cases := []struct {
+		typ   string
+		valid bool
+	}{
+		{metadata.InventoryType, true},
+		{"Object", false},
+		{"https://ocfl.io/0.1/spec/#inventory", false},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.typ, func(t *testing.T) {
+			inv := metadata.NewInventory("foo")
+			inv.Type = c.typ
+
+			buf := &bytes.Buffer{}
+			_ = inv.Serialize(buf)
+			parsed := &metadata.Inventory{}
+			if err := metadata.Parse(buf, parsed); err != nil {
+				t.Fatalf("could not parse inventory of type %s: %+v", c.typ, err)
+			}
+
+			err := parsed.Validate()
+			if (err == nil) != c.valid || (err != nil && err.(*metadata.ValidationError).Code != metadata.E038) {
+				t.Errorf("expected valid: %t, got %v", c.valid, err)
+			}
+		})
+	}
+}
+
+func TestValidateEmpty(t *testing.T) {
+	empty := metadata.NewInventory("foo")
+	if err := empty.Validate(); err != nil {
+		t.Errorf("new, empty inventory should be valid: %+v", err)
+	}
+
+	err := empty.ClearHead()
+	if err != nil {
+		t.Fatalf("could not clear head %+v", err)
+	}
+	if err := empty.Validate(); err != nil {
+		t.Errorf("cleared inventory should be valid: %+v", err)
+	}
+
+	noState := metadata.NewInventory("foo")
+	noState.Versions["v1"] = metadata.Version{}
+	if err := noState.Validate(); err == nil || err.(*metadata.ValidationError).Code != metadata.E048 {
+		t.Errorf("version without state should be invalid with code E048, got %v", err)
+	}
+
+	noID := metadata.NewInventory("")
+	if err := noID.Validate(); err == nil || err.(*metadata.ValidationError).Code != metadata.E036 {
+		t.Errorf("inventory without ID should be invalid with code E036, got %v", err)
+	}
+}
+
+func TestValidateDigests(t *testing.T) {
+	sha512 := strings.Repeat("ab", 64)
+
+	cases := []struct {
+		name   string
+		digest metadata.Digest
+		valid  bool
+	}{
+		{"good", metadata.Digest(sha512), true},
+		{"tooShort", metadata.Digest(sha512[1:]), false},
+		{"notHex", metadata.Digest("zz" + sha512[2:]), false},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			inv := metadata.NewInventory("foo")
+			inv.Manifest[c.digest] = []string{"v1/content/foo"}
+			inv.Versions["v1"].State[c.digest] = []string{"foo"}
+
+			err := inv.Validate()
+			if (err == nil) != c.valid {
+				t.Errorf("expected valid: %t, got %v", c.valid, err)
+			}
+		})
+	}
+}
+
+func TestDigestCase(t *testing.T) {
+	lower := strings.Repeat("ab", 64)
+	upper := strings.ToUpper(lower)
+
+	d, err := metadata.ParseDigest("sha512", " "+upper+"\n")
+	if err != nil || d != metadata.Digest(lower) {
+		t.Errorf("expected %s, got %s, %v", lower, d, err)
+	}
+	if _, err := metadata.ParseDigest("sha512", lower[2:]); err == nil {
+		t.Errorf("expected an error parsing a short digest")
+	}
+	if !metadata.Digest(upper).Equal(metadata.Digest(lower)) || metadata.Digest(upper).Equal(metadata.Digest(lower[2:]+"00")) {
+		t.Errorf("digests should be compared case insensitively")
+	}
+
+	// Content recorded in uppercase by another tool is matched by lowercase digests
+	inv := metadata.NewInventory("foo")
+	inv.Manifest[metadata.Digest(upper)] = []string{"v1/content/a"}
+	inv.Versions["v1"].State[metadata.Digest(upper)] = []string{"a"}
+
+	if found, ok := inv.Manifest.Find(metadata.Digest(lower)); !ok || found != metadata.Digest(upper) {
+		t.Errorf("expected to find %s, got %s", upper, found)
+	}
+	if err := inv.PutLogicalFile("b", metadata.Digest(lower)); err != nil {
+		t.Fatal(err)
+	}
+	if err := inv.PutFile("c", "v1/content/c", metadata.Digest(lower)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := metadata.Manifest{metadata.Digest(upper): {"a", "b", "c"}}
+	if diff := deep.Equal(inv.Versions["v1"].State, expected); diff != nil {
+		t.Error(diff)
+	}
+	if err := inv.Validate(); err != nil {
+		t.Errorf("inventory should be valid: %v", err)
+	}
+
+	// ..as is content recorded in mixed case
+	mixed := metadata.Digest(strings.Repeat("cD", 64))
+	inv.Manifest[mixed] = []string{"v1/content/d"}
+	if found, ok := inv.Manifest.Find(mixed.Normalize()); !ok || found != mixed {
+		t.Errorf("expected to find %s, got %s", mixed, found)
+	}
+
+	inv = metadata.NewInventory("foo")
+	inv.Manifest[mixed] = []string{"v1/content/d"}
+	if found, ok := inv.FindDigest(mixed.Normalize()); !ok || found != mixed {
+		t.Errorf("expected to find %s, got %s", mixed, found)
+	}
+	if err := inv.PutFile("e", "v1/content/e", mixed.Normalize()); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(inv.Manifest, metadata.Manifest{mixed: {"v1/content/d", "v1/content/e"}}); diff != nil {
+		t.Error(diff)
+	}
+	if _, ok := inv.FindDigest(metadata.Digest(lower)); ok {
+		t.Errorf("should not find content that is not in the manifest")
+	}
+}
+
+func TestVersionNumbering(t *testing.T) {
+	cases := []struct {
+		name     string
+		versions []string
+		head     string
+		code     metadata.Code
+	}{
+		{"continuous", []string{"v1", "v2", "v3"}, "v3", ""},
+		{"gap", []string{"v1", "v3", "v4"}, "v4", metadata.E009},
+		{"notFromOne", []string{"v2", "v3"}, "v3", metadata.E009},
+		{"mixedPadding", []string{"v1", "v02", "v3"}, "v3", metadata.E011},
+		{"headNotHighest", []string{"v1", "v2", "v3"}, "v2", metadata.E040},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			inv := metadata.NewInventory("urn:foo")
+			inv.Versions = make(map[string]metadata.Version)
+			for _, v := range c.versions {
+				inv.Versions[v] = metadata.Version{State: metadata.Manifest{}}
+			}
+			inv.Head = c.head
+
+			var codes []metadata.Code
+			for _, r := range inv.Check().Filter(metadata.SeverityError) {
+				codes = append(codes, r.Code)
+			}
+
+			var expected []metadata.Code
+			if c.code != "" {
+				expected = append(expected, c.code)
+			}
+			if diff := deep.Equal(codes, expected); diff != nil {
+				t.Errorf("%v: %v", diff, inv.Check())
+			}
+
+			if err := inv.Validate(); c.code != "" && (err == nil || err.(*metadata.ValidationError).Code != c.code) {
+				t.Errorf("expected validation to fail with %s, got %v", c.code, err)
+			}
+		})
+	}
+}
+
+func TestPadding(t *testing.T) {
+	cases := []struct {
+		padding metadata.Padding
+		valid   bool
+		first   metadata.VersionID
+		max     int
+	}{
+		{metadata.Unpadded, true, "v1", 0},
+		{2, true, "v01", 9},
+		{4, true, "v0001", 999},
+		{1, false, "", 0},
+		{-1, false, "", 0},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(fmt.Sprint(c.padding), func(t *testing.T) {
+			inv, err := metadata.NewPaddedInventory("urn:foo", c.padding)
+			if (err == nil) != c.valid {
+				t.Fatalf("expected valid: %t, got %v", c.valid, err)
+			}
+			if !c.valid {
+				return
+			}
+
+			if inv.Head != string(c.first) || len(inv.Versions) != 1 || inv.Versions[inv.Head].State == nil {
+				t.Errorf("expected a single version %s, got %s: %v", c.first, inv.Head, inv.Versions)
+			}
+			if p := metadata.VersionID(inv.Head).Padding(); p != c.padding {
+				t.Errorf("expected padding %d, got %d", c.padding, p)
+			}
+			if max := c.padding.MaxVersions(); max != c.max {
+				t.Errorf("expected at most %d versions, got %d", c.max, max)
+			}
+			if err := inv.Validate(); err != nil {
+				t.Errorf("inventory should be valid: %v", err)
+			}
+		})
+	}
+}
+
+func TestPaddingExhausted(t *testing.T) {
+	inv, _ := metadata.NewPaddedInventory("urn:foo", 2)
+	count := func() int {
+		n := 0
+		for _, r := range inv.Check() {
+			if r.Code == metadata.W001 {
+				n++
+			}
+		}
+		return n
+	}
+
+	if n := count(); n != 1 {
+		t.Errorf("expected a single W001 warning, got %d", n)
+	}
+
+	v := inv.Versions[inv.Head]
+	delete(inv.Versions, inv.Head)
+	inv.Head = "v09"
+	inv.Versions["v09"] = v
+	if n := count(); n != 2 {
+		t.Errorf("expected a further W001 warning once no more versions can be added, got %d", n)
+	}
+}
+
+func TestSortVersions(t *testing.T) {
+	versions := []string{"v10", "v0002", "v9", "v1", "v03"}
+	metadata.SortVersions(versions)
+
+	if diffs := deep.Equal([]string{"v1", "v0002", "v03", "v9", "v10"}, versions); len(diffs) > 0 {
+		t.Errorf("versions not sorted numerically: %s", diffs)
+	}
+}
+
+func TestMixedPaddingFiles(t *testing.T) {
+	inv := metadata.Inventory{
+		ID: "mixed",
+		Manifest: metadata.Manifest{
+			"a": {"v1/content/a", "v02/content/a", "v10/content/a"},
+		},
+		Versions: map[string]metadata.Version{
+			"v1":  {State: metadata.Manifest{"a": {"a"}}},
+			"v02": {State: metadata.Manifest{"a": {"a"}}},
+			"v9":  {State: metadata.Manifest{"a": {"a"}}},
+			"v10": {State: metadata.Manifest{"a": {"a"}}},
+		},
+	}
+
+	cases := map[string]string{
+		"v1":  "v1/content/a",
+		"v02": "v02/content/a",
+		"v9":  "v02/content/a",
+		"v10": "v10/content/a",
+	}
+
+	for v, expected := range cases {
+		v, expected := v, expected
+		t.Run(v, func(t *testing.T) {
+			files, err := inv.Files(v)
+			if err != nil {
+				t.Fatalf("error getting files %+v", err)
+			}
+			if files[0].PhysicalPath != expected {
+				t.Errorf("expected %s, got %s", expected, files[0].PhysicalPath)
+			}
+		})
+	}
+
+	if errs := inv.Check().Filter(metadata.SeverityError); len(errs) == 0 || errs[0].Code != metadata.E011 {
+		t.Errorf("expected a padding error, got %s", errs)
+	}
+
+	for _, r := range testInventory.Check() {
+		if r.Code == metadata.E009 || r.Code == metadata.E011 {
+			t.Errorf("expected no numbering errors, got %s", r)
+		}
+	}
+}
+
+func TestPutLogicalFile(t *testing.T) {
+	inv := metadata.NewInventory("foo")
+	_ = inv.PutFile("a", "v1/content/a", "1234")
+
+	err := inv.PutLogicalFile("b", "1234")
+	if err != nil {
+		t.Fatalf("could not put logical file %+v", err)
+	}
+
+	if diffs := deep.Equal([]string{"a", "b"}, inv.Versions["v1"].State["1234"]); len(diffs) > 0 {
+		t.Errorf("%s", diffs)
+	}
+
+	if len(inv.Manifest["1234"]) != 1 {
+		t.Errorf("manifest should not have been modified: %s", inv.Manifest)
+	}
+
+	if err := inv.PutLogicalFile("c", "5678"); err == nil {
+		t.Errorf("should not be able to reference content not in the manifest")
+	}
+}
+
+func TestManifestEqual(t *testing.T) {
+	a := metadata.Manifest{"1": {"a", "b"}, "2": {"c"}}
+
+	cases := []struct {
+		name  string
+		other metadata.Manifest
+		equal bool
+	}{
+		{"reordered", metadata.Manifest{"2": {"c"}, "1": {"b", "a"}}, true},
+		{"differentPath", metadata.Manifest{"1": {"a", "x"}, "2": {"c"}}, false},
+		{"missingDigest", metadata.Manifest{"1": {"a", "b"}}, false},
+		{"differentDigest", metadata.Manifest{"1": {"a", "b"}, "3": {"c"}}, false},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if a.Equal(c.other) != c.equal {
+				t.Errorf("expected equal: %t", c.equal)
+			}
+		})
+	}
+}
+
+func TestVersionRenumber(t *testing.T) {
+	cases := map[metadata.VersionID]metadata.VersionID{
+		"v1":    "v7",
+		"v12":   "v7",
+		"v0003": "v0007",
+	}
+
+	for before, expected := range cases {
+		if after := before.Renumber(7); after != expected {
+			t.Errorf("Renumbering %s resulted in %s instead of %s", before, after, expected)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	inv := metadata.NewInventory("foo")
+
+	results := inv.Check()
+	if results.HasErrors() {
+		t.Errorf("expected no errors, got %s", results)
+	}
+	var warnings []metadata.Code
+	for _, r := range results.Filter(metadata.SeverityWarning) {
+		warnings = append(warnings, r.Code)
+	}
+	if diff := deep.Equal(warnings, []metadata.Code{metadata.W005, metadata.W007}); diff != nil {
+		t.Errorf("%v: %s", diff, results)
+	}
+
+	inv.Versions["v02"] = metadata.Version{State: metadata.Manifest{}}
+	inv.Versions["v3"] = metadata.Version{}
+	results = inv.Check()
+	if !results.HasErrors() {
+		t.Errorf("version without state should be an error")
+	}
+
+	errs := results.Filter(metadata.SeverityError)
+	var codes []metadata.Code
+	for _, r := range errs {
+		codes = append(codes, r.Code)
+	}
+	if diff := deep.Equal(codes, []metadata.Code{metadata.E011, metadata.E048}); diff != nil {
+		t.Errorf("%v: %s", diff, results)
+	}
+
+	encoded, err := json.Marshal(errs[1])
+	if err != nil {
+		t.Fatalf("could not marshal result %+v", err)
+	}
+	expected := `{"objectId":"foo","code":"E048","severity":"error","message":"version v3 of foo has no state; empty versions must have an empty state"}`
+	if string(encoded) != expected {
+		t.Errorf("expected %s, got %s", expected, encoded)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	inv := metadata.NewInventory("foo")
+	inv.Versions["v1"] = metadata.Version{Message: "first", State: metadata.Manifest{
+		"aa": {"b", "a"},
+		"bb": {"c"},
+	}}
+
+	snapshot, err := inv.Snapshot("v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Modifying the inventory's state, including its path slices, leaves the snapshot as it was
+	inv.Versions["v1"].State["aa"][0] = "changed"
+	inv.Versions["v1"].State["cc"] = []string{"d"}
+
+	if d := deep.Equal(snapshot.Paths(), []string{"a", "b", "c"}); d != nil {
+		t.Error(d)
+	}
+	if digest, ok := snapshot.Digest("b"); !ok || digest != "aa" {
+		t.Errorf("expected b to have digest aa, got %s", digest)
+	}
+	if _, ok := snapshot.Digest("d"); ok {
+		t.Errorf("d should not be in the snapshot")
+	}
+	if snapshot.Len() != 3 || snapshot.ID != "v1" || snapshot.Message != "first" {
+		t.Errorf("bad snapshot %+v", snapshot)
+	}
+
+	// ..as does modifying a copy of its state
+	state := snapshot.State()
+	state["aa"][1] = "changed"
+	if d := deep.Equal(snapshot.State(), metadata.Manifest{"aa": {"b", "a"}, "bb": {"c"}}); d != nil {
+		t.Error(d)
+	}
+
+	if _, err = inv.Snapshot("v2"); err == nil {
+		t.Errorf("expected an error for a missing version")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	start := time.Now()
+
+	first := metadata.NewInventory("first")
+	first.Manifest = metadata.Manifest{"aa": {"v1/content/a"}, "cc": {"v2/content/c"}}
+	first.Versions["v1"] = metadata.Version{Created: start, State: metadata.Manifest{"aa": {"a"}}}
+	first.Versions["v2"] = metadata.Version{Created: start.Add(2 * time.Hour), State: metadata.Manifest{"aa": {"a"}, "cc": {"c"}}}
+	first.Head = "v2"
+
+	other := metadata.NewInventory("other")
+	other.Manifest = metadata.Manifest{"AA": {"v1/content/a"}, "bb": {"v1/content/b"}}
+	other.Versions["v1"] = metadata.Version{Created: start.Add(time.Hour), State: metadata.Manifest{"AA": {"a"}, "bb": {"b"}}}
+	other.Fixity = metadata.Fixity{"md5": {"b5": {"v1/content/b"}}}
+
+	inv, sources, err := metadata.Merge(first, other, metadata.MergeOptions{Order: metadata.InterleaveVersions})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]metadata.Manifest{
+		"v1": {"aa": {"a"}},
+		"v2": {"aa": {"a"}, "bb": {"b"}},
+		"v3": {"aa": {"a"}, "bb": {"b"}, "cc": {"c"}},
+	}
+	for v, state := range expected {
+		if d := deep.Equal(inv.Versions[v].State, state); d != nil {
+			t.Errorf("%s: %s", v, d)
+		}
+	}
+	if d := deep.Equal(inv.Manifest, metadata.Manifest{"aa": {"v1/content/a"}, "bb": {"v2/content/b"}, "cc": {"v3/content/c"}}); d != nil {
+		t.Error(d)
+	}
+	if d := deep.Equal(sources["v2/content/b"], metadata.ContentSource{ID: "other", Path: "v1/content/b"}); d != nil {
+		t.Error(d)
+	}
+	if d := deep.Equal(inv.GetFixity("v2/content/b"), map[metadata.DigestAlgorithm]metadata.Digest{"md5": "b5"}); d != nil {
+		t.Error(d)
+	}
+	// Appended, the other object's versions come last
+	inv, _, _ = metadata.Merge(first, other, metadata.MergeOptions{})
+	if inv.Head != "v3" || len(inv.Versions["v2"].State) != 2 || inv.Versions["v3"].Created != other.Versions["v1"].Created {
+		t.Errorf("other object's version should have been appended: %+v", inv.Versions)
+	}
+
+	// Logical paths with different content conflict, unless prefixed
+	other.Versions["v1"].State["bb"] = []string{"b", "c"}
+	if _, _, err = metadata.Merge(first, other, metadata.MergeOptions{}); err == nil {
+		t.Errorf("expected a conflict merging different content at the same path")
+	}
+	if _, _, err = metadata.Merge(first, other, metadata.MergeOptions{Prefix: "other"}); err != nil {
+		t.Errorf("prefixed paths should not conflict: %+v", err)
+	}
+
+	other.DigestAlgorithm = "md5"
+	if _, _, err = metadata.Merge(first, other, metadata.MergeOptions{}); err == nil {
+		t.Errorf("expected an error merging objects with different digest algorithms")
+	}
+}
+
+func TestNewInventoryOptions(t *testing.T) {
+	inv := metadata.NewInventory("foo")
+	if inv.DigestAlgorithm != "sha512" || inv.Type != metadata.InventoryType || inv.ContentDirectory() != "content" {
+		t.Errorf("bad defaults %+v", inv)
+	}
+	if inv.Versions[inv.Head].State == nil {
+		t.Errorf("initial state should be allocated")
+	}
+
+	created := time.Date(2020, 1, 2, 3, 4, 5, 6789123, time.FixedZone("EST", -5*60*60))
+	inv = metadata.NewInventory("foo",
+		metadata.WithDigestAlgorithm("sha256"),
+		metadata.WithContentDirectory("data"),
+		metadata.WithSpecVersion("1.1"),
+		metadata.WithCreated(created))
+
+	if inv.DigestAlgorithm != "sha256" || inv.ContentDir != "data" || inv.Type != "https://ocfl.io/1.1/spec/#inventory" {
+		t.Errorf("options not applied %+v", inv)
+	}
+	if got := inv.Versions["v1"].Created; got != time.Date(2020, 1, 2, 8, 4, 5, 6000000, time.UTC) {
+		t.Errorf("bad creation date %s", got)
+	}
+
+	if inv = metadata.NewInventory("foo", metadata.WithContentDirectory("content")); inv.ContentDir != "" {
+		t.Errorf("default content directory should not be recorded, got %s", inv.ContentDir)
+	}
+
+	padded, _ := metadata.NewPaddedInventory("foo", 3, metadata.WithCreated(created))
+	if padded.Head != "v001" || !padded.Versions["v001"].Created.Equal(created.Truncate(time.Millisecond)) {
+		t.Errorf("options should apply to padded inventories %+v", padded)
+	}
+}
+
+func TestTimestampPrecision(t *testing.T) {
+	raw := `{"id": "foo", "type": "` + metadata.InventoryType + `", "digestAlgorithm": "sha512", "head": "v2",
+		"manifest": {}, "versions": {
+			"v1": {"created": "2019-01-02T03:04:05.1-05:00", "state": {}},
+			"v2": {"created": "2019-01-03T03:04:05Z", "state": {}}}}`
+
+	inv := metadata.Inventory{}
+	if err := metadata.Parse(strings.NewReader(raw), &inv); err != nil {
+		t.Fatal(err)
+	}
+
+	// Dates are normalized to UTC
+	if created := inv.Versions["v1"].Created; created != time.Date(2019, 1, 2, 8, 4, 5, 100000000, time.UTC) {
+		t.Errorf("created date should be in UTC, got %s", created)
+	}
+
+	created := func(inv *metadata.Inventory) map[string]string {
+		var buf bytes.Buffer
+		_ = inv.Serialize(&buf)
+
+		var written struct {
+			Versions map[string]struct {
+				Created string `json:"created"`
+			} `json:"versions"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &written); err != nil {
+			t.Fatal(err)
+		}
+
+		dates := make(map[string]string)
+		for v, version := range written.Versions {
+			dates[v] = version.Created
+		}
+		return dates
+	}
+
+	// Unchanged dates are written as they were read
+	if d := deep.Equal(created(&inv), map[string]string{
+		"v1": "2019-01-02T03:04:05.1-05:00",
+		"v2": "2019-01-03T03:04:05Z",
+	}); d != nil {
+		t.Error(d)
+	}
+
+	// ..and changed ones according to the precision
+	v2 := inv.Versions["v2"]
+	v2.Created = time.Date(2020, 1, 2, 3, 4, 5, 600000000, time.FixedZone("EST", -5*60*60))
+	inv.Versions["v2"] = v2
+	if d := deep.Equal(created(&inv)["v2"], "2020-01-02T08:04:05.600Z"); d != nil {
+		t.Error(d)
+	}
+
+	inv.TimestampPrecision = metadata.Seconds
+	if d := deep.Equal(created(&inv)["v2"], "2020-01-02T08:04:05Z"); d != nil {
+		t.Error(d)
+	}
+
+	// Dates truncated to the precision are written exactly
+	for _, p := range []metadata.TimestampPrecision{metadata.Milliseconds, metadata.Seconds} {
+		date := time.Date(2020, 1, 2, 3, 4, 5, 678901234, time.FixedZone("EST", -5*60*60))
+		parsed, _ := time.Parse(time.RFC3339Nano, p.Format(date))
+		if truncated := p.Truncate(date); truncated != parsed.UTC() {
+			t.Errorf("expected %s truncated to %s, got %s", date, parsed.UTC(), truncated)
+		}
+	}
+
+	bad := strings.Replace(raw, "2019-01-03T03:04:05Z", "yesterday", 1)
+	if err := metadata.Parse(strings.NewReader(bad), &metadata.Inventory{}); err == nil {
+		t.Errorf("expected an error parsing a malformed date")
+	}
+}
+
+func TestManifestQueries(t *testing.T) {
+	inv := metadata.NewInventory("foo")
+	inv.Manifest = metadata.Manifest{
+		"aa": {"v1/content/a"},
+		"bb": {"v2/content/b", "v2/content/b.copy"},
+	}
+	inv.Versions["v1"] = metadata.Version{State: metadata.Manifest{"aa": {"z", "a"}}}
+	inv.Versions["v2"] = metadata.Version{State: metadata.Manifest{"aa": {"a"}, "bb": {"b"}}}
+	inv.Versions["v3"] = metadata.Version{State: metadata.Manifest{"bb": {"b"}}}
+	inv.Head = "v3"
+
+	if digest, ok := inv.DigestOf("v2/content/b.copy"); !ok || digest != "bb" {
+		t.Errorf("expected digest bb, got %s", digest)
+	}
+	if _, ok := inv.DigestOf("v2/content/missing"); ok {
+		t.Errorf("missing path should not have a digest")
+	}
+
+	if d := deep.Equal(inv.LogicalPaths("v1", "AA"), []string{"a", "z"}); d != nil {
+		t.Error(d)
+	}
+	if paths := inv.LogicalPaths("v3", "aa"); paths != nil {
+		t.Errorf("expected no paths, got %v", paths)
+	}
+
+	if d := deep.Equal(inv.VersionsWith("aa"), []string{"v1", "v2"}); d != nil {
+		t.Error(d)
+	}
+	if versions := inv.VersionsWith("cc"); versions != nil {
+		t.Errorf("expected no versions, got %v", versions)
+	}
+}
+
+func TestCaseConflict(t *testing.T) {
+	inv := metadata.NewInventory("foo")
+	_ = inv.PutFile("a", "v1/content/a", "aa")
+
+	if p, conflict := inv.CaseConflict("v1/content/A"); !conflict || p != "v1/content/a" {
+		t.Errorf("expected a conflict with v1/content/a, got %q", p)
+	}
+	if p, conflict := inv.CaseConflict("v1/content/a"); conflict {
+		t.Errorf("a path should not conflict with itself, got %q", p)
+	}
+
+	// The index is kept up to date as content is put and removed
+	_ = inv.PutFile("B", "v1/content/B", "bb")
+	if p, conflict := inv.CaseConflict("v1/content/b"); !conflict || p != "v1/content/B" {
+		t.Errorf("expected a conflict with v1/content/B, got %q", p)
+	}
+	if _, err := inv.RemoveLogicalPath("B", true); err != nil {
+		t.Fatal(err)
+	}
+	if p, conflict := inv.CaseConflict("v1/content/b"); conflict {
+		t.Errorf("expected no conflict with removed content, got %q", p)
+	}
+}
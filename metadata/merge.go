@@ -0,0 +1,219 @@
+package metadata
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MergeOrder determines how the versions of merged objects are ordered
+type MergeOrder int
+
+// Merge orders
+const (
+	AppendVersions     MergeOrder = iota // The versions of the other object follow those of the first (default)
+	InterleaveVersions                   // Versions are ordered by creation date, each object's remaining in order
+)
+
+// MergeOptions configure the merging of two objects' histories
+type MergeOptions struct {
+	Order  MergeOrder // How versions are ordered
+	Prefix string     // If given, the other object's logical paths are placed under this directory
+}
+
+// ContentSource identifies the content file that a path in a merged manifest is copied from
+type ContentSource struct {
+	ID   string // ID of the object containing the content
+	Path string // Path of the content, relative to the object root
+}
+
+// a version of either of the merged objects
+type mergeEntry struct {
+	inv     *Inventory
+	version string
+	other   bool
+}
+
+// Merge combines the histories of two objects into a new inventory, having the ID, content
+// directory, and version padding of the first.  Returns the inventory, and the source of each
+// path in its manifest, which is to be copied into the merged object.
+//
+// Each merged version has the metadata of a version of either object, and a state combining the
+// state of that version with the state of the other object at that point in the history, so that
+// files of both objects are present once introduced.  Both objects must use the same digest
+// algorithm.  Content with the same digest in both is considered identical, and stored once.  It
+// is an error if a logical path has different content in each object, or is a file in one and a
+// directory in the other (see MergeOptions.Prefix).
+func Merge(first, other *Inventory, opts MergeOptions) (*Inventory, map[string]ContentSource, error) {
+	if first.DigestAlgorithm != other.DigestAlgorithm {
+		return nil, nil, fmt.Errorf("cannot merge %s into %s: digest algorithms %s and %s differ",
+			other.ID, first.ID, other.DigestAlgorithm, first.DigestAlgorithm)
+	}
+	if opts.Prefix != "" {
+		if err := ValidateLogicalPath(opts.Prefix); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	entries := mergeOrder(first, other, opts.Order)
+
+	head := VersionID(first.Head)
+	if max := head.Padding().MaxVersions(); max > 0 && len(entries) > max {
+		return nil, nil, fmt.Errorf("cannot merge %s into %s: %d versions exceed the maximum of %d allowed by its padding",
+			other.ID, first.ID, len(entries), max)
+	}
+
+	merged := &Inventory{
+		ID:              first.ID,
+		Type:            InventoryType,
+		DigestAlgorithm: first.DigestAlgorithm,
+		ContentDir:      first.ContentDir,
+		Manifest:        make(Manifest),
+		Versions:        make(map[string]Version, len(entries)),
+	}
+	sources := make(map[string]ContentSource)
+
+	digests := make(map[*Inventory]map[string]Digest, 2)
+	fixity := make(map[*Inventory]map[string]map[DigestAlgorithm]Digest, 2)
+	for _, inv := range []*Inventory{first, other} {
+		index, err := index(inv.Manifest)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not index manifest of %s: %s", inv.ID, err)
+		}
+		digests[inv] = index
+		fixity[inv] = inv.fixityIndex()
+	}
+
+	var states [2]map[string]Digest
+	for n, e := range entries {
+		vid := string(head.Renumber(n + 1))
+		v := e.inv.Versions[e.version]
+
+		// Content new to the version, that is not already in the merged manifest
+		srcContent := e.version + "/" + e.inv.ContentDirectory() + "/"
+		var paths []string
+		for p := range digests[e.inv] {
+			if strings.HasPrefix(p, srcContent) {
+				paths = append(paths, p)
+			}
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			digest := digests[e.inv][p].Normalize()
+			if _, exists := merged.Manifest[digest]; exists {
+				continue
+			}
+
+			newPath := vid + "/" + merged.ContentDirectory() + "/" + strings.TrimPrefix(p, srcContent)
+			merged.Manifest[digest] = []string{newPath}
+			sources[newPath] = ContentSource{ID: e.inv.ID, Path: p}
+
+			for alg, d := range fixity[e.inv][p] {
+				if merged.Fixity == nil {
+					merged.Fixity = make(Fixity)
+				}
+				if merged.Fixity[alg] == nil {
+					merged.Fixity[alg] = make(Manifest)
+				}
+				merged.Fixity[alg][d] = append(merged.Fixity[alg][d], newPath)
+			}
+		}
+
+		state := make(map[string]Digest, len(v.State))
+		for digest, lpaths := range v.State {
+			for _, p := range lpaths {
+				if e.other && opts.Prefix != "" {
+					p = path.Join(opts.Prefix, p)
+				}
+				state[p] = digest.Normalize()
+			}
+		}
+		if e.other {
+			states[1] = state
+		} else {
+			states[0] = state
+		}
+
+		combined, err := combineStates(states[0], states[1])
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "cannot merge %s %s into %s", e.inv.ID, e.version, first.ID)
+		}
+
+		merged.Versions[vid] = Version{
+			Created: v.Created,
+			Message: v.Message,
+			User:    v.User,
+			Type:    v.Type,
+			State:   combined,
+		}
+		merged.Head = vid
+	}
+
+	return merged, sources, nil
+}
+
+// Lists the versions of both objects in merged order
+func mergeOrder(first, other *Inventory, order MergeOrder) []mergeEntry {
+	var a, b []mergeEntry
+	for _, v := range first.VersionNames() {
+		a = append(a, mergeEntry{inv: first, version: v})
+	}
+	for _, v := range other.VersionNames() {
+		b = append(b, mergeEntry{inv: other, version: v, other: true})
+	}
+
+	if order != InterleaveVersions {
+		return append(a, b...)
+	}
+
+	entries := make([]mergeEntry, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		if b[0].inv.Versions[b[0].version].Created.Before(a[0].inv.Versions[a[0].version].Created) {
+			entries, b = append(entries, b[0]), b[1:]
+		} else {
+			entries, a = append(entries, a[0]), a[1:]
+		}
+	}
+	return append(append(entries, a...), b...)
+}
+
+// Combines the logical files of two states, which must not conflict
+func combineStates(a, b map[string]Digest) (Manifest, error) {
+	dirs := make(map[string]bool)
+	for p := range a {
+		for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+
+	combined := make(Manifest, len(a)+len(b))
+	for p, digest := range a {
+		combined[digest] = append(combined[digest], p)
+	}
+
+	for p, digest := range b {
+		if d, exists := a[p]; exists {
+			if d != digest {
+				return nil, invalid(E095, "%s has different content in each object", p)
+			}
+			continue
+		}
+		if dirs[p] {
+			return nil, invalid(E095, "%s is a directory in one object, and a file in the other", p)
+		}
+		for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+			if _, exists := a[dir]; exists {
+				return nil, invalid(E095, "%s is a file in one object, and a directory in the other", dir)
+			}
+		}
+		combined[digest] = append(combined[digest], p)
+	}
+
+	for _, paths := range combined {
+		sort.Strings(paths)
+	}
+	return combined, nil
+}
@@ -0,0 +1,44 @@
+package metadata
+
+import (
+	"time"
+)
+
+// InventoryOption changes a default of an inventory created by NewInventory
+type InventoryOption func(*Inventory)
+
+// WithDigestAlgorithm sets the digest algorithm of the inventory's manifest and states
+func WithDigestAlgorithm(alg DigestAlgorithm) InventoryOption {
+	return func(i *Inventory) {
+		i.DigestAlgorithm = alg
+	}
+}
+
+// WithContentDirectory sets the name of the content directory of each version.  The
+// default name, content, is not recorded in the inventory.
+func WithContentDirectory(dir string) InventoryOption {
+	return func(i *Inventory) {
+		if dir == contentDir {
+			dir = ""
+		}
+		i.ContentDir = dir
+	}
+}
+
+// WithSpecVersion sets the type of the inventory to that of the given version of the OCFL
+// spec (e.g. 1.0).  Inventories of versions other than SpecVersion do not validate.
+func WithSpecVersion(version string) InventoryOption {
+	return func(i *Inventory) {
+		i.Type = "https://ocfl.io/" + version + "/spec/#inventory"
+	}
+}
+
+// WithCreated sets the creation date of the initial version, in UTC, and truncated to
+// the millisecond precision inventories are written with.
+func WithCreated(created time.Time) InventoryOption {
+	return func(i *Inventory) {
+		v := i.Versions[i.Head]
+		v.Created = created.UTC().Truncate(1 * time.Millisecond)
+		i.Versions[i.Head] = v
+	}
+}
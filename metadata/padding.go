@@ -0,0 +1,65 @@
+package metadata
+
+import (
+	"fmt"
+	"math"
+)
+
+// Padding is a policy for numbering the versions of new objects.  Unpadded
+// version numbers (v1, v2, ...) are recommended by the OCFL spec, but padded
+// ones are allowed, in which case Padding is the number of digits (e.g. 3 for
+// v001, v002, ...).  As padded version numbers must keep a leading zero, an
+// object may have at most 10^(Padding-1) - 1 versions.
+type Padding int
+
+// Unpadded version numbers, the default
+const Unpadded Padding = 0
+
+// Validate verifies that the padding allows at least one version
+func (p Padding) Validate() error {
+	if p < 0 || p == 1 {
+		return fmt.Errorf("invalid version padding %d: must be unpadded (0), or at least 2 digits", p)
+	}
+	return nil
+}
+
+// First returns the ID of the first version of an object, under the policy
+func (p Padding) First() VersionID {
+	if p == Unpadded {
+		return VersionID(fmt.Sprintf(vfmt, 1))
+	}
+	return VersionID(fmt.Sprintf("v%0*d", int(p), 1))
+}
+
+// MaxVersions returns the maximum number of versions allowed by the policy, or
+// zero if unlimited
+func (p Padding) MaxVersions() int {
+	if p == Unpadded {
+		return 0
+	}
+	return int(math.Pow10(int(p)-1)) - 1
+}
+
+// Padding returns the padding of the version ID, or Unpadded if it is not padded
+func (v VersionID) Padding() Padding {
+	if padded(string(v)) {
+		return Padding(len(v) - 1)
+	}
+	return Unpadded
+}
+
+// NewPaddedInventory creates an inventory for a new object, like NewInventory,
+// whose versions are zero padded according to the given policy.
+func NewPaddedInventory(id string, padding Padding, opts ...InventoryOption) (*Inventory, error) {
+	if err := padding.Validate(); err != nil {
+		return nil, err
+	}
+
+	inv := NewInventory(id, opts...)
+	if first := padding.First(); first != VersionID(inv.Head) {
+		inv.Versions[string(first)] = inv.Versions[inv.Head]
+		delete(inv.Versions, inv.Head)
+		inv.Head = string(first)
+	}
+	return inv, nil
+}
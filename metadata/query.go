@@ -0,0 +1,93 @@
+package metadata
+
+import (
+	"sort"
+	"strings"
+)
+
+// DigestOf returns the digest of the content file at the given physical path (relative to
+// the object root), and whether it is in the manifest.  The manifest is indexed upon first
+// use, so that repeated lookups need not scan it.
+func (i *Inventory) DigestOf(physicalPath string) (Digest, bool) {
+	if err := i.indexManifest(); err != nil {
+		// A path in the manifest under several digests is ambiguous, so it is looked up by scanning
+		for digest, paths := range i.Manifest {
+			if contains(paths, physicalPath) {
+				return digest, true
+			}
+		}
+		return "", false
+	}
+
+	digest, ok := i.manifestIndex[physicalPath]
+	return digest, ok
+}
+
+// FindDigest looks up a digest in the manifest, as Manifest.Find does, comparing digests
+// case insensitively.  The manifest's digests are indexed by their lower case upon first
+// use, and the index is kept up to date as content is put, so that repeated lookups (e.g.
+// of content that is not yet in the manifest) need not scan it.
+func (i *Inventory) FindDigest(d Digest) (Digest, bool) {
+	if i.digestIndex == nil {
+		i.digestIndex = make(map[Digest]Digest, len(i.Manifest))
+		for digest := range i.Manifest {
+			i.digestIndex[digest.Normalize()] = digest
+		}
+	}
+
+	// Entries for digests since removed from the manifest are ignored
+	digest, ok := i.digestIndex[d.Normalize()]
+	if _, present := i.Manifest[digest]; !ok || !present {
+		return "", false
+	}
+	return digest, true
+}
+
+// CaseConflict returns a physical path in the manifest that differs from the given one
+// only by case, and whether there is one; on case insensitive filesystems, both would
+// refer to the same file.  The manifest's paths are indexed by their lower case upon
+// first use, and the index is kept up to date as content is put or removed, so that
+// repeated lookups need not scan the manifest.
+func (i *Inventory) CaseConflict(physicalPath string) (string, bool) {
+	if i.foldedIndex == nil {
+		i.foldedIndex = make(map[string][]string, len(i.Manifest))
+		for _, paths := range i.Manifest {
+			for _, p := range paths {
+				i.foldPath(p)
+			}
+		}
+	}
+
+	for _, p := range i.foldedIndex[strings.ToLower(physicalPath)] {
+		if p != physicalPath {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// LogicalPaths returns the sorted logical paths of the files in the given version whose
+// content has the given digest, compared case-insensitively (see Manifest.Find).
+func (i *Inventory) LogicalPaths(version string, digest Digest) []string {
+	state := i.Versions[version].State
+	found, ok := state.Find(digest)
+	if !ok {
+		return nil
+	}
+
+	paths := append([]string(nil), state[found]...)
+	sort.Strings(paths)
+	return paths
+}
+
+// VersionsWith returns the versions whose state contains content with the given digest,
+// compared case-insensitively (see Manifest.Find), in order.
+func (i *Inventory) VersionsWith(digest Digest) []string {
+	var versions []string
+	for _, v := range i.VersionNames() {
+		if _, ok := i.Versions[v].State.Find(digest); ok {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
@@ -0,0 +1,97 @@
+package metadata
+
+import (
+	"fmt"
+)
+
+// Severity distinguishes validation errors, which make an object invalid,
+// from warnings about discouraged conditions
+type Severity string
+
+// Validation result severities
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Severity of problems identified by the code
+func (c Code) Severity() Severity {
+	if c.IsError() {
+		return SeverityError
+	}
+	return SeverityWarning
+}
+
+// ValidationResult is a single problem found by validation, in a form suitable
+// for processing by other tools (e.g. as JSON)
+type ValidationResult struct {
+	ObjectID string   `json:"objectId,omitempty"` // ID of the object, if it could be determined
+	Code     Code     `json:"code,omitempty"`     // OCFL validation code, or empty if the problem is not defined by the spec
+	Severity Severity `json:"severity"`
+	Path     string   `json:"path,omitempty"` // Path of the offending file, relative to the object root, if any
+	Message  string   `json:"message"`
+}
+
+func (r ValidationResult) String() string {
+	var s string
+	if r.Code != "" {
+		s = fmt.Sprintf("[%s] ", r.Code)
+	}
+	if r.Path != "" {
+		s += r.Path + ": "
+	}
+	return fmt.Sprintf("%s: %s%s", r.Severity, s, r.Message)
+}
+
+// ValidationResults are the problems found by validation
+type ValidationResults []ValidationResult
+
+// HasErrors indicates whether any of the results are errors
+func (r ValidationResults) HasErrors() bool {
+	for _, result := range r {
+		if result.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the results of the given severity
+func (r ValidationResults) Filter(severity Severity) ValidationResults {
+	var filtered ValidationResults
+	for _, result := range r {
+		if result.Severity == severity {
+			filtered = append(filtered, result)
+		}
+	}
+	return filtered
+}
+
+// Check validates the inventory (see Validate), and reports any problems
+// found as validation results, including departures from the spec's
+// recommendations (e.g. versions without a message or user)
+func (i *Inventory) Check() ValidationResults {
+	var results ValidationResults
+	add := func(problem *ValidationError) {
+		results = append(results, ValidationResult{
+			ObjectID: i.ID,
+			Code:     problem.Code,
+			Severity: problem.Code.Severity(),
+			Message:  problem.Message,
+		})
+	}
+
+	// Version numbering problems are reported even when Validate stops at
+	// another error first
+	numbering := i.validateVersionNames()
+	if numbering != nil {
+		add(numbering.(*ValidationError))
+	}
+	if err := i.Validate(); err != nil && (numbering == nil || err.Error() != numbering.Error()) {
+		add(err.(*ValidationError))
+	}
+	for _, w := range i.recommendations() {
+		add(w)
+	}
+	return results
+}
@@ -0,0 +1,73 @@
+package metadata
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Snapshot is an immutable view of a version of an object.  Unlike the inventory's
+// Version, which shares its state with the inventory, a snapshot may be held and
+// shared safely while the inventory is modified (e.g. by a session writing a new
+// version).
+type Snapshot struct {
+	ID      string    // The version ID, e.g. v1
+	Created time.Time // Creation date of the version
+	Message string    // Commit message of the version
+	User    User      // Committer of the version
+
+	state Manifest
+	index map[string]Digest
+}
+
+// Snapshot returns an immutable snapshot of the given version of the inventory
+func (i *Inventory) Snapshot(version string) (*Snapshot, error) {
+	v, ok := i.Versions[version]
+	if !ok {
+		return nil, errors.Wrapf(ErrNotFound, "version %s of %s", version, i.ID)
+	}
+
+	state := v.State.Clone()
+	idx, err := index(state)
+	if err != nil {
+		return nil, fmt.Errorf("could not index state of %s %s: %s", i.ID, version, err)
+	}
+
+	return &Snapshot{
+		ID:      version,
+		Created: v.Created,
+		Message: v.Message,
+		User:    v.User,
+		state:   state,
+		index:   idx,
+	}, nil
+}
+
+// Digest returns the digest of the content of the given logical path, and whether
+// the path is present in the version.
+func (s *Snapshot) Digest(logicalPath string) (Digest, bool) {
+	digest, ok := s.index[logicalPath]
+	return digest, ok
+}
+
+// Paths returns the sorted logical paths of the version
+func (s *Snapshot) Paths() []string {
+	paths := make([]string, 0, len(s.index))
+	for p := range s.index {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Len returns the number of logical paths in the version
+func (s *Snapshot) Len() int {
+	return len(s.index)
+}
+
+// State returns a copy of the state of the version, which the caller may modify
+func (s *Snapshot) State() Manifest {
+	return s.state.Clone()
+}
@@ -0,0 +1,98 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// TimestampPrecision is a policy for serializing the creation dates of versions.  The OCFL
+// spec allows any RFC3339 date, so implementations differ in fractional precision and time
+// zone.  Dates are always written in UTC, with a fixed precision, except for those of
+// versions that are unchanged since their inventory was parsed, which are written exactly
+// as they were read, so that re-serializing an inventory does not change it.
+type TimestampPrecision int
+
+// Timestamp precisions
+const (
+	Milliseconds TimestampPrecision = iota // e.g. 2019-01-02T03:04:05.678Z (default)
+	Seconds                                // e.g. 2019-01-02T03:04:05Z
+)
+
+// Format formats a date according to the policy
+func (p TimestampPrecision) Format(t time.Time) string {
+	switch p {
+	case Seconds:
+		return t.UTC().Format("2006-01-02T15:04:05Z07:00")
+	default:
+		return t.UTC().Format("2006-01-02T15:04:05.000Z07:00")
+	}
+}
+
+// Truncate returns the date in UTC, truncated to the precision of the policy, so that
+// it is the date read back once it is written.
+func (p TimestampPrecision) Truncate(t time.Time) time.Time {
+	switch p {
+	case Seconds:
+		return t.UTC().Truncate(time.Second)
+	default:
+		return t.UTC().Truncate(time.Millisecond)
+	}
+}
+
+// MarshalJSON serializes the inventory, writing the creation dates of versions according
+// to its TimestampPrecision.
+func (i *Inventory) MarshalJSON() ([]byte, error) {
+	type inventory Inventory // Without methods, to avoid recursion
+
+	cp := inventory(*i)
+	if i.Versions != nil {
+		cp.Versions = make(map[string]Version, len(i.Versions))
+		for name, v := range i.Versions {
+			v.precision = i.TimestampPrecision
+			cp.Versions[name] = v
+		}
+	}
+	return json.Marshal(&cp)
+}
+
+// MarshalJSON serializes the version, writing its creation date as it was parsed if
+// it is unchanged, or according to the precision of its inventory otherwise.
+func (v Version) MarshalJSON() ([]byte, error) {
+	type version Version // Without methods, to avoid recursion
+
+	created := v.created
+	if parsed, err := time.Parse(time.RFC3339Nano, created); err != nil || !parsed.Equal(v.Created) {
+		created = v.precision.Format(v.Created)
+	}
+
+	return json.Marshal(struct {
+		Created string `json:"created"`
+		version
+	}{created, version(v)})
+}
+
+// UnmarshalJSON parses the version, normalizing its creation date to UTC while
+// retaining how it was written.
+func (v *Version) UnmarshalJSON(b []byte) error {
+	type version Version // Without methods, to avoid recursion
+
+	parsed := struct {
+		Created string `json:"created"`
+		*version
+	}{version: (*version)(v)}
+	if err := json.Unmarshal(b, &parsed); err != nil {
+		return err
+	}
+
+	v.created = parsed.Created
+	v.Created = time.Time{}
+	if parsed.Created != "" {
+		created, err := time.Parse(time.RFC3339Nano, parsed.Created)
+		if err != nil {
+			return fmt.Errorf("bad created date '%s': %s", parsed.Created, err)
+		}
+		v.Created = created.UTC()
+	}
+	return nil
+}
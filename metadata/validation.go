@@ -1,6 +1,13 @@
 package metadata
 
-// Validate verifies whether inventory metadata is internally consistent and allowable by the OCFL spec
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Validate verifies whether inventory metadata is internally consistent and allowable by the OCFL spec.
+// Any error returned is a *ValidationError, identifying the violated requirement by its code.
 // A positive result (no error returned) means only that a given manifest reflects a plausible internal state.  It does
 // not imply that the files referenced by the manifest actually exist, or match their claimed checksums, etc.
 //
@@ -26,9 +33,258 @@ package metadata
 //
 // Digest values match the length and composition implied by their algorithm.
 //
-// Version numbers increase monotonically, and have the same zero padding convention
+// Version numbers start at v1, are continuous, and have the same zero padding convention
+//
+// Empty objects and versions
+//
+// A version with zero files is valid, so an object may consist solely of empty
+// versions.  However, every version must still declare its state, even if it is
+// an empty one; a missing (null) state is invalid.  Likewise, the manifest must
+// be present, even if empty.
 func (i *Inventory) Validate() error {
 
-	// TODO: implement
+	if err := i.validateRequired(); err != nil {
+		return err
+	}
+
+	if err := i.validateDigests(); err != nil {
+		return err
+	}
+
+	if err := i.validateVersionNames(); err != nil {
+		return err
+	}
+
+	if err := i.validateHead(); err != nil {
+		return err
+	}
+
+	if err := i.validateState(); err != nil {
+		return err
+	}
+
+	if err := i.validateLogicalPaths(); err != nil {
+		return err
+	}
+
+	if dir := i.ContentDir; dir != "" && (dir == "." || dir == ".." || strings.Contains(dir, "/")) {
+		return invalid(E017, "content directory '%s' of %s is not a valid directory name", dir, i.ID)
+	}
+
+	// TODO: implement remaining checks
+	return nil
+}
+
+// Verify that versions are validly named, numbered continuously from v1, and
+// padded consistently
+func (i *Inventory) validateVersionNames() error {
+	names := i.VersionNames()
+	for _, name := range names {
+		if !VersionID(name).Valid() {
+			return invalid(E009, "version %s of %s is not a valid version name", name, i.ID)
+		}
+	}
+
+	if len(names) > 1 && !consistentPadding(names) {
+		return invalid(E011, "versions of %s use inconsistent zero padding: %v", i.ID, names)
+	}
+
+	if missing, first := missingVersions(names); missing > 0 {
+		return invalid(E009, "versions of %s are not numbered continuously from v1: %d missing, starting with v%d",
+			i.ID, missing, first)
+	}
+	return nil
+}
+
+// Verify that the head is the highest version
+func (i *Inventory) validateHead() error {
+	if _, ok := i.Versions[i.Head]; !ok {
+		return invalid(E040, "head %s of %s is not a version in the inventory", i.Head, i.ID)
+	}
+
+	names := i.VersionNames()
+	if highest := names[len(names)-1]; highest != i.Head {
+		return invalid(E040, "head %s of %s is not the highest version, %s", i.Head, i.ID, highest)
+	}
+	return nil
+}
+
+// Verify that every digest in the state of each version is in the manifest
+func (i *Inventory) validateState() error {
+	for _, name := range i.VersionNames() {
+		for digest := range i.Versions[name].State {
+			if _, ok := i.Manifest[digest]; !ok {
+				return invalid(E050, "digest %s in the state of version %s of %s is not in the manifest", digest, name, i.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// Verify that every logical path in the state of each version is well formed
+func (i *Inventory) validateLogicalPaths() error {
+	for name, v := range i.Versions {
+		for _, paths := range v.State {
+			for _, path := range paths {
+				if err := ValidateLogicalPath(path); err != nil {
+					err.Message = fmt.Sprintf("%s, in version %s of %s", err.Message, name, i.ID)
+					return err
+				}
+			}
+		}
+	}
 	return nil
 }
+
+// ValidateLogicalPath verifies that a logical path is a sequence of one or more
+// path elements joined by '/', none of which are empty, '.' or '..'.  Paths
+// containing backslashes are rejected too, as other implementations may
+// interpret them as separators.
+func ValidateLogicalPath(path string) *ValidationError {
+	switch {
+	case path == "":
+		return invalid(E052, "logical path is empty")
+	case strings.HasPrefix(path, "/") || strings.HasSuffix(path, "/"):
+		return invalid(E053, "logical path '%s' begins or ends with /", path)
+	case strings.Contains(path, "\\"):
+		return invalid(E051, "logical path '%s' contains a backslash; elements must be separated by /", path)
+	}
+
+	for _, elem := range strings.Split(path, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return invalid(E052, "logical path '%s' contains an empty, '.', or '..' element", path)
+		}
+	}
+	return nil
+}
+
+// Verify that digests are well-formed hex strings of the length
+// implied by their algorithm, if known.
+func (i *Inventory) validateDigests() error {
+	for digest := range i.Manifest {
+		if err := digest.Validate(i.DigestAlgorithm); err != nil {
+			return invalid(E039, "bad manifest digest in %s: %s", i.ID, err)
+		}
+	}
+
+	for name, v := range i.Versions {
+		for digest := range v.State {
+			if err := digest.Validate(i.DigestAlgorithm); err != nil {
+				return invalid(E039, "bad state digest in version %s of %s: %s", name, i.ID, err)
+			}
+		}
+	}
+
+	for alg, manifest := range i.Fixity {
+		for digest := range manifest {
+			if err := digest.Validate(alg); err != nil {
+				return invalid(E057, "bad fixity digest in %s: %s", i.ID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Verify the presence of required values
+func (i *Inventory) validateRequired() error {
+	switch {
+	case i.ID == "":
+		return invalid(E036, "inventory is missing an id")
+	case i.Type == "":
+		return invalid(E036, "inventory of %s is missing a type", i.ID)
+	case i.DigestAlgorithm == "":
+		return invalid(E036, "inventory of %s is missing a digest algorithm", i.ID)
+	case i.Head == "":
+		return invalid(E036, "inventory of %s is missing a head version", i.ID)
+	case i.Manifest == nil:
+		return invalid(E041, "inventory of %s is missing a manifest", i.ID)
+	case len(i.Versions) == 0:
+		return invalid(E008, "inventory of %s has no versions", i.ID)
+	case i.Type != InventoryType:
+		return invalid(E038, "inventory of %s has type '%s', expected %s", i.ID, i.Type, InventoryType)
+	}
+
+	for name, v := range i.Versions {
+		if v.State == nil {
+			return invalid(E048, "version %s of %s has no state; empty versions must have an empty state", name, i.ID)
+		}
+	}
+
+	return nil
+}
+
+// Counts the numbers missing from a sequence of version names sorted by number,
+// up to the highest, and finds the first of them.  Invalid names are ignored.
+func missingVersions(names []string) (missing, first int) {
+	prev := 0
+	for _, name := range names {
+		n, err := VersionID(name).Int()
+		if err != nil || n <= prev {
+			continue
+		}
+		if n > prev+1 {
+			if missing == 0 {
+				first = prev + 1
+			}
+			missing += n - prev - 1
+		}
+		prev = n
+	}
+	return missing, first
+}
+
+// Reports departures from the recommendations (SHOULD requirements) of the
+// OCFL spec for inventories
+func (i *Inventory) recommendations() []*ValidationError {
+	var warnings []*ValidationError
+
+	names := i.VersionNames()
+	if len(names) > 0 && padded(names[0]) && consistentPadding(names) {
+		max := VersionID(names[0]).Padding().MaxVersions()
+		warnings = append(warnings, invalid(W001, "versions of %s are zero padded, allowing at most %d versions", i.ID, max))
+		if n, err := VersionID(i.Head).Int(); err == nil && n >= max {
+			warnings = append(warnings, invalid(W001, "%s has as many versions as its zero padding allows; no more can be added", i.ID))
+		}
+	}
+
+	if !isURI(i.ID) {
+		warnings = append(warnings, invalid(W005, "id %s is not a URI", i.ID))
+	}
+
+	for _, name := range names {
+		v := i.Versions[name]
+		switch {
+		case v.Message == "" || v.User.Name == "":
+			warnings = append(warnings, invalid(W007, "version %s of %s has no message or user", name, i.ID))
+		case v.User.Address == "":
+			warnings = append(warnings, invalid(W008, "user of version %s of %s has no address", name, i.ID))
+		case !isURI(v.User.Address):
+			warnings = append(warnings, invalid(W009, "user address %s of version %s of %s is not a URI", v.User.Address, name, i.ID))
+		}
+	}
+
+	return warnings
+}
+
+// Determines whether a string is an absolute URI, i.e. has a scheme
+func isURI(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != ""
+}
+
+func padded(v string) bool {
+	return len(v) > 2 && v[1] == '0'
+}
+
+// Versions must be either all unpadded, or all padded to the same width
+func consistentPadding(names []string) bool {
+	first := names[0]
+	for _, name := range names[1:] {
+		if padded(first) != padded(name) || (padded(first) && len(first) != len(name)) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,135 @@
+package objectid
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Encoding is a reversible mapping of IDs to directory names
+type Encoding interface {
+	Encode(id string) string
+	Decode(name string) (string, error)
+}
+
+// EncodingFuncs adapts a pair of functions to the Encoding interface
+type EncodingFuncs struct {
+	EncodeFunc func(string) string
+	DecodeFunc func(string) (string, error)
+}
+
+// Encode an ID
+func (e EncodingFuncs) Encode(id string) string {
+	return e.EncodeFunc(id)
+}
+
+// Decode a name
+func (e EncodingFuncs) Decode(name string) (string, error) {
+	return e.DecodeFunc(name)
+}
+
+// Query encodes IDs by query escaping them, e.g. "urn:a/b c" becomes "urn%3Aa%2Fb+c".
+// Names are a single path segment, and are fairly readable.
+var Query Encoding = EncodingFuncs{url.QueryEscape, url.QueryUnescape}
+
+// Percent encodes all bytes of IDs other than ASCII letters, digits, '-' and '_' as
+// '%' followed by two lower case hex digits, as does the OCFL hashed n-tuple with ID
+// encapsulation layout.  Names are a single path segment, and contain no characters
+// that are special on any common filesystem.
+var Percent Encoding = EncodingFuncs{percentEncode, url.PathUnescape}
+
+// Hex encodes IDs as the lower case hex of their bytes.  Names are a single path
+// segment, and are unaffected by case insensitivity, but are twice as long as their IDs.
+var Hex Encoding = EncodingFuncs{
+	func(id string) string { return hex.EncodeToString([]byte(id)) },
+	func(name string) (string, error) {
+		id, err := hex.DecodeString(strings.ToLower(name))
+		return string(id), err
+	},
+}
+
+// Pairtree encodes IDs as a pairtree (see https://tools.ietf.org/html/draft-kunze-pairtree-01),
+// i.e. the cleaned ID split into two character segments, followed by a segment
+// containing the full cleaned ID.
+var Pairtree Encoding = EncodingFuncs{pairtreeEncode, pairtreeDecode}
+
+func percentEncode(id string) string {
+	var encoded strings.Builder
+	for _, b := range []byte(id) {
+		switch {
+		case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9', b == '-', b == '_':
+			encoded.WriteByte(b)
+		default:
+			fmt.Fprintf(&encoded, "%%%02x", b)
+		}
+	}
+	return encoded.String()
+}
+
+// Pairtree character cleaning: '^' hex escapes for special characters, then
+// single character substitutions for '/', ':' and '.'
+func pairtreeClean(id string) string {
+	var cleaned strings.Builder
+	for _, b := range []byte(id) {
+		switch {
+		case b <= 0x20, b >= 0x7f, strings.IndexByte(`"*+,<=>?\^|`, b) >= 0:
+			fmt.Fprintf(&cleaned, "^%02x", b)
+		case b == '/':
+			cleaned.WriteByte('=')
+		case b == ':':
+			cleaned.WriteByte('+')
+		case b == '.':
+			cleaned.WriteByte(',')
+		default:
+			cleaned.WriteByte(b)
+		}
+	}
+	return cleaned.String()
+}
+
+func pairtreeUnclean(cleaned string) (string, error) {
+	var id strings.Builder
+	for i := 0; i < len(cleaned); i++ {
+		switch b := cleaned[i]; b {
+		case '^':
+			if i+2 >= len(cleaned) {
+				return "", fmt.Errorf("truncated escape in '%s'", cleaned)
+			}
+			decoded, err := hex.DecodeString(cleaned[i+1 : i+3])
+			if err != nil {
+				return "", fmt.Errorf("invalid escape in '%s'", cleaned)
+			}
+			id.Write(decoded)
+			i += 2
+		case '=':
+			id.WriteByte('/')
+		case '+':
+			id.WriteByte(':')
+		case ',':
+			id.WriteByte('.')
+		default:
+			id.WriteByte(b)
+		}
+	}
+	return id.String(), nil
+}
+
+func pairtreeEncode(id string) string {
+	cleaned := pairtreeClean(id)
+
+	var segments []string
+	for i := 0; i < len(cleaned); i += 2 {
+		segments = append(segments, cleaned[i:min(i+2, len(cleaned))])
+	}
+	return strings.Join(append(segments, cleaned), "/")
+}
+
+func pairtreeDecode(name string) (string, error) {
+	segments := strings.Split(name, "/")
+	cleaned := segments[len(segments)-1]
+	if strings.Join(segments[:len(segments)-1], "") != cleaned {
+		return "", fmt.Errorf("'%s' is not a pairtree path", name)
+	}
+	return pairtreeUnclean(cleaned)
+}
@@ -0,0 +1,148 @@
+// Package objectid provides helpers for validating OCFL object IDs against the
+// constraints of the filesystems their object roots are stored in, and for
+// encoding IDs as directory names that can always be decoded again.
+//
+// An ID is checked by encoding it, and verifying that the resulting path is
+// within the given Constraints and decodes to the same ID.  Generator combines
+// an Encoding and Constraints into an fspath.Generator, so that drivers reject
+// unsuitable IDs when objects are opened rather than creating directories whose
+// names cannot be used, or cannot be mapped back to their IDs.
+package objectid
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Constraints limit the directory names that an ID may be encoded as
+type Constraints struct {
+	MaxIDLength     int    // Maximum length of an ID in bytes, or zero for no limit
+	MaxNameLength   int    // Maximum length of each path segment in bytes, or zero for no limit
+	Illegal         string // Characters that may not appear in a path
+	NoControl       bool   // If true, control characters (e.g. NUL, newline) may not appear in a path
+	CaseInsensitive bool   // If true, paths that differ only by case refer to the same directory
+}
+
+// Portable constraints allow paths that can be created on the common filesystems
+// of Windows, macOS, and Linux
+var Portable = Constraints{
+	MaxNameLength:   255,
+	Illegal:         `<>:"\|?*`,
+	NoControl:       true,
+	CaseInsensitive: true,
+}
+
+// POSIX constraints allow paths that can be created on typical Linux filesystems
+var POSIX = Constraints{
+	MaxNameLength: 255,
+	Illegal:       "\x00",
+}
+
+// CheckID verifies that an ID is valid UTF-8, and not longer than allowed
+func (c Constraints) CheckID(id string) error {
+	if id == "" {
+		return fmt.Errorf("object ID is empty")
+	}
+	if !utf8.ValidString(id) {
+		return fmt.Errorf("object ID '%s' is not valid UTF-8", id)
+	}
+	if c.MaxIDLength > 0 && len(id) > c.MaxIDLength {
+		return fmt.Errorf("object ID '%s' is longer than %d bytes", id, c.MaxIDLength)
+	}
+	return nil
+}
+
+// CheckPath verifies that each segment of a relative, solidus delimited path
+// is a usable directory name within the constraints
+func (c Constraints) CheckPath(path string) error {
+	for _, segment := range strings.Split(path, "/") {
+		switch segment {
+		case "", ".", "..":
+			return fmt.Errorf("path '%s' contains an empty, '.', or '..' segment", path)
+		}
+		if c.MaxNameLength > 0 && len(segment) > c.MaxNameLength {
+			return fmt.Errorf("path '%s' contains a name longer than %d bytes", path, c.MaxNameLength)
+		}
+		if i := strings.IndexAny(segment, c.Illegal); i >= 0 {
+			return fmt.Errorf("path '%s' contains illegal character %q", path, segment[i])
+		}
+		if c.NoControl {
+			for _, r := range segment {
+				if r < 0x20 || r == 0x7f {
+					return fmt.Errorf("path '%s' contains control character %q", path, r)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// CaseConflict finds an entry of the given directory whose name differs from the
+// given name only by case, which would be the same directory on case insensitive
+// filesystems.  Returns the empty string if there is none, or the directory does
+// not exist.
+func CaseConflict(dir, name string) (string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "could not read directory %s", dir)
+	}
+
+	for _, entry := range entries {
+		if entry.Name() != name && strings.EqualFold(entry.Name(), name) {
+			return entry.Name(), nil
+		}
+	}
+	return "", nil
+}
+
+// Generator is a reversible fspath.Generator that encodes IDs with its Encoding,
+// and rejects IDs whose encoded paths are not within its Constraints, or do not
+// decode to the same ID.
+type Generator struct {
+	Encoding    Encoding
+	Constraints Constraints
+}
+
+// Generate the path of an ID by encoding it
+func (g Generator) Generate(id string) string {
+	return g.Encoding.Encode(id)
+}
+
+// Reverse maps a path to an ID by decoding it
+func (g Generator) Reverse(path string) (string, bool) {
+	id, err := g.Encoding.Decode(path)
+	return id, err == nil
+}
+
+// ValidateID verifies that an ID is within the constraints, and that its encoded
+// path is within the constraints and can be decoded again
+func (g Generator) ValidateID(id string) error {
+	if err := g.Constraints.CheckID(id); err != nil {
+		return err
+	}
+
+	path := g.Encoding.Encode(id)
+	if err := g.Constraints.CheckPath(path); err != nil {
+		return errors.Wrapf(err, "cannot encode object ID '%s'", id)
+	}
+
+	decoded, err := g.Encoding.Decode(path)
+	if err != nil || decoded != id {
+		return fmt.Errorf("object ID '%s' is encoded as '%s', which cannot be decoded", id, path)
+	}
+	return nil
+}
+
+// CaseInsensitive indicates whether paths that differ only by case must be
+// considered the same
+func (g Generator) CaseInsensitive() bool {
+	return g.Constraints.CaseInsensitive
+}
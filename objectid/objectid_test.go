@@ -0,0 +1,119 @@
+package objectid_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl/fspath"
+	"github.com/birkland/ocfl/objectid"
+)
+
+var _ fspath.Generator = objectid.Generator{}
+var _ fspath.Reverser = objectid.Generator{}
+var _ fspath.IDValidator = objectid.Generator{}
+
+func TestEncodings(t *testing.T) {
+	cases := []struct {
+		name     string
+		encoding objectid.Encoding
+		id       string
+		encoded  string
+	}{
+		{"query", objectid.Query, "urn:a/b c", "urn%3Aa%2Fb+c"},
+		{"percent", objectid.Percent, "..hor/rib:le-$id", "%2e%2ehor%2frib%3ale-%24id"},
+		{"hex", objectid.Hex, "a/B", "612f42"},
+		{"pairtree", objectid.Pairtree, "ark:/13030/xt12t3", "ar/k+/=1/30/30/=x/t1/2t/3/ark+=13030=xt12t3"},
+		{"pairtreeEscapes", objectid.Pairtree, "a b.c", "a^/20/b,/c/a^20b,c"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if encoded := c.encoding.Encode(c.id); encoded != c.encoded {
+				t.Errorf("Expected %s, got %s", c.encoded, encoded)
+			}
+
+			decoded, err := c.encoding.Decode(c.encoded)
+			if err != nil {
+				t.Fatalf("could not decode %s: %+v", c.encoded, err)
+			}
+			if decoded != c.id {
+				t.Errorf("Expected %s, got %s", c.id, decoded)
+			}
+		})
+	}
+}
+
+func TestValidateID(t *testing.T) {
+	cases := []struct {
+		name      string
+		generator objectid.Generator
+		id        string
+		invalid   bool
+	}{
+		{"queryPortable", objectid.Generator{objectid.Query, objectid.Portable}, "urn:a/b", false},
+		{"empty", objectid.Generator{objectid.Query, objectid.Portable}, "", true},
+		{"notUTF8", objectid.Generator{objectid.Query, objectid.Portable}, "a\xffb", true},
+		{"tooLongName", objectid.Generator{objectid.Hex, objectid.Portable}, strings.Repeat("x", 128), true},
+		{"tooLongID", objectid.Generator{objectid.Query, objectid.Constraints{MaxIDLength: 8}}, "123456789", true},
+		{"illegalChar", objectid.Generator{identity, objectid.Portable}, "a:b", true},
+		{"controlChar", objectid.Generator{identity, objectid.Portable}, "a\nb", true},
+		{"dotDot", objectid.Generator{identity, objectid.POSIX}, "..", true},
+		{"nested", objectid.Generator{identity, objectid.POSIX}, "a/b", false},
+		{"undecodable", objectid.Generator{lossy, objectid.POSIX}, "AbC", true},
+		{"pairtree", objectid.Generator{objectid.Pairtree, objectid.Portable}, "ark:/13030/xt12t3", false},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			err := c.generator.ValidateID(c.id)
+			if (err != nil) != c.invalid {
+				t.Errorf("expected invalid: %t, got %v", c.invalid, err)
+			}
+		})
+	}
+}
+
+func TestCaseConflict(t *testing.T) {
+	dir, err := ioutil.TempDir("", "objectid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	_ = os.Mkdir(filepath.Join(dir, "Obj"), 0755)
+
+	cases := map[string]string{
+		"Obj":   "",
+		"obj":   "Obj",
+		"OBJ":   "Obj",
+		"other": "",
+	}
+	for name, expected := range cases {
+		conflict, err := objectid.CaseConflict(dir, name)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if conflict != expected {
+			t.Errorf("%s: expected conflict '%s', got '%s'", name, expected, conflict)
+		}
+	}
+
+	if conflict, err := objectid.CaseConflict(filepath.Join(dir, "missing"), "x"); conflict != "" || err != nil {
+		t.Errorf("a missing directory has no conflicts, got %s, %v", conflict, err)
+	}
+}
+
+var identity = objectid.EncodingFuncs{
+	EncodeFunc: func(id string) string { return id },
+	DecodeFunc: func(name string) (string, error) { return name, nil },
+}
+
+var lossy = objectid.EncodingFuncs{
+	EncodeFunc: strings.ToLower,
+	DecodeFunc: func(name string) (string, error) { return name, nil },
+}
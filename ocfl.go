@@ -1,9 +1,16 @@
 package ocfl
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"path"
+	"regexp"
 	"strings"
 	"time"
+
+	"github.com/birkland/ocfl/metadata"
 )
 
 // Type names a kind of OCFL entity
@@ -68,10 +75,12 @@ func (t Type) String() string {
 
 // EntityRef represents a single OCFL entity.
 type EntityRef struct {
-	ID     string     // The logical ID of the entity (string, uri, or relative file path)
-	Addr   string     // Physical address of the entity (absolute file path or URI)
-	Parent *EntityRef // Parent of next highest type that isn't an intermediate node (e.g. object parent is root)
-	Type   Type       // Type of entity
+	ID     string          // The logical ID of the entity (string, uri, or relative file path)
+	Addr   string          // Physical address of the entity (absolute file path or URI)
+	Parent *EntityRef      // Parent of next highest type that isn't an intermediate node (e.g. object parent is root)
+	Type   Type            // Type of entity
+	Size   int64           // Size of a file in bytes, if selected with Select.Sizes (-1 if it could not be determined)
+	Digest metadata.Digest // Digest of a file's content, using its object's digest algorithm, if known
 }
 
 // Coords returns a slice of the logical coordinates of an entity ref, of
@@ -94,10 +103,60 @@ func (e EntityRef) Coords() []string {
 // or an auto-named new version.  Otherwise, provide the name of an existing
 // version to access its contents.
 type Options struct {
-	Create  bool   // If true, this will create a new object if one does not exist.
-	Version string // Desired version, default (zero value) ocfl.HEAD
+	Create    bool            // If true, this will create a new object if one does not exist.
+	Version   string          // Desired version, default (zero value) ocfl.HEAD
+	PreCommit []PreCommitHook // Hooks run, in order, before the inventory is written at Commit time
+	Validate  bool            // If true, refuse to commit unless the object is valid and consistent
+	Unchanged UnchangedPolicy // What to do when committing a new version identical to the previous one
+
+	// Progress, if defined, is invoked as content is put, and once a new version is committed.
+	// It is never invoked concurrently, and should return quickly.
+	Progress func(Progress)
+}
+
+// Progress describes how far a session has progressed.  Counts are cumulative over
+// the session.
+type Progress struct {
+	Version      string // Name of the version the session reads or writes
+	FilesPut     int    // Number of files whose content has been put
+	BytesRead    int64  // Number of bytes of content read so far, including that of files still being put
+	BytesWritten int64  // Number of bytes of new content written, i.e. content that was not deduplicated
+	Path         string // Logical path of the file most recently put
+	Committed    bool   // True once the session's version has been committed
 }
 
+// UnchangedPolicy determines the behavior when committing a new version whose
+// state is identical to that of the previous version (e.g. a session opened with ocfl.NEW
+// that commits without any puts or deletes).
+type UnchangedPolicy int
+
+// Unchanged version policies
+const (
+	CommitUnchanged  UnchangedPolicy = iota // Commit the new version anyway (default)
+	RefuseUnchanged                         // Refuse to commit, returning ErrUnchanged
+	DiscardUnchanged                        // Silently discard the new version, leaving the object as it was
+)
+
+// ErrUnchanged is returned when a commit is refused because a new version
+// would have been identical to the previous one.
+var ErrUnchanged = errors.New("new version is unchanged from the previous version")
+
+// ErrNotFound is returned (as the cause of an error) when an object does not exist
+var ErrNotFound = errors.New("object does not exist")
+
+// ErrConflict is returned when a commit is refused because another writer
+// committed to the same object after the session was opened.  Sessions
+// creating the same new version share its directory until commit, so
+// concurrent writers that must not overwrite each other's content should
+// use locks instead.
+var ErrConflict = errors.New("object was modified by another writer")
+
+// PreCommitHook is invoked with the pending inventory of a session
+// just before it is written upon Commit.  Hooks may inspect the inventory to
+// enforce policy (returning an error aborts the commit), or modify
+// it to enrich the pending version (e.g. setting a default message).
+type PreCommitHook func(*metadata.Inventory) error
+
 // CommitInfo defines informative text to be included when committing an OCFL version
 type CommitInfo struct {
 	Name    string // User name
@@ -106,24 +165,69 @@ type CommitInfo struct {
 	Date    time.Time
 }
 
+// Identity names the person or agent responsible for a commit
+type Identity struct {
+	Name    string // User name
+	Address string // Some sort of identifier - e-mail, URL, etc
+}
+
+// IdentityProvider supplies a default identity, used for populating
+// CommitInfo when its Name or Address are not given.
+type IdentityProvider interface {
+	Identity() (Identity, error)
+}
+
+// WithDefaults returns a copy of the commit info with any empty Name or Address
+// populated from the given identity provider, and a zero Date set to now.
+func (c CommitInfo) WithDefaults(p IdentityProvider) (CommitInfo, error) {
+	if c.Date.IsZero() {
+		c.Date = time.Now()
+	}
+
+	if p == nil || (c.Name != "" && c.Address != "") {
+		return c, nil
+	}
+
+	id, err := p.Identity()
+	if err != nil {
+		return c, err
+	}
+
+	if c.Name == "" {
+		c.Name = id.Name
+	}
+	if c.Address == "" {
+		c.Address = id.Address
+	}
+
+	return c, nil
+}
+
 // Session allows reading or writing to the an OCFL object.
 //
 // Each session is bound to a single OCFL object version; either a pre-existing version,
 // or an uncommitted new version.  New versions contain the content of the previous
 // version as a starting point.  Drivers may or may not allow writes/commits
 // to existing versions.
+//
+// Methods with a Context suffix accept a context.Context which, when cancelled or past
+// its deadline, aborts the operation with the context's error.  The plain variants
+// are equivalent to calling the Context variant with context.Background()
 type Session interface {
-	Put(lpath string, r io.Reader) error // Put file content at the given logical path
+	Put(lpath string, r io.Reader) error                             // Put file content at the given logical path
+	PutContext(ctx context.Context, lpath string, r io.Reader) error // Put, aborting if ctx is done
 	Delete(lpath string) error
-	// TODO: Move(src, dest string) error
+	Move(src, dest string) error // Rename a logical file, without copying its content
 	// TODO: Read(lpath string) (io.Reader, error)
 	Commit(CommitInfo) error
-	// TODO: Close() error
+	CommitContext(ctx context.Context, commit CommitInfo) error // Commit, aborting if ctx is done
+	Close() error                                               // Release the session's resources, abandoning any uncommitted changes
 }
 
 // Opener opens an OCFL object session, potentially allowing reading and writing to it.
 type Opener interface {
-	Open(id string, opts Options) (Session, error) // Open an OCFL object
+	Open(id string, opts Options) (Session, error)                             // Open an OCFL object
+	OpenContext(ctx context.Context, id string, opts Options) (Session, error) // Open, aborting if ctx is done
 }
 
 // Walker crawls through a bounded scope of OCFL entities "underneath" a start
@@ -136,18 +240,104 @@ type Opener interface {
 // by an object ID, and logical file paths must be preceded by the version ID.
 //
 // If no location is given, the scope of the walk is implied to be the entirety of content under an OCFL root.
+//
+// WalkContext behaves identically to Walk, but stops walking and returns the
+// context's error as soon as the given context is done.
 type Walker interface {
 	Walk(desired Select, cb func(EntityRef) error, loc ...string) error
+	WalkContext(ctx context.Context, desired Select, cb func(EntityRef) error, loc ...string) error
 }
 
 // Select indicates desired properties of matching OCFL entities
+//
+// Patterns narrow the selection further.  ObjectID and ObjectRegexp restrict objects, as
+// well as the versions and files within them, to those whose IDs match.  LogicalPath and
+// PathRegexp restrict files to those whose logical paths match.  Glob patterns follow the
+// syntax of path.Match within each slash separated segment, and a ** segment matches any
+// number of segments, e.g. **/*.tiff matches every .tiff file, at any depth.
 type Select struct {
-	Type Type // Desired OCFL type
-	Head bool // True if desired files or versions must be in the head revision
+	Type         Type           // Desired OCFL type
+	Head         bool           // True if desired files or versions must be in the head revision
+	Sorted       bool           // True if entities must be visited in a deterministic, lexically sorted order
+	ObjectID     string         // Glob pattern that object IDs must match, if not empty
+	ObjectRegexp *regexp.Regexp // Regular expression that object IDs must match, if not nil
+	LogicalPath  string         // Glob pattern that logical file paths must match, if not empty
+	PathRegexp   *regexp.Regexp // Regular expression that logical file paths must match, if not nil
+	Sizes        bool           // True if the sizes of files must be determined, without reading their content
+}
+
+// Validate checks that the selection's glob patterns are well formed
+func (s Select) Validate() error {
+	for _, pattern := range []string{s.ObjectID, s.LogicalPath} {
+		for _, segment := range strings.Split(pattern, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("bad pattern '%s': %s", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// MatchObject determines if an object with the given ID is selected by the
+// ObjectID and ObjectRegexp patterns, if any
+func (s Select) MatchObject(id string) bool {
+	if s.ObjectID != "" && !matchGlob(s.ObjectID, id) {
+		return false
+	}
+	return s.ObjectRegexp == nil || s.ObjectRegexp.MatchString(id)
+}
+
+// MatchFile determines if a file with the given logical path is selected
+// by the LogicalPath and PathRegexp patterns, if any
+func (s Select) MatchFile(lpath string) bool {
+	if s.LogicalPath != "" && !matchGlob(s.LogicalPath, lpath) {
+		return false
+	}
+	return s.PathRegexp == nil || s.PathRegexp.MatchString(lpath)
+}
+
+// Matches a slash separated name against a glob pattern, segment by segment.
+// A ** segment matches zero or more segments.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], name[0]); !matched {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// PurgeOptions configure the removal of an OCFL object
+type PurgeOptions struct {
+	Tombstone bool       // If true, record a tombstone noting the object's removal
+	Info      CommitInfo // Who removed the object, when, and why; recorded in the tombstone
+}
+
+// Purger permanently removes OCFL objects, including all of their versions
+type Purger interface {
+	Purge(id string, opts PurgeOptions) error
 }
 
 // Driver provides basic OCFL access via some backend
 type Driver interface {
 	Walker
 	Opener
+	Purger
 }
@@ -59,3 +59,31 @@ func TestCoords(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchFileGlob(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		matches bool
+	}{
+		{"*.tiff", "a.tiff", true},
+		{"*.tiff", "dir/a.tiff", false},
+		{"**/*.tiff", "a.tiff", true},
+		{"**/*.tiff", "dir/sub/a.tiff", true},
+		{"**/*.tiff", "dir/a.tif", false},
+		{"dir/**", "dir/sub/a.tiff", true},
+		{"dir/**", "other/a.tiff", false},
+		{"dir/**/a.*", "dir/a.tiff", true},
+		{"dir/*/a.*", "dir/x/y/a.tiff", false},
+	}
+
+	for _, c := range cases {
+		if matched := (ocfl.Select{LogicalPath: c.pattern}).MatchFile(c.path); matched != c.matches {
+			t.Errorf("Expected %s matching %s to be %t", c.pattern, c.path, c.matches)
+		}
+	}
+
+	if err := (ocfl.Select{LogicalPath: "**/[bad"}).Validate(); err == nil {
+		t.Errorf("Expected an error for a malformed pattern")
+	}
+}
@@ -0,0 +1,315 @@
+package report
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// Rows are buffered, and written as a row group, in batches of this many
+const parquetRowGroupSize = 64 * 1024
+
+var parquetMagic = []byte("PAR1")
+
+// Parquet physical and converted types, repetition types, encodings, and page
+// types, as defined by parquet.thrift in the Parquet format specification
+const (
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetUTF8            = 0
+	parquetTimestampMicros = 10
+
+	parquetRequired = 0
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetDataPage = 0
+)
+
+// A column of the report, in the order of Header
+type parquetColumn struct {
+	kind      int32
+	converted int32
+	encode    func(*bytes.Buffer, Row)
+}
+
+var parquetColumns = []parquetColumn{
+	{parquetByteArray, parquetUTF8, func(b *bytes.Buffer, r Row) { plainString(b, r.Object) }},
+	{parquetByteArray, parquetUTF8, func(b *bytes.Buffer, r Row) { plainString(b, r.Version) }},
+	{parquetByteArray, parquetUTF8, func(b *bytes.Buffer, r Row) { plainString(b, r.LogicalPath) }},
+	{parquetByteArray, parquetUTF8, func(b *bytes.Buffer, r Row) { plainString(b, string(r.DigestAlgorithm)) }},
+	{parquetByteArray, parquetUTF8, func(b *bytes.Buffer, r Row) { plainString(b, string(r.Digest)) }},
+	{parquetInt64, -1, func(b *bytes.Buffer, r Row) { plainInt64(b, r.Size) }},
+	{parquetInt64, parquetTimestampMicros, func(b *bytes.Buffer, r Row) { plainInt64(b, r.Created.UnixMicro()) }},
+}
+
+func plainString(b *bytes.Buffer, s string) {
+	_ = binary.Write(b, binary.LittleEndian, uint32(len(s)))
+	b.WriteString(s)
+}
+
+func plainInt64(b *bytes.Buffer, v int64) {
+	_ = binary.Write(b, binary.LittleEndian, v)
+}
+
+// The location and size of a column chunk, as recorded in the file's footer
+type parquetChunk struct {
+	offset int64
+	size   int64
+}
+
+type parquetRowGroup struct {
+	rows   int64
+	size   int64
+	chunks []parquetChunk
+}
+
+type parquetWriter struct {
+	w       io.Writer
+	offset  int64
+	started bool
+	rows    []Row
+	groups  []parquetRowGroup
+}
+
+// NewParquetWriter creates a Writer that writes rows as a Parquet file, with one column per
+// field of Header.  Sizes are 64 bit integers (-1 if unknown), creation dates are timestamps
+// in microseconds (UTC), and everything else is a UTF-8 string.  Columns are written
+// uncompressed, with plain encoding, in row groups of up to 65536 rows, which are buffered in
+// memory until written.  Nothing is written until the first row, or Flush, which writes the
+// footer that completes the file; no rows may be written after it.
+func NewParquetWriter(w io.Writer) Writer {
+	return &parquetWriter{w: w}
+}
+
+func (p *parquetWriter) Write(r Row) error {
+	if err := p.start(); err != nil {
+		return err
+	}
+
+	p.rows = append(p.rows, r)
+	if len(p.rows) >= parquetRowGroupSize {
+		return p.writeRowGroup()
+	}
+	return nil
+}
+
+func (p *parquetWriter) Flush() error {
+	if err := p.start(); err != nil {
+		return err
+	}
+	if err := p.writeRowGroup(); err != nil {
+		return err
+	}
+
+	footer := p.footer()
+	if err := p.write(footer); err != nil {
+		return err
+	}
+	if err := binary.Write(p.w, binary.LittleEndian, uint32(len(footer))); err != nil {
+		return err
+	}
+	return p.write(parquetMagic)
+}
+
+func (p *parquetWriter) start() error {
+	if p.started {
+		return nil
+	}
+	p.started = true
+	return p.write(parquetMagic)
+}
+
+func (p *parquetWriter) write(b []byte) error {
+	n, err := p.w.Write(b)
+	p.offset += int64(n)
+	return err
+}
+
+// Writes the buffered rows as a row group, with each column chunk in a single data page
+func (p *parquetWriter) writeRowGroup() error {
+	if len(p.rows) == 0 {
+		return nil
+	}
+
+	group := parquetRowGroup{rows: int64(len(p.rows))}
+	for _, col := range parquetColumns {
+		var data bytes.Buffer
+		for _, r := range p.rows {
+			col.encode(&data, r)
+		}
+
+		var header thriftWriter
+		header.begin()
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(data.Len()))
+		header.i32(3, int32(data.Len()))
+		header.beginStruct(5)
+		header.i32(1, int32(len(p.rows)))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.end()
+		header.end()
+
+		chunk := parquetChunk{offset: p.offset, size: int64(header.buf.Len() + data.Len())}
+		if err := p.write(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if err := p.write(data.Bytes()); err != nil {
+			return err
+		}
+
+		group.chunks = append(group.chunks, chunk)
+		group.size += chunk.size
+	}
+
+	p.groups = append(p.groups, group)
+	p.rows = p.rows[:0]
+	return nil
+}
+
+// Encodes the file metadata
+func (p *parquetWriter) footer() []byte {
+	var total int64
+	for _, g := range p.groups {
+		total += g.rows
+	}
+
+	var t thriftWriter
+	t.begin()
+	t.i32(1, 1)
+
+	t.list(2, thriftStruct, len(parquetColumns)+1)
+	t.begin()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(parquetColumns)))
+	t.end()
+	for i, col := range parquetColumns {
+		t.begin()
+		t.i32(1, col.kind)
+		t.i32(3, parquetRequired)
+		t.binary(4, Header[i])
+		if col.converted >= 0 {
+			t.i32(6, col.converted)
+		}
+		t.end()
+	}
+
+	t.i64(3, total)
+
+	t.list(4, thriftStruct, len(p.groups))
+	for _, g := range p.groups {
+		t.begin()
+		t.list(1, thriftStruct, len(g.chunks))
+		for i, c := range g.chunks {
+			t.begin()
+			t.i64(2, c.offset)
+			t.beginStruct(3)
+			t.i32(1, parquetColumns[i].kind)
+			t.list(2, thriftI32, 2)
+			t.listI32(parquetPlain)
+			t.listI32(parquetRLE)
+			t.list(3, thriftBinary, 1)
+			t.listBinary(Header[i])
+			t.i32(4, 0) // Uncompressed
+			t.i64(5, g.rows)
+			t.i64(6, c.size)
+			t.i64(7, c.size)
+			t.i64(9, c.offset)
+			t.end()
+			t.end()
+		}
+		t.i64(2, g.size)
+		t.i64(3, g.rows)
+		t.end()
+	}
+
+	t.binary(6, "github.com/birkland/ocfl")
+	t.end()
+
+	return t.buf.Bytes()
+}
+
+// Thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// Encodes structs in the Thrift compact protocol, in which Parquet metadata is written.
+// Fields are written in the order of their ids, each struct (including a list
+// element) between begin and end.
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last []int16 // The id of the last field written in each open struct
+}
+
+func (t *thriftWriter) begin() {
+	t.last = append(t.last, 0)
+}
+
+func (t *thriftWriter) end() {
+	t.buf.WriteByte(0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *thriftWriter) field(id int16, kind byte) {
+	last := &t.last[len(t.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | kind)
+	} else {
+		t.buf.WriteByte(kind)
+		t.varint(int64(id))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) varint(v int64) {
+	t.buf.Write(binary.AppendUvarint(nil, uint64(v<<1^v>>63)))
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.listBinary(s)
+}
+
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.begin()
+}
+
+// Begins a list field of n elements of the given type, which are written next
+func (t *thriftWriter) list(id int16, kind byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | kind)
+		return
+	}
+	t.buf.WriteByte(0xf0 | kind)
+	t.buf.Write(binary.AppendUvarint(nil, uint64(n)))
+}
+
+func (t *thriftWriter) listI32(v int32) {
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) listBinary(s string) {
+	t.buf.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	t.buf.WriteString(s)
+}
@@ -0,0 +1,300 @@
+package report_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/birkland/ocfl/report"
+	"github.com/go-test/deep"
+)
+
+// Parquet files decode to the rows written, with metadata describing them as the
+// Parquet format specification requires
+func TestParquetRoundTrip(t *testing.T) {
+	driver, err := fs.NewDriver(fs.Config{Root: testRoot})
+	if err != nil {
+		t.Fatalf("could not create driver %+v", err)
+	}
+
+	var generated rowRecorder
+	if err = report.Generate(context.Background(), driver, &generated, report.Options{}); err != nil {
+		t.Fatalf("could not generate report %+v", err)
+	}
+
+	// Enough rows for several row groups, the last of them partial
+	var rows []report.Row
+	for len(rows) < 2*64*1024+3 {
+		for _, r := range generated {
+			r.Object = fmt.Sprintf("%s-%d", r.Object, len(rows))
+			rows = append(rows, r)
+		}
+	}
+
+	cases := map[string][]report.Row{
+		"empty":     nil,
+		"report":    generated,
+		"rowGroups": rows,
+	}
+
+	for name, written := range cases {
+		written := written
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := report.NewParquetWriter(&buf)
+			for _, r := range written {
+				if err := w.Write(r); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			read := readParquet(t, buf.Bytes())
+
+			var expected []report.Row
+			for _, r := range written {
+				r.Created = time.UnixMicro(r.Created.UnixMicro()).UTC()
+				expected = append(expected, r)
+			}
+			if diff := deep.Equal(read, expected); diff != nil {
+				t.Error(diff[:min(len(diff), 10)])
+			}
+		})
+	}
+}
+
+type rowRecorder []report.Row
+
+func (r *rowRecorder) Write(row report.Row) error {
+	*r = append(*r, row)
+	return nil
+}
+
+func (r *rowRecorder) Flush() error {
+	return nil
+}
+
+// Reads a Parquet file written by report.NewParquetWriter, verifying its structure
+// and metadata as it goes
+func readParquet(t *testing.T, file []byte) []report.Row {
+	if !bytes.HasPrefix(file, []byte("PAR1")) || !bytes.HasSuffix(file, []byte("PAR1")) {
+		t.Fatalf("missing magic number in %q", file)
+	}
+	footerLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footerStart := len(file) - 8 - footerLen
+	if footerStart < 4 {
+		t.Fatalf("footer length %d exceeds the file", footerLen)
+	}
+
+	footer := &thriftReader{b: file, p: footerStart}
+	meta := footer.readStruct()
+	if footer.p != len(file)-8 {
+		t.Fatalf("footer ends at %d, not at its length %d", footer.p, footerLen)
+	}
+
+	// FileMetaData: version, schema, num_rows, row_groups, created_by
+	schema := []interface{}{thriftStruct{4: "schema", 5: int32(7)}}
+	kinds := []int32{6, 6, 6, 6, 6, 2, 2}       // BYTE_ARRAY for strings, INT64 for size and created
+	converted := []int32{0, 0, 0, 0, 0, -1, 10} // UTF8, none, and TIMESTAMP_MICROS
+	for i, name := range report.Header {
+		col := thriftStruct{1: kinds[i], 3: int32(0), 4: name}
+		if converted[i] >= 0 {
+			col[6] = converted[i]
+		}
+		schema = append(schema, col)
+	}
+
+	groups, _ := meta[4].([]interface{})
+	delete(meta, 4)
+	var total int64
+	for _, g := range groups {
+		total += g.(thriftStruct)[3].(int64)
+	}
+	if diff := deep.Equal(meta, thriftStruct{1: int32(1), 2: schema, 3: total, 6: "github.com/birkland/ocfl"}); diff != nil {
+		t.Fatalf("wrong file metadata %s", diff)
+	}
+
+	var rows []report.Row
+	offset := int64(4)
+	for _, g := range groups {
+		group := g.(thriftStruct)
+		n := group[3].(int64)
+		chunks := group[1].([]interface{})
+		if len(chunks) != len(report.Header) {
+			t.Fatalf("expected a column chunk per column, got %d", len(chunks))
+		}
+
+		groupRows := make([]report.Row, n)
+		groupSize := int64(0)
+		for i, c := range chunks {
+			chunk := c.(thriftStruct)
+			chunkMeta := chunk[3].(thriftStruct)
+			size := chunkMeta[7].(int64)
+
+			// ColumnChunk: file_offset, and ColumnMetaData: type, encodings, path_in_schema,
+			// codec, num_values, total_uncompressed_size, total_compressed_size, data_page_offset,
+			// with chunks following each other from the start of the file
+			expected := thriftStruct{2: offset, 3: thriftStruct{
+				1: kinds[i],
+				2: []interface{}{int32(0), int32(3)},
+				3: []interface{}{report.Header[i]},
+				4: int32(0),
+				5: n,
+				6: size,
+				7: size,
+				9: offset,
+			}}
+			if diff := deep.Equal(chunk, expected); diff != nil {
+				t.Fatalf("wrong metadata of column %s %s", report.Header[i], diff)
+			}
+
+			// PageHeader: type DATA_PAGE, sizes, and DataPageHeader: num_values, and PLAIN
+			// encoding of values with RLE encoded (absent) levels
+			page := &thriftReader{b: file, p: int(offset)}
+			header := page.readStruct()
+			dataSize := int64(header[3].(int32))
+			expectedHeader := thriftStruct{1: int32(0), 2: int32(dataSize), 3: int32(dataSize), 5: thriftStruct{
+				1: int32(n), 2: int32(0), 3: int32(3), 4: int32(3),
+			}}
+			if diff := deep.Equal(header, expectedHeader); diff != nil {
+				t.Fatalf("wrong page header of column %s %s", report.Header[i], diff)
+			}
+			if int64(page.p)-offset+dataSize != size {
+				t.Fatalf("column %s is %d bytes, not its recorded size %d", report.Header[i], int64(page.p)-offset+dataSize, size)
+			}
+
+			data := file[page.p : int64(page.p)+dataSize]
+			if rest := decodePlain(data, i, groupRows); len(rest) != 0 {
+				t.Fatalf("%d bytes left over in column %s", len(rest), report.Header[i])
+			}
+
+			offset += size
+			groupSize += size
+		}
+
+		if group[2].(int64) != groupSize {
+			t.Errorf("row group is %d bytes, not its recorded size %d", groupSize, group[2])
+		}
+		rows = append(rows, groupRows...)
+	}
+
+	if offset != int64(footerStart) {
+		t.Errorf("column chunks end at %d, not at the footer %d", offset, footerStart)
+	}
+	return rows
+}
+
+// Decodes the plain encoded values of a column into the given rows, returning any
+// bytes left over
+func decodePlain(data []byte, column int, rows []report.Row) []byte {
+	for i := range rows {
+		r := &rows[i]
+		if column >= 5 {
+			v := int64(binary.LittleEndian.Uint64(data))
+			if column == 5 {
+				r.Size = v
+			} else {
+				r.Created = time.UnixMicro(v).UTC()
+			}
+			data = data[8:]
+			continue
+		}
+
+		n := binary.LittleEndian.Uint32(data)
+		s := string(data[4 : 4+n])
+		data = data[4+n:]
+		switch column {
+		case 0:
+			r.Object = s
+		case 1:
+			r.Version = s
+		case 2:
+			r.LogicalPath = s
+		case 3:
+			r.DigestAlgorithm = metadata.DigestAlgorithm(s)
+		case 4:
+			r.Digest = metadata.Digest(s)
+		}
+	}
+	return data
+}
+
+// A Thrift compact protocol struct, by field id.  Values are int32, int64, string,
+// []interface{} (lists), or nested thriftStructs, according to their encoded types.
+type thriftStruct map[int16]interface{}
+
+// Decodes the Thrift compact protocol, independently of the writer's encoder
+type thriftReader struct {
+	b []byte
+	p int
+}
+
+func (r *thriftReader) byte() byte {
+	b := r.b[r.p]
+	r.p++
+	return b
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b[r.p:])
+	r.p += n
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) readStruct() thriftStruct {
+	s := thriftStruct{}
+	var last int16
+	for {
+		header := r.byte()
+		if header == 0 {
+			return s
+		}
+
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		last = id
+		s[id] = r.value(header & 0x0f)
+	}
+}
+
+func (r *thriftReader) value(kind byte) interface{} {
+	switch kind {
+	case 5:
+		return int32(r.zigzag())
+	case 6:
+		return r.zigzag()
+	case 8:
+		n := int(r.uvarint())
+		r.p += n
+		return string(r.b[r.p-n : r.p])
+	case 9:
+		header := r.byte()
+		n := int(header >> 4)
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			list[i] = r.value(header & 0x0f)
+		}
+		return list
+	case 12:
+		return r.readStruct()
+	default:
+		panic(fmt.Sprintf("unexpected thrift type %d at %d", kind, r.p))
+	}
+}
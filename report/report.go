@@ -0,0 +1,198 @@
+// Package report generates tabular reports of the content of an OCFL repository,
+// suitable for ingestion into spreadsheets or analytics tools.
+//
+// Reports contain one row per logical file per version, and are produced by
+// walking OCFL objects via an ocfl.Walker and consulting their inventories.
+package report
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/metadata"
+	"github.com/pkg/errors"
+)
+
+// Header contains the column names of report rows, in order
+var Header = []string{"object", "version", "logicalPath", "digestAlgorithm", "digest", "size", "created"}
+
+// Row describes a single logical file within a version of an OCFL object
+type Row struct {
+	Object          string
+	Version         string
+	LogicalPath     string
+	DigestAlgorithm metadata.DigestAlgorithm
+	Digest          metadata.Digest
+	Size            int64 // Size in bytes, or -1 if unknown
+	Created         time.Time
+}
+
+// Writer writes report rows in some tabular format
+type Writer interface {
+	Write(Row) error
+	Flush() error
+}
+
+// Options configure report generation.
+//
+// By default, inventories are read from, and file sizes are determined from,
+// the local filesystem using each entity's address.  Drivers whose entities are not
+// addressed by file paths should provide alternate functions.
+type Options struct {
+	Head      bool                                                     // Report only the head version of each object
+	Inventory func(object ocfl.EntityRef) (*metadata.Inventory, error) // Retrieves the inventory of an object
+	Size      func(file ocfl.EntityRef) (int64, error)                 // Determines the size of a file.
+}
+
+// Generate walks the objects under the given location (see ocfl.Walker), writing a row
+// for each file in each version of each object encountered.
+func Generate(ctx context.Context, w ocfl.Walker, out Writer, opts Options, loc ...string) error {
+	if opts.Inventory == nil {
+		opts.Inventory = func(obj ocfl.EntityRef) (*metadata.Inventory, error) {
+			return fs.ReadInventory(obj.Addr)
+		}
+	}
+
+	if opts.Size == nil {
+		opts.Size = func(file ocfl.EntityRef) (int64, error) {
+			info, err := os.Stat(file.Addr)
+			if err != nil {
+				return -1, err
+			}
+			return info.Size(), nil
+		}
+	}
+
+	err := w.WalkContext(ctx, ocfl.Select{Type: ocfl.Object}, func(obj ocfl.EntityRef) error {
+		return reportObject(ctx, obj, out, opts)
+	}, loc...)
+	if err != nil {
+		return errors.Wrapf(err, "could not generate report")
+	}
+
+	return out.Flush()
+}
+
+func reportObject(ctx context.Context, obj ocfl.EntityRef, out Writer, opts Options) error {
+	inv, err := opts.Inventory(obj)
+	if err != nil {
+		return errors.Wrapf(err, "could not read inventory of %s", obj.ID)
+	}
+
+	versions := inv.VersionNames()
+	if opts.Head {
+		versions = []string{inv.Head}
+	}
+
+	for _, v := range versions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var writeErr error
+		err := inv.FilesIter(v, func(f metadata.File) error {
+			size, err := opts.Size(ocfl.EntityRef{
+				ID:   f.LogicalPath,
+				Type: ocfl.File,
+				Addr: physicalAddr(obj.Addr, f.PhysicalPath),
+			})
+			if err != nil {
+				size = -1
+			}
+
+			digest, _ := inv.DigestOf(f.PhysicalPath)
+			err = out.Write(Row{
+				Object:          inv.ID,
+				Version:         v,
+				LogicalPath:     f.LogicalPath,
+				DigestAlgorithm: inv.DigestAlgorithm,
+				Digest:          digest,
+				Size:            size,
+				Created:         f.Version.Created,
+			})
+			if err != nil {
+				writeErr = errors.Wrapf(err, "could not write report row for %s in %s %s", f.LogicalPath, obj.ID, v)
+			}
+			return writeErr
+		})
+		if writeErr != nil {
+			return writeErr
+		}
+		if err != nil {
+			return errors.Wrapf(err, "could not enumerate files of %s %s", obj.ID, v)
+		}
+	}
+
+	return nil
+}
+
+// Join object addresses and relative physical paths, whether the
+// address is a file path or a URI
+func physicalAddr(objAddr, relative string) string {
+	if strings.Contains(objAddr, "://") {
+		return strings.TrimRight(objAddr, "/") + "/" + relative
+	}
+	return filepath.Join(objAddr, filepath.FromSlash(relative))
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+// NewCSVWriter creates a Writer that writes rows as CSV, preceded by a header row.
+func NewCSVWriter(w io.Writer) Writer {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) Write(r Row) error {
+	if !c.headerWritten {
+		if err := c.w.Write(Header); err != nil {
+			return err
+		}
+		c.headerWritten = true
+	}
+
+	return c.w.Write([]string{
+		r.Object,
+		r.Version,
+		r.LogicalPath,
+		string(r.DigestAlgorithm),
+		string(r.Digest),
+		strconv.FormatInt(r.Size, 10),
+		r.Created.UTC().Format(time.RFC3339Nano),
+	})
+}
+
+func (c *csvWriter) Flush() error {
+	if !c.headerWritten {
+		if err := c.w.Write(Header); err != nil {
+			return err
+		}
+		c.headerWritten = true
+	}
+
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// NewWriter creates a Writer for the named format, "csv" or "parquet".
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch strings.ToLower(format) {
+	case "", "csv":
+		return NewCSVWriter(w), nil
+	case "parquet":
+		return NewParquetWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unsupported report format '%s'", format)
+	}
+}
@@ -0,0 +1,117 @@
+package report_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"testing"
+
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/report"
+)
+
+const testRoot = "../drivers/fs/testdata/ocflroot"
+
+func TestCSVReport(t *testing.T) {
+	cases := []struct {
+		name  string
+		head  bool
+		loc   []string
+		count int
+	}{
+		{"all", false, nil, 20},
+		{"head", true, nil, 8},
+		{"oneObject", false, []string{"urn:/obj4"}, 5},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			driver, err := fs.NewDriver(fs.Config{Root: testRoot})
+			if err != nil {
+				t.Fatalf("could not create driver %+v", err)
+			}
+
+			var buf bytes.Buffer
+			err = report.Generate(context.Background(), driver, report.NewCSVWriter(&buf), report.Options{
+				Head: c.head,
+			}, c.loc...)
+			if err != nil {
+				t.Fatalf("could not generate report %+v", err)
+			}
+
+			rows, err := csv.NewReader(&buf).ReadAll()
+			if err != nil {
+				t.Fatalf("could not parse csv %+v", err)
+			}
+
+			if len(rows) != c.count+1 {
+				t.Fatalf("expected %d rows plus a header, got %d", c.count, len(rows))
+			}
+
+			for _, row := range rows[1:] {
+				if row[4] == "" {
+					t.Errorf("missing digest in %s", row)
+				}
+				if row[5] == "-1" {
+					t.Errorf("missing size in %s", row)
+				}
+			}
+		})
+	}
+}
+
+func TestParquetReport(t *testing.T) {
+	cases := []struct {
+		name string
+		loc  []string
+		rows int
+	}{
+		{"oneObject", []string{"urn:/obj4"}, 5},
+		{"empty", []string{"urn:/nope"}, 0},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			driver, err := fs.NewDriver(fs.Config{Root: testRoot})
+			if err != nil {
+				t.Fatalf("could not create driver %+v", err)
+			}
+
+			var buf bytes.Buffer
+			w, err := report.NewWriter("parquet", &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = report.Generate(context.Background(), driver, w, report.Options{}, c.loc...)
+			if err != nil {
+				t.Fatalf("could not generate report %+v", err)
+			}
+
+			// A file begins and ends with the magic number, preceded by the length of the footer
+			file := buf.Bytes()
+			if !bytes.HasPrefix(file, []byte("PAR1")) || !bytes.HasSuffix(file, []byte("PAR1")) {
+				t.Fatalf("missing magic number in %q", file)
+			}
+			footer := binary.LittleEndian.Uint32(file[len(file)-8:])
+			if int(footer) > len(file)-12 {
+				t.Errorf("footer length %d exceeds the file", footer)
+			}
+
+			// Each row has a plain encoded object ID
+			id := append([]byte{9, 0, 0, 0}, "urn:/obj4"...)
+			if n := bytes.Count(file, id); n != c.rows {
+				t.Errorf("expected %d rows, got %d", c.rows, n)
+			}
+		})
+	}
+}
+
+func TestUnsupportedFormat(t *testing.T) {
+	_, err := report.NewWriter("xls", &bytes.Buffer{})
+	if err == nil {
+		t.Errorf("expected an error for an unsupported format")
+	}
+}
@@ -0,0 +1,345 @@
+// Package server exposes read access to the objects of an OCFL repository over HTTP,
+// so that a repository can be browsed and fetched without shared filesystem access.
+//
+// Object IDs appear in URLs as single, escaped path segments (e.g. urn%3Atest%2Fobj),
+// and the name "head" may be used in place of the name of an object's head version:
+//
+//	GET /objects                                         IDs of all objects, as JSON
+//	GET /objects/{id}                                    Object summary and versions, as JSON
+//	GET /objects/{id}/inventory.json                     The object's inventory, as stored
+//	GET /objects/{id}/{version}                          Version summary and files, as JSON
+//	GET /objects/{id}/{version}/inventory.json           The version's inventory, as stored
+//	GET /objects/{id}/{version}/content/{logical path}   Content of a logical file
+//
+// Content is served with the file's digest as its ETag, and supports range and conditional
+// requests.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/metadata"
+)
+
+// Head may be used in URLs in place of the name of an object's head version
+const Head = "head"
+
+// Options configure the server.
+//
+// By default, inventories and content are read from the local filesystem using each
+// entity's address.  Drivers whose entities are not addressed by file paths should
+// provide alternate functions.
+type Options struct {
+	Inventory func(object ocfl.EntityRef) (*metadata.Inventory, error) // Retrieves the inventory of an object
+	Open      func(file ocfl.EntityRef) (io.ReadSeekCloser, error)     // Opens a file within an object
+}
+
+// ObjectSummary describes an object and its versions
+type ObjectSummary struct {
+	ID              string                   `json:"id"`
+	Head            string                   `json:"head"`
+	DigestAlgorithm metadata.DigestAlgorithm `json:"digestAlgorithm"`
+	Versions        []VersionSummary         `json:"versions"`
+}
+
+// VersionSummary describes a version of an object, and the files it contains if listed
+type VersionSummary struct {
+	Name    string        `json:"name"`
+	Created time.Time     `json:"created"`
+	Message string        `json:"message,omitempty"`
+	User    metadata.User `json:"user"`
+	Files   []FileSummary `json:"files,omitempty"`
+}
+
+// FileSummary describes a logical file within a version
+type FileSummary struct {
+	Path   string          `json:"path"`
+	Digest metadata.Digest `json:"digest"`
+}
+
+type server struct {
+	walker ocfl.Walker
+	opts   Options
+
+	rootOnce sync.Once
+	root     ocfl.EntityRef // The OCFL root served
+	rootErr  error
+}
+
+// errNotFound marks errors that are reported as 404 Not Found
+var errNotFound = errors.New("not found")
+
+// errFound stops a walk once the entity sought is found
+var errFound = errors.New("found")
+
+// New creates an http.Handler serving read only access to the objects found
+// by the given walker.
+func New(w ocfl.Walker, opts Options) http.Handler {
+	if opts.Inventory == nil {
+		opts.Inventory = func(obj ocfl.EntityRef) (*metadata.Inventory, error) {
+			return fs.ReadInventory(obj.Addr)
+		}
+	}
+
+	if opts.Open == nil {
+		opts.Open = func(file ocfl.EntityRef) (io.ReadSeekCloser, error) {
+			return os.Open(file.Addr)
+		}
+	}
+
+	s := &server{walker: w, opts: opts}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /objects", s.listObjects)
+	mux.HandleFunc("GET /objects/{id}", s.getObject)
+	mux.HandleFunc("GET /objects/{id}/inventory.json", s.getInventory)
+	mux.HandleFunc("GET /objects/{id}/{version}", s.getVersion)
+	mux.HandleFunc("GET /objects/{id}/{version}/inventory.json", s.getInventory)
+	mux.HandleFunc("GET /objects/{id}/{version}/content/{path...}", s.getContent)
+	return mux
+}
+
+func (s *server) listObjects(w http.ResponseWriter, r *http.Request) {
+	ids := []string{}
+	err := s.walker.WalkContext(r.Context(), ocfl.Select{Type: ocfl.Object, Sorted: true}, func(obj ocfl.EntityRef) error {
+		ids = append(ids, obj.ID)
+		return nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, ids)
+}
+
+func (s *server) getObject(w http.ResponseWriter, r *http.Request) {
+	_, inv, err := s.object(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	summary := ObjectSummary{
+		ID:              inv.ID,
+		Head:            inv.Head,
+		DigestAlgorithm: inv.DigestAlgorithm,
+		Versions:        []VersionSummary{},
+	}
+	for _, name := range inv.VersionNames() {
+		v := inv.Versions[name]
+		summary.Versions = append(summary.Versions, VersionSummary{
+			Name:    name,
+			Created: v.Created,
+			Message: v.Message,
+			User:    v.User,
+		})
+	}
+
+	writeJSON(w, summary)
+}
+
+func (s *server) getVersion(w http.ResponseWriter, r *http.Request) {
+	_, inv, err := s.object(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	v, err := version(inv, r.PathValue("version"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	summary := VersionSummary{
+		Name:    v.ID,
+		Created: v.Created,
+		Message: v.Message,
+		User:    v.User,
+		Files:   []FileSummary{},
+	}
+	for _, p := range v.Paths() {
+		digest, _ := v.Digest(p)
+		summary.Files = append(summary.Files, FileSummary{Path: p, Digest: digest})
+	}
+
+	writeJSON(w, summary)
+}
+
+func (s *server) getInventory(w http.ResponseWriter, r *http.Request) {
+	obj, inv, err := s.object(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	physicalPath := "inventory.json"
+	if r.PathValue("version") != "" {
+		v, err := version(inv, r.PathValue("version"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		physicalPath = path.Join(v.ID, physicalPath)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	s.serveFile(w, r, obj, physicalPath)
+}
+
+func (s *server) getContent(w http.ResponseWriter, r *http.Request) {
+	obj, inv, err := s.object(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	v, err := version(inv, r.PathValue("version"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	logicalPath := r.PathValue("path")
+	digest, ok := v.Digest(logicalPath)
+	if !ok {
+		writeError(w, fmt.Errorf("%w: no file %s in %s", errNotFound, logicalPath, inv.ID))
+		return
+	}
+	physicalPaths := inv.Manifest[digest]
+	if len(physicalPaths) == 0 {
+		writeError(w, fmt.Errorf("no content for %s in the manifest of %s", logicalPath, inv.ID))
+		return
+	}
+
+	// Content paths follow the rules of logical paths, so cannot escape the object
+	if err := metadata.ValidateLogicalPath(physicalPaths[0]); err != nil {
+		writeError(w, fmt.Errorf("invalid content path for %s in the manifest of %s: %s", logicalPath, inv.ID, err))
+		return
+	}
+
+	w.Header().Set("ETag", fmt.Sprintf(`"%s"`, digest))
+	s.serveFile(w, r, obj, physicalPaths[0])
+}
+
+// Serves the file at the given physical path within an object
+func (s *server) serveFile(w http.ResponseWriter, r *http.Request, obj ocfl.EntityRef, physicalPath string) {
+	f, err := s.opts.Open(ocfl.EntityRef{
+		ID:     physicalPath,
+		Type:   ocfl.File,
+		Addr:   physicalAddr(obj.Addr, physicalPath),
+		Parent: &obj,
+	})
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = fmt.Errorf("%w: %s in %s", errNotFound, physicalPath, obj.ID)
+		}
+		writeError(w, err)
+		return
+	}
+	defer f.Close()
+
+	http.ServeContent(w, r, path.Base(physicalPath), time.Time{}, f)
+}
+
+// Locates an object by ID, and reads its inventory.  Walkers may also locate objects
+// given their paths, so an object is only served if it has the requested ID, and is
+// within the root being served.
+func (s *server) object(ctx context.Context, id string) (ocfl.EntityRef, *metadata.Inventory, error) {
+	root, err := s.servedRoot()
+	if err != nil {
+		return ocfl.EntityRef{}, nil, err
+	}
+
+	var obj *ocfl.EntityRef
+	err = s.walker.WalkContext(ctx, ocfl.Select{Type: ocfl.Object}, func(ref ocfl.EntityRef) error {
+		if ref.ID == id && rootOf(ref).Addr == root.Addr {
+			obj = &ref
+		}
+		return nil
+	}, id)
+	if err != nil || obj == nil {
+		return ocfl.EntityRef{}, nil, fmt.Errorf("%w: no object %s", errNotFound, id)
+	}
+
+	inv, err := s.opts.Inventory(*obj)
+	if err != nil {
+		return ocfl.EntityRef{}, nil, fmt.Errorf("could not read inventory of %s: %s", id, err)
+	}
+	return *obj, inv, nil
+}
+
+// Finds the OCFL root served, the first time it is needed
+func (s *server) servedRoot() (ocfl.EntityRef, error) {
+	s.rootOnce.Do(func() {
+		found := false
+		err := s.walker.WalkContext(context.Background(), ocfl.Select{Type: ocfl.Root}, func(ref ocfl.EntityRef) error {
+			s.root, found = ref, true
+			return errFound
+		})
+		switch {
+		case found:
+		case err != nil:
+			s.rootErr = fmt.Errorf("could not find the OCFL root: %s", err)
+		default:
+			s.rootErr = fmt.Errorf("could not find the OCFL root")
+		}
+	})
+	return s.root, s.rootErr
+}
+
+// Finds the root an entity is within, or the outermost entity if none
+func rootOf(ref ocfl.EntityRef) ocfl.EntityRef {
+	for ref.Type != ocfl.Root && ref.Parent != nil {
+		ref = *ref.Parent
+	}
+	return ref
+}
+
+// Looks up a version by name, or the head version
+func version(inv *metadata.Inventory, name string) (*metadata.Snapshot, error) {
+	if name == Head {
+		name = inv.Head
+	}
+
+	if _, ok := inv.Versions[name]; !ok {
+		return nil, fmt.Errorf("%w: no version %s of %s", errNotFound, name, inv.ID)
+	}
+	return inv.Snapshot(name)
+}
+
+// Join object addresses and relative physical paths, whether the
+// address is a file path or a URI
+func physicalAddr(objAddr, relative string) string {
+	if strings.Contains(objAddr, "://") {
+		return strings.TrimRight(objAddr, "/") + "/" + relative
+	}
+	return filepath.Join(objAddr, filepath.FromSlash(relative))
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, errNotFound) {
+		status = http.StatusNotFound
+	}
+	http.Error(w, err.Error(), status)
+}
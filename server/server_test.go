@@ -0,0 +1,144 @@
+package server_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/birkland/ocfl"
+	"github.com/birkland/ocfl/drivers/fs"
+	"github.com/birkland/ocfl/fspath"
+	"github.com/birkland/ocfl/server"
+	"github.com/go-test/deep"
+)
+
+const testRoot = "../drivers/fs/testdata/ocflroot"
+
+func TestServer(t *testing.T) {
+	driver, err := fs.NewDriver(fs.Config{Root: testRoot})
+	if err != nil {
+		t.Fatalf("could not create driver %+v", err)
+	}
+
+	srv := httptest.NewServer(server.New(driver, server.Options{}))
+	defer srv.Close()
+
+	obj4 := "/objects/" + url.PathEscape("urn:/obj4")
+
+	get := func(path string, status int) string {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("could not GET %s %+v", path, err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		if resp.StatusCode != status {
+			t.Errorf("Expected status %d from %s, got %d: %s", status, path, resp.StatusCode, body)
+		}
+		return string(body)
+	}
+
+	var ids []string
+	_ = json.Unmarshal([]byte(get("/objects", http.StatusOK)), &ids)
+	if len(ids) != 4 {
+		t.Errorf("Expected 4 objects, got %v", ids)
+	}
+
+	var obj server.ObjectSummary
+	_ = json.Unmarshal([]byte(get(obj4, http.StatusOK)), &obj)
+	if obj.ID != "urn:/obj4" || obj.Head != "v3" || len(obj.Versions) != 3 || obj.Versions[1].Message != "Oops" {
+		t.Errorf("Wrong object summary %+v", obj)
+	}
+
+	var v server.VersionSummary
+	_ = json.Unmarshal([]byte(get(obj4+"/head", http.StatusOK)), &v)
+	if diff := deep.Equal(v.Files, []server.FileSummary{
+		{Path: "obj1.txt", Digest: "00"},
+		{Path: "obj2.txt", Digest: "01"},
+	}); diff != nil || v.Name != "v3" {
+		t.Errorf("Wrong head version %s: %v", v.Name, diff)
+	}
+
+	if content := get(obj4+"/v1/content/obj1-copy.txt", http.StatusOK); content != "one" {
+		t.Errorf("Wrong content %q", content)
+	}
+
+	if inv := get(obj4+"/inventory.json", http.StatusOK); !strings.Contains(inv, `"head": "v3"`) {
+		t.Errorf("Wrong inventory %s", inv)
+	}
+
+	for _, path := range []string{
+		"/objects/" + url.PathEscape("urn:/nope"),
+		obj4 + "/v9",
+		obj4 + "/v2/content/obj2.txt",
+		obj4 + "/v1/inventory.json",
+	} {
+		get(path, http.StatusNotFound)
+	}
+}
+
+// Objects outside the served root, or content outside an object, are never served
+func TestServerConfinement(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocfl_server_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	served, other := filepath.Join(dir, "served"), filepath.Join(dir, "other")
+	drivers := make(map[string]*fs.Driver)
+	for _, root := range []string{served, other} {
+		if err := fs.MkRoot(root); err != nil {
+			t.Fatalf("could not create root %+v", err)
+		}
+		drivers[root], err = fs.NewDriver(fs.Config{
+			Root:        root,
+			ObjectPaths: fspath.GeneratorFunc(url.QueryEscape),
+			FilePaths:   fspath.GeneratorFunc(fs.Passthrough),
+		})
+		if err != nil {
+			t.Fatalf("could not create driver %+v", err)
+		}
+
+		session, _ := drivers[root].Open("obj", ocfl.Options{Create: true, Version: ocfl.NEW})
+		_ = session.Put("a", strings.NewReader("a"))
+		if err := session.Commit(ocfl.CommitInfo{}); err != nil {
+			t.Fatalf("commit failed %+v", err)
+		}
+	}
+
+	// The served object's manifest points outside of it
+	secret := filepath.Join(dir, "secret")
+	_ = ioutil.WriteFile(secret, []byte("secret"), 0664)
+	objRoot, _ := drivers[served].ObjectPath("obj")
+	invFile := filepath.Join(objRoot, "inventory.json")
+	inv, _ := ioutil.ReadFile(invFile)
+	_ = ioutil.WriteFile(invFile, []byte(strings.Replace(string(inv), `"v1/content/a"`, `"../../secret"`, 1)), 0664)
+
+	srv := httptest.NewServer(server.New(drivers[served], server.Options{}))
+	defer srv.Close()
+
+	otherObj, _ := drivers[other].ObjectPath("obj")
+	for path, status := range map[string]int{
+		"/objects/" + url.PathEscape(otherObj) + "/inventory.json": http.StatusNotFound,
+		"/objects/" + url.PathEscape(otherObj):                     http.StatusNotFound,
+		"/objects/obj/head/content/a":                              http.StatusInternalServerError,
+	} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("could not GET %s %+v", path, err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != status || string(body) == "secret" {
+			t.Errorf("Expected status %d from %s, got %d: %s", status, path, resp.StatusCode, body)
+		}
+	}
+}
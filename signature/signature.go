@@ -0,0 +1,136 @@
+// Package signature provides detached signatures over OCFL inventories, for
+// repositories that need verifiable provenance of their versions.
+//
+// Signers and Verifiers are interfaces, so that external tools (e.g. GPG, or
+// hardware keys) may be plugged in.  An ed25519 implementation is provided,
+// using PEM encoded PKCS #8 private keys and PKIX public keys.
+package signature
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// Ed25519 names the ed25519 signature algorithm
+const Ed25519 = "ed25519"
+
+// Signature is a detached signature
+type Signature struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"keyId"`
+	Value     []byte `json:"value"`
+}
+
+// Signer produces detached signatures
+type Signer interface {
+	Sign(data []byte) (Signature, error)
+}
+
+// Verifier verifies detached signatures, returning an error if
+// the signature is not valid for the given data
+type Verifier interface {
+	Verify(data []byte, sig Signature) error
+}
+
+// Ed25519Signer signs using an ed25519 private key
+type Ed25519Signer struct {
+	Key ed25519.PrivateKey
+}
+
+// Sign signs the data
+func (s Ed25519Signer) Sign(data []byte) (Signature, error) {
+	return Signature{
+		Algorithm: Ed25519,
+		KeyID:     KeyID(s.Key.Public().(ed25519.PublicKey)),
+		Value:     ed25519.Sign(s.Key, data),
+	}, nil
+}
+
+// Ed25519Verifier verifies signatures using an ed25519 public key
+type Ed25519Verifier struct {
+	Key ed25519.PublicKey
+}
+
+// Verify verifies that the signature was produced by the key's counterpart
+func (v Ed25519Verifier) Verify(data []byte, sig Signature) error {
+	if sig.Algorithm != Ed25519 {
+		return fmt.Errorf("unsupported signature algorithm '%s'", sig.Algorithm)
+	}
+
+	if sig.KeyID != KeyID(v.Key) {
+		return fmt.Errorf("signed by key %s, not %s", sig.KeyID, KeyID(v.Key))
+	}
+
+	if !ed25519.Verify(v.Key, data, sig.Value) {
+		return fmt.Errorf("signature by key %s is not valid", sig.KeyID)
+	}
+
+	return nil
+}
+
+// KeyID computes a short identifier of a public key, from its sha256 digest
+func KeyID(key ed25519.PublicKey) string {
+	digest := sha256.Sum256(key)
+	return hex.EncodeToString(digest[:8])
+}
+
+// LoadSigner reads a PEM encoded PKCS #8 ed25519 private key
+func LoadSigner(path string) (Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse private key in %s", path)
+	}
+
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s does not contain an ed25519 private key", path)
+	}
+
+	return Ed25519Signer{Key: edKey}, nil
+}
+
+// LoadVerifier reads a PEM encoded PKIX ed25519 public key
+func LoadVerifier(path string) (Verifier, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not parse public key in %s", path)
+	}
+
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s does not contain an ed25519 public key", path)
+	}
+
+	return Ed25519Verifier{Key: edKey}, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read key file")
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM encoded key found in %s", path)
+	}
+
+	return block, nil
+}
@@ -0,0 +1,79 @@
+package signature_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/birkland/ocfl/signature"
+)
+
+func TestSignVerify(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	data := []byte("inventory content")
+	sig, err := signature.Ed25519Signer{Key: priv}.Sign(data)
+	if err != nil {
+		t.Fatalf("could not sign %+v", err)
+	}
+
+	cases := []struct {
+		name  string
+		key   ed25519.PublicKey
+		data  []byte
+		valid bool
+	}{
+		{"valid", pub, data, true},
+		{"tampered", pub, []byte("modified content"), false},
+		{"wrongKey", otherPub, data, false},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			err := signature.Ed25519Verifier{Key: c.key}.Verify(c.data, sig)
+			if (err == nil) != c.valid {
+				t.Errorf("expected valid: %t, got %v", c.valid, err)
+			}
+		})
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "signature")
+	defer os.RemoveAll(dir)
+
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	privBytes, _ := x509.MarshalPKCS8PrivateKey(priv)
+	pubBytes, _ := x509.MarshalPKIXPublicKey(pub)
+
+	privFile := filepath.Join(dir, "key")
+	pubFile := filepath.Join(dir, "key.pub")
+	_ = ioutil.WriteFile(privFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privBytes}), 0600)
+	_ = ioutil.WriteFile(pubFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes}), 0600)
+
+	signer, err := signature.LoadSigner(privFile)
+	if err != nil {
+		t.Fatalf("could not load signer %+v", err)
+	}
+
+	verifier, err := signature.LoadVerifier(pubFile)
+	if err != nil {
+		t.Fatalf("could not load verifier %+v", err)
+	}
+
+	sig, _ := signer.Sign([]byte("data"))
+	if err := verifier.Verify([]byte("data"), sig); err != nil {
+		t.Errorf("loaded keys do not verify %+v", err)
+	}
+
+	if _, err := signature.LoadSigner(pubFile); err == nil {
+		t.Errorf("loading a public key as a signer should fail")
+	}
+}